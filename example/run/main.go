@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/cli"
+)
+
+func main() {
+	config := `
+database:
+  host: localhost
+  port: 5432
+debug: true
+`
+
+	fmt.Println("=== Running cfg2env in-process with cli.Run ===")
+	fmt.Println()
+
+	// Run takes the same arguments as the command line, without the program
+	// name, and returns the exit status instead of exiting
+	var out, errOut bytes.Buffer
+	args := []string{"--prefix", "MYAPP", "--exclude", "*_PORT"}
+	code := cli.Run(args, strings.NewReader(config), &out, &errOut)
+	fmt.Printf("cfg2env %s (exit %d):\n%s\n", strings.Join(args, " "), code, out.String())
+
+	// Errors are written to the error stream, as the command would
+	out.Reset()
+	errOut.Reset()
+	code = cli.Run([]string{"--get", "missing"}, strings.NewReader(config), &out, &errOut)
+	fmt.Printf("cfg2env --get missing (exit %d): %s", code, errOut.String())
+}
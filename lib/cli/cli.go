@@ -0,0 +1,1970 @@
+// Package cli implements the cfg2env command. Run parses the command line
+// and converts, merges, validates or diffs configs exactly as the cfg2env
+// binary does, so programs and tests can drive it without a process.
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/handaber/cfg2env/lib/converter"
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/lib/version"
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins"
+	"github.com/handaber/cfg2env/plugins/dotenv"
+	"github.com/handaber/cfg2env/plugins/environ"
+)
+
+// Readme is the documentation printed by --docs. The cfg2env command sets
+// it to the README embedded in the binary.
+var Readme string
+
+// options holds the flag values, streams and other state of one Run, so
+// that concurrent Runs do not share anything
+type options struct {
+	// Flag values, defined on flags by defineFlags
+	format                 *string
+	source                 *string
+	query                  *string
+	table                  *string
+	version                *bool
+	help                   *bool
+	docs                   *bool
+	dunderCollapse         *bool
+	dunder                 *int
+	include                *patternList
+	exclude                *patternList
+	filterOriginal         *bool
+	sort                   *string
+	noSort                 *bool
+	naturalSort            *bool
+	group                  *bool
+	groupHeaders           *bool
+	keepComments           *bool
+	template               *bool
+	templateSecrets        *string
+	redactFile             *string
+	schema                 *string
+	mergeStrategy          *string
+	prefix                 *string
+	prefixSeparator        *string
+	caseMode               *string
+	set                    *pairList
+	unset                  *patternList
+	prefixFromFilename     *bool
+	env                    *string
+	errorOnUnknownFormat   *bool
+	baseline               *string
+	outputFile             *string
+	ifChanged              *bool
+	append                 *bool
+	appendSeparator        *string
+	tar                    *bool
+	arrayMode              *string
+	arraySep               *string
+	arrayKeyField          *string
+	compactArrays          *bool
+	sanitizeKeys           *bool
+	reservedPrefix         *string
+	reservedKeys           *patternList
+	onConflict             *string
+	strictKeys             *bool
+	pruneEmpty             *bool
+	trimValues             *bool
+	maxValueLength         *int
+	errorOnOversize        *bool
+	errorOnEmpty           *bool
+	omitEmptyContainers    *bool
+	strictNewlines         *bool
+	validateOnly           *bool
+	inputEncoding          *string
+	formatDetectReport     *bool
+	printConfig            *bool
+	formatChain            *string
+	outputFormat           *string
+	kvSep                  *string
+	errorOnAmbiguousKeys   *bool
+	keysOnly               *bool
+	valuesOnly             *bool
+	get                    *string
+	noTrailingNewline      *bool
+	commentPrefix          *string
+	commentHeader          *bool
+	matcher                *string
+	limit                  *int
+	maxInputBytes          *int64
+	strictOptions          *bool
+	strictSourceDuplicates *bool
+	concat                 *bool
+	jsonRelaxed            *bool
+	maxDepth               *int
+	skipPath               *string
+	onlyPath               *string
+	onlyPathStrip          *bool
+	pathPrefix             *string
+	timeFormat             *string
+	preserveYAMLLiterals   *bool
+	require                *patternList
+	requireFile            *string
+
+	// stdin, stdout and stderr are the streams of the Run
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// flags is the flag set of the Run
+	flags *flag.FlagSet
+
+	// checked records the formats whose options have been checked, so a
+	// format used by several files is only warned about once
+	checked map[string]bool
+
+	// encoding is the --input-encoding of the Run
+	encoding utils.Encoding
+}
+
+// defineFlags defines the command-line flags on fs
+func (o *options) defineFlags(fs *flag.FlagSet) {
+	o.format = fs.String("format", "", "Input format (yaml, json, sqlite, dotenv, systemd, k8s, ssm)")
+	o.source = fs.String("source", "input", "Where to read config from (input, env)")
+	o.query = fs.String("query", "", "Custom query for SQLite format")
+	o.table = fs.String("table", "", "Table to read key/value columns from for SQLite format")
+	o.version = fs.Bool("version", false, "Show version information")
+	o.help = fs.Bool("help", false, "Show help information")
+	o.docs = fs.Bool("docs", false, "Show documentation")
+	o.dunderCollapse = fs.Bool("dunder-collapse", false, "Collapse every run of two or more underscores to one")
+	o.dunder = fs.Int("dunder", 0, "Number of underscores to remove from consecutive sequences (default: 0, negative values treated as 0)")
+	o.include = patternFlag(fs, "include", "Comma-separated glob patterns for keys to include (repeatable)")
+	o.exclude = patternFlag(fs, "exclude", "Comma-separated glob patterns for keys to exclude; !PATTERN re-includes (repeatable)")
+	o.filterOriginal = fs.Bool("filter-original", false, "Match --include and --exclude against source keys, such as database.host, rather than output keys")
+	o.sort = fs.String("sort", "key", "Output key order (key, none, grouped)")
+	o.noSort = fs.Bool("no-sort", false, "Skip sorting and write keys in map iteration order")
+	o.naturalSort = fs.Bool("natural-sort", false, "Order numbers in keys by value, so WORKER_2 sorts before WORKER_10")
+	o.group = fs.Bool("group", false, "Write a blank line between keys with different top-level prefixes")
+	o.groupHeaders = fs.Bool("group-headers", false, "Start each group of keys with a comment naming its prefix; implies --group")
+	o.keepComments = fs.Bool("keep-comments", false, "Write source comments above their keys (yaml)")
+	o.template = fs.Bool("template", false, "Write keys with empty values for a .env.example")
+	o.templateSecrets = fs.String("template-secrets", "", "Comma-separated glob patterns for keys to blank in template mode (default: all)")
+	o.redactFile = fs.String("redact-file", "", "Mask the values of keys matching the patterns in a file, one per line")
+	o.schema = fs.String("schema", "", "YAML file mapping key patterns to value coercions (bool01, quote, int, lower)")
+	o.mergeStrategy = fs.String("merge-strategy", "override", "How to combine keys from multiple files (override, error-on-conflict)")
+	o.prefix = fs.String("prefix", "", "Prefix prepended to every key, e.g. MYAPP")
+	o.prefixSeparator = fs.String("prefix-separator", converter.DefaultPrefixSeparator, "Separator between the prefix and the rest of each key")
+	o.caseMode = fs.String("case-mode", "upper", "Case of output keys (upper, mixed-prefix)")
+	o.set = new(pairList)
+	fs.Var(o.set, "set", "Add KEY=VALUE to the output, overriding a parsed value for the same key (repeatable)")
+	o.unset = patternFlag(fs, "unset", "Comma-separated keys removed from the output regardless of filters (repeatable)")
+	o.prefixFromFilename = fs.Bool("prefix-from-filename", false, "Prefix each file's keys with its base filename")
+	o.env = fs.String("env", "", "Deep merge each file's environment overlay over it, e.g. prod reads config.prod.yaml over config.yaml")
+	o.errorOnUnknownFormat = fs.Bool("error-on-unknown-format", false, "Fail instead of falling back to YAML when the format cannot be told")
+	o.baseline = fs.String("baseline", "", "Write only keys that are new or changed from this .env file, listing removed keys as comments")
+	o.outputFile = fs.String("output-file", "", "Write output to a file, replaced atomically, instead of stdout")
+	o.ifChanged = fs.Bool("if-changed", false, "With --output-file, leave the file alone and exit 3 if its content is unchanged")
+	o.append = fs.Bool("append", false, "With --output-file, append to the file instead of replacing it, warning about keys it already sets")
+	o.appendSeparator = fs.String("append-separator", "", "Comment written before the appended entries, e.g. \"from config.yaml\"")
+	o.tar = fs.Bool("tar", false, "Read a tar archive of config files from stdin and merge them, prefixed by filename")
+	o.arrayMode = fs.String("array-mode", "index", "How arrays are flattened (index, join)")
+	o.arraySep = fs.String("array-sep", utils.DefaultArraySep, "Separator for joined arrays")
+	o.arrayKeyField = fs.String("array-key-field", "", "Field of array elements used as their key instead of the index, e.g. name")
+	o.compactArrays = fs.Bool("compact-arrays", false, "Drop null array elements and reindex the rest instead of writing KEY_N=")
+	o.sanitizeKeys = fs.Bool("sanitize-keys", false, "Replace characters not allowed in shell identifiers with underscores")
+	o.reservedPrefix = fs.String("reserved-prefix", "", "Prefix for keys that would overwrite reserved shell variables such as PATH, e.g. APP_")
+	o.reservedKeys = patternFlag(fs, "reserved-keys", "Comma-separated keys renamed by --reserved-prefix, replacing the default list (repeatable)")
+	o.onConflict = fs.String("on-conflict", "error", "How keys normalizing to the same output key resolve (first, last, longest-value, error)")
+	o.strictKeys = fs.Bool("strict-keys", false, "Fail if an output key is not a legal shell identifier")
+	o.pruneEmpty = fs.Bool("prune-empty", false, "Omit keys whose value is empty")
+	o.trimValues = fs.Bool("trim-values", false, "Remove leading and trailing whitespace from each value")
+	o.maxValueLength = fs.Int("max-value-length", 0, "Truncate values longer than N characters, appending \"...\" (0: unlimited)")
+	o.errorOnOversize = fs.Bool("error-on-oversize", false, "Fail instead of truncating values longer than --max-value-length")
+	o.errorOnEmpty = fs.Bool("error-on-empty", false, "Exit 6 instead of writing no keys, such as when filters match nothing")
+	o.omitEmptyContainers = fs.Bool("omit-empty-containers", false, "Omit empty maps and arrays instead of writing KEY=")
+	o.strictNewlines = fs.Bool("strict-newlines", false, "Fail if a value contains a line break instead of escaping it")
+	o.validateOnly = fs.Bool("validate-only", false, "Check that input is well formed without writing output")
+	o.inputEncoding = fs.String("input-encoding", "utf-8", "Character encoding of the input (utf-8, latin1, utf-16, utf-16le, utf-16be)")
+	o.formatDetectReport = fs.Bool("format-detect-report", false, "Print the chosen input format to stderr")
+	o.printConfig = fs.Bool("print-config", false, "Print the effective options to stderr before converting")
+	o.formatChain = fs.String("format-chain", "", "Comma-separated formats to try in order on stdin, e.g. json,yaml")
+	o.outputFormat = fs.String("output-format", "env", "Output format (env, json, yaml)")
+	o.kvSep = fs.String("kv-sep", "=", "Delimiter written between each key and value")
+	o.errorOnAmbiguousKeys = fs.Bool("error-on-ambiguous-keys", false, "Fail instead of warning when a key contains the --kv-sep delimiter")
+	o.keysOnly = fs.Bool("keys-only", false, "Write only the keys, one per line, without values or header")
+	o.valuesOnly = fs.Bool("values-only", false, "Write only the values, one per line, without keys or header")
+	o.get = fs.String("get", "", "Print the value of a single key; exit 1 if it is absent")
+	o.noTrailingNewline = fs.Bool("no-trailing-newline", false, "Omit the newline after the last line of output")
+	o.commentPrefix = fs.String("comment-prefix", "#", "Marker starting comment lines in the output, e.g. ;")
+	o.commentHeader = fs.Bool("comment-header", false, "Record the generation time in the header")
+	o.matcher = fs.String("matcher", "glob", "How filter and template patterns match keys (glob, substring)")
+	o.limit = fs.Int("limit", 0, "Write at most N keys after sorting and filtering (0: unlimited)")
+	o.maxInputBytes = fs.Int64("max-input-bytes", 0, "Fail if the input is larger than N bytes (0: unlimited)")
+	o.strictOptions = fs.Bool("strict-options", false, "Fail instead of warning when an option does not apply to the input format")
+	o.strictSourceDuplicates = fs.Bool("strict-source-duplicates", false, "Fail if an object in the source repeats a key (json)")
+	o.concat = fs.Bool("concat", false, "Read every concatenated JSON value from the input and merge them")
+	o.jsonRelaxed = fs.Bool("json-relaxed", false, "Accept comments and trailing commas in JSON input")
+	o.maxDepth = fs.Int("max-depth", utils.DefaultMaxDepth, "Fail if maps and arrays nest more than N levels deep (0: unlimited)")
+	o.skipPath = fs.String("skip-path", "", "Comma-separated dotted paths to drop before flattening (yaml, json)")
+	o.onlyPath = fs.String("only-path", "", "Dotted path of the only subtree to flatten (yaml, json)")
+	o.onlyPathStrip = fs.Bool("only-path-strip", false, "Drop the --only-path prefix from keys")
+	o.pathPrefix = fs.String("path-prefix", "", "Leading parameter path to drop from ssm names, e.g. /app")
+	o.timeFormat = fs.String("time-format", time.RFC3339, "Go time layout for unquoted YAML timestamps")
+	o.preserveYAMLLiterals = fs.Bool("preserve-yaml-literals", false, "Write YAML booleans and nulls as written, e.g. True or ~")
+	o.require = patternFlag(fs, "require", "Comma-separated keys that must be present and non-empty in the output (repeatable)")
+	o.requireFile = fs.String("require-file", "", "Require every key listed in a .env schema file, such as .env.example")
+}
+
+// patternList is a flag holding comma-separated patterns, collected in order
+// across repeated uses of the flag
+type patternList []string
+
+// String implements flag.Value
+func (l *patternList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value
+func (l *patternList) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// pairList is a flag holding KEY=VALUE pairs, collected in order across
+// repeated uses of the flag
+type pairList []plugin.KV
+
+// String implements flag.Value
+func (l *pairList) String() string {
+	pairs := make([]string, len(*l))
+	for i, kv := range *l {
+		pairs[i] = kv.Key + "=" + kv.Value
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value
+func (l *pairList) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("%q is not KEY=VALUE", value)
+	}
+	if key == "" {
+		return fmt.Errorf("%q has an empty key", value)
+	}
+	*l = append(*l, plugin.KV{Key: key, Value: val})
+	return nil
+}
+
+// patternFlag defines a repeatable pattern list flag on fs
+func patternFlag(fs *flag.FlagSet, name, usage string) *patternList {
+	l := new(patternList)
+	fs.Var(l, name, usage)
+	return l
+}
+
+func (o *options) printHelp() {
+	fmt.Fprintf(o.stdout, `cfg2env - Convert config files to .env format
+
+USAGE:
+  cfg2env [OPTIONS] < input > output.env
+  cat config.yaml | cfg2env > .env
+  cfg2env [OPTIONS] base.yaml override.json ... > output.env
+  cfg2env diff [OPTIONS] old.yaml new.yaml
+
+OPTIONS:
+  -format string
+        Input format: yaml, json, sqlite, dotenv, systemd, k8s, ssm (default: from the file extension,
+        or detected from stdin content with yaml as the fallback)
+  -source string
+        Where to read config from: input (default) reads stdin or file
+        arguments; env reads the current process environment
+  -query string
+        Custom SQL query for SQLite (default: "SELECT key, value FROM config").
+        Separate several queries with ";" to merge their results; later
+        queries override keys from earlier ones
+  -table string
+        SQLite table to read (default: config). Key/value columns are
+        detected from key/value, name/val or setting/data; without a table,
+        the first table with such columns is used
+  -dunder int
+        Remove N underscores from consecutive sequences (default: 0). Runs
+        are counted in the flattened key, so --dunder 1 also removes the
+        underscores joining nested levels (DATABASE_HOST -> DATABASEHOST);
+        the --prefix and --prefix-separator are left as set
+  -dunder-collapse
+        Collapse every run of two or more underscores to one, so A__B___C
+        becomes A_B_C; takes precedence over --dunder
+  -include string
+        Comma-separated glob patterns for keys to include (e.g., "DATABASE_*,API_*")
+  -exclude string
+        Comma-separated glob patterns for keys to exclude (e.g., "*_PASSWORD,*_SECRET").
+        Patterns apply in order and the last match wins; a leading ! re-includes
+        matching keys, so --exclude '*' --exclude '!DATABASE_*' keeps DATABASE_ keys.
+        --include and --exclude may be repeated
+  -filter-original
+        Match --include and --exclude against keys as written in the source
+        rather than output keys: dotted paths for nested formats, so
+        --include 'database.*' keeps DATABASE_HOST from database.host, and the
+        keys themselves for flat formats. Matching ignores case
+  -matcher string
+        How --include, --exclude and --template-secrets patterns match keys:
+        glob (default), or substring for a case-insensitive contains match
+  -sort string
+        Output key order: key (default), none, grouped
+  -natural-sort
+        With --sort key or grouped, compare runs of digits in keys by their
+        numeric value, so WORKER_2 sorts before WORKER_10 rather than after
+  -group
+        Write a blank line wherever the top-level prefix changes between
+        consecutive keys, so DATABASE_* and API_* keys form separate blocks.
+        Only the layout changes; keys-only and values-only output is ungrouped
+  -group-headers
+        Start each group with a comment naming its prefix, e.g. # DATABASE;
+        implies --group
+  -limit int
+        Write at most N keys after sorting and filtering (default: 0, unlimited)
+  -input-encoding string
+        Character encoding of stdin and file arguments, converted to UTF-8
+        before parsing: utf-8 (default), latin1 (ISO-8859-1), or utf-16,
+        utf-16le and utf-16be for files exported by Windows tools. Input
+        starting with a UTF-16 byte order mark is decoded as UTF-16 in any
+        case. Applies to text formats, not sqlite, and cannot be set with
+        a --format-chain including sqlite; --max-input-bytes counts the
+        decoded input
+  -max-input-bytes int
+        Fail if an input is larger than N bytes instead of reading it all
+        into memory (default: 0, unlimited)
+  -max-depth int
+        Fail if maps and arrays nest more than N levels deep, including
+        levels expanded from YAML aliases (default: 100; 0: unlimited)
+  -strict-options
+        Fail instead of warning when an option does not apply to the input
+        format, such as --query with yaml or --keep-comments with json
+  -strict-source-duplicates
+        Fail if a JSON object repeats a key instead of keeping the last
+        value. YAML input always rejects repeated keys
+  -skip-path string
+        Comma-separated dotted paths whose subtrees are dropped before
+        flattening (e.g., "logging,app.metadata"). Matching is
+        case-insensitive; unlike --exclude, skipped subtrees are never
+        flattened (yaml, json)
+  -only-path string
+        Dotted path of the only subtree to flatten (e.g., "database");
+        a missing path produces no keys (yaml, json)
+  -only-path-strip
+        Drop the --only-path prefix, so database.host is written as HOST
+  -path-prefix string
+        Leading path to drop from parameter names (e.g., "/app"), so
+        /app/database/host is written as DATABASE_HOST; parameters outside
+        it are skipped with a warning (ssm)
+  -concat
+        Read every JSON value in the input, such as the output of
+        cat a.json b.json, instead of failing after the first; keys from
+        later values override earlier ones (json)
+  -json-relaxed
+        Accept JSONC input: // and /* */ comments and trailing commas in
+        objects and arrays
+  -no-sort
+        Skip sorting entirely for very large inputs; output order is
+        nondeterministic
+  -array-mode string
+        How arrays are flattened: index (default) writes KEY_0, KEY_1;
+        join writes arrays of scalars as a single KEY=a,b value
+  -require string
+        Comma-separated keys that must be present with a non-empty value in
+        the output after filtering; reports every missing or empty key and
+        exits 5 (repeatable)
+  -require-file string
+        Require every key in a .env schema file, such as a .env.example
+        generated with --template
+  -time-format string
+        Go time layout for unquoted YAML timestamps, which would otherwise
+        differ from the same value quoted or in JSON (default: RFC3339,
+        "2006-01-02T15:04:05Z07:00"; e.g., "2006-01-02" for dates)
+  -preserve-yaml-literals
+        Write YAML booleans and nulls with the text they were written with,
+        so True, FALSE, ~ and Null are kept instead of becoming true, false
+        and empty values; yes, no, on and off are always kept
+  -array-sep string
+        Separator for joined arrays (default ",")
+  -array-key-field string
+        Field of the maps in arrays whose value replaces the element's
+        index, so servers: [{name: db, host: x}] gives SERVERS_DB_HOST
+        rather than SERVERS_0_HOST and SERVERS_0_NAME; elements without the
+        field keep their index
+  -compact-arrays
+        Drop null elements from arrays and reindex the rest, so
+        [a, null, c] gives KEY_0=a, KEY_1=c rather than an empty KEY_1=;
+        also applies before --array-mode join (yaml, json)
+  -sanitize-keys
+        Replace characters not allowed in shell identifiers with underscores
+        and prefix keys starting with a digit with an underscore; filter,
+        redact and secret patterns are sanitized the same way
+  -reserved-prefix string
+        Prefix for keys that would overwrite reserved shell variables, so
+        path: /srv is written as APP_PATH with --reserved-prefix APP_
+        (default list: PATH, HOME, IFS, SHELL, USER, LD_PRELOAD, ...)
+  -reserved-keys value
+        Comma-separated keys renamed by --reserved-prefix, replacing the
+        default list (repeatable)
+  -on-conflict string
+        How source keys that normalize to the same output key, such as
+        db_host and DB_HOST in a dotenv file, are resolved: first, last,
+        longest-value, or error (default "error"). First and last follow
+        source order with --sort none and sorted source keys otherwise.
+        Given explicitly, it also resolves keys YAML and JSON flatten
+        together, such as db_host next to db: {host} or Api_Key next to
+        API_KEY, which are otherwise warnings
+  -strict-keys
+        Fail if an output key does not match [A-Za-z_][A-Za-z0-9_]* or is
+        produced by more than one path (db_host and db: {host})
+  -prune-empty
+        Omit keys whose value is empty (nulls, empty strings, maps, arrays)
+  -trim-values
+        Remove leading and trailing whitespace from each value; values of
+        only whitespace become empty, so --prune-empty drops them
+  -max-value-length int
+        Truncate values longer than N characters to their first N characters
+        followed by "...", after every other value transform; useful for
+        previews of configs holding large blobs (default: 0, unlimited)
+  -error-on-oversize
+        Fail instead of truncating values longer than --max-value-length
+  -error-on-empty
+        Exit 6 instead of writing no keys, such as for empty input, filters
+        matching nothing or no changes from --baseline. Keys removed from the
+        baseline count as output
+  -omit-empty-containers
+        Omit empty maps and arrays instead of writing them as KEY=. Unlike
+        --prune-empty, empty strings and nulls are still written
+  -strict-newlines
+        Fail if a value contains a line break, such as a YAML block scalar.
+        By default line breaks are written as \n and \r so each pair stays
+        on one line, and backslashes in values are doubled
+  -keys-only
+        Write only the output keys, one per line, after filtering and
+        sorting; no values, header or comments. Handy for comparing key
+        sets across environments
+  -values-only
+        Write only the output values, one per line, in key order; no keys,
+        header or comments
+  -get string
+        Print the value of a single key after the full pipeline, then exit.
+        The key is uppercased and dunder-processed like output keys; exits 1
+        if the key is absent
+  -output-format string
+        Output format: env (default), json for a flat object, or yaml for a
+        flat mapping. json and yaml have no header or comments
+  -kv-sep string
+        Delimiter written between each key and value (default "="), e.g.
+        ": " for KEY: value. Readers split lines at the first delimiter, so a
+        key containing it, such as a=b from a flat source, is ambiguous and
+        reported as a warning. A delimiter made of key characters, such as
+        "_", is warned about up front, as most nested keys would contain it
+  -error-on-ambiguous-keys
+        Fail with exit status 5 instead of warning when a key contains the
+        --kv-sep delimiter
+  -no-trailing-newline
+        Omit the newline after the last line of output
+  -comment-prefix string
+        Marker starting the header and other comment lines, for consumers
+        that use another comment syntax, e.g. ";" (default "#")
+  -comment-header
+        Add a "# Generated at: <time>" UTC timestamp to the header; set
+        SOURCE_DATE_EPOCH for reproducible output
+  -baseline string
+        Write only the keys whose value is new or differs from this .env
+        file, such as the output of an earlier run, so the result patches
+        it. Keys the baseline has but the input lacks are listed as
+        "# Removed: KEY" comments
+  -output-file string
+        Write output to this file instead of stdout. The file is replaced
+        atomically through a temporary file in the same directory
+  -if-changed
+        With --output-file, record a content hash in the header and leave
+        the file alone if the new output has the same hash, exiting 3. The
+        hash ignores the header, so --comment-header timestamps do not count
+        as changes. Requires env output with a header
+  -append
+        With --output-file, add the pairs to the end of the file instead of
+        replacing it, creating the file if it is missing. No header is
+        written, so the file keeps its own. Keys the file already sets are
+        reported as warnings, since loaders differ on which of two settings
+        wins. Requires env output with keys and values
+  -append-separator string
+        With --append, write this text as a comment line, after a blank
+        line, between the existing content and the appended entries
+  -validate-only
+        Check that stdin or each file argument is well formed without
+        writing output; exits 4 if any input is malformed
+  -format-chain string
+        Comma-separated formats to try in order on stdin (e.g., "json,yaml");
+        the first that parses is used. YAML also accepts JSON and most plain
+        text, so list it last. Cannot be combined with --format
+  -error-on-unknown-format
+        Fail instead of falling back to YAML when no --format is given and
+        a file has no extension or stdin is not recognizably JSON or SQLite
+  -print-config
+        Print the effective options to stderr before converting: the main
+        options such as --format, --kv-sep, --dunder, --include and --prefix
+        with their values, defaults included, followed by every other option
+        set on the command line and the file arguments
+  -format-detect-report
+        Print the chosen format to stderr, e.g. "detected: json"
+  -keep-comments
+        Write source comments above their keys (yaml)
+  -template
+        Write keys with empty values to produce a .env.example
+  -template-secrets string
+        Comma-separated glob patterns for keys to blank in template mode;
+        other values are kept as defaults (default: blank all values)
+  -redact-file string
+        Replace the values of keys matching any pattern in a file with
+        ********, so output can be shared. The file lists one pattern per
+        line, matched as set by --matcher; blank lines and lines starting
+        with # are skipped
+  -schema string
+        Coerce the values of matching keys for picky consumers. The YAML
+        file maps glob patterns, matched against output keys, to a coercion
+        or a list of them: bool01 writes true/false as 1/0, int writes
+        5432.0 as 5432, lower lowercases, and quote writes KEY="value".
+        Every matching pattern applies in file order; values that cannot be
+        coerced exit 5
+  -merge-strategy string
+        How to combine keys from multiple files: override (default),
+        error-on-conflict
+  -prefix string
+        Prefix prepended to every key (e.g., MYAPP gives MYAPP_DATABASE_HOST);
+        names the input's root when it is an array (ITEMS_0, ITEMS_1) or a
+        single value, which has no key of its own and is an error without it
+  -prefix-separator string
+        Separator between the prefix and the rest of each key (default "_");
+        nested levels are still joined with "_", so "__" gives
+        MYAPP__DATABASE_HOST for tools like Viper that nest on "__". The
+        separator is kept by --dunder and --dunder-collapse
+  -prefix-from-filename
+        Prefix each file argument's keys with its uppercased base filename
+        (database.yaml -> DATABASE_HOST); ignored when reading stdin. With
+        --prefix, the filename follows it: MYAPP_DATABASE_HOST
+  -case-mode string
+        Case of output keys: upper (default) uppercases every key;
+        mixed-prefix uppercases only the --prefix and keeps the rest as in
+        the source, so --prefix myapp gives MYAPP_database_host. Patterns
+        and --set, --unset and --get keys then match case-sensitively, as
+        written; --require still ignores case
+  -set KEY=VALUE
+        Add a pair to the output after parsing, replacing any parsed value
+        for the same key. KEY is uppercased and follows the --dunder,
+        --sanitize-keys and --reserved-prefix rules, but is not prefixed;
+        the pair is then filtered and sorted like the rest (repeatable)
+  -unset KEY
+        Remove a key from the output, whether or not --include and
+        --exclude keep it; simpler than an exclude pattern for a few exact
+        keys. KEY is normalized like --set keys, and keys the output lacks
+        are ignored. Accepts a comma-separated list (repeatable)
+  -env string
+        Merge an environment overlay over each file argument before
+        flattening: --env prod reads config.prod.yaml next to config.yaml.
+        Maps merge recursively, while scalars and arrays in the overlay
+        replace the base value. The overlay must exist and is read with the
+        base file's format; also applies to diff
+  -tar
+        Read a tar archive, optionally gzip-compressed, from stdin; convert
+        each file with --format or its extension and merge them with keys
+        prefixed by filename as with --prefix-from-filename. Files without
+        a known extension, such as a README, are skipped
+  -version
+        Show version information
+  -help
+        Show this help message
+
+FORMATS:
+  yaml     YAML configuration files (default if no format is detected)
+  json     JSON configuration files
+  sqlite   SQLite database files
+  dotenv   .env files; values may reference earlier keys as $KEY or ${KEY}
+  systemd  systemd EnvironmentFile= files (also: envfile), parsed with
+           systemd's quoting, escaping and line continuation rules
+  k8s      data of Kubernetes ConfigMap and Secret manifests (also:
+           kubernetes); Secret values are base64-decoded
+  ssm      AWS SSM Parameter Store exports from aws ssm get-parameters-by-path
+           (also: parameter-store); /app/database/host becomes APP_DATABASE_HOST
+
+  Without --format, file arguments use their extension. Stdin is detected
+  from its content: a SQLite header selects sqlite, a leading '{' or '['
+  selects json, and anything else is read as yaml.
+
+EXAMPLES:
+  # Convert YAML to .env (default format)
+  cat config.yaml | cfg2env > .env
+
+  # Convert JSON to .env
+  cat config.json | cfg2env --format json > .env
+
+  # Convert SSM parameters under /app to .env
+  aws ssm get-parameters-by-path --path /app --recursive --with-decryption |
+    cfg2env --format ssm --path-prefix /app > .env
+
+  # Convert SQLite database
+  cat config.db | cfg2env --format sqlite > .env
+
+  # Expand references between keys in a .env file
+  cfg2env app.env > .env
+
+  # Read stdin as JSON, falling back to YAML
+  cat config.txt | cfg2env --format-chain json,yaml > .env
+
+  # Detect the format from content and report it on stderr
+  cat config.db | cfg2env --format-detect-report > .env
+
+  # Snapshot the current environment without secrets
+  cfg2env --source env --exclude "*_TOKEN,*_SECRET" > snapshot.env
+
+  # Use custom SQLite query
+  cat settings.db | cfg2env --format sqlite --query "SELECT name, val FROM settings" > .env
+
+  # Read a different SQLite table, detecting its columns
+  cat settings.db | cfg2env --format sqlite --table settings > .env
+
+  # Remove single underscores from consecutive sequences
+  cat config.yaml | cfg2env --dunder 1 > .env
+
+  # Collapse A__B___C to A_B_C
+  cat config.yaml | cfg2env --dunder-collapse > .env
+
+  # Filter output to only DATABASE_ keys
+  cat config.yaml | cfg2env --include "DATABASE_*" > .env
+
+  # Filter on source paths rather than output keys
+  cat config.yaml | cfg2env --filter-original --include "database.*" > .env
+
+  # Exclude sensitive keys
+  cat config.yaml | cfg2env --exclude "*_PASSWORD,*_SECRET,*_TOKEN" > .env
+
+  # Preview the first 10 DATABASE_ keys
+  cat config.yaml | cfg2env --include "DATABASE_*" --limit 10
+
+  # Exclude any key containing "secret"
+  cat config.yaml | cfg2env --matcher substring --exclude secret > .env
+
+  # Include DATABASE_ keys but exclude passwords
+  cat config.yaml | cfg2env --include "DATABASE_*" --exclude "*_PASSWORD" > .env
+
+  # Group keys by their top-level prefix
+  cat config.yaml | cfg2env --sort grouped > .env
+
+  # Sort WORKER_2 before WORKER_10
+  cat config.yaml | cfg2env --natural-sort > .env
+
+  # Separate prefix groups with blank lines and "# DATABASE" comments
+  cat config.yaml | cfg2env --group-headers > .env
+
+  # Write scalar arrays as comma-separated values
+  cat config.yaml | cfg2env --array-mode join > .env
+
+  # Make keys like "log-level" safe to source in a shell
+  cat config.json | cfg2env --format json --sanitize-keys > .env
+
+  # Keep YAML comments as # lines above each key
+  cat config.yaml | cfg2env --keep-comments > .env
+
+  # Generate a .env.example with blank values
+  cat config.yaml | cfg2env --template > .env.example
+
+  # Merge several files, later files override earlier keys
+  cfg2env base.yaml overrides.json > .env
+
+  # Override a value and add a key the config lacks
+  cat config.yaml | cfg2env --set DATABASE_HOST=db.internal --set REGION=eu > .env
+
+  # Uppercase only the prefix: MYAPP_database_host
+  cat config.yaml | cfg2env --prefix myapp --case-mode mixed-prefix > .env
+
+  # Drop a couple of keys by name
+  cat config.yaml | cfg2env --unset DEBUG,DATABASE_PASSWORD > .env
+
+  # Deep merge config.prod.yaml over config.yaml
+  cfg2env --env prod config.yaml > .env
+
+  # Namespace each file's keys by its filename
+  cfg2env --prefix-from-filename database.yaml cache.yaml > .env
+
+  # Convert a bundle of config files in one pass
+  tar -cz -C configs . | cfg2env --tar > .env
+
+  # Mask the secrets a team lists in a shared file
+  cfg2env --redact-file .redact < config.yaml
+
+  # Generate a .env.example that only blanks secrets
+  cat config.yaml | cfg2env --template --template-secrets "*_PASSWORD,*_TOKEN" > .env.example
+
+  # Write booleans as 1/0 and quote tokens, per a schema file
+  cat config.yaml | cfg2env --schema schema.yaml > .env
+
+  # Read a single value in a script
+  DB_HOST=$(cfg2env --get DATABASE_HOST < config.yaml)
+
+  # Fail CI if required keys are missing or empty
+  cat config.yaml | cfg2env --require DATABASE_URL,API_KEY > .env
+
+  # Convert a Latin-1 file exported by a legacy tool
+  cfg2env --input-encoding latin1 legacy.yaml > .env
+
+  # Lint config files without converting them
+  cfg2env --validate-only config.yaml settings.json
+
+OUTPUT:
+  Nested keys are flattened with underscores and converted to uppercase:
+    database.host       -> DATABASE_HOST
+    api.features[0]     -> API_FEATURES_0
+    nested.deep.value   -> NESTED_DEEP_VALUE
+
+  With --array-mode join, arrays of scalars become one value and arrays
+  containing maps keep indexed keys:
+    api.features        -> API_FEATURES=logging,metrics
+
+  With --array-key-field name, maps in arrays are keyed by their name:
+    servers[0].host     -> SERVERS_DB_HOST   (servers[0].name is db)
+
+MERGE:
+  File arguments are converted using --format or each file's extension and
+  merged into one output. Later files override keys from earlier files unless
+  --merge-strategy error-on-conflict is set. Files in a --tar archive are
+  merged the same way, in archive order.
+
+EXIT STATUS:
+  0  Success
+  1  Any other error, such as a missing file or --get key
+  2  Invalid flags, flag values or combinations of flags
+  3  --if-changed left the output file unchanged
+  4  Input could not be parsed, detected, or read within --max-input-bytes
+     and --max-depth
+  5  Converted keys failed a check: duplicates, --strict-keys,
+     --strict-newlines, --error-on-oversize, --error-on-ambiguous-keys,
+     --require, --schema or merge conflicts
+  6  --error-on-empty found no keys to write
+
+DIFF:
+  cfg2env diff OLD NEW parses both files (format from --format or the file
+  extension) and prints one line per difference:
+    +KEY=value          added in NEW
+    -KEY                removed from OLD
+    ~KEY: old -> new    value changed
+  Exits 0 when the configs match, 1 when they differ, 2 on error.
+
+ORDERING:
+  key      Keys are sorted lexicographically (default)
+  none     Keys are written in source order (yaml, json, dotenv)
+  grouped  Keys are sorted by top-level prefix, then by key
+
+`)
+}
+
+// Run runs cfg2env with the command-line arguments args, not including the
+// program name, reading input from in and writing output and errors to out
+// and errOut. It returns one of the Exit statuses; diff returns one of the
+// ExitDiff statuses instead. Each call has options of its own, so
+// calls may run concurrently.
+func Run(args []string, in io.Reader, out, errOut io.Writer) int {
+	o := &options{
+		stdin:   in,
+		stdout:  out,
+		stderr:  errOut,
+		flags:   flag.NewFlagSet("cfg2env", flag.ContinueOnError),
+		checked: make(map[string]bool),
+	}
+	o.flags.SetOutput(errOut)
+	o.flags.Usage = o.printHelp
+	o.defineFlags(o.flags)
+	return o.run(args)
+}
+
+// run runs cfg2env with args as Run does
+func (o *options) run(args []string) int {
+	// Subcommands accept the same options after their name
+	diffMode := len(args) > 0 && args[0] == "diff"
+	if diffMode {
+		args = args[1:]
+	}
+	if err := o.flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if *o.help {
+		o.printHelp()
+		return ExitOK
+	}
+
+	if *o.version {
+		fmt.Fprintf(o.stdout, "cfg2env version %s\n", version.Version())
+		return ExitOK
+	}
+
+	if *o.docs {
+		fmt.Fprint(o.stdout, Readme)
+		return ExitOK
+	}
+
+	if *o.printConfig {
+		o.reportConfig()
+	}
+
+	enc, err := utils.ParseEncoding(*o.inputEncoding)
+	if err != nil {
+		return o.exitStatus(usage(err))
+	}
+	o.encoding = enc
+
+	if diffMode {
+		return o.runDiff(o.flags.Args())
+	}
+
+	// Read the process environment instead of stdin or files
+	var p plugin.Plugin
+	switch *o.source {
+	case "env":
+		p = environ.New()
+	case "input":
+	default:
+		return o.exitStatus(usagef("unsupported source: %s (valid: input, env)", *o.source))
+	}
+
+	// Overlays are found next to file arguments
+	if *o.env != "" {
+		switch {
+		case p != nil || *o.tar || o.flags.NArg() == 0:
+			return o.exitStatus(usagef("--env requires config file arguments"))
+		case *o.validateOnly:
+			return o.exitStatus(usagef("--env cannot be used with --validate-only"))
+		}
+	}
+
+	// Convert and merge the files of a tar archive on stdin
+	if *o.tar {
+		if err := o.checkTarArgs(p); err != nil {
+			return o.exitStatus(err)
+		}
+		if err := o.runTar(); err != nil {
+			return o.exitStatus(err)
+		}
+		return ExitOK
+	}
+
+	// Check input without writing output
+	if *o.validateOnly {
+		if err := o.runValidate(p, o.flags.Args()); err != nil {
+			return o.exitStatus(err)
+		}
+		return ExitOK
+	}
+
+	// Convert and merge file arguments instead of stdin
+	if p == nil && o.flags.NArg() > 0 {
+		if err := o.runMerge(o.flags.Args()); err != nil {
+			return o.exitStatus(err)
+		}
+		return ExitOK
+	}
+
+	// Get plugin for format, detecting it from stdin if unset
+	var input io.Reader = o.stdin
+	if p == nil {
+		var err error
+		p, input, err = o.stdinPlugin()
+		if err != nil {
+			return o.exitStatus(err)
+		}
+	}
+	o.reportFormat(p, "")
+
+	c, err := o.newConverter(p)
+	if err != nil {
+		return o.exitStatus(err)
+	}
+	required, err := o.requiredKeys()
+	if err != nil {
+		return o.exitStatus(err)
+	}
+	c.SetRequired(required)
+
+	// Print a single value
+	if *o.get != "" {
+		v, err := c.Get(input, *o.get)
+		if err != nil {
+			return o.exitStatus(err)
+		}
+		fmt.Fprintln(o.stdout, v)
+		return ExitOK
+	}
+
+	// Convert stdin to stdout or --output-file
+	err = o.writeOutput(c, func(w io.Writer) error {
+		return c.Convert(input, w)
+	})
+	if err != nil {
+		return o.exitStatus(err)
+	}
+	return ExitOK
+}
+
+// Exit statuses returned by Run. Scripts can tell failures apart by them;
+// the diff subcommand has statuses of its own.
+const (
+	ExitOK        = 0 // success
+	ExitError     = 1 // any other error, such as a missing file or --get key
+	ExitUsage     = 2 // invalid flags, flag values or combinations of flags
+	ExitUnchanged = 3 // --if-changed left the output file as it is
+	ExitParse     = 4 // input could not be parsed, detected or read in full
+	ExitInvalid   = 5 // converted keys failed a check, such as --require
+	ExitEmpty     = 6 // --error-on-empty found no keys to write
+)
+
+// Exit statuses returned by Run for the diff subcommand, following diff(1)
+const (
+	ExitDiffSame    = 0 // the configs match
+	ExitDiffChanged = 1 // the configs differ
+	ExitDiffError   = 2 // any error, such as invalid flags or a missing file
+)
+
+// errUnchanged reports that --if-changed found the output file up to date
+var errUnchanged = errors.New("output file is unchanged")
+
+// usageError reports invalid flag values or combinations of flags
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// usage marks err, if not nil, as a usage error
+func usage(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &usageError{err: err}
+}
+
+// usagef returns a usage error formatted as by fmt.Errorf
+func usagef(format string, args ...interface{}) error {
+	return usage(fmt.Errorf(format, args...))
+}
+
+// failedFiles reports that some of several files failed, unwrapping to the
+// first failure so the exit status follows it
+type failedFiles struct {
+	msg   string
+	first error
+}
+
+func (e *failedFiles) Error() string {
+	return e.msg
+}
+
+func (e *failedFiles) Unwrap() error {
+	return e.first
+}
+
+// exitStatus reports err and returns the exit status for it
+func (o *options) exitStatus(err error) int {
+	if errors.Is(err, errUnchanged) {
+		fmt.Fprintf(o.stderr, "%s is unchanged\n", *o.outputFile)
+		return ExitUnchanged
+	}
+	fmt.Fprintf(o.stderr, "Error: %v\n", err)
+	return statusOf(err)
+}
+
+// statusOf returns the exit status for the failure err reports
+func statusOf(err error) int {
+	var (
+		usageErr   *usageError
+		parseErr   *converter.ParseError
+		invalidErr *converter.ValidationError
+		dupErr     *converter.DuplicateKeyError
+		missingErr *converter.MissingKeysError
+	)
+	switch {
+	case errors.As(err, &usageErr):
+		return ExitUsage
+	case errors.As(err, &parseErr),
+		errors.Is(err, plugins.ErrUnknownFormat),
+		errors.Is(err, utils.ErrInputTooLarge),
+		errors.Is(err, utils.ErrMaxDepth):
+		return ExitParse
+	case errors.As(err, &invalidErr),
+		errors.As(err, &dupErr),
+		errors.As(err, &missingErr),
+		errors.Is(err, converter.ErrUnnamedRoot):
+		return ExitInvalid
+	case errors.Is(err, converter.ErrEmptyOutput):
+		return ExitEmpty
+	}
+	return ExitError
+}
+
+// writeOutput writes the output produced by write to --output-file, or to
+// stdout if it is not set. With --if-changed, an output file whose content
+// hash matches that of the new output is left alone and errUnchanged is
+// returned. With --append, the output is added to the end of the file; c
+// reads the keys the file already holds.
+func (o *options) writeOutput(c *converter.Converter, write func(io.Writer) error) error {
+	if *o.outputFile == "" {
+		return write(o.stdout)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	if *o.ifChanged {
+		same, err := sameContent(*o.outputFile, buf.Bytes())
+		if err != nil {
+			return err
+		}
+		if same {
+			return errUnchanged
+		}
+	}
+	if *o.append {
+		return o.appendFile(c, *o.outputFile, buf.Bytes())
+	}
+	return writeFile(*o.outputFile, buf.Bytes())
+}
+
+// sameContent reports whether the file at path records the same content
+// hash as out. A missing file or one without a hash never matches.
+func sameContent(path string, out []byte) (bool, error) {
+	want, err := converter.ReadContentHash(bytes.NewReader(out))
+	if err != nil || want == "" {
+		return false, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	got, err := converter.ReadContentHash(f)
+	return got == want, err
+}
+
+// writeFile replaces the file at path with data through a temporary file in
+// the same directory, so readers never see a partial file. An existing
+// file keeps its permissions.
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// appendFile adds data, a converter's env pairs without a header, to the
+// end of the file at path, creating it if it is missing. Existing content is
+// followed by a blank line and the --append-separator comment, if set. Keys
+// of data that the file already sets are reported as warnings.
+func (o *options) appendFile(c *converter.Converter, path string, data []byte) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if len(existing) > 0 {
+		fileKeys, err := c.ReadBaseline(bytes.NewReader(existing))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		added, err := c.ReadBaseline(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		var dups []string
+		for k := range added {
+			if _, ok := fileKeys[k]; ok {
+				dups = append(dups, k)
+			}
+		}
+		sort.Strings(dups)
+		for _, k := range dups {
+			fmt.Fprintf(o.stderr, "Warning: key '%s' is already set in %s\n", k, path)
+		}
+
+		var sep bytes.Buffer
+		if !bytes.HasSuffix(existing, []byte("\n")) {
+			sep.WriteByte('\n')
+		}
+		sep.WriteByte('\n')
+		if *o.appendSeparator != "" {
+			prefix := *o.commentPrefix
+			if prefix == "" {
+				prefix = "#"
+			}
+			fmt.Fprintf(&sep, "%s %s\n", prefix, *o.appendSeparator)
+		}
+		data = append(sep.Bytes(), data...)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// newConverter creates a converter for p configured from the command-line flags
+func (o *options) newConverter(p plugin.Plugin) (*converter.Converter, error) {
+	if err := o.checkOptions(p); err != nil {
+		return nil, err
+	}
+	if err := o.configurePlugin(p); err != nil {
+		return nil, err
+	}
+
+	// Parse sort mode
+	sortMode, err := converter.ParseSortMode(*o.sort)
+	if err != nil {
+		return nil, usage(err)
+	}
+
+	policy, err := converter.ParseConflictPolicy(*o.onConflict)
+	if err != nil {
+		return nil, usage(err)
+	}
+
+	caseMode, err := converter.ParseCaseMode(*o.caseMode)
+	if err != nil {
+		return nil, usage(err)
+	}
+
+	// Create converter with plugin. The case mode comes first, as filter
+	// patterns are normalized when they are set.
+	c := converter.New(p)
+	c.SetCaseMode(caseMode)
+	c.SetSort(sortMode)
+	// An explicit policy also resolves keys a format flattens together,
+	// which are otherwise warnings
+	if o.isSet("on-conflict") {
+		c.SetConflictPolicy(policy)
+	}
+	c.SetSorted(!*o.noSort)
+	c.SetNaturalSort(*o.naturalSort)
+	c.SetKeepComments(*o.keepComments)
+	c.SetGroupBreaks(*o.group || *o.groupHeaders, *o.groupHeaders)
+	c.SetSanitizeKeys(*o.sanitizeKeys)
+	c.SetReservedPrefix(*o.reservedPrefix, o.reservedKeyList())
+	c.SetStrictKeys(*o.strictKeys)
+	c.SetStrictNewlines(*o.strictNewlines)
+	c.SetPruneEmpty(*o.pruneEmpty)
+	c.SetTrimValues(*o.trimValues)
+	if *o.errorOnOversize && *o.maxValueLength <= 0 {
+		return nil, usagef("--error-on-oversize requires --max-value-length")
+	}
+	c.SetMaxValueLength(*o.maxValueLength)
+	c.SetErrorOnOversize(*o.errorOnOversize)
+	c.SetErrorOnEmpty(*o.errorOnEmpty)
+	if strings.ContainsAny(*o.kvSep, "\r\n") {
+		return nil, usagef("--kv-sep must not contain line breaks")
+	}
+	if converter.IsKeySeparator(*o.kvSep) {
+		fmt.Fprintf(o.stderr, "Warning: --kv-sep %q can appear in keys, making their lines ambiguous\n", *o.kvSep)
+	}
+	c.SetKVSeparator(*o.kvSep)
+	c.SetErrorOnAmbiguousKeys(*o.errorOnAmbiguousKeys)
+	c.SetKeysOnly(*o.keysOnly)
+	c.SetValuesOnly(*o.valuesOnly)
+	c.SetFinalNewline(!*o.noTrailingNewline)
+	c.SetLimit(*o.limit)
+	c.SetMaxInputBytes(*o.maxInputBytes)
+	if strings.ContainsAny(*o.commentPrefix, "\r\n") {
+		return nil, usagef("--comment-prefix must not contain line breaks")
+	}
+	c.SetCommentPrefix(*o.commentPrefix)
+	if *o.commentHeader {
+		ts, err := headerTime()
+		if err != nil {
+			return nil, err
+		}
+		c.SetCommentHeader(true, ts)
+	}
+	c.SetWarningWriter(o.stderr)
+	if *o.dunder > 0 {
+		c.SetDunder(*o.dunder)
+	}
+	c.SetDunderCollapse(*o.dunderCollapse)
+	c.SetPrefix(*o.prefix)
+	c.SetPrefixSeparator(*o.prefixSeparator)
+	c.SetExtraPairs(*o.set)
+	c.SetUnsetKeys(*o.unset)
+
+	// Write a format other than .env
+	enc, err := converter.ParseEncoder(*o.outputFormat)
+	if err != nil {
+		return nil, usage(err)
+	}
+	if _, ok := enc.(converter.EnvEncoder); !ok {
+		if *o.keysOnly || *o.valuesOnly {
+			return nil, usagef("--keys-only and --values-only only apply to env output")
+		}
+		c.SetEncoder(enc)
+	}
+
+	// Write only the changes from a baseline .env
+	if *o.baseline != "" {
+		baseline, err := readBaseline(c, *o.baseline)
+		if err != nil {
+			return nil, err
+		}
+		c.SetBaseline(baseline)
+	}
+
+	// Record a content hash for --if-changed to compare
+	if *o.ifChanged {
+		switch {
+		case *o.outputFile == "":
+			return nil, usagef("--if-changed requires --output-file")
+		case *o.keysOnly || *o.valuesOnly || *o.outputFormat != "env":
+			return nil, usagef("--if-changed only applies to env output with a header")
+		}
+		c.SetContentHash(true)
+	}
+
+	// Append to --output-file rather than replacing it
+	switch {
+	case *o.append && *o.outputFile == "":
+		return nil, usagef("--append requires --output-file")
+	case *o.append && *o.ifChanged:
+		return nil, usagef("--append cannot be used with --if-changed")
+	case *o.append && (*o.keysOnly || *o.valuesOnly || *o.outputFormat != "env"):
+		return nil, usagef("--append only applies to env output with keys and values")
+	case *o.appendSeparator != "" && !*o.append:
+		return nil, usagef("--append-separator requires --append")
+	case strings.ContainsAny(*o.appendSeparator, "\r\n"):
+		return nil, usagef("--append-separator must not contain line breaks")
+	}
+	// The file keeps its own header, so only the pairs are appended
+	if *o.append {
+		c.SetHeader(false)
+	}
+
+	// Parse pattern matcher
+	matcher, err := converter.ParseMatcher(*o.matcher)
+	if err != nil {
+		return nil, usage(err)
+	}
+
+	// Configure filtering if patterns provided
+	switch {
+	case len(*o.include) == 0 && len(*o.exclude) == 0:
+		if *o.filterOriginal {
+			return nil, usagef("--filter-original requires --include or --exclude")
+		}
+	case *o.filterOriginal:
+		c.SetOriginalFilterPatterns(*o.include, *o.exclude, matcher)
+	default:
+		c.SetFilterPatterns(*o.include, *o.exclude, matcher)
+	}
+
+	// Configure template mode
+	if *o.template {
+		var secretPatterns []string
+		if *o.templateSecrets != "" {
+			secretPatterns = strings.Split(*o.templateSecrets, ",")
+		}
+		c.SetTemplate(true, secretPatterns, matcher)
+	}
+
+	// Mask secrets listed in a shared patterns file
+	if *o.redactFile != "" {
+		patterns, err := readPatterns(*o.redactFile)
+		if err != nil {
+			return nil, err
+		}
+		c.SetRedactPatterns(patterns, matcher)
+	}
+
+	// Coerce values as a schema file asks
+	if *o.schema != "" {
+		rules, err := readSchema(*o.schema)
+		if err != nil {
+			return nil, err
+		}
+		c.SetSchema(rules)
+	}
+
+	return c, nil
+}
+
+// readSchema reads the schema rules in the file at path
+func readSchema(path string) ([]converter.SchemaRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules, err := converter.ReadSchema(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// readPatterns reads the key patterns listed in the file at path
+func readPatterns(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns, err := converter.ReadPatterns(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// checkOptions warns about format-specific options that p ignores, such as
+// --query for a format without queries, or fails with --strict-options
+func (o *options) checkOptions(p plugin.Plugin) error {
+	if o.checked[p.Name()] {
+		return nil
+	}
+	o.checked[p.Name()] = true
+
+	opts := []plugins.Option{
+		{Name: "--query", Set: *o.query != "", Supported: func(c plugin.Capabilities) bool { return c.Query }},
+		{Name: "--table", Set: *o.table != "", Supported: func(c plugin.Capabilities) bool { return c.Table }},
+		{Name: "--keep-comments", Set: *o.keepComments, Supported: func(c plugin.Capabilities) bool { return c.Comments }},
+		{Name: "--skip-path", Set: *o.skipPath != "", Supported: func(c plugin.Capabilities) bool { return c.Paths }},
+		{Name: "--only-path", Set: *o.onlyPath != "", Supported: func(c plugin.Capabilities) bool { return c.Paths }},
+		{Name: "--concat", Set: *o.concat, Supported: func(c plugin.Capabilities) bool { return c.Concat }},
+		{Name: "--array-mode", Set: *o.arrayMode != "index", Supported: func(c plugin.Capabilities) bool { return c.Arrays }},
+		{Name: "--array-key-field", Set: *o.arrayKeyField != "", Supported: func(c plugin.Capabilities) bool { return c.Arrays }},
+		{Name: "--compact-arrays", Set: *o.compactArrays, Supported: func(c plugin.Capabilities) bool { return c.Arrays }},
+		{Name: "--path-prefix", Set: *o.pathPrefix != "", Supported: func(c plugin.Capabilities) bool { return c.PathPrefix }},
+	}
+	return usage(plugins.CheckOptions(p, opts, o.stderr, *o.strictOptions))
+}
+
+// configurePlugin applies the format-specific flags that p supports
+func (o *options) configurePlugin(p plugin.Plugin) error {
+	// Set custom query
+	if q, ok := p.(interface{ SetQuery(string) }); ok {
+		q.SetQuery(*o.query)
+	}
+
+	// Set table to read
+	if t, ok := p.(interface{ SetTable(string) }); ok {
+		t.SetTable(*o.table)
+	}
+
+	// Configure array flattening
+	arrayMode, err := utils.ParseArrayMode(*o.arrayMode)
+	if err != nil {
+		return usage(err)
+	}
+	if a, ok := p.(interface {
+		SetArrayMode(utils.ArrayMode, string)
+	}); ok {
+		a.SetArrayMode(arrayMode, *o.arraySep)
+	}
+	if k, ok := p.(interface{ SetArrayKeyField(string) }); ok {
+		k.SetArrayKeyField(*o.arrayKeyField)
+	}
+	if ca, ok := p.(interface{ SetCompactArrays(bool) }); ok {
+		ca.SetCompactArrays(*o.compactArrays)
+	}
+
+	// Drop empty maps and arrays
+	if oe, ok := p.(interface{ SetOmitEmptyContainers(bool) }); ok {
+		oe.SetOmitEmptyContainers(*o.omitEmptyContainers)
+	}
+
+	// Format YAML timestamps
+	if tf, ok := p.(interface{ SetTimeFormat(string) }); ok {
+		tf.SetTimeFormat(*o.timeFormat)
+	}
+
+	// Keep YAML booleans and nulls as written
+	if pl, ok := p.(interface{ SetPreserveLiterals(bool) }); ok {
+		pl.SetPreserveLiterals(*o.preserveYAMLLiterals)
+	}
+
+	// Reject repeated keys in the source document
+	if d, ok := p.(interface{ SetStrictDuplicates(bool) }); ok {
+		d.SetStrictDuplicates(*o.strictSourceDuplicates)
+	}
+
+	// Guard against pathologically nested input
+	if md, ok := p.(interface{ SetMaxDepth(int) }); ok {
+		md.SetMaxDepth(*o.maxDepth)
+	}
+
+	// Drop unwanted subtrees before flattening
+	if sp, ok := p.(interface{ SetSkipPaths([]string) }); ok {
+		var paths []string
+		if *o.skipPath != "" {
+			paths = strings.Split(*o.skipPath, ",")
+		}
+		sp.SetSkipPaths(paths)
+	}
+
+	// Flatten a single subtree
+	if op, ok := p.(interface{ SetOnlyPath(string, bool) }); ok {
+		op.SetOnlyPath(*o.onlyPath, *o.onlyPathStrip)
+	}
+
+	// Drop the leading path of hierarchical parameter names
+	if pp, ok := p.(interface{ SetPathPrefix(string) }); ok {
+		pp.SetPathPrefix(*o.pathPrefix)
+	}
+
+	// Read concatenated JSON values
+	if cc, ok := p.(interface{ SetConcat(bool) }); ok {
+		cc.SetConcat(*o.concat)
+	}
+
+	// Accept JSONC comments and trailing commas
+	if rl, ok := p.(interface{ SetRelaxed(bool) }); ok {
+		rl.SetRelaxed(*o.jsonRelaxed)
+	}
+	return nil
+}
+
+// reservedKeyList returns the keys named by --reserved-keys, or nil for the
+// converter's default list
+func (o *options) reservedKeyList() []string {
+	if len(*o.reservedKeys) == 0 {
+		return nil
+	}
+	return *o.reservedKeys
+}
+
+// requiredKeys returns the keys named by --require and the keys of the
+// --require-file schema
+func (o *options) requiredKeys() ([]string, error) {
+	keys := append([]string(nil), *o.require...)
+	if *o.requireFile == "" {
+		return keys, nil
+	}
+
+	f, err := os.Open(*o.requireFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	schema, err := dotenv.New().Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", *o.requireFile, err)
+	}
+	for key := range schema {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// readBaseline reads the --baseline file with the separator and comment
+// prefix c writes
+func readBaseline(c *converter.Converter, path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	baseline, err := c.ReadBaseline(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// headerTime returns the time recorded by --comment-header. SOURCE_DATE_EPOCH
+// overrides the current time for reproducible builds.
+func headerTime() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Time{}, nil
+	}
+	secs, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %s", epoch)
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// pluginForFile returns the plugin for path, using --format if set and the
+// file extension otherwise
+func (o *options) pluginForFile(path string) (plugin.Plugin, error) {
+	if *o.format != "" {
+		return plugins.Get(*o.format)
+	}
+	return plugins.ForFile(path, *o.errorOnUnknownFormat)
+}
+
+// stdinPlugin returns the plugin for stdin, using --format or
+// --format-chain if set and the content otherwise, along with the reader to
+// convert
+func (o *options) stdinPlugin() (plugin.Plugin, io.Reader, error) {
+	if *o.formatChain != "" {
+		if *o.format != "" {
+			return nil, nil, usagef("--format and --format-chain cannot be used together")
+		}
+		return o.chainPlugin(strings.Split(*o.formatChain, ","))
+	}
+	if *o.format != "" {
+		p, err := plugins.Get(*o.format)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, o.decodeInput(p, o.stdin), nil
+	}
+	if *o.errorOnUnknownFormat {
+		return o.detectStdin(plugins.DetectStrict)
+	}
+	return o.detectStdin(plugins.Detect)
+}
+
+// detectStdin picks the plugin for stdin with detect and returns it with a
+// reader yielding stdin decoded for it. Binary content is recognized as it
+// is; anything else is detected once decoded, so that UTF-16 JSON is found.
+func (o *options) detectStdin(detect func(io.Reader) (plugin.Plugin, io.Reader, error)) (plugin.Plugin, io.Reader, error) {
+	p, r, err := plugins.Detect(o.stdin)
+	if err != nil || binaryFormats[p.Name()] {
+		return p, r, err
+	}
+	return detect(utils.DecodeInput(r, o.encoding))
+}
+
+// binaryFormats are the input formats read as bytes rather than text, which
+// --input-encoding does not apply to
+var binaryFormats = map[string]bool{"sqlite": true}
+
+// decodeInput returns r decoded from --input-encoding to UTF-8 for p, or r
+// unchanged if p reads a binary format
+func (o *options) decodeInput(p plugin.Plugin, r io.Reader) io.Reader {
+	if binaryFormats[p.Name()] {
+		return r
+	}
+	return utils.DecodeInput(r, o.encoding)
+}
+
+// chainPlugin returns the first of formats whose plugin parses stdin, with
+// a reader replaying stdin. Each plugin is configured from the flags before
+// it is tried, so options such as --json-relaxed affect which one parses.
+func (o *options) chainPlugin(formats []string) (plugin.Plugin, io.Reader, error) {
+	var input io.Reader = utils.DecodeInput(o.stdin, o.encoding)
+	for _, format := range formats {
+		p, err := plugins.Get(format)
+		if err != nil {
+			return nil, nil, err
+		}
+		if binaryFormats[p.Name()] {
+			if o.encoding != utils.EncodingUTF8 {
+				return nil, nil, usagef("--input-encoding cannot be used with a --format-chain including %s", p.Name())
+			}
+			input = o.stdin
+		}
+	}
+	return plugins.Chain(formats, input, o.configurePlugin)
+}
+
+// mainOptions are the options --print-config always lists, in this order
+var mainOptions = []string{
+	"format", "source", "output-format", "kv-sep", "dunder", "dunder-collapse",
+	"prefix", "prefix-separator", "include", "exclude", "matcher", "sort", "array-mode",
+}
+
+// isSet reports whether the flag name was given on the command line
+func (o *options) isSet(name string) bool {
+	set := false
+	o.flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// reportConfig writes the effective options to stderr: mainOptions, then
+// the other options set on the command line in name order, then the file
+// arguments. String values are quoted so that empty values and
+// separators stay visible.
+func (o *options) reportConfig() {
+	core := make(map[string]bool, len(mainOptions))
+	for _, name := range mainOptions {
+		core[name] = true
+	}
+	names := append([]string(nil), mainOptions...)
+	set := make(map[string]bool)
+	o.flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		if !core[f.Name] && f.Name != "print-config" {
+			names = append(names, f.Name)
+		}
+	})
+
+	fmt.Fprintln(o.stderr, "Effective options:")
+	for _, name := range names {
+		f := o.flags.Lookup(name)
+		note := ""
+		if !set[name] {
+			note = " (default)"
+		}
+		fmt.Fprintf(o.stderr, "  %-24s %s%s\n", name, optionValue(f.Value), note)
+	}
+	if o.flags.NArg() > 0 {
+		fmt.Fprintf(o.stderr, "  %-24s %s\n", "files", strings.Join(o.flags.Args(), " "))
+	}
+}
+
+// optionValue formats a flag's value for reportConfig, quoting strings and
+// pattern lists
+func optionValue(v flag.Value) string {
+	switch v.(type) {
+	case *patternList, *pairList:
+		return strconv.Quote(v.String())
+	}
+	if g, ok := v.(flag.Getter); ok {
+		if _, ok := g.Get().(string); ok {
+			return strconv.Quote(v.String())
+		}
+	}
+	return v.String()
+}
+
+// reportFormat writes the plugin chosen for path, or for stdin when path is
+// empty, to stderr if --format-detect-report is set
+func (o *options) reportFormat(p plugin.Plugin, path string) {
+	if !*o.formatDetectReport {
+		return
+	}
+	if path == "" {
+		fmt.Fprintf(o.stderr, "detected: %s\n", p.Name())
+		return
+	}
+	fmt.Fprintf(o.stderr, "detected: %s (%s)\n", p.Name(), path)
+}
+
+// convertFile converts the config file at path into a map of env pairs
+func (o *options) convertFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p, err := o.pluginForFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if *o.env != "" {
+		if p, err = o.withOverlay(p, path); err != nil {
+			return nil, err
+		}
+	}
+	return o.convertReader(p, path, f, *o.prefixFromFilename)
+}
+
+// withOverlay returns p merging the --env overlay of the config file at
+// path over its input
+func (o *options) withOverlay(p plugin.Plugin, path string) (plugin.Plugin, error) {
+	f, err := os.Open(plugins.OverlayPath(path, *o.env))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(o.decodeInput(p, f))
+	if err != nil {
+		return nil, err
+	}
+
+	// The overlay hides the setters of p, so configure it first
+	if err := o.configurePlugin(p); err != nil {
+		return nil, err
+	}
+	return plugins.Overlay(p, data), nil
+}
+
+// convertReader converts r, read from path, with p into a map of env pairs.
+// With filePrefix, keys are prefixed with the base filename of path.
+func (o *options) convertReader(p plugin.Plugin, path string, r io.Reader, filePrefix bool) (map[string]string, error) {
+	o.reportFormat(p, path)
+	r = o.decodeInput(p, r)
+
+	c, err := o.newConverter(p)
+	if err != nil {
+		return nil, err
+	}
+	if filePrefix {
+		// The filename nests under --prefix when both are set
+		pfx := converter.PrefixFromFilename(path)
+		if *o.prefix != "" {
+			pfx = *o.prefix + *o.prefixSeparator + pfx
+		}
+		c.SetPrefix(pfx)
+	}
+
+	env, err := c.ConvertMap(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return env, nil
+}
+
+// runMerge converts each file with its detected format and writes the merged result
+func (o *options) runMerge(paths []string) error {
+	strategy, err := converter.ParseMergeStrategy(*o.mergeStrategy)
+	if err != nil {
+		return usage(err)
+	}
+
+	var envs []map[string]string
+	var names []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		p, err := o.pluginForFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !seen[p.Name()] {
+			seen[p.Name()] = true
+			names = append(names, p.Name())
+		}
+
+		env, err := o.convertFile(path)
+		if err != nil {
+			return err
+		}
+		envs = append(envs, env)
+	}
+
+	p, err := o.pluginForFile(paths[0])
+	if err != nil {
+		return err
+	}
+	return o.writeMerged(p, strategy, envs, names)
+}
+
+// runTar converts each config file in the tar archive on stdin, prefixing
+// its keys with its base filename, and writes the merged result
+func (o *options) runTar() error {
+	strategy, err := converter.ParseMergeStrategy(*o.mergeStrategy)
+	if err != nil {
+		return usage(err)
+	}
+
+	var envs []map[string]string
+	var names []string
+	var first plugin.Plugin
+	seen := make(map[string]bool)
+	err = plugins.WalkTar(o.stdin, *o.format, func(name string, p plugin.Plugin, r io.Reader) error {
+		if first == nil {
+			first = p
+		}
+		if !seen[p.Name()] {
+			seen[p.Name()] = true
+			names = append(names, p.Name())
+		}
+
+		env, err := o.convertReader(p, name, r, true)
+		if err != nil {
+			return err
+		}
+		envs = append(envs, env)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if first == nil {
+		return fmt.Errorf("no config files found in the tar archive")
+	}
+	return o.writeMerged(first, strategy, envs, names)
+}
+
+// checkTarArgs rejects options that choose another input than the tar
+// archive on stdin, with p the plugin chosen by --source if any
+func (o *options) checkTarArgs(p plugin.Plugin) error {
+	switch {
+	case p != nil:
+		return usagef("--tar cannot be used with --source %s", *o.source)
+	case o.flags.NArg() > 0:
+		return usagef("--tar reads stdin and takes no file arguments")
+	case *o.formatChain != "":
+		return usagef("--tar cannot be used with --format-chain")
+	case *o.validateOnly:
+		return usagef("--tar cannot be used with --validate-only")
+	}
+	return nil
+}
+
+// writeMerged merges envs and writes the result using a converter for p,
+// listing the formats in names in the header
+func (o *options) writeMerged(p plugin.Plugin, strategy converter.MergeStrategy, envs []map[string]string, names []string) error {
+	merged, err := converter.Merge(strategy, envs...)
+	if err != nil {
+		return err
+	}
+
+	// Required keys may come from any of the files
+	required, err := o.requiredKeys()
+	if err != nil {
+		return err
+	}
+	if err := converter.CheckRequired(merged, required); err != nil {
+		return err
+	}
+
+	c, err := o.newConverter(p)
+	if err != nil {
+		return err
+	}
+
+	// Print a single value
+	if *o.get != "" {
+		v, err := c.Lookup(merged, *o.get)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.stdout, v)
+		return nil
+	}
+	return o.writeOutput(c, func(w io.Writer) error {
+		return c.WriteMap(w, merged, names...)
+	})
+}
+
+// runValidate checks each file argument with its detected format, or stdin
+// using p, --format or its content when there are none, without writing output
+func (o *options) runValidate(p plugin.Plugin, paths []string) error {
+	if p != nil || len(paths) == 0 {
+		var input io.Reader = o.stdin
+		if p == nil {
+			var err error
+			if p, input, err = o.stdinPlugin(); err != nil {
+				return err
+			}
+		}
+		o.reportFormat(p, "")
+
+		c, err := o.newConverter(p)
+		if err != nil {
+			return err
+		}
+		return c.Validate(input)
+	}
+
+	var first error
+	failed := 0
+	for _, path := range paths {
+		if err := o.validateFile(path); err != nil {
+			fmt.Fprintf(o.stderr, "Error: %v\n", err)
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return &failedFiles{msg: fmt.Sprintf("%d of %d files failed validation", failed, len(paths)), first: first}
+	}
+	return nil
+}
+
+// validateFile checks the config file at path without converting it
+func (o *options) validateFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	p, err := o.pluginForFile(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	o.reportFormat(p, path)
+
+	c, err := o.newConverter(p)
+	if err != nil {
+		return err
+	}
+	if err := c.Validate(o.decodeInput(p, f)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// runDiff compares two config files and prints their differences. It returns
+// one of the ExitDiff statuses.
+func (o *options) runDiff(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(o.stderr, "Error: diff requires exactly two files: cfg2env diff OLD NEW")
+		return ExitDiffError
+	}
+
+	oldEnv, err := o.convertFile(args[0])
+	if err != nil {
+		fmt.Fprintf(o.stderr, "Error: %v\n", err)
+		return ExitDiffError
+	}
+	newEnv, err := o.convertFile(args[1])
+	if err != nil {
+		fmt.Fprintf(o.stderr, "Error: %v\n", err)
+		return ExitDiffError
+	}
+
+	changes := converter.Diff(oldEnv, newEnv)
+	if err := converter.WriteDiff(o.stdout, changes); err != nil {
+		fmt.Fprintf(o.stderr, "Error: %v\n", err)
+		return ExitDiffError
+	}
+	if len(changes) > 0 {
+		return ExitDiffChanged
+	}
+	return ExitDiffSame
+}
@@ -0,0 +1,766 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+// run calls Run with args and input, returning the exit status and output
+func run(t *testing.T, input string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(input), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFiles writes files, mapping names to contents, to a temporary
+// directory and returns its path
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// pairs returns the non-comment lines of .env output
+func pairs(out string) string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRun(t *testing.T) {
+	yamlInput := "database:\n  host: localhost\n  port: 5432\nDebug: true\n"
+
+	tests := []struct {
+		name       string
+		input      string
+		args       []string
+		wantCode   int
+		wantOut    string
+		wantStderr string
+	}{
+		{
+			name:    "yaml on stdin",
+			input:   yamlInput,
+			wantOut: "DATABASE_HOST=localhost\nDATABASE_PORT=5432\nDEBUG=true",
+		},
+		{
+			name:    "json detected on stdin",
+			input:   `{"b": 1, "a": {"c": "x"}}`,
+			wantOut: "A_C=x\nB=1",
+		},
+		{
+			name:    "filters, prefix and separator",
+			input:   yamlInput,
+			args:    []string{"--include", "APP_DATABASE_*", "--prefix", "APP", "--kv-sep", ": "},
+			wantOut: "APP_DATABASE_HOST: localhost\nAPP_DATABASE_PORT: 5432",
+		},
+		{
+			name:    "prefix separator with dunder",
+			input:   "db:\n  host: x\n",
+			args:    []string{"--prefix", "my", "--prefix-separator", "__", "--dunder", "1"},
+			wantOut: "MY__DBHOST=x",
+		},
+		{
+			name:    "prefix separator with dunder collapse",
+			input:   "db__host: x\n",
+			args:    []string{"--prefix", "my", "--prefix-separator", "__", "--dunder-collapse", "--get", "my__db_host"},
+			wantOut: "x",
+		},
+		{
+			name:    "compact arrays",
+			input:   `{"hosts": ["a", null, "c", null]}`,
+			args:    []string{"--compact-arrays"},
+			wantOut: "HOSTS_0=a\nHOSTS_1=c",
+		},
+		{
+			name:       "key containing the separator",
+			input:      `{"a=b": 1}`,
+			wantOut:    "A=B=1",
+			wantStderr: "ambiguous",
+		},
+		{
+			name:       "key containing the separator is an error when asked",
+			input:      `{"a=b": 1}`,
+			args:       []string{"--error-on-ambiguous-keys"},
+			wantCode:   ExitInvalid,
+			wantStderr: "keys are ambiguous",
+		},
+		{
+			name:       "separator made of key characters",
+			input:      `{"a": 1}`,
+			args:       []string{"--kv-sep", "_"},
+			wantOut:    "A_1",
+			wantStderr: `--kv-sep "_" can appear in keys`,
+		},
+		{
+			name:       "separator with a line break",
+			args:       []string{"--kv-sep", "=\n"},
+			wantCode:   ExitUsage,
+			wantStderr: "line breaks",
+		},
+		{
+			name:    "ssm parameters",
+			input:   `{"Parameters": [{"Name": "/app/database/host", "Value": "db"}, {"Name": "/app/port", "Value": "80"}]}`,
+			args:    []string{"--format", "ssm", "--path-prefix", "/app"},
+			wantOut: "DATABASE_HOST=db\nPORT=80",
+		},
+		{
+			name:    "natural sort",
+			input:   "worker_10: j\nworker_2: b\nworker_1: a\n",
+			args:    []string{"--natural-sort"},
+			wantOut: "WORKER_1=a\nWORKER_2=b\nWORKER_10=j",
+		},
+		{
+			name:    "set adds and overrides keys",
+			input:   "port: 80\nhost: a\n",
+			args:    []string{"--set", "port=9", "--set", "region=eu"},
+			wantOut: "HOST=a\nPORT=9\nREGION=eu",
+		},
+		{
+			name:    "mixed-prefix case mode",
+			input:   "database:\n  host: a\n  Port: 1\n",
+			args:    []string{"--prefix", "myapp", "--case-mode", "mixed-prefix"},
+			wantOut: "MYAPP_database_Port=1\nMYAPP_database_host=a",
+		},
+		{
+			name:       "unknown case mode",
+			input:      "a: 1\n",
+			args:       []string{"--case-mode", "lower"},
+			wantCode:   ExitUsage,
+			wantStderr: "unsupported case mode",
+		},
+		{
+			name:       "flattened collision warns by default",
+			input:      "database_host: literal\ndatabase:\n  host: nested\n",
+			wantOut:    "DATABASE_HOST=literal",
+			wantStderr: "produced by more than one path",
+		},
+		{
+			name:    "on-conflict resolves flattened collisions",
+			input:   "database_host: literal\ndatabase:\n  host: nested\n",
+			args:    []string{"--on-conflict", "first"},
+			wantOut: "DATABASE_HOST=nested",
+		},
+		{
+			name:       "on-conflict error rejects flattened collisions",
+			input:      `{"Api_Key": "a", "API_KEY": "b"}`,
+			args:       []string{"--on-conflict", "error"},
+			wantCode:   ExitInvalid,
+			wantStderr: "API_KEY",
+		},
+		{
+			name:    "unset removes keys",
+			input:   "port: 80\nhost: a\ndebug: true\n",
+			args:    []string{"--unset", "port", "--unset", "DEBUG,missing"},
+			wantOut: "HOST=a",
+		},
+		{
+			name:       "set needs a key and value",
+			input:      "a: 1\n",
+			args:       []string{"--set", "region"},
+			wantCode:   ExitUsage,
+			wantStderr: "not KEY=VALUE",
+		},
+		{
+			name:    "keys only",
+			input:   yamlInput,
+			args:    []string{"--keys-only", "--sort", "none"},
+			wantOut: "DATABASE_HOST\nDATABASE_PORT\nDEBUG",
+		},
+		{
+			name:    "get a key",
+			input:   yamlInput,
+			args:    []string{"--get", "database_port"},
+			wantOut: "5432",
+		},
+		{
+			name:       "get a missing key",
+			input:      yamlInput,
+			args:       []string{"--get", "missing"},
+			wantCode:   ExitError,
+			wantStderr: "Error:",
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"--no-such-flag"},
+			wantCode:   ExitUsage,
+			wantStderr: "flag provided but not defined",
+		},
+		{
+			name:       "unsupported source",
+			args:       []string{"--source", "nowhere"},
+			wantCode:   ExitUsage,
+			wantStderr: "unsupported source: nowhere",
+		},
+		{
+			name:       "unknown format is an error when strict",
+			input:      yamlInput,
+			args:       []string{"--error-on-unknown-format"},
+			wantCode:   ExitParse,
+			wantStderr: "unknown format",
+		},
+		{
+			name:       "invalid input",
+			input:      `{"a": `,
+			args:       []string{"--format", "json"},
+			wantCode:   ExitParse,
+			wantStderr: "Error:",
+		},
+		{
+			name:       "validate only",
+			input:      `{"a": 1}`,
+			args:       []string{"--validate-only", "--format", "json"},
+			wantCode:   0,
+			wantOut:    "",
+			wantStderr: "",
+		},
+		{
+			name:       "required key missing",
+			input:      yamlInput,
+			args:       []string{"--require", "API_KEY"},
+			wantCode:   ExitInvalid,
+			wantStderr: "API_KEY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, stderr := run(t, tt.input, tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("Run() = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			if code == 0 && pairs(out) != tt.wantOut {
+				t.Errorf("Run() output = %q, want %q", pairs(out), tt.wantOut)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("Run() stderr = %q, want it to contain %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRun_ExitStatus(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"good.json": `{"a": 1}`,
+		"bad.json":  `{"a": `,
+	})
+
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		want  int
+	}{
+		{"success", "a: 1\n", nil, ExitOK},
+		{"missing file", "", []string{filepath.Join(dir, "missing.yaml")}, ExitError},
+		{"unknown flag", "", []string{"--no-such-flag"}, ExitUsage},
+		{"invalid flag value", "a: 1\n", []string{"--sort", "random"}, ExitUsage},
+		{"conflicting flags", "a: 1\n", []string{"--error-on-oversize"}, ExitUsage},
+		{"option unsupported by the format", "a: 1\n", []string{"--format", "yaml", "--query", "x", "--strict-options"}, ExitUsage},
+		{"syntax error", "a: [1\n", []string{"--format", "yaml"}, ExitParse},
+		{"input too large", "a: 1\n", []string{"--max-input-bytes", "2"}, ExitParse},
+		{"nested too deep", `{"a": {"b": 1}}`, []string{"--max-depth", "1"}, ExitParse},
+		{"validate only", `{"a": `, []string{"--validate-only", "--format", "json"}, ExitParse},
+		{"validate files", "", []string{"--validate-only", filepath.Join(dir, "good.json"), filepath.Join(dir, "bad.json")}, ExitParse},
+		{"duplicate keys", "a=1\nA=2\n", []string{"--format", "dotenv"}, ExitInvalid},
+		{"invalid keys", `{"a-b": 1}`, []string{"--strict-keys"}, ExitInvalid},
+		{"oversize value", "a: long\n", []string{"--max-value-length", "2", "--error-on-oversize"}, ExitInvalid},
+		{"required key missing", "a: 1\n", []string{"--require", "B"}, ExitInvalid},
+		{"unnamed root", "42\n", []string{"--format", "yaml"}, ExitInvalid},
+		{"empty input", "", []string{"--format", "json", "--error-on-empty"}, ExitEmpty},
+		{"filters match nothing", "a: 1\n", []string{"--include", "B", "--error-on-empty"}, ExitEmpty},
+		{"empty output allowed", "a: 1\n", []string{"--include", "B"}, ExitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, stderr := run(t, tt.input, tt.args...); code != tt.want {
+				t.Errorf("Run() = %d, want %d; stderr: %s", code, tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_FlagsDoNotLeak(t *testing.T) {
+	if code, out, _ := run(t, "a: 1\n", "--prefix", "APP"); code != 0 || pairs(out) != "APP_A=1" {
+		t.Fatalf("Run() = %d, %q", code, out)
+	}
+	if code, out, _ := run(t, "a: 1\n"); code != 0 || pairs(out) != "A=1" {
+		t.Errorf("second Run() = %d, %q, want A=1 without the earlier prefix", code, out)
+	}
+
+	// Format options on the shared plugins are reset as well
+	input := `{"skip": {"a": 1}, "keep": 2}`
+	if code, out, _ := run(t, input, "--skip-path", "skip"); code != 0 || pairs(out) != "KEEP=2" {
+		t.Fatalf("Run() = %d, %q", code, out)
+	}
+	if code, out, _ := run(t, input); code != 0 || pairs(out) != "KEEP=2\nSKIP_A=1" {
+		t.Errorf("second Run() = %d, %q, want the skipped path back", code, out)
+	}
+}
+
+func TestRun_Concurrent(t *testing.T) {
+	input := `{"skip": {"a": 1}, "keep": 2}`
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if code, out, _ := run(t, input, "--prefix", "APP", "--skip-path", "skip"); code != 0 || pairs(out) != "APP_KEEP=2" {
+				t.Errorf("Run() = %d, %q, want APP_KEEP=2", code, out)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if code, out, _ := run(t, input); code != 0 || pairs(out) != "KEEP=2\nSKIP_A=1" {
+				t.Errorf("Run() = %d, %q, want no prefix or skipped path", code, out)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRun_Info(t *testing.T) {
+	if code, out, _ := run(t, "", "--version"); code != 0 || !strings.HasPrefix(out, "cfg2env version ") {
+		t.Errorf("--version = %d, %q", code, out)
+	}
+	if code, out, _ := run(t, "", "--help"); code != 0 || !strings.Contains(out, "USAGE:") {
+		t.Errorf("--help = %d, %q", code, out)
+	}
+
+	Readme = "# cfg2env docs\n"
+	defer func() { Readme = "" }()
+	if code, out, _ := run(t, "", "--docs"); code != 0 || out != Readme {
+		t.Errorf("--docs = %d, %q", code, out)
+	}
+}
+
+func TestRun_Files(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yaml":     "host: localhost\nport: 5432\n",
+		"override.json": `{"port": 6543}`,
+	})
+	base := filepath.Join(dir, "base.yaml")
+	override := filepath.Join(dir, "override.json")
+
+	code, out, stderr := run(t, "", base, override)
+	if code != 0 || pairs(out) != "HOST=localhost\nPORT=6543" {
+		t.Errorf("merge = %d, %q; stderr: %s", code, out, stderr)
+	}
+
+	code, _, stderr = run(t, "", "--merge-strategy", "error-on-conflict", base, override)
+	if code != ExitInvalid || !strings.Contains(stderr, "conflicting values") {
+		t.Errorf("merge conflict = %d, stderr %q", code, stderr)
+	}
+
+	code, out, _ = run(t, "", "--prefix-from-filename", base)
+	if code != 0 || pairs(out) != "BASE_HOST=localhost\nBASE_PORT=5432" {
+		t.Errorf("--prefix-from-filename = %d, %q", code, out)
+	}
+
+	code, _, stderr = run(t, "", filepath.Join(dir, "missing.yaml"))
+	if code != ExitError || !strings.Contains(stderr, "missing.yaml") {
+		t.Errorf("missing file = %d, stderr %q", code, stderr)
+	}
+	// Conversion rejects what --validate-only rejects
+	trailing := filepath.Join(writeFiles(t, map[string]string{"t.json": `{"a": 1} garbage`}), "t.json")
+	for _, args := range [][]string{{trailing}, {"--validate-only", trailing}} {
+		if code, _, _ := run(t, "", args...); code != ExitParse {
+			t.Errorf("Run(%q) = %d, want %d", args, code, ExitParse)
+		}
+	}
+}
+
+func TestRun_Env(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.yaml":      "db:\n  host: localhost\n  port: 5432\nhosts: [a, b]\n",
+		"config.prod.yaml": "db:\n  host: prod-db\n  user: admin\nhosts: [x]\n",
+	})
+	base := filepath.Join(dir, "config.yaml")
+
+	code, out, stderr := run(t, "", "--env", "prod", base)
+	if want := "DB_HOST=prod-db\nDB_PORT=5432\nDB_USER=admin\nHOSTS_0=x"; code != 0 || pairs(out) != want {
+		t.Errorf("--env prod = %d, %q, want %q; stderr: %s", code, pairs(out), want, stderr)
+	}
+
+	if code, _, stderr := run(t, "", "--env", "staging", base); code != ExitError || !strings.Contains(stderr, "config.staging.yaml") {
+		t.Errorf("missing overlay = %d, stderr %q", code, stderr)
+	}
+	if code, _, _ := run(t, "a: 1\n", "--env", "prod"); code != ExitUsage {
+		t.Errorf("--env on stdin = %d, want %d", code, ExitUsage)
+	}
+}
+
+func TestRun_OutputFormat(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.yaml":      "a: 1\nb: x\n",
+		"a.prod.yaml": "b: y\n",
+	})
+	file := filepath.Join(dir, "a.yaml")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"json file", []string{"--output-format", "json", file}, "{\n  \"A\": \"1\",\n  \"B\": \"x\"\n}\n"},
+		{"yaml file", []string{"--output-format", "yaml", file}, "A: \"1\"\nB: x\n"},
+		{"json with overlay", []string{"--output-format", "json", "--env", "prod", file}, "{\n  \"A\": \"1\",\n  \"B\": \"y\"\n}\n"},
+		{"json limited", []string{"--output-format", "json", "--limit", "1", file}, "{\n  \"A\": \"1\"\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, stderr := run(t, "", tt.args...)
+			if code != ExitOK || out != tt.want {
+				t.Errorf("Run() = %d, %q, want %q; stderr: %s", code, out, tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_RedactFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".redact": "# Keys the team treats as secrets\n*_PASSWORD\nAPI_KEY\n",
+	})
+	input := "db:\n  host: localhost\n  password: hunter2\napi_key: abc123\n"
+
+	code, out, stderr := run(t, input, "--redact-file", filepath.Join(dir, ".redact"))
+	want := "API_KEY=********\nDB_HOST=localhost\nDB_PASSWORD=********"
+	if code != 0 || pairs(out) != want {
+		t.Errorf("--redact-file = %d, %q, want %q; stderr: %s", code, pairs(out), want, stderr)
+	}
+
+	if code, _, stderr := run(t, input, "--redact-file", filepath.Join(dir, "missing")); code != ExitError || !strings.Contains(stderr, "missing") {
+		t.Errorf("missing patterns file = %d, stderr %q", code, stderr)
+	}
+}
+
+// utf16LE encodes s as UTF-16LE, with a byte order mark if bom is set
+func utf16LE(s string, bom bool) string {
+	var b []byte
+	if bom {
+		b = append(b, 0xFF, 0xFE)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return string(b)
+}
+
+func TestRun_InputEncoding(t *testing.T) {
+	config := "database:\n  host: localhost\nowner: Zoë\n"
+	dir := writeFiles(t, map[string]string{
+		"bom.yaml":    utf16LE(config, true),
+		"nobom.yaml":  utf16LE(config, false),
+		"latin1.yaml": "owner: Zo\xEB\n",
+	})
+	want := "DATABASE_HOST=localhost\nOWNER=Zoë"
+
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		want  string
+	}{
+		{"utf-16le file with bom", "", []string{filepath.Join(dir, "bom.yaml")}, want},
+		{"utf-16le stdin with bom", utf16LE(config, true), nil, want},
+		{"utf-16le json detected on stdin", utf16LE(`{"owner": "Zoë"}`, true), []string{"--error-on-unknown-format"}, "OWNER=Zoë"},
+		{"utf-16le file without bom", "", []string{"--input-encoding", "utf-16le", filepath.Join(dir, "nobom.yaml")}, want},
+		{"latin1 file", "", []string{"--input-encoding", "latin1", filepath.Join(dir, "latin1.yaml")}, "OWNER=Zoë"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, stderr := run(t, tt.input, tt.args...)
+			if code != ExitOK || pairs(out) != tt.want {
+				t.Errorf("Run() = %d, %q, want %q; stderr: %s", code, pairs(out), tt.want, stderr)
+			}
+		})
+	}
+
+	if code, _, stderr := run(t, "a: 1\n", "--input-encoding", "ebcdic"); code != ExitUsage || !strings.Contains(stderr, "unsupported input encoding") {
+		t.Errorf("unknown encoding = %d, stderr %q", code, stderr)
+	}
+}
+
+func TestRun_Schema(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"schema.yaml": "\"*_ENABLED\": bool01\ndb_port: int\napi_token: [lower, quote]\n",
+	})
+	schema := filepath.Join(dir, "schema.yaml")
+	input := "cache_enabled: true\nlog_enabled: False\ndb:\n  port: 5432.0\napi:\n  token: AbC\n"
+
+	code, out, stderr := run(t, input, "--schema", schema)
+	want := "API_TOKEN=\"abc\"\nCACHE_ENABLED=1\nDB_PORT=5432\nLOG_ENABLED=0"
+	if code != 0 || pairs(out) != want {
+		t.Errorf("--schema = %d, %q, want %q; stderr: %s", code, pairs(out), want, stderr)
+	}
+
+	if code, _, stderr := run(t, "cache_enabled: maybe\n", "--schema", schema); code != ExitInvalid || !strings.Contains(stderr, "CACHE_ENABLED") {
+		t.Errorf("uncoercible value = %d, stderr %q", code, stderr)
+	}
+}
+
+func TestRun_FilterOriginal(t *testing.T) {
+	input := "database:\n  host: localhost\n  port: 5432\ndatabase_url: postgres://\n"
+
+	code, out, stderr := run(t, input, "--filter-original", "--include", "database.*")
+	want := "DATABASE_HOST=localhost\nDATABASE_PORT=5432"
+	if code != 0 || pairs(out) != want {
+		t.Errorf("--filter-original = %d, %q, want %q; stderr: %s", code, pairs(out), want, stderr)
+	}
+
+	// Without the flag the dotted pattern matches no output key
+	if code, out, _ := run(t, input, "--include", "database.*"); code != 0 || pairs(out) != "" {
+		t.Errorf("--include without --filter-original = %d, %q", code, pairs(out))
+	}
+
+	if code, _, _ := run(t, input, "--filter-original"); code != ExitUsage {
+		t.Errorf("--filter-original without patterns = %d, want %d", code, ExitUsage)
+	}
+}
+
+func TestRun_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.env")
+	if err := os.WriteFile(path, []byte("# Shared settings\nEXISTING=1\nA=0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, stderr := run(t, "a: 1\nb: 2\n", "--output-file", path, "--append", "--append-separator", "from config.yaml")
+	if code != 0 || out != "" {
+		t.Fatalf("Run() = %d, %q; stderr: %s", code, out, stderr)
+	}
+	if want := "Warning: key 'A' is already set in " + path + "\n"; stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Shared settings\nEXISTING=1\nA=0\n\n# from config.yaml\nA=1\nB=2\n"
+	if string(data) != want {
+		t.Errorf("appended file = %q, want %q", data, want)
+	}
+
+	// A file written with --if-changed keeps its only content hash
+	path = filepath.Join(t.TempDir(), "hashed.env")
+	if code, _, stderr := run(t, "a: 1\n", "--output-file", path, "--if-changed"); code != 0 {
+		t.Fatalf("Run() with --if-changed = %d; stderr: %s", code, stderr)
+	}
+	if code, _, stderr := run(t, "b: 2\n", "--output-file", path, "--append", "--append-separator", "more"); code != 0 || stderr != "" {
+		t.Fatalf("Run() with --append = %d; stderr: %s", code, stderr)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "Content-Hash"); n != 1 {
+		t.Errorf("appended file has %d content hashes, want 1: %q", n, data)
+	}
+	if !strings.HasSuffix(string(data), "A=1\n\n# more\nB=2\n") {
+		t.Errorf("appended file = %q, want B=2 right after the separator", data)
+	}
+
+	// A missing file is created without a separator
+	path = filepath.Join(t.TempDir(), "new.env")
+	if code, _, stderr := run(t, "c: 3\n", "--output-file", path, "--append", "--append-separator", "unused"); code != 0 || stderr != "" {
+		t.Fatalf("Run() on a missing file = %d; stderr: %s", code, stderr)
+	}
+	if data, err := os.ReadFile(path); err != nil || strings.Contains(string(data), "unused") || pairs(string(data)) != "C=3" {
+		t.Errorf("created file = %q, %v", data, err)
+	}
+
+	for _, args := range [][]string{
+		{"--append"},
+		{"--output-file", path, "--append", "--if-changed"},
+		{"--output-file", path, "--append", "--output-format", "json"},
+		{"--output-file", path, "--append-separator", "x"},
+	} {
+		if code, _, _ := run(t, "a: 1\n", args...); code != ExitUsage {
+			t.Errorf("Run(%q) = %d, want %d", args, code, ExitUsage)
+		}
+	}
+}
+
+func TestRun_Group(t *testing.T) {
+	input := "database:\n  host: localhost\n  port: 5432\napi:\n  key: abc\n"
+
+	code, out, stderr := run(t, input, "--group-headers")
+	want := "#\n\n# API\nAPI_KEY=abc\n\n# DATABASE\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\n"
+	if code != 0 || !strings.HasSuffix(out, want) {
+		t.Errorf("--group-headers = %d, %q, want suffix %q; stderr: %s", code, out, want, stderr)
+	}
+
+	code, out, _ = run(t, input, "--group")
+	want = "#\n\nAPI_KEY=abc\n\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\n"
+	if code != 0 || !strings.HasSuffix(out, want) {
+		t.Errorf("--group = %d, %q, want suffix %q", code, out, want)
+	}
+}
+
+func TestRun_PrintConfig(t *testing.T) {
+	code, out, stderr := run(t, "a: 1\n", "--print-config", "--kv-sep", ": ", "--dunder", "2", "--include", "A*,B*", "--template")
+	if code != 0 || pairs(out) != "A: " {
+		t.Fatalf("Run() = %d, %q; stderr: %s", code, out, stderr)
+	}
+	for _, want := range []string{
+		"Effective options:\n",
+		"  format                   \"\" (default)\n",
+		"  kv-sep                   \": \"\n",
+		"  dunder                   2\n",
+		"  include                  \"A*,B*\"\n",
+		"  prefix                   \"\" (default)\n",
+		"  template                 true\n",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr missing %q:\n%s", want, stderr)
+		}
+	}
+	// Options left alone are only listed if they are main options
+	if strings.Contains(stderr, "keep-comments") || strings.Contains(stderr, "print-config") {
+		t.Errorf("stderr lists unset or self options:\n%s", stderr)
+	}
+
+	if _, _, stderr := run(t, "a: 1\n"); stderr != "" {
+		t.Errorf("stderr without --print-config = %q", stderr)
+	}
+}
+
+func TestRun_Diff(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"old.yaml":  "host: localhost\nport: 5432\n",
+		"same.json": `{"host": "localhost", "port": 5432}`,
+		"new.json":  `{"host": "db", "debug": true}`,
+	})
+	old := filepath.Join(dir, "old.yaml")
+
+	if code, out, _ := run(t, "", "diff", old, filepath.Join(dir, "same.json")); code != ExitDiffSame || out != "" {
+		t.Errorf("diff of equal configs = %d, %q", code, out)
+	}
+
+	code, out, _ := run(t, "", "diff", old, filepath.Join(dir, "new.json"))
+	if want := "+DEBUG=true\n~HOST: localhost -> db\n-PORT\n"; code != ExitDiffChanged || out != want {
+		t.Errorf("diff = %d, %q, want %d, %q", code, out, ExitDiffChanged, want)
+	}
+
+	if code, _, _ := run(t, "", "diff", old); code != ExitDiffError {
+		t.Errorf("diff with one file = %d, want %d", code, ExitDiffError)
+	}
+}
+
+func TestRun_OutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.env")
+	args := []string{"--output-file", path, "--if-changed"}
+
+	code, out, stderr := run(t, "a: 1\n", args...)
+	if code != 0 || out != "" {
+		t.Fatalf("first Run() = %d, %q; stderr: %s", code, out, stderr)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pairs(string(data)) != "A=1" {
+		t.Errorf("output file = %q", data)
+	}
+
+	if code, _, stderr := run(t, "a: 1\n", args...); code != ExitUnchanged || !strings.Contains(stderr, "unchanged") {
+		t.Errorf("unchanged Run() = %d, stderr %q, want %d", code, stderr, ExitUnchanged)
+	}
+	if code, _, _ := run(t, "a: 2\n", args...); code != 0 {
+		t.Errorf("changed Run() = %d, want 0", code)
+	}
+
+	if code, _, stderr := run(t, "a: 1\n", "--if-changed"); code != ExitUsage || !strings.Contains(stderr, "--output-file") {
+		t.Errorf("--if-changed alone = %d, stderr %q", code, stderr)
+	}
+}
+
+// shoutingYAML is a YAML plugin override that upper-cases values
+type shoutingYAML struct {
+	plugin.BasePlugin
+}
+
+func (p shoutingYAML) Parse(r io.Reader) (map[string]string, error) {
+	env, err := yaml.New().Parse(r)
+	for k, v := range env {
+		env[k] = strings.ToUpper(v)
+	}
+	return env, err
+}
+
+func TestRun_OverrideDefaultPlugin(t *testing.T) {
+	plugins.Register(shoutingYAML{plugin.NewBasePlugin("yaml", "yml")})
+	t.Cleanup(func() { plugins.RegisterConstructor(func() plugin.Plugin { return yaml.New() }) })
+
+	for _, args := range [][]string{nil, {"--format", "yaml"}} {
+		code, out, stderr := run(t, "name: app\n", args...)
+		if code != ExitOK || pairs(out) != "NAME=APP" {
+			t.Errorf("Run(%q) = %d, %q, want the override; stderr: %s", args, code, pairs(out), stderr)
+		}
+	}
+}
+
+func TestRun_FormatDetectReport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.json": `{"a": 1}`,
+		"app.yaml": "b: 2\n",
+	})
+	jsonPath, yamlPath := filepath.Join(dir, "app.json"), filepath.Join(dir, "app.yaml")
+
+	tests := []struct {
+		name       string
+		input      string
+		args       []string
+		wantStderr string
+	}{
+		{"json on stdin", `{"a": 1}`, nil, "detected: json\n"},
+		{"yaml on stdin", "b: 2\n", nil, "detected: yaml\n"},
+		{"format on stdin", "b: 2\n", []string{"--format", "yaml"}, "detected: yaml\n"},
+		{"file argument", "", []string{jsonPath}, "detected: json (" + jsonPath + ")\n"},
+		{"file arguments", "", []string{jsonPath, yamlPath}, "detected: json (" + jsonPath + ")\ndetected: yaml (" + yamlPath + ")\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, want, _ := run(t, tt.input, tt.args...)
+			if code != ExitOK {
+				t.Fatalf("Run() without report = %d", code)
+			}
+
+			args := append([]string{"--format-detect-report"}, tt.args...)
+			code, out, stderr := run(t, tt.input, args...)
+			if code != ExitOK {
+				t.Fatalf("Run() = %d; stderr: %s", code, stderr)
+			}
+			if stderr != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantStderr)
+			}
+			if out != want {
+				t.Errorf("stdout = %q, want it unchanged: %q", out, want)
+			}
+		})
+	}
+}
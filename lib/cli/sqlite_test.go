@@ -0,0 +1,76 @@
+//go:build !nosqlite
+
+package cli
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugins/sqlite"
+)
+
+// sqliteFile creates a small SQLite config database and returns its path
+func sqliteFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.db")
+	db, err := sql.Open(sqlite.DriverName, path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE config (key TEXT, value TEXT); INSERT INTO config VALUES ('owner', 'Zoë')"); err != nil {
+		t.Fatalf("Failed to set up test data: %v", err)
+	}
+	return path
+}
+
+func TestRun_InputEncodingSQLite(t *testing.T) {
+	path := sqliteFile(t)
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		want  string
+	}{
+		{"file argument", "", []string{"--input-encoding", "latin1", path}, "OWNER=Zoë"},
+		{"detected on stdin", string(blob), []string{"--input-encoding", "latin1"}, "OWNER=Zoë"},
+		{"format on stdin", string(blob), []string{"--input-encoding", "utf-16le", "--format", "sqlite"}, "OWNER=Zoë"},
+		{"validate file", "", []string{"--input-encoding", "latin1", "--validate-only", path}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, stderr := run(t, tt.input, tt.args...)
+			if code != ExitOK || pairs(out) != tt.want {
+				t.Errorf("Run() = %d, %q, want %q; stderr: %s", code, pairs(out), tt.want, stderr)
+			}
+		})
+	}
+
+	code, _, stderr := run(t, string(blob), "--input-encoding", "latin1", "--format-chain", "sqlite,yaml")
+	if code != ExitUsage {
+		t.Errorf("chain with sqlite = %d, want %d; stderr: %s", code, ExitUsage, stderr)
+	}
+}
+
+func TestRun_QueryDoesNotLeak(t *testing.T) {
+	path := sqliteFile(t)
+
+	code, out, stderr := run(t, "", "--query", "SELECT 'custom', value FROM config", path)
+	if code != ExitOK || pairs(out) != "CUSTOM=Zoë" {
+		t.Fatalf("Run() with --query = %d, %q; stderr: %s", code, pairs(out), stderr)
+	}
+
+	// A later Run without --query reads the default table again
+	code, out, stderr = run(t, "", path)
+	if code != ExitOK || pairs(out) != "OWNER=Zoë" {
+		t.Errorf("Run() without --query = %d, %q, want %q; stderr: %s", code, pairs(out), "OWNER=Zoë", stderr)
+	}
+}
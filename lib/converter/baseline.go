@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+)
+
+// SetBaseline limits output to the keys whose value differs from baseline
+// or that baseline lacks, so the output patches a .env holding baseline.
+// Values are compared as written, with line breaks and backslashes escaped.
+// Keys of baseline missing from the output are listed as "# Removed: KEY"
+// comments after the pairs, except in keys-only, values-only and encoded
+// output; keys excluded by the filter patterns are not reported. nil
+// disables it.
+func (c *Converter) SetBaseline(baseline map[string]string) {
+	c.baseline = baseline
+}
+
+// ReadBaseline reads a .env file, such as earlier output, for SetBaseline.
+// Lines are split at the first key-value separator, and comment and blank
+// lines are skipped. An "export " prefix and a pair of matching quotes
+// around the value are removed; references are not expanded.
+func (c *Converter) ReadBaseline(r io.Reader) (map[string]string, error) {
+	lines, err := utils.ReadLines(utils.StripBOM(r))
+	if err != nil {
+		return nil, err
+	}
+
+	comment := c.commentLine("")
+	baseline := make(map[string]string)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, comment) {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), c.kvSep)
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY%svalue", i+1, c.kvSep)
+		}
+		baseline[key] = unquote(value)
+	}
+	return baseline, nil
+}
+
+// unquote removes a pair of matching single or double quotes around s
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// delta returns the keys, in order, whose values differ from the baseline
+// or are missing from it, along with the sorted baseline keys that are not
+// among keys. Without a baseline keys is returned as is.
+func (c *Converter) delta(keys []string, values map[string]string) ([]string, []string) {
+	if c.baseline == nil {
+		return keys, nil
+	}
+
+	changed := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if old, ok := c.baseline[k]; !ok || old != escapeNewlines(values[k]) {
+			changed = append(changed, k)
+		}
+	}
+
+	written := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		written[k] = true
+	}
+	var removed []string
+	for k := range c.baseline {
+		if written[k] {
+			continue
+		}
+		// Removed keys have no source key for original filters to match
+		if c.filter == nil || c.filter.original || c.filter.shouldInclude(k) {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// writeRemoved lists removed keys as comments unless the output is bare
+func (c *Converter) writeRemoved(w io.Writer, removed []string) error {
+	if c.bare() {
+		return nil
+	}
+	for _, k := range removed {
+		if err := c.writeComment(w, "Removed: "+k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_ReadBaseline(t *testing.T) {
+	input := "\xEF\xBB\xBF# This file was auto-generated by cfg2env\n#\n\n" +
+		"HOST=localhost\n" +
+		"export PORT=\"5432\"\n" +
+		"GREETING='hello # world'\n" +
+		"URL=http://x?a=b\n" +
+		"NOTE=line1\\nline2\n"
+
+	got, err := New(nil).ReadBaseline(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadBaseline() error = %v", err)
+	}
+	want := map[string]string{
+		"HOST":     "localhost",
+		"PORT":     "5432",
+		"GREETING": "hello # world",
+		"URL":      "http://x?a=b",
+		"NOTE":     `line1\nline2`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadBaseline() = %v, want %v", got, want)
+	}
+
+	// The converter's own separator and comment prefix apply
+	c := New(nil)
+	c.SetKVSeparator(": ")
+	c.SetCommentPrefix(";")
+	got, err = c.ReadBaseline(strings.NewReader("; header\nHOST: localhost\n"))
+	if err != nil {
+		t.Fatalf("ReadBaseline() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]string{"HOST": "localhost"}) {
+		t.Errorf("ReadBaseline() = %v", got)
+	}
+
+	if _, err := New(nil).ReadBaseline(strings.NewReader("HOST\n")); err == nil {
+		t.Error("ReadBaseline() error = nil for a line without a separator")
+	}
+}
+
+func TestConverter_Baseline(t *testing.T) {
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return map[string]string{
+				"same":    "1",
+				"changed": "new",
+				"added":   "3",
+				"note":    "line1\nline2",
+				"other":   "x",
+			}, nil
+		},
+	}
+	baseline := map[string]string{
+		"SAME":    "1",
+		"CHANGED": "old",
+		"NOTE":    `line1\nline2`,
+		"GONE":    "4",
+		"OTHER":   "x",
+		"SKIPPED": "5",
+	}
+
+	tests := []struct {
+		name  string
+		setup func(c *Converter)
+		want  string
+	}{
+		{
+			name: "changed and new keys, then removed",
+			want: "ADDED=3\nCHANGED=new\n# Removed: GONE\n# Removed: SKIPPED\n",
+		},
+		{
+			name:  "filtered keys are not removed",
+			setup: func(c *Converter) { c.SetFilterPatterns(nil, []string{"SKIPPED", "ADDED"}, GlobMatcher{}) },
+			want:  "CHANGED=new\n# Removed: GONE\n",
+		},
+		{
+			name:  "keys-only leaves out removed keys",
+			setup: func(c *Converter) { c.SetKeysOnly(true) },
+			want:  "ADDED\nCHANGED\n",
+		},
+		{
+			name:  "limit applies to the changes",
+			setup: func(c *Converter) { c.SetLimit(1) },
+			want:  "ADDED=3\n# Removed: GONE\n# Removed: SKIPPED\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(p)
+			c.SetBaseline(baseline)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			body := out.String()
+			if i := strings.Index(body, "\n\n"); i >= 0 {
+				body = body[i+2:]
+			}
+			if body != tt.want {
+				t.Errorf("Convert() = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_BaselineWriteMap(t *testing.T) {
+	c := New(nil)
+	c.SetBaseline(map[string]string{"A": "1", "B": "2"})
+
+	var out bytes.Buffer
+	if err := c.WriteMap(&out, map[string]string{"A": "1", "B": "3", "C": "4"}, "yaml"); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "\n\nB=3\nC=4\n") {
+		t.Errorf("WriteMap() = %q, want only B and C", out.String())
+	}
+
+	// Without changes only the header is written
+	c.SetBaseline(map[string]string{"A": "1"})
+	out.Reset()
+	if err := c.WriteMap(&out, map[string]string{"A": "1"}, "yaml"); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+	if strings.Contains(out.String(), "A=1") {
+		t.Errorf("WriteMap() = %q, want no pairs", out.String())
+	}
+}
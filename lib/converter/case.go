@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// CaseMode controls the case of output keys
+type CaseMode int
+
+const (
+	// CaseUpper uppercases every key (default)
+	CaseUpper CaseMode = iota
+
+	// CaseMixedPrefix uppercases only the prefix and keeps the rest of each
+	// key as written in the source, so prefix myapp and database.host give
+	// MYAPP_database_host
+	CaseMixedPrefix
+)
+
+// String returns the flag value for the case mode
+func (m CaseMode) String() string {
+	switch m {
+	case CaseMixedPrefix:
+		return "mixed-prefix"
+	default:
+		return "upper"
+	}
+}
+
+// ParseCaseMode converts a flag value into a CaseMode
+func ParseCaseMode(s string) (CaseMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "upper":
+		return CaseUpper, nil
+	case "mixed-prefix":
+		return CaseMixedPrefix, nil
+	default:
+		return CaseUpper, fmt.Errorf("unsupported case mode: %s (valid: upper, mixed-prefix)", s)
+	}
+}
+
+// SetCaseMode sets the case of output keys. With CaseMixedPrefix, keys and
+// patterns given in output form, such as filter patterns and extra pairs,
+// keep their case too, so set the mode before them; without a prefix, keys
+// are written as in the source. Flat formats such as dotenv then keep keys
+// differing only in case, such as db_host and DB_HOST, as separate keys;
+// YAML and JSON still flatten them to one key and warn.
+func (c *Converter) SetCaseMode(mode CaseMode) {
+	c.caseMode = mode
+}
+
+// prefixedKey prepends the prefix to the key of a parsed pair and applies
+// the case mode: the whole key is uppercased by default, and only the prefix
+// with CaseMixedPrefix, the rest taking its case from the pair's source
+func (c *Converter) prefixedKey(kv plugin.KV) string {
+	if c.caseMode != CaseMixedPrefix {
+		return strings.ToUpper(c.prefixKey(kv.Key))
+	}
+	key := sourceCase(kv.Key, kv.Source())
+	prefixed := c.prefixKey(key)
+	// prefixKey only adds to the front of key
+	n := len(prefixed) - len(key)
+	return strings.ToUpper(prefixed[:n]) + key
+}
+
+// sourceCase returns key with the case of the letters in source, the key or
+// dotted path it was flattened from, such as database.host for
+// DATABASE_HOST. Characters that differ other than by case, such as the dot
+// joining nested levels, are kept from key. A source that does not line up
+// with key, such as one whose array elements are keyed by a field, leaves
+// key unchanged.
+func sourceCase(key, source string) string {
+	k, s := []rune(key), []rune(source)
+	if len(k) != len(s) {
+		return key
+	}
+	for i, r := range s {
+		if unicode.ToUpper(r) == unicode.ToUpper(k[i]) {
+			k[i] = r
+		}
+	}
+	return string(k)
+}
+
+// outputCase applies the case mode to a key or pattern given in output form,
+// uppercasing it by default and keeping it as written with CaseMixedPrefix
+func (c *Converter) outputCase(key string) string {
+	if c.caseMode == CaseMixedPrefix {
+		return key
+	}
+	return strings.ToUpper(key)
+}
@@ -0,0 +1,158 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/dotenv"
+)
+
+func TestParseCaseMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    CaseMode
+		wantErr bool
+	}{
+		{"", CaseUpper, false},
+		{"upper", CaseUpper, false},
+		{" Mixed-Prefix ", CaseMixedPrefix, false},
+		{"lower", CaseUpper, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCaseMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCaseMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCaseMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_CaseMode(t *testing.T) {
+	data := map[string]string{
+		"database_host": "localhost",
+		"Api_Key":       "abc123",
+	}
+
+	tests := []struct {
+		name  string
+		setup func(c *Converter)
+		want  []string
+	}{
+		{
+			name:  "upper by default",
+			setup: func(c *Converter) { c.SetPrefix("myapp") },
+			want:  []string{"MYAPP_API_KEY=abc123", "MYAPP_DATABASE_HOST=localhost"},
+		},
+		{
+			name: "mixed prefix uppercases only the prefix",
+			setup: func(c *Converter) {
+				c.SetCaseMode(CaseMixedPrefix)
+				c.SetPrefix("myapp")
+			},
+			want: []string{"MYAPP_Api_Key=abc123", "MYAPP_database_host=localhost"},
+		},
+		{
+			name: "prefix separator is kept",
+			setup: func(c *Converter) {
+				c.SetCaseMode(CaseMixedPrefix)
+				c.SetPrefix("myapp")
+				c.SetPrefixSeparator("__")
+			},
+			want: []string{"MYAPP__Api_Key=abc123", "MYAPP__database_host=localhost"},
+		},
+		{
+			name:  "no prefix keeps source case",
+			setup: func(c *Converter) { c.SetCaseMode(CaseMixedPrefix) },
+			want:  []string{"Api_Key=abc123", "database_host=localhost"},
+		},
+		{
+			name: "patterns and extra pairs keep their case",
+			setup: func(c *Converter) {
+				c.SetCaseMode(CaseMixedPrefix)
+				c.SetPrefix("myapp")
+				c.SetFilterPatterns([]string{"MYAPP_database_*"}, nil, GlobMatcher{})
+				c.SetExtraPairs([]plugin.KV{{Key: "MYAPP_database_port", Value: "5432"}})
+			},
+			want: []string{"MYAPP_database_host=localhost", "MYAPP_database_port=5432"},
+		},
+		{
+			name: "required keys ignore case",
+			setup: func(c *Converter) {
+				c.SetCaseMode(CaseMixedPrefix)
+				c.SetPrefix("myapp")
+				c.SetRequired([]string{"MYAPP_DATABASE_HOST"})
+			},
+			want: []string{"MYAPP_Api_Key=abc123", "MYAPP_database_host=localhost"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			tt.setup(c)
+			var buf bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			got := outputPairs(buf.String())
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Convert() pairs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_CaseModeCaseOnlyKeys(t *testing.T) {
+	// Flattening folds case, so YAML keys differing only in case still
+	// collide, while dotenv keys stay separate
+	var warnings bytes.Buffer
+	c := New(yamlPlugin())
+	c.SetCaseMode(CaseMixedPrefix)
+	c.SetPrefix("x")
+	c.SetWarningWriter(&warnings)
+	env, err := c.ConvertMap(strings.NewReader("db_host: a\nDB_HOST: b\n"))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if len(env) != 1 {
+		t.Errorf("ConvertMap() = %v, want one key", env)
+	}
+	if !strings.Contains(warnings.String(), "produced by more than one path") {
+		t.Errorf("warnings = %q, want a collision warning", warnings.String())
+	}
+
+	c = New(dotenv.New())
+	c.SetCaseMode(CaseMixedPrefix)
+	c.SetPrefix("x")
+	env, err = c.ConvertMap(strings.NewReader("db_host=a\nDB_HOST=b\n"))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if env["X_db_host"] != "a" || env["X_DB_HOST"] != "b" {
+		t.Errorf("ConvertMap() = %v, want X_db_host and X_DB_HOST", env)
+	}
+}
+
+func TestSourceCase(t *testing.T) {
+	tests := []struct {
+		key, source, want string
+	}{
+		{"DATABASE_HOST", "database.host", "database_host"},
+		{"API_KEY", "Api_Key", "Api_Key"},
+		{"SERVERS_DB_HOST", "servers.0.host", "SERVERS_DB_HOST"},
+		{"LIST_0", "list.0", "list_0"},
+	}
+
+	for _, tt := range tests {
+		if got := sourceCase(tt.key, tt.source); got != tt.want {
+			t.Errorf("sourceCase(%q, %q) = %q, want %q", tt.key, tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// ConflictPolicy controls how source keys normalizing to the same output
+// key, such as db_host and DB_HOST or a literal db_host next to a nested
+// db.host, are resolved
+type ConflictPolicy int
+
+const (
+	// ConflictError rejects the input with a DuplicateKeyError (default)
+	ConflictError ConflictPolicy = iota
+
+	// ConflictFirst keeps the value parsed first
+	ConflictFirst
+
+	// ConflictLast keeps the value parsed last
+	ConflictLast
+
+	// ConflictLongest keeps the longest value, the first of them on a tie
+	ConflictLongest
+)
+
+// String returns the flag value for the conflict policy
+func (p ConflictPolicy) String() string {
+	switch p {
+	case ConflictFirst:
+		return "first"
+	case ConflictLast:
+		return "last"
+	case ConflictLongest:
+		return "longest-value"
+	default:
+		return "error"
+	}
+}
+
+// ParseConflictPolicy converts a flag value into a ConflictPolicy
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "error":
+		return ConflictError, nil
+	case "first":
+		return ConflictFirst, nil
+	case "last":
+		return ConflictLast, nil
+	case "longest-value":
+		return ConflictLongest, nil
+	default:
+		return ConflictError, fmt.Errorf("unsupported conflict policy: %s (valid: first, last, longest-value, error)", s)
+	}
+}
+
+// SetConflictPolicy sets how duplicate keys are resolved. First and last
+// follow source order for plugins that preserve it and sorted source keys
+// otherwise, so the result never depends on map iteration order.
+//
+// Once a policy is set, it also resolves keys a plugin implementing
+// plugin.CollisionReporter flattens from more than one path, such as a
+// literal database_host next to a nested database.host, or Api_Key next to
+// API_KEY. Each path is a candidate, named by its dotted source path and
+// ordered by flatten order or by path. Without a policy set, such keys keep
+// the value the plugin kept and are reported as warnings.
+func (c *Converter) SetConflictPolicy(policy ConflictPolicy) {
+	c.conflict = policy
+	c.resolveCollisions = true
+}
+
+// candidate is one source key and value producing an output key
+type candidate struct {
+	key   string
+	value string
+
+	// source is the key as written in the source, or its dotted path for
+	// nested formats; see plugin.KV.Source
+	source string
+}
+
+// candidatesOf returns the candidates kv contributes to its output key: its
+// own value, or one per path for a key in collisions
+func candidatesOf(kv plugin.KV, collisions map[string][]plugin.KV) []candidate {
+	pairs, ok := collisions[kv.Key]
+	if !ok {
+		return []candidate{{key: kv.Key, value: kv.Value, source: kv.Source()}}
+	}
+	candidates := make([]candidate, len(pairs))
+	for i, p := range pairs {
+		candidates[i] = candidate{key: p.Source(), value: p.Value, source: p.Source()}
+	}
+	return candidates
+}
+
+// resolve picks the value of one of candidates, which are in parse order,
+// according to the conflict policy. Unless ordered is set the parse order
+// is arbitrary, so candidates are first sorted by source key and value.
+func (c *Converter) resolve(candidates []candidate, ordered bool) string {
+	if len(candidates) == 1 {
+		return candidates[0].value
+	}
+	if !ordered {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].key != candidates[j].key {
+				return candidates[i].key < candidates[j].key
+			}
+			return candidates[i].value < candidates[j].value
+		})
+	}
+
+	switch c.conflict {
+	case ConflictLast:
+		return candidates[len(candidates)-1].value
+	case ConflictLongest:
+		best := candidates[0].value
+		for _, cand := range candidates[1:] {
+			if len(cand.value) > len(best) {
+				best = cand.value
+			}
+		}
+		return best
+	default:
+		return candidates[0].value
+	}
+}
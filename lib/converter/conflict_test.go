@@ -0,0 +1,194 @@
+package converter
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ConflictPolicy
+		wantErr bool
+	}{
+		{"", ConflictError, false},
+		{"error", ConflictError, false},
+		{"first", ConflictFirst, false},
+		{"LAST", ConflictLast, false},
+		{" longest-value ", ConflictLongest, false},
+		{"longest", ConflictError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseConflictPolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConflictPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseConflictPolicy() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr && got.String() != strings.ToLower(strings.TrimSpace(tt.input)) && tt.input != "" {
+				t.Errorf("String() = %q, want %q", got.String(), tt.input)
+			}
+		})
+	}
+}
+
+func TestConverter_ConflictPolicy(t *testing.T) {
+	// Uppercase sorts first in byte order, so sorted source keys run
+	// DB_HOST, Db_Host, db_host, unlike the source order
+	pairs := []plugin.KV{
+		{Key: "db_host", Value: "first"},
+		{Key: "DB_HOST", Value: "longest"},
+		{Key: "Db_Host", Value: "last"},
+		{Key: "port", Value: "5432"},
+	}
+
+	tests := []struct {
+		name    string
+		policy  ConflictPolicy
+		sort    SortMode
+		want    string
+		wantErr bool
+	}{
+		{name: "error", policy: ConflictError, sort: SortNone, wantErr: true},
+		{name: "first in source order", policy: ConflictFirst, sort: SortNone, want: "first"},
+		{name: "last in source order", policy: ConflictLast, sort: SortNone, want: "last"},
+		{name: "longest in source order", policy: ConflictLongest, sort: SortNone, want: "longest"},
+		{name: "first in key order", policy: ConflictFirst, sort: SortKey, want: "longest"},
+		{name: "last in key order", policy: ConflictLast, sort: SortKey, want: "first"},
+		{name: "longest in key order", policy: ConflictLongest, sort: SortKey, want: "longest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &orderedPlugin{BasePlugin: plugin.NewBasePlugin("ordered"), pairs: pairs}
+			c := New(p)
+			c.SetSort(tt.sort)
+			c.SetConflictPolicy(tt.policy)
+
+			// Map order varies between runs, so repeat to catch any dependence
+			for i := 0; i < 20; i++ {
+				env, err := c.ConvertMap(strings.NewReader(""))
+				if tt.wantErr {
+					var dup *DuplicateKeyError
+					if !errors.As(err, &dup) {
+						t.Fatalf("ConvertMap() error = %v, want DuplicateKeyError", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ConvertMap() error = %v", err)
+				}
+				if env["DB_HOST"] != tt.want || env["PORT"] != "5432" {
+					t.Fatalf("ConvertMap() = %v, want DB_HOST=%s", env, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestConverter_ConflictPolicyTies(t *testing.T) {
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return map[string]string{"a_b": "xx", "A_B": "yy", "a__b": " z  "}, nil
+		},
+	}
+
+	// Equal lengths keep the first in key order, and values are compared
+	// after trimming
+	c := New(p)
+	c.SetDunderCollapse(true)
+	c.SetTrimValues(true)
+	c.SetConflictPolicy(ConflictLongest)
+	for i := 0; i < 20; i++ {
+		env, err := c.ConvertMap(strings.NewReader(""))
+		if err != nil {
+			t.Fatalf("ConvertMap() error = %v", err)
+		}
+		if env["A_B"] != "yy" {
+			t.Fatalf("ConvertMap() A_B = %q, want yy", env["A_B"])
+		}
+	}
+}
+
+func TestConverter_ConflictPolicyCollisions(t *testing.T) {
+	// Paths sort database.host before database_host, which is also the
+	// order they are flattened in
+	yamlInput := "database_host: literal\ndatabase:\n  host: nested\n"
+	jsonInput := `{"Api_Key": "a", "API_KEY": "bb", "port": 1}`
+	streamed := `[{"name": "db", "host": "a"}, {"name": "db", "host": "bb"}]`
+
+	tests := []struct {
+		name    string
+		plugin  func() plugin.Plugin
+		input   string
+		key     string
+		policy  ConflictPolicy
+		want    string
+		wantDup []string
+	}{
+		{name: "yaml first", plugin: yamlPlugin, input: yamlInput, key: "DATABASE_HOST", policy: ConflictFirst, want: "nested"},
+		{name: "yaml last", plugin: yamlPlugin, input: yamlInput, key: "DATABASE_HOST", policy: ConflictLast, want: "literal"},
+		{name: "yaml longest", plugin: yamlPlugin, input: yamlInput, key: "DATABASE_HOST", policy: ConflictLongest, want: "literal"},
+		{name: "yaml error", plugin: yamlPlugin, input: yamlInput, key: "DATABASE_HOST", policy: ConflictError, wantDup: []string{"database.host", "database_host"}},
+		{name: "json first", plugin: jsonPlugin, input: jsonInput, key: "API_KEY", policy: ConflictFirst, want: "bb"},
+		{name: "json last", plugin: jsonPlugin, input: jsonInput, key: "API_KEY", policy: ConflictLast, want: "a"},
+		{name: "json error", plugin: jsonPlugin, input: jsonInput, key: "API_KEY", policy: ConflictError, wantDup: []string{"API_KEY", "Api_Key"}},
+		{name: "json stream first", plugin: keyedJSONPlugin, input: streamed, key: "DB_HOST", policy: ConflictFirst, want: "a"},
+		{name: "json stream error", plugin: keyedJSONPlugin, input: streamed, key: "DB_HOST", policy: ConflictError, wantDup: []string{"db.host", "db.host"}},
+	}
+
+	for _, tt := range tests {
+		for _, sortMode := range []SortMode{SortKey, SortNone} {
+			t.Run(tt.name+"/"+sortMode.String(), func(t *testing.T) {
+				var warnings strings.Builder
+				c := New(tt.plugin())
+				c.SetSort(sortMode)
+				c.SetConflictPolicy(tt.policy)
+				c.SetWarningWriter(&warnings)
+
+				env, err := c.ConvertMap(strings.NewReader(tt.input))
+				if tt.wantDup != nil {
+					var dup *DuplicateKeyError
+					if !errors.As(err, &dup) {
+						t.Fatalf("ConvertMap() error = %v, want DuplicateKeyError", err)
+					}
+					if got := dup.Keys[tt.key]; strings.Join(got, ",") != strings.Join(tt.wantDup, ",") {
+						t.Errorf("duplicates of %s = %v, want %v", tt.key, got, tt.wantDup)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ConvertMap() error = %v", err)
+				}
+				if env[tt.key] != tt.want {
+					t.Errorf("ConvertMap() %s = %q, want %q", tt.key, env[tt.key], tt.want)
+				}
+				if warnings.Len() != 0 {
+					t.Errorf("warnings = %q, want none for a resolved collision", warnings.String())
+				}
+			})
+		}
+	}
+}
+
+func yamlPlugin() plugin.Plugin { return yaml.New() }
+
+func jsonPlugin() plugin.Plugin { return json.New() }
+
+// keyedJSONPlugin keys array elements by name, so that a top-level array
+// streams elements producing the same keys
+func keyedJSONPlugin() plugin.Plugin {
+	p := json.New()
+	p.SetArrayKeyField("name")
+	return p
+}
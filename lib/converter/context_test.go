@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// contextPlugin implements plugin.ContextPlugin, calling onParse with the
+// context it is given
+type contextPlugin struct {
+	plugin.BasePlugin
+	onParse func(ctx context.Context) error
+}
+
+func (p *contextPlugin) Parse(r io.Reader) (map[string]string, error) {
+	return p.ParseContext(context.Background(), r)
+}
+
+func (p *contextPlugin) ParseContext(ctx context.Context, r io.Reader) (map[string]string, error) {
+	if err := p.onParse(ctx); err != nil {
+		return nil, err
+	}
+	return map[string]string{"key": "value"}, nil
+}
+
+func TestConverter_ConvertContext(t *testing.T) {
+	var gotCtx context.Context
+	p := &contextPlugin{
+		BasePlugin: plugin.NewBasePlugin("ctx"),
+		onParse: func(ctx context.Context) error {
+			gotCtx = ctx
+			return nil
+		},
+	}
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "marker")
+
+	var out bytes.Buffer
+	if err := New(p).ConvertContext(ctx, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("ConvertContext() error = %v", err)
+	}
+	if gotCtx == nil || gotCtx.Value(ctxKey{}) != "marker" {
+		t.Error("ConvertContext() did not pass its context to ParseContext")
+	}
+	if !strings.HasSuffix(out.String(), "KEY=value\n") {
+		t.Errorf("ConvertContext() = %q, want KEY=value", out.String())
+	}
+}
+
+func TestConverter_ConvertContext_Canceled(t *testing.T) {
+	t.Run("before parse", func(t *testing.T) {
+		parsed := false
+		p := &contextPlugin{
+			BasePlugin: plugin.NewBasePlugin("ctx"),
+			onParse: func(ctx context.Context) error {
+				parsed = true
+				return nil
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var out bytes.Buffer
+		err := New(p).ConvertContext(ctx, strings.NewReader(""), &out)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("ConvertContext() error = %v, want context.Canceled", err)
+		}
+		if parsed {
+			t.Error("ConvertContext() parsed input after the context was cancelled")
+		}
+		if out.Len() != 0 {
+			t.Errorf("ConvertContext() wrote %q, want no output", out.String())
+		}
+	})
+
+	t.Run("during parse", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &contextPlugin{
+			BasePlugin: plugin.NewBasePlugin("ctx"),
+			onParse: func(context.Context) error {
+				// Cancel after parsing has started but let it finish
+				cancel()
+				return nil
+			},
+		}
+
+		var out bytes.Buffer
+		err := New(p).ConvertContext(ctx, strings.NewReader(""), &out)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("ConvertContext() error = %v, want context.Canceled", err)
+		}
+		if strings.Contains(out.String(), "KEY=") {
+			t.Errorf("ConvertContext() wrote pairs after cancellation: %q", out.String())
+		}
+	})
+
+	t.Run("plugin error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		p := &contextPlugin{
+			BasePlugin: plugin.NewBasePlugin("ctx"),
+			onParse: func(context.Context) error {
+				cancel()
+				return context.Canceled
+			},
+		}
+
+		err := New(p).ConvertContext(ctx, strings.NewReader(""), io.Discard)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || !errors.Is(err, context.Canceled) {
+			t.Errorf("ConvertContext() error = %v, want ParseError wrapping context.Canceled", err)
+		}
+	})
+}
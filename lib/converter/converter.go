@@ -1,36 +1,106 @@
 package converter
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/lib/version"
 	"github.com/handaber/cfg2env/plugin"
 )
 
-// Converter handles the conversion of configuration files to .env format
+// Converter handles the conversion of configuration files to .env format.
+//
+// Once configured, a Converter is safe for concurrent use: conversions keep
+// their scratch state in locals and never modify the converter. The Set
+// methods are not safe for concurrent use and must be called before the
+// converter is shared. Parses through plugins implementing plugin.Warner or
+// plugin.PathReporter are serialized, since their warnings and paths
+// describe the most recent Parse. The warning writer must be safe for
+// concurrent writes.
 type Converter struct {
 	plugin  plugin.Plugin
 	version string
 	dunder  int
 	filter  *filter
+	sort    SortMode
+
+	collapse          bool
+	unsorted          bool
+	naturalSort       bool
+	keepComments      bool
+	groupBreaks       bool
+	groupHeaders      bool
+	template          *template
+	redact            *redact
+	prefix            string
+	prefixSep         string
+	sanitizeKeys      bool
+	reserved          map[string]bool
+	reservedPrefix    string
+	strictKeys        bool
+	conflict          ConflictPolicy
+	resolveCollisions bool
+	strictNL          bool
+	pruneEmpty        bool
+	trimValues        bool
+	maxValueLen       int
+	failOversize      bool
+	errorOnEmpty      bool
+	errorOnAmbig      bool
+	kvSep             string
+	cmtPrefix         string
+	noFinalNL         bool
+	bufSize           int
+	contentHash       bool
+	keysOnly          bool
+	valuesOnly        bool
+	limit             int
+	maxInput          int64
+	required          []string
+	extra             []plugin.KV
+	unset             []string
+	caseMode          CaseMode
+	schema            []SchemaRule
+	baseline          map[string]string
+	encoder           Encoder
+	warnings          io.Writer
+
+	noHeader      bool
+	commentHeader bool
+	timestamp     time.Time
+
+	// parseMu serializes parses through plugins that keep per-parse state
+	parseMu sync.Mutex
 }
 
 // New creates a new Converter with the given plugin
 func New(p plugin.Plugin) *Converter {
 	return &Converter{
 		plugin:  p,
-		version: "dev", // This will be overridden by the version from main
+		version: version.Version(),
 		dunder:  0,
+		kvSep:   "=",
 	}
 }
 
-// SetVersion sets the version string for the converter
+// SetVersion sets the version string written in the output header
 func (c *Converter) SetVersion(version string) {
 	c.version = version
 }
 
+// Version returns the version string written in the output header. It
+// defaults to version.Version().
+func (c *Converter) Version() string {
+	return c.version
+}
+
 // SetDunder sets the number of underscores to remove from consecutive sequences
 func (c *Converter) SetDunder(n int) {
 	if n > 0 {
@@ -38,12 +108,163 @@ func (c *Converter) SetDunder(n int) {
 	}
 }
 
-// processKey processes the key according to dunder rules
+// SetDunderCollapse controls whether every run of two or more underscores
+// is collapsed to a single underscore, so A__B___C becomes A_B_C. Single
+// underscores are kept. Collapsing takes precedence over SetDunder.
+func (c *Converter) SetDunderCollapse(collapse bool) {
+	c.collapse = collapse
+}
+
+// SetKeepComments controls whether source comments are written above their keys
+func (c *Converter) SetKeepComments(keep bool) {
+	c.keepComments = keep
+}
+
+// SetPruneEmpty controls whether keys with empty values are omitted. This
+// covers nulls, empty strings and empty maps or arrays. Values blanked by
+// template mode are not pruned.
+func (c *Converter) SetPruneEmpty(prune bool) {
+	c.pruneEmpty = prune
+}
+
+// SetTrimValues controls whether leading and trailing whitespace is
+// removed from each value. Trimming happens before pruning and required key
+// checks, so a value of only whitespace counts as empty.
+func (c *Converter) SetTrimValues(trim bool) {
+	c.trimValues = trim
+}
+
+// SetKVSeparator sets the delimiter written between each key and its value,
+// such as ": " or " = ". An empty sep restores the default "=".
+func (c *Converter) SetKVSeparator(sep string) {
+	if sep == "" {
+		sep = "="
+	}
+	c.kvSep = sep
+}
+
+// SetCommentPrefix sets the marker that starts comment lines in the header
+// and those written by SetKeepComments, such as ";" for consumers that do
+// not treat # as a comment. An empty prefix restores the default "#".
+func (c *Converter) SetCommentPrefix(prefix string) {
+	if prefix == "" {
+		prefix = "#"
+	}
+	c.cmtPrefix = prefix
+}
+
+// SetHeader controls whether output starts with the header comment naming
+// the version and source format, such as when appending to a file that
+// already has one. It is written by default; without it, SetContentHash has
+// nowhere to record the hash.
+func (c *Converter) SetHeader(header bool) {
+	c.noHeader = !header
+}
+
+// SetCommentHeader controls whether the header records the generation time
+// below the version and source format. A zero timestamp uses the time of
+// each conversion.
+func (c *Converter) SetCommentHeader(enabled bool, timestamp time.Time) {
+	c.commentHeader = enabled
+	c.timestamp = timestamp
+}
+
+// SetLimit stops output after the first n keys in output order. Zero or a
+// negative n writes every key.
+func (c *Converter) SetLimit(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c.limit = n
+}
+
+// SetMaxInputBytes fails conversions whose input is longer than n bytes
+// with an error wrapping utils.ErrInputTooLarge. Zero or a negative n
+// allows input of any size.
+func (c *Converter) SetMaxInputBytes(n int64) {
+	c.maxInput = n
+}
+
+// SetFinalNewline controls whether the last line of output ends with a
+// newline. It is written by default.
+func (c *Converter) SetFinalNewline(final bool) {
+	c.noFinalNL = !final
+}
+
+// SetBufferSize sets the size in bytes of the buffer that output is
+// collected in before it is written, so a slow or network writer sees a few
+// large writes rather than one per key. The buffer is flushed when Convert
+// returns, and an error from the final flush is returned as a WriteError.
+// Zero or a negative n restores the default of 4096 bytes.
+func (c *Converter) SetBufferSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c.bufSize = n
+}
+
+// SetKeysOnly controls whether output lists only the keys, one per line,
+// without values, the header or comments. Keys are filtered and ordered as
+// usual, which makes key sets easy to compare across environments.
+func (c *Converter) SetKeysOnly(keysOnly bool) {
+	c.keysOnly = keysOnly
+}
+
+// SetValuesOnly controls whether output lists only the values, one per
+// line, without keys, the header or comments. Line breaks in values are
+// escaped as usual. Keys-only mode takes precedence.
+func (c *Converter) SetValuesOnly(valuesOnly bool) {
+	c.valuesOnly = valuesOnly
+}
+
+// bare reports whether output is a plain list of keys or values
+func (c *Converter) bare() bool {
+	return c.keysOnly || c.valuesOnly
+}
+
+// SetWarningWriter sets where plugin warnings are written. Warnings are
+// discarded when w is nil.
+func (c *Converter) SetWarningWriter(w io.Writer) {
+	c.warnings = w
+}
+
+// Reset clears state left over from the previous conversion so that c can
+// be reused for unrelated inputs. Every option set on c is preserved: the
+// plugin, version, dunder, filters, sort, template, prefix, key checks,
+// separator, limits, header settings and warning writer. The converter
+// itself keeps no per-run state; Reset discards the plugin's warnings and
+// any other state of plugins implementing plugin.Resetter.
+func (c *Converter) Reset() {
+	c.parseMu.Lock()
+	defer c.parseMu.Unlock()
+
+	if r, ok := c.plugin.(plugin.Resetter); ok {
+		r.Reset()
+	}
+}
+
+// processKey processes the key according to dunder rules. The rules see
+// the flattened key, so the single underscores joining nested levels and
+// array indices count like any other. A leading prefix and its separator
+// are left alone, so a separator such as "__" is written as set. A key made
+// only of underscores is returned unchanged rather than reduced to nothing.
 func (c *Converter) processKey(key string) string {
-	if c.dunder == 0 {
+	if c.dunder == 0 && !c.collapse {
+		return key
+	}
+	if pfx := c.outputPrefix(); pfx != "" && strings.HasPrefix(key, pfx) {
+		return pfx + c.processUnderscores(key[len(pfx):])
+	}
+	if c.prefix != "" && key == strings.ToUpper(c.prefix) {
+		// The key of a root value is the prefix itself
 		return key
 	}
+	return c.processUnderscores(key)
+}
 
+// processUnderscores applies the dunder rules to every run of underscores
+// in key
+func (c *Converter) processUnderscores(key string) string {
 	var result strings.Builder
 	underscoreCount := 0
 
@@ -52,11 +273,7 @@ func (c *Converter) processKey(key string) string {
 			underscoreCount++
 		} else {
 			if underscoreCount > 0 {
-				// If we have consecutive underscores, remove up to dunder count
-				remaining := underscoreCount - c.dunder
-				if remaining > 0 {
-					result.WriteString(strings.Repeat("_", remaining))
-				}
+				result.WriteString(strings.Repeat("_", c.keptUnderscores(underscoreCount)))
 				underscoreCount = 0
 			}
 			result.WriteRune(char)
@@ -65,34 +282,292 @@ func (c *Converter) processKey(key string) string {
 
 	// Handle trailing underscores
 	if underscoreCount > 0 {
-		remaining := underscoreCount - c.dunder
-		if remaining > 0 {
-			result.WriteString(strings.Repeat("_", remaining))
-		}
+		result.WriteString(strings.Repeat("_", c.keptUnderscores(underscoreCount)))
 	}
 
+	if result.Len() == 0 {
+		return key
+	}
 	return result.String()
 }
 
-func (c *Converter) writeHeader(w io.Writer) error {
+// keptUnderscores returns how many of a run of n consecutive underscores
+// are kept by the dunder rules
+func (c *Converter) keptUnderscores(n int) int {
+	if c.collapse {
+		return 1
+	}
+	// Remove up to dunder count
+	if n > c.dunder {
+		return n - c.dunder
+	}
+	return 0
+}
+
+// writeHeader writes the header comment, recording hash if it is not empty
+func (c *Converter) writeHeader(w io.Writer, pluginName, hash string) error {
+	if c.bare() || c.noHeader {
+		return nil
+	}
+
 	header := []string{
-		"# This file was auto-generated by cfg2env",
-		fmt.Sprintf("# Version: %s", c.version),
-		fmt.Sprintf("# Plugin: %s", c.plugin.Name()),
-		"#",
-		"",
+		"This file was auto-generated by cfg2env",
+		fmt.Sprintf("Version: %s", c.version),
+		fmt.Sprintf("Plugin: %s", pluginName),
+	}
+	if hash != "" {
+		header = append(header, contentHashLabel+hash)
+	}
+	if c.commentHeader {
+		ts := c.timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		header = append(header, fmt.Sprintf("Generated at: %s", ts.UTC().Format(time.RFC3339)))
 	}
+	header = append(header, "")
 
-	for _, line := range header {
-		if _, err := io.WriteString(w, line+"\n"); err != nil {
-			return fmt.Errorf("writing header error: %w", err)
+	if err := c.writeComment(w, strings.Join(header, "\n")); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
+
+// commentLine returns text as a comment line, without the trailing newline
+func (c *Converter) commentLine(text string) string {
+	prefix := c.cmtPrefix
+	if prefix == "" {
+		prefix = "#"
+	}
+	if text == "" {
+		return prefix
+	}
+	return prefix + " " + text
+}
+
+// writeComment writes each line of comment as a comment line
+func (c *Converter) writeComment(w io.Writer, comment string) error {
+	for _, line := range strings.Split(comment, "\n") {
+		if _, err := io.WriteString(w, c.commentLine(line)+"\n"); err != nil {
+			return &WriteError{Err: err}
 		}
 	}
 	return nil
 }
 
-// Convert reads from r and writes the converted output to w
+// parsed holds the result of running the plugin
+type parsed struct {
+	pairs    []plugin.KV
+	warnings []string
+
+	// collisions maps each key the plugin produced from more than one path
+	// to the pairs produced for it, when the conflict policy resolves them
+	collisions map[string][]plugin.KV
+}
+
+// parse runs the plugin and returns its pairs along with any warnings it
+// reported. Plugins implementing plugin.Warner, plugin.PathReporter or
+// plugin.CollisionReporter are run one at a time so that their warnings,
+// paths and collisions belong to this parse. Warnings about collisions the
+// conflict policy resolves are dropped.
+func (c *Converter) parse(ctx context.Context, r io.Reader) (*parsed, error) {
+	wr, warns := c.plugin.(plugin.Warner)
+	pr, reports := c.plugin.(plugin.PathReporter)
+	cr, collides := c.plugin.(plugin.CollisionReporter)
+	collides = collides && c.resolveCollisions
+	if !warns && !reports && !collides {
+		pairs, _, err := c.parsePairs(ctx, r)
+		return &parsed{pairs: pairs}, err
+	}
+
+	c.parseMu.Lock()
+	defer c.parseMu.Unlock()
+
+	pairs, repeats, err := c.parsePairs(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	res := &parsed{pairs: pairs}
+	if reports {
+		paths := pr.Paths()
+		for i := range pairs {
+			pairs[i].Path = paths[pairs[i].Key]
+		}
+		for _, emitted := range repeats {
+			for i := range emitted {
+				emitted[i].Path = paths[emitted[i].Key]
+			}
+		}
+	}
+	if collides {
+		res.collisions = mergeCollisions(cr.Collisions(), repeats)
+	}
+	if warns {
+		for _, msg := range wr.Warnings() {
+			if !res.collided(msg) {
+				res.warnings = append(res.warnings, msg)
+			}
+		}
+	}
+	return res, nil
+}
+
+// collided reports whether msg warns about one of the collisions
+func (p *parsed) collided(msg string) bool {
+	for k := range p.collisions {
+		if msg == utils.CollisionWarning(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeCollisions returns the pairs flattened to each key produced by more
+// than one path, given the plugin's collisions within a document or
+// element and the keys a stream emitted more than once. The last emission
+// of a repeated key contributes its own collisions, if any, in place of its
+// value.
+func mergeCollisions(flattened map[string][]plugin.KV, repeats map[string][]plugin.KV) map[string][]plugin.KV {
+	if len(repeats) == 0 {
+		return flattened
+	}
+	merged := make(map[string][]plugin.KV, len(flattened)+len(repeats))
+	for k, pairs := range flattened {
+		merged[k] = pairs
+	}
+	for k, emitted := range repeats {
+		if pairs, ok := flattened[k]; ok {
+			emitted = append(emitted[:len(emitted)-1:len(emitted)-1], pairs...)
+		}
+		merged[k] = emitted
+	}
+	return merged
+}
+
+// parsePairs runs the plugin, using its ordered output when the sort mode
+// keeps parse order or comments are requested. Plugins implementing
+// plugin.StreamPlugin hand over their pairs while reading, so the decoded
+// document is never held in full; the keys they emit more than once are
+// returned with every pair emitted for them. ctx is passed to plugins
+// implementing plugin.ContextPlugin.
+func (c *Converter) parsePairs(ctx context.Context, r io.Reader) ([]plugin.KV, map[string][]plugin.KV, error) {
+	if !c.parsesInOrder() {
+		pairs, err := c.parseMap(ctx, r)
+		return pairs, nil, err
+	}
+	if sp, ok := c.plugin.(plugin.StreamPlugin); ok {
+		return collectPairs(ctx, sp, r)
+	}
+	pairs, err := c.plugin.(plugin.OrderedPlugin).ParseOrdered(r)
+	return pairs, nil, err
+}
+
+// parsesInOrder reports whether parsePairs returns pairs in source order:
+// the sort mode keeps parse order or comments are requested, and the plugin
+// can stream or order its pairs
+func (c *Converter) parsesInOrder() bool {
+	if c.sort != SortNone && !c.keepComments {
+		return false
+	}
+	switch c.plugin.(type) {
+	case plugin.StreamPlugin, plugin.OrderedPlugin:
+		return true
+	}
+	return false
+}
+
+// parseMap runs the plugin's unordered parse and returns its pairs in map
+// order
+func (c *Converter) parseMap(ctx context.Context, r io.Reader) ([]plugin.KV, error) {
+	var env map[string]string
+	var err error
+	if cp, ok := c.plugin.(plugin.ContextPlugin); ok {
+		env, err = cp.ParseContext(ctx, r)
+	} else {
+		env, err = c.plugin.Parse(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]plugin.KV, 0, len(env))
+	for k, v := range env {
+		pairs = append(pairs, plugin.KV{Key: k, Value: v})
+	}
+	return pairs, nil
+}
+
+// collectPairs gathers the pairs sp emits while reading r, stopping with
+// ctx.Err() once ctx is done. A repeated key keeps its first position and
+// takes the last value, and is returned with every pair emitted for it.
+func collectPairs(ctx context.Context, sp plugin.StreamPlugin, r io.Reader) ([]plugin.KV, map[string][]plugin.KV, error) {
+	var pairs []plugin.KV
+	var repeats map[string][]plugin.KV
+	index := make(map[string]int)
+	err := sp.ParseStream(r, func(kv plugin.KV) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i, ok := index[kv.Key]; ok {
+			if repeats == nil {
+				repeats = make(map[string][]plugin.KV)
+			}
+			if _, ok := repeats[kv.Key]; !ok {
+				repeats[kv.Key] = []plugin.KV{pairs[i]}
+			}
+			repeats[kv.Key] = append(repeats[kv.Key], kv)
+			pairs[i] = kv
+			return nil
+		}
+		index[kv.Key] = len(pairs)
+		pairs = append(pairs, kv)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pairs, repeats, nil
+}
+
+// reportWarnings writes plugin warnings, or returns them as an error when
+// strict keys are enabled
+func (c *Converter) reportWarnings(warnings []string) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	if c.strictKeys {
+		return &ValidationError{Err: fmt.Errorf("key collisions found: %s", strings.Join(warnings, "; "))}
+	}
+	if c.warnings != nil {
+		for _, msg := range warnings {
+			if _, err := fmt.Fprintf(c.warnings, "Warning: %s\n", msg); err != nil {
+				return &WriteError{Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// result holds the normalized key-value pairs produced by a conversion
+type result struct {
+	values   map[string]string // maps output key to value
+	keys     []string          // output keys after filtering, in output order
+	comments map[string]string // maps output key to its source comment
+}
+
+// Convert reads from r and writes the converted output to w. Output is
+// buffered and flushed before Convert returns.
 func (c *Converter) Convert(r io.Reader, w io.Writer) error {
+	return c.ConvertContext(context.Background(), r, w)
+}
+
+// ConvertContext is like Convert but stops with ctx.Err() if ctx is done
+// before parsing or before the pairs are written. Plugins implementing
+// plugin.ContextPlugin can also stop while parsing.
+func (c *Converter) ConvertContext(ctx context.Context, r io.Reader, w io.Writer) error {
 	// Handle nil input/output
 	if r == nil {
 		return fmt.Errorf("input reader is nil")
@@ -100,101 +575,353 @@ func (c *Converter) Convert(r io.Reader, w io.Writer) error {
 	if w == nil {
 		return fmt.Errorf("output writer is nil")
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	// Write header first
-	if err := c.writeHeader(w); err != nil {
+	bw := c.newWriter(w)
+	if err := c.write(ctx, r, bw); err != nil {
+		// Flush what was written so far, such as the header
+		bw.Flush()
 		return err
 	}
+	if err := bw.Flush(); err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
+
+// write converts r and writes the header and pairs to w
+func (c *Converter) write(ctx context.Context, r io.Reader, w *bufio.Writer) error {
+	if c.encoder != nil {
+		return c.encode(ctx, r, w)
+	}
 
-	// Parse input using plugin
-	env, err := c.plugin.Parse(r)
+	return c.writeWithHeader(w, c.plugin.Name(), func(w *bufio.Writer) error {
+		return c.writePairs(ctx, r, w)
+	})
+}
+
+// writePairs converts r and writes the pairs, with their comments, to w
+func (c *Converter) writePairs(ctx context.Context, r io.Reader, w *bufio.Writer) error {
+	res, err := c.convert(ctx, r)
 	if err != nil {
-		return fmt.Errorf("parsing error: %w", err)
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// Convert all keys to uppercase and detect duplicates
-	normalized := make(map[string]string)
-	keyMapping := make(map[string][]string) // maps uppercase key to original keys
+	keys, removed := c.delta(res.keys, res.values)
+	if err := c.checkEmpty(keys, removed); err != nil {
+		return err
+	}
+	if err := c.checkSeparator(keys); err != nil {
+		return err
+	}
 
-	for k := range env {
-		upperKey := strings.ToUpper(k)
-		processedKey := c.processKey(upperKey)
-		keyMapping[processedKey] = append(keyMapping[processedKey], k)
+	// Handle empty result
+	if c.filter != nil && len(res.keys) == 0 && !c.bare() {
+		return c.writeComment(w, "No keys matched the specified filters")
 	}
 
-	// Check for duplicates
-	var exactDuplicates []string
-	var caseInsensitiveDuplicates []string
+	// Write output in .env format
+	for i, k := range keys {
+		if c.limit > 0 && i == c.limit {
+			break
+		}
+		if err := c.writeGroupBreak(w, previous(keys, i), k); err != nil {
+			return err
+		}
+		if c.keepComments && !c.bare() && res.comments[k] != "" {
+			if err := c.writeComment(w, res.comments[k]); err != nil {
+				return err
+			}
+		}
+		if err := c.writePair(w, k, res.values[k]); err != nil {
+			return err
+		}
+	}
+
+	return c.writeRemoved(w, removed)
+}
+
+// encode converts r and writes the pairs to w with the configured encoder
+func (c *Converter) encode(ctx context.Context, r io.Reader, w io.Writer) error {
+	res, err := c.convert(ctx, r)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.encodePairs(w, res.keys, res.values)
+}
+
+// encodePairs writes the pairs of values named by keys to w with the
+// configured encoder, after the baseline, empty-output and limit handling
+func (c *Converter) encodePairs(w io.Writer, keys []string, values map[string]string) error {
+	keys, _ = c.delta(keys, values)
+	if err := c.checkEmpty(keys, nil); err != nil {
+		return err
+	}
+	if c.limit > 0 && len(keys) > c.limit {
+		keys = keys[:c.limit]
+	}
+	if err := c.encoder.Encode(w, values, keys); err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
 
-	for upperKey, originalKeys := range keyMapping {
-		if len(originalKeys) > 1 {
-			// Check if it's an exact duplicate or case-insensitive duplicate
-			allSame := true
-			for i := 1; i < len(originalKeys); i++ {
-				if originalKeys[i] != originalKeys[0] {
-					allSame = false
-					break
-				}
+// newWriter returns a buffered writer for w that drops the final newline
+// when final newlines are disabled. Once a write to w fails, every later
+// write fails with the same error without reaching w, so conversion stops at
+// the next pair.
+func (c *Converter) newWriter(w io.Writer) *bufio.Writer {
+	if c.noFinalNL {
+		w = &trimNewlineWriter{w: w}
+	}
+	if c.bufSize > 0 {
+		return bufio.NewWriterSize(w, c.bufSize)
+	}
+	return bufio.NewWriter(w)
+}
+
+// trimNewlineWriter holds back a trailing newline until more output follows,
+// so the last newline written is never passed on
+type trimNewlineWriter struct {
+	w       io.Writer
+	pending bool
+}
+
+// Write implements io.Writer
+func (t *trimNewlineWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if t.pending {
+		if _, err := t.w.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		t.pending = false
+	}
+
+	n := len(p)
+	if p[n-1] == '\n' {
+		p = p[:n-1]
+		t.pending = true
+	}
+	if _, err := t.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// writePair writes a single KEY=value line, using the configured separator,
+// without building an intermediate string. Line breaks and backslashes in
+// value are escaped.
+// In keys-only or values-only mode just the key or value is written.
+func (c *Converter) writePair(w *bufio.Writer, key, value string) error {
+	switch {
+	case c.keysOnly:
+		w.WriteString(key)
+	case c.valuesOnly:
+		w.WriteString(escapeNewlines(value))
+	case c.quoted(key):
+		w.WriteString(key)
+		w.WriteString(c.kvSep)
+		w.WriteString(quoteValue(value))
+	default:
+		w.WriteString(key)
+		w.WriteString(c.kvSep)
+		w.WriteString(escapeNewlines(value))
+	}
+	if err := w.WriteByte('\n'); err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
+
+// ConvertMap reads from r and returns the converted key-value pairs without
+// writing any output. Keys are normalized, filtered and blanked exactly as
+// Convert would write them.
+func (c *Converter) ConvertMap(r io.Reader) (map[string]string, error) {
+	if r == nil {
+		return nil, fmt.Errorf("input reader is nil")
+	}
+
+	res, err := c.convert(context.Background(), r)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string, len(res.keys))
+	for _, k := range res.keys {
+		env[k] = res.values[k]
+	}
+	return env, nil
+}
+
+// convert parses r and normalizes, checks, filters and orders the resulting keys
+func (c *Converter) convert(ctx context.Context, r io.Reader) (*result, error) {
+	// Guard against oversized input
+	var limited *utils.LimitedReader
+	if c.maxInput > 0 {
+		limited = utils.LimitInput(r, c.maxInput)
+		r = limited
+	}
+
+	// Parse input using plugin. Plugins may not pass read errors through, so
+	// an oversized input is reported in place of the plugin's error.
+	parsed, err := c.parse(ctx, r)
+	if limited != nil && limited.Err() != nil {
+		return nil, limited.Err()
+	}
+	if err != nil {
+		return nil, &ParseError{Plugin: c.plugin.Name(), Err: err}
+	}
+	pairs := parsed.pairs
+
+	// Report plugin warnings, which are errors in strict mode
+	if err := c.reportWarnings(parsed.warnings); err != nil {
+		return nil, err
+	}
+
+	// Convert all keys to output case and detect duplicates
+	normalized := make(map[string]string, len(pairs))
+	keyMapping := make(map[string][]candidate, len(pairs)) // maps uppercase key to original keys and values
+	comments := make(map[string]string)                    // maps uppercase key to its source comment
+	order := make([]string, 0, len(pairs))                 // uppercase keys in the order they were parsed
+
+	for _, kv := range pairs {
+		outKey := c.prefixedKey(kv)
+		if outKey == "" {
+			return nil, ErrUnnamedRoot
+		}
+		processedKey := c.normalizeKey(outKey)
+		if _, ok := keyMapping[processedKey]; !ok {
+			order = append(order, processedKey)
+		}
+		if kv.Comment != "" {
+			comments[processedKey] = kv.Comment
+		}
+		keyMapping[processedKey] = append(keyMapping[processedKey], candidatesOf(kv, parsed.collisions)...)
+	}
+
+	// Extra pairs override parsed values for the same key
+	order = c.addExtraPairs(keyMapping, order)
+
+	// Check for duplicates, which the conflict policy may resolve
+	var duplicates map[string][]string
+	ordered := c.parsesInOrder()
+	for upperKey, candidates := range keyMapping {
+		if len(candidates) > 1 && c.conflict == ConflictError {
+			if duplicates == nil {
+				duplicates = make(map[string][]string)
 			}
+			originalKeys := make([]string, len(candidates))
+			for i, cand := range candidates {
+				originalKeys[i] = cand.key
+			}
+			// Plugins without source order return keys in map order
+			sort.Strings(originalKeys)
+			duplicates[upperKey] = originalKeys
+			continue
+		}
 
-			if allSame {
-				exactDuplicates = append(exactDuplicates, fmt.Sprintf("duplicate key '%s'", originalKeys[0]))
-			} else {
-				// Sort for consistent error messages
-				sortedKeys := make([]string, len(originalKeys))
-				copy(sortedKeys, originalKeys)
-				sort.Strings(sortedKeys)
-				caseInsensitiveDuplicates = append(caseInsensitiveDuplicates,
-					fmt.Sprintf("duplicate key '%s' (found as '%s')", upperKey, strings.Join(sortedKeys, "' and '")))
+		// Trim first so that longest-value compares the values written
+		if c.trimValues {
+			for i := range candidates {
+				candidates[i].value = strings.TrimSpace(candidates[i].value)
 			}
-		} else {
-			// No duplicate, add to normalized map
-			normalized[upperKey] = env[originalKeys[0]]
+		}
+		normalized[upperKey] = c.resolve(candidates, ordered)
+	}
+	if duplicates != nil {
+		return nil, &DuplicateKeyError{Keys: duplicates}
+	}
+
+	// Reject patterns the matcher cannot use
+	if c.filter != nil {
+		if err := c.filter.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply filter if configured, dropping unset keys and empty values when
+	// pruning
+	unset := c.unsetKeys()
+	keys := make([]string, 0, len(order))
+	for _, k := range order {
+		if unset[k] || c.pruneEmpty && normalized[k] == "" {
+			continue
+		}
+		if c.filter == nil || c.filter.includes(k, keyMapping[k]) {
+			keys = append(keys, k)
 		}
 	}
 
-	// Report all duplicates if any found
-	if len(exactDuplicates) > 0 || len(caseInsensitiveDuplicates) > 0 {
-		var errMsg strings.Builder
-		errMsg.WriteString("duplicate keys found: ")
+	// Reject keys that are not legal shell identifiers
+	if c.strictKeys {
+		if err := checkKeys(keys); err != nil {
+			return nil, err
+		}
+	}
 
-		allErrors := append(exactDuplicates, caseInsensitiveDuplicates...)
-		sort.Strings(allErrors)
-		errMsg.WriteString(strings.Join(allErrors, "; "))
+	// Check required keys before template mode blanks their values. Required
+	// keys are uppercased, so mixed-case keys are matched ignoring case.
+	if len(c.required) > 0 {
+		kept := make(map[string]string, len(keys))
+		for _, k := range keys {
+			kept[strings.ToUpper(k)] = normalized[k]
+		}
+		if err := CheckRequired(kept, c.required); err != nil {
+			return nil, err
+		}
+	}
 
-		return fmt.Errorf(errMsg.String())
+	// Coerce values to the forms the schema asks for
+	if err := c.coerceValues(keys, normalized); err != nil {
+		return nil, err
 	}
 
-	// Apply filter if configured
-	if c.filter != nil {
-		filtered := make(map[string]string)
-		for k, v := range normalized {
-			if c.filter.shouldInclude(k) {
-				filtered[k] = v
+	// Mask secret values
+	if c.redact != nil {
+		for _, k := range keys {
+			if c.redact.shouldRedact(k) {
+				normalized[k] = RedactedValue
 			}
 		}
-		normalized = filtered
+	}
 
-		// Handle empty result
-		if len(normalized) == 0 {
-			_, err := io.WriteString(w, "# No keys matched the specified filters\n")
-			return err
+	// Blank values in template mode
+	if c.template != nil {
+		for _, k := range keys {
+			if c.template.shouldBlank(k) {
+				normalized[k] = ""
+			}
 		}
 	}
 
-	// Get sorted keys for consistent output
-	var keys []string
-	for k := range normalized {
-		keys = append(keys, k)
+	// Truncate or reject long values
+	if err := c.limitValues(keys, normalized); err != nil {
+		return nil, err
 	}
-	sort.Strings(keys)
 
-	// Write output in .env format
-	for _, k := range keys {
-		if _, err := io.WriteString(w, k+"="+normalized[k]+"\n"); err != nil {
-			return fmt.Errorf("writing error: %w", err)
+	// Reject values that would span several lines
+	if c.strictNL {
+		if err := checkNewlines(keys, normalized); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	// Order keys according to the configured sort mode
+	c.orderKeys(keys)
+
+	return &result{
+		values:   normalized,
+		keys:     keys,
+		comments: comments,
+	}, nil
 }
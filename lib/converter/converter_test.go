@@ -2,11 +2,20 @@ package converter
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/lib/version"
 	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
 )
 
 // mockPlugin implements plugin.Plugin for testing
@@ -284,6 +293,91 @@ func (w *failingWriter) Write(p []byte) (n int, err error) {
 	return 0, io.ErrShortWrite
 }
 
+func TestConverter_CommentPrefix(t *testing.T) {
+	input := "# Primary database\nhost: localhost # or a socket path\nport: 5432\n"
+
+	tests := []struct {
+		name    string
+		prefix  string
+		exclude []string
+		want    string
+	}{
+		{
+			name:   "default",
+			prefix: "",
+			want: `# This file was auto-generated by cfg2env
+# Version: test
+# Plugin: yaml
+#
+
+# Primary database
+# or a socket path
+HOST=localhost
+PORT=5432
+`,
+		},
+		{
+			name:   "semicolon",
+			prefix: ";",
+			want: `; This file was auto-generated by cfg2env
+; Version: test
+; Plugin: yaml
+;
+
+; Primary database
+; or a socket path
+HOST=localhost
+PORT=5432
+`,
+		},
+		{
+			name:    "no matches",
+			prefix:  "//",
+			exclude: []string{"*"},
+			want: `// This file was auto-generated by cfg2env
+// Version: test
+// Plugin: yaml
+//
+
+// No keys matched the specified filters
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(yaml.New())
+			c.SetVersion("test")
+			c.SetKeepComments(true)
+			c.SetSort(SortNone)
+			c.SetCommentPrefix(tt.prefix)
+			if tt.exclude != nil {
+				c.SetFilterPatterns(nil, tt.exclude, GlobMatcher{})
+			}
+
+			var out strings.Builder
+			if err := c.Convert(strings.NewReader(input), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Convert() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	// Merged output uses the prefix too
+	c := New(yaml.New())
+	c.SetVersion("test")
+	c.SetCommentPrefix(";")
+	var out strings.Builder
+	if err := c.WriteMap(&out, map[string]string{"A": "1"}); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+	if want := "; This file was auto-generated by cfg2env\n; Version: test\n; Plugin: yaml\n;\n\nA=1\n"; out.String() != want {
+		t.Errorf("WriteMap() = %q, want %q", out.String(), want)
+	}
+}
+
 func TestConverterHeader(t *testing.T) {
 	// Create a simple test plugin
 	p := &testPlugin{
@@ -339,6 +433,94 @@ func TestConverterHeader(t *testing.T) {
 	}
 }
 
+func TestConverter_Version(t *testing.T) {
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test")})
+	if got := c.Version(); got != version.Version() {
+		t.Errorf("Version() = %q, want default %q", got, version.Version())
+	}
+
+	c.SetVersion("1.0.0-test")
+	if got := c.Version(); got != "1.0.0-test" {
+		t.Errorf("Version() = %q, want %q", got, "1.0.0-test")
+	}
+}
+
+func TestConverterCommentHeader(t *testing.T) {
+	p := &testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data:       map[string]string{"key": "value"},
+	}
+
+	c := New(p)
+	c.SetVersion("1.0.0-test")
+	c.SetCommentHeader(true, time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)))
+
+	var buf bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	want := "# This file was auto-generated by cfg2env\n" +
+		"# Version: 1.0.0-test\n" +
+		"# Plugin: test\n" +
+		"# Generated at: 2024-01-02T02:04:05Z\n" +
+		"#\n" +
+		"\n" +
+		"KEY=value\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Convert() = %q, want %q", got, want)
+	}
+}
+
+func TestConverter_NoHeader(t *testing.T) {
+	p := &testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data:       map[string]string{"key": "value"},
+	}
+
+	c := New(p)
+	c.SetHeader(false)
+	c.SetCommentHeader(true, time.Time{})
+
+	var buf bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if got, want := buf.String(), "KEY=value\n"; got != want {
+		t.Errorf("Convert() = %q, want %q", got, want)
+	}
+}
+
+func TestConverterCommentHeader_CurrentTime(t *testing.T) {
+	p := &testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data:       map[string]string{"key": "value"},
+	}
+
+	c := New(p)
+	c.SetCommentHeader(true, time.Time{})
+
+	before := time.Now().Add(-time.Second)
+	var buf bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var stamp string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "# Generated at: ") {
+			stamp = strings.TrimPrefix(line, "# Generated at: ")
+		}
+	}
+	ts, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		t.Fatalf("Generated at line %q is not RFC 3339: %v", stamp, err)
+	}
+	if ts.Before(before.Truncate(time.Second)) {
+		t.Errorf("Generated at %v, want current time", ts)
+	}
+}
+
 func TestConverter_DuplicateKeys(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -506,6 +688,99 @@ func TestConverter_Dunder(t *testing.T) {
 	}
 }
 
+func TestConverter_CaseVariantDuplicates(t *testing.T) {
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return map[string]string{"api_key": "a", "Api_Key": "b", "API_KEY": "c", "host": "h"}, nil
+		},
+	}
+	c := New(p)
+
+	want := "duplicate keys found: duplicate key 'API_KEY' (found as 'API_KEY' and 'Api_Key' and 'api_key')"
+	for i := 0; i < 20; i++ {
+		var out bytes.Buffer
+		err := c.Convert(strings.NewReader(""), &out)
+
+		var dupErr *DuplicateKeyError
+		if !errors.As(err, &dupErr) {
+			t.Fatalf("run %d: Convert() error = %v, want DuplicateKeyError", i, err)
+		}
+		if got := dupErr.Keys["API_KEY"]; !reflect.DeepEqual(got, []string{"API_KEY", "Api_Key", "api_key"}) {
+			t.Fatalf("run %d: Keys[API_KEY] = %v, want sorted source keys", i, got)
+		}
+		if err.Error() != want {
+			t.Fatalf("run %d: error = %q, want %q", i, err, want)
+		}
+	}
+}
+
+func TestConverter_DunderEdgeCases(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		dunder int
+		want   string
+	}{
+		{"leading run", "__private", 1, "_PRIVATE"},
+		{"leading single", "_private", 1, "PRIVATE"},
+		{"trailing run", "key___", 1, "KEY__"},
+		{"trailing run removed", "key___", 3, "KEY"},
+		// Flattening joins levels with a single underscore, which dunder
+		// counts like any other; database: {host: ...} loses its separator
+		{"level separator", "database_host", 1, "DATABASEHOST"},
+		{"double underscore source key", "database__host", 1, "DATABASE_HOST"},
+		// api__: [a] flattens to API___0 and api: [a] to API_0
+		{"array index after run", "api___0", 1, "API__0"},
+		{"array index", "api_0", 1, "API0"},
+		{"array index with dunder 2", "a__0", 2, "A0"},
+		{"run longer than dunder", "a______b", 2, "A____B"},
+		{"only underscores", "___", 3, "___"},
+		{"only underscores partly removed", "___", 1, "__"},
+		{"no underscores", "key", 2, "KEY"},
+		{"zero dunder", "a__b", 0, "A__B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&mockPlugin{BasePlugin: plugin.NewBasePlugin("mock")})
+			c.SetDunder(tt.dunder)
+			if got := c.processKey(strings.ToUpper(tt.key)); got != tt.want {
+				t.Errorf("processKey(%q) with dunder %d = %q, want %q", tt.key, tt.dunder, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_DunderCollapse(t *testing.T) {
+	tests := []struct {
+		key    string
+		dunder int
+		want   string
+	}{
+		{"example_key", 0, "EXAMPLE_KEY"},
+		{"example__key", 0, "EXAMPLE_KEY"},
+		{"example___key", 0, "EXAMPLE_KEY"},
+		{"example____key", 0, "EXAMPLE_KEY"},
+		{"a__b___c", 0, "A_B_C"},
+		{"__leading__trailing__", 0, "_LEADING_TRAILING_"},
+		// Collapsing takes precedence over removing N underscores
+		{"a__b", 1, "A_B"},
+		{"a_b", 3, "A_B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			c := New(&mockPlugin{BasePlugin: plugin.NewBasePlugin("mock")})
+			c.SetDunder(tt.dunder)
+			c.SetDunderCollapse(true)
+			if got := c.processKey(strings.ToUpper(tt.key)); got != tt.want {
+				t.Errorf("processKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestConverterWithFilter(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -715,3 +990,752 @@ func TestConverterWithFilterNoMatches(t *testing.T) {
 		})
 	}
 }
+
+func TestConverter_KeepComments(t *testing.T) {
+	pairs := []plugin.KV{
+		{Key: "database_host", Value: "localhost", Comment: "Primary host\nsecond line"},
+		{Key: "database_port", Value: "5432"},
+		{Key: "api_url", Value: "https://api.example.com", Comment: "API endpoint"},
+	}
+
+	tests := []struct {
+		name string
+		keep bool
+		want string
+	}{
+		{
+			name: "comments kept",
+			keep: true,
+			want: "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: ordered\n#\n\n" +
+				"# API endpoint\nAPI_URL=https://api.example.com\n" +
+				"# Primary host\n# second line\nDATABASE_HOST=localhost\n" +
+				"DATABASE_PORT=5432\n",
+		},
+		{
+			name: "comments dropped by default",
+			keep: false,
+			want: "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: ordered\n#\n\n" +
+				"API_URL=https://api.example.com\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &orderedPlugin{
+				BasePlugin: plugin.NewBasePlugin("ordered"),
+				pairs:      pairs,
+			}
+
+			c := New(p)
+			c.SetKeepComments(tt.keep)
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkConvert_10k(b *testing.B) {
+	c := New(manyKeys(10000))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Convert(strings.NewReader(""), io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// countingWriter counts the writes it receives and fails every write from
+// the failAt-th on, if failAt is set
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	failAt int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.failAt > 0 && w.writes >= w.failAt {
+		return 0, io.ErrShortWrite
+	}
+	return w.Buffer.Write(p)
+}
+
+// manyKeys returns a plugin producing n keys
+func manyKeys(n int) *testPlugin {
+	data := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		data[fmt.Sprintf("section_%03d_key_%03d", i/100, i%100)] = fmt.Sprintf("value-%d", i)
+	}
+	return &testPlugin{BasePlugin: plugin.NewBasePlugin("bench"), data: data}
+}
+
+func TestConverter_BufferSize(t *testing.T) {
+	p := manyKeys(1000)
+
+	var want bytes.Buffer
+	if err := New(p).Convert(strings.NewReader(""), &want); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		size      int
+		maxWrites int
+	}{
+		{"default", 0, want.Len()/4096 + 1},
+		{"large", 1 << 20, 1},
+		{"small", 64, want.Len()/64 + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(p)
+			c.SetBufferSize(tt.size)
+
+			var w countingWriter
+			if err := c.Convert(strings.NewReader(""), &w); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if w.String() != want.String() {
+				t.Error("Convert() output differs from the default buffer size")
+			}
+			if w.writes > tt.maxWrites {
+				t.Errorf("Convert() made %d writes, want at most %d", w.writes, tt.maxWrites)
+			}
+		})
+	}
+}
+
+func TestConverter_FlushError(t *testing.T) {
+	// The output fits in the buffer, so the first write is the final flush
+	for _, format := range []string{"env", "json"} {
+		t.Run(format, func(t *testing.T) {
+			c := New(manyKeys(3))
+			enc, err := ParseEncoder(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SetEncoder(enc)
+
+			w := &countingWriter{failAt: 1}
+			err = c.Convert(strings.NewReader(""), w)
+			var werr *WriteError
+			if !errors.As(err, &werr) || !errors.Is(err, io.ErrShortWrite) {
+				t.Errorf("Convert() error = %v, want WriteError wrapping io.ErrShortWrite", err)
+			}
+			if w.writes != 1 {
+				t.Errorf("Convert() made %d writes, want 1", w.writes)
+			}
+		})
+	}
+}
+
+func TestConverter_WriteErrorStopsEarly(t *testing.T) {
+	c := New(manyKeys(10000))
+	c.SetBufferSize(64)
+
+	// Fail the second write, long before the output is complete
+	w := &countingWriter{failAt: 2}
+	err := c.Convert(strings.NewReader(""), w)
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("Convert() error = %v, want io.ErrShortWrite", err)
+	}
+	if w.writes != 2 {
+		t.Errorf("Convert() made %d writes after the failure, want none", w.writes-2)
+	}
+}
+
+// BenchmarkConvert_BufferSize reports how many writes reach the underlying
+// writer, which is what a network writer pays for
+func BenchmarkConvert_BufferSize(b *testing.B) {
+	p := manyKeys(10000)
+	for _, size := range []int{64, 4096, 64 << 10} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			c := New(p)
+			c.SetBufferSize(size)
+
+			var w countingWriter
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w.Reset()
+				if err := c.Convert(strings.NewReader(""), &w); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+func TestConverter_PruneEmpty(t *testing.T) {
+	input := `{
+		"name": "demo",
+		"nothing": null,
+		"blank": "",
+		"empty_map": {},
+		"empty_list": [],
+		"nested": {"kept": "yes", "dropped": null}
+	}`
+
+	tests := []struct {
+		name  string
+		prune bool
+		want  map[string]string
+	}{
+		{
+			name:  "prune",
+			prune: true,
+			want: map[string]string{
+				"NAME":        "demo",
+				"NESTED_KEPT": "yes",
+			},
+		},
+		{
+			name: "default keeps empty values",
+			want: map[string]string{
+				"NAME":           "demo",
+				"NOTHING":        "",
+				"BLANK":          "",
+				"EMPTY_MAP":      "",
+				"NESTED_KEPT":    "yes",
+				"NESTED_DROPPED": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(json.New())
+			c.SetPruneEmpty(tt.prune)
+
+			got, err := c.ConvertMap(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_TrimValues(t *testing.T) {
+	input := map[string]string{
+		"host":    "  localhost\t",
+		"name":    "demo",
+		"blank":   " \t ",
+		"inner":   " a  b ",
+		"newline": "value\n",
+	}
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return input, nil
+		},
+	}
+
+	tests := []struct {
+		name  string
+		trim  bool
+		prune bool
+		want  map[string]string
+	}{
+		{
+			name: "default keeps values exactly",
+			want: map[string]string{"HOST": "  localhost\t", "NAME": "demo", "BLANK": " \t ", "INNER": " a  b ", "NEWLINE": "value\n"},
+		},
+		{
+			name: "trim",
+			trim: true,
+			want: map[string]string{"HOST": "localhost", "NAME": "demo", "BLANK": "", "INNER": "a  b", "NEWLINE": "value"},
+		},
+		{
+			name:  "all-whitespace values are pruned once trimmed",
+			trim:  true,
+			prune: true,
+			want:  map[string]string{"HOST": "localhost", "NAME": "demo", "INNER": "a  b", "NEWLINE": "value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(p)
+			c.SetTrimValues(tt.trim)
+			c.SetPruneEmpty(tt.prune)
+
+			got, err := c.ConvertMap(strings.NewReader(""))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Whitespace-only values fail required checks once trimmed
+	c := New(p)
+	c.SetTrimValues(true)
+	c.SetRequired([]string{"BLANK"})
+	var missing *MissingKeysError
+	if _, err := c.ConvertMap(strings.NewReader("")); !errors.As(err, &missing) {
+		t.Errorf("ConvertMap() error = %v, want MissingKeysError", err)
+	}
+}
+
+func TestConverter_PruneEmptyWithTemplate(t *testing.T) {
+	c := New(json.New())
+	c.SetPruneEmpty(true)
+	c.SetTemplate(true, nil, GlobMatcher{})
+
+	got, err := c.ConvertMap(strings.NewReader(`{"host": "localhost", "unset": null}`))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+
+	// Template blanking happens after pruning, so HOST is kept
+	want := map[string]string{"HOST": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %v, want %v", got, want)
+	}
+}
+
+func TestConverter_KVSeparator(t *testing.T) {
+	input := `{"host": "localhost", "url": "a=b:c"}`
+	header := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: json\n#\n\n"
+
+	tests := []struct {
+		name string
+		set  bool
+		sep  string
+		want string
+	}{
+		{
+			name: "default",
+			want: "HOST=localhost\nURL=a=b:c\n",
+		},
+		{
+			name: "empty restores default",
+			set:  true,
+			sep:  "",
+			want: "HOST=localhost\nURL=a=b:c\n",
+		},
+		{
+			name: "colon",
+			set:  true,
+			sep:  ": ",
+			want: "HOST: localhost\nURL: a=b:c\n",
+		},
+		{
+			name: "spaced equals",
+			set:  true,
+			sep:  " = ",
+			want: "HOST = localhost\nURL = a=b:c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(json.New())
+			if tt.set {
+				c.SetKVSeparator(tt.sep)
+			}
+
+			var out strings.Builder
+			if err := c.Convert(strings.NewReader(input), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got := out.String(); got != header+tt.want {
+				t.Errorf("Convert() = %q, want %q", got, header+tt.want)
+			}
+
+			// Merged output uses the same separator
+			out.Reset()
+			env := map[string]string{"HOST": "localhost", "URL": "a=b:c"}
+			if err := c.WriteMap(&out, env); err != nil {
+				t.Fatalf("WriteMap() error = %v", err)
+			}
+			if got := out.String(); got != header+tt.want {
+				t.Errorf("WriteMap() = %q, want %q", got, header+tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_FinalNewline(t *testing.T) {
+	header := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: json\n#\n\n"
+
+	tests := []struct {
+		name  string
+		input string
+		final bool
+		want  string
+	}{
+		{
+			name:  "single key with newline",
+			input: `{"host": "localhost"}`,
+			final: true,
+			want:  header + "HOST=localhost\n",
+		},
+		{
+			name:  "single key without newline",
+			input: `{"host": "localhost"}`,
+			final: false,
+			want:  header + "HOST=localhost",
+		},
+		{
+			name:  "multiple keys with newline",
+			input: `{"host": "localhost", "port": 5432}`,
+			final: true,
+			want:  header + "HOST=localhost\nPORT=5432\n",
+		},
+		{
+			name:  "multiple keys without newline",
+			input: `{"host": "localhost", "port": 5432}`,
+			final: false,
+			want:  header + "HOST=localhost\nPORT=5432",
+		},
+		{
+			name:  "value ending in newline keeps it escaped",
+			input: `{"motd": "hello\n", "port": 5432}`,
+			final: false,
+			want:  header + "MOTD=hello\\n\nPORT=5432",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(json.New())
+			c.SetFinalNewline(tt.final)
+
+			var out strings.Builder
+			if err := c.Convert(strings.NewReader(tt.input), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_FinalNewline_WriteMap(t *testing.T) {
+	c := New(json.New())
+	c.SetFinalNewline(false)
+
+	var out strings.Builder
+	if err := c.WriteMap(&out, map[string]string{"B": "2", "A": "1"}); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+	want := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: json\n#\n\nA=1\nB=2"
+	if got := out.String(); got != want {
+		t.Errorf("WriteMap() = %q, want %q", got, want)
+	}
+}
+
+func TestConverter_Limit(t *testing.T) {
+	input := `{"e": "5", "d": "4", "c": "3", "b": "2", "a": "1"}`
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"unlimited", 0, []string{"A=1", "B=2", "C=3", "D=4", "E=5"}},
+		{"negative is unlimited", -1, []string{"A=1", "B=2", "C=3", "D=4", "E=5"}},
+		{"first two after sorting", 2, []string{"A=1", "B=2"}},
+		{"one", 1, []string{"A=1"}},
+		{"larger than input", 10, []string{"A=1", "B=2", "C=3", "D=4", "E=5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(json.New())
+			c.SetLimit(tt.limit)
+
+			var out strings.Builder
+			if err := c.Convert(strings.NewReader(input), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			var got []string
+			for _, line := range strings.Split(out.String(), "\n") {
+				if line != "" && !strings.HasPrefix(line, "#") {
+					got = append(got, line)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Convert() lines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_LimitWithFilter(t *testing.T) {
+	c := New(json.New())
+	c.SetLimit(1)
+	c.SetFilterPatterns([]string{"DB_*"}, nil, GlobMatcher{})
+
+	var out strings.Builder
+	if err := c.Convert(strings.NewReader(`{"api": "x", "db_user": "u", "db_host": "h"}`), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "\n\nDB_HOST=h\n") {
+		t.Errorf("Convert() = %q, want only DB_HOST", out.String())
+	}
+}
+
+func TestConverter_MaxInputBytes(t *testing.T) {
+	input := `{"database": {"host": "localhost", "port": 5432}}`
+
+	tests := []struct {
+		name    string
+		plugin  plugin.Plugin
+		limit   int64
+		wantErr bool
+	}{
+		{"unlimited", json.New(), 0, false},
+		{"exactly at limit", json.New(), int64(len(input)), false},
+		{"json over limit", json.New(), int64(len(input)) - 1, true},
+		{"yaml over limit", yaml.New(), 10, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.plugin)
+			c.SetMaxInputBytes(tt.limit)
+
+			var out bytes.Buffer
+			err := c.Convert(strings.NewReader(input), &out)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Convert() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, utils.ErrInputTooLarge) {
+				t.Fatalf("Convert() error = %v, want ErrInputTooLarge", err)
+			}
+			if !strings.Contains(err.Error(), fmt.Sprintf("limit of %d bytes", tt.limit)) {
+				t.Errorf("Convert() error = %q, want it to name the limit", err.Error())
+			}
+			if strings.Contains(out.String(), "=") {
+				t.Errorf("Convert() wrote pairs for oversized input: %q", out.String())
+			}
+
+			// Validation enforces the same limit
+			if err := c.Validate(strings.NewReader(input)); !errors.Is(err, utils.ErrInputTooLarge) {
+				t.Errorf("Validate() error = %v, want ErrInputTooLarge", err)
+			}
+		})
+	}
+}
+
+func TestConverter_Reset(t *testing.T) {
+	p := yaml.New()
+	c := New(p)
+	c.SetVersion("test")
+	c.SetPrefix("APP")
+	c.SetFilterPatterns(nil, []string{"*_SECRET"}, GlobMatcher{})
+
+	// The first input produces a key collision warning
+	first := "database_host: literal\ndatabase:\n  host: nested\n  secret: x\n"
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader(first), &out); err != nil {
+		t.Fatalf("first Convert() error = %v", err)
+	}
+	if len(p.Warnings()) == 0 {
+		t.Fatal("first Convert() produced no warnings, want a key collision")
+	}
+
+	c.Reset()
+	if len(p.Warnings()) != 0 {
+		t.Errorf("Warnings() after Reset() = %v, want none", p.Warnings())
+	}
+
+	out.Reset()
+	var warnings bytes.Buffer
+	c.SetWarningWriter(&warnings)
+	if err := c.Convert(strings.NewReader("cache:\n  host: redis\n  secret: y\n"), &out); err != nil {
+		t.Fatalf("second Convert() error = %v", err)
+	}
+
+	// Options are preserved and nothing from the first input leaks through
+	want := `# This file was auto-generated by cfg2env
+# Version: test
+# Plugin: yaml
+#
+
+APP_CACHE_HOST=redis
+`
+	if out.String() != want {
+		t.Errorf("second Convert() = %q, want %q", out.String(), want)
+	}
+	if warnings.Len() != 0 {
+		t.Errorf("second Convert() warnings = %q, want none", warnings.String())
+	}
+}
+
+func TestConverter_Reset_NonResetter(t *testing.T) {
+	// Plugins without state to reset are left alone
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: map[string]string{"KEY": "value"}})
+	c.Reset()
+
+	got, err := c.ConvertMap(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]string{"KEY": "value"}) {
+		t.Errorf("ConvertMap() = %v", got)
+	}
+}
+
+func TestConverter_ConcurrentConvert(t *testing.T) {
+	// Run with -race. Strict keys turn warnings into errors, so a warning
+	// leaking from one goroutine's parse into another's would fail it.
+	c := New(yaml.New())
+	c.SetVersion("test")
+	c.SetPrefix("APP")
+	c.SetStrictKeys(true)
+	c.SetFilterPatterns(nil, []string{"*_SECRET"}, GlobMatcher{})
+
+	clean := "database:\n  host: localhost\n  secret: x\n"
+	colliding := "database_host: literal\ndatabase:\n  host: nested\n"
+	want := `# This file was auto-generated by cfg2env
+# Version: test
+# Plugin: yaml
+#
+
+APP_DATABASE_HOST=localhost
+`
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 1 {
+				if _, err := c.ConvertMap(strings.NewReader(colliding)); err == nil {
+					t.Error("ConvertMap() with colliding keys succeeded, want error")
+				}
+				return
+			}
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(clean), &out); err != nil {
+				t.Errorf("Convert() error = %v", err)
+				return
+			}
+			if out.String() != want {
+				t.Errorf("Convert() = %q, want %q", out.String(), want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConverter_KeysOnly(t *testing.T) {
+	input := `# Primary host
+database:
+  host: localhost
+  password: secret
+  port: 5432
+api_key: abc
+`
+
+	tests := []struct {
+		name  string
+		setup func(c *Converter)
+		want  string
+	}{
+		{
+			name: "sorted keys",
+			want: "API_KEY\nDATABASE_HOST\nDATABASE_PASSWORD\nDATABASE_PORT\n",
+		},
+		{
+			name: "filtered keys",
+			setup: func(c *Converter) {
+				c.SetFilterPatterns([]string{"DATABASE_*"}, []string{"*_PASSWORD"}, GlobMatcher{})
+			},
+			want: "DATABASE_HOST\nDATABASE_PORT\n",
+		},
+		{
+			name: "no comments",
+			setup: func(c *Converter) {
+				c.SetKeepComments(true)
+				c.SetSort(SortNone)
+			},
+			want: "DATABASE_HOST\nDATABASE_PASSWORD\nDATABASE_PORT\nAPI_KEY\n",
+		},
+		{
+			name: "no filter message",
+			setup: func(c *Converter) {
+				c.SetFilterPatterns([]string{"CACHE_*"}, nil, GlobMatcher{})
+			},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(yaml.New())
+			c.SetKeysOnly(true)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(input), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Convert() = %q, want %q", out.String(), tt.want)
+			}
+			if strings.Contains(out.String(), "=") {
+				t.Errorf("Convert() output contains '=': %q", out.String())
+			}
+		})
+	}
+}
+
+func TestConverter_KeysOnly_WriteMap(t *testing.T) {
+	c := New(yaml.New())
+	c.SetKeysOnly(true)
+
+	var out bytes.Buffer
+	if err := c.WriteMap(&out, map[string]string{"B": "2", "A": "1"}); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+	if want := "A\nB\n"; out.String() != want {
+		t.Errorf("WriteMap() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestConverter_ValuesOnly(t *testing.T) {
+	input := `database:
+  host: localhost
+  port: 5432
+motd: "hello\nworld"
+`
+	c := New(yaml.New())
+	c.SetValuesOnly(true)
+	c.SetKeepComments(true)
+
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	want := "localhost\n5432\nhello\\nworld\n"
+	if out.String() != want {
+		t.Errorf("Convert() = %q, want %q", out.String(), want)
+	}
+}
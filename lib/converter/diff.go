@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ChangeKind describes how a key differs between two configs
+type ChangeKind int
+
+const (
+	// Added keys only exist in the new config
+	Added ChangeKind = iota
+	// Removed keys only exist in the old config
+	Removed
+	// Changed keys exist in both configs with different values
+	Changed
+)
+
+// Change is a single difference between two converted configs
+type Change struct {
+	Kind ChangeKind
+	Key  string
+	Old  string
+	New  string
+}
+
+// String formats the change as a diff line
+func (ch Change) String() string {
+	switch ch.Kind {
+	case Added:
+		return fmt.Sprintf("+%s=%s", ch.Key, ch.New)
+	case Removed:
+		return fmt.Sprintf("-%s", ch.Key)
+	default:
+		return fmt.Sprintf("~%s: %s -> %s", ch.Key, ch.Old, ch.New)
+	}
+}
+
+// Diff compares two converted configs and returns their differences sorted by key
+func Diff(oldEnv, newEnv map[string]string) []Change {
+	var changes []Change
+	for k, oldVal := range oldEnv {
+		newVal, ok := newEnv[k]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Key: k, Old: oldVal})
+		} else if newVal != oldVal {
+			changes = append(changes, Change{Kind: Changed, Key: k, Old: oldVal, New: newVal})
+		}
+	}
+	for k, newVal := range newEnv {
+		if _, ok := oldEnv[k]; !ok {
+			changes = append(changes, Change{Kind: Added, Key: k, New: newVal})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// WriteDiff writes one line per change to w
+func WriteDiff(w io.Writer, changes []Change) error {
+	for _, ch := range changes {
+		if _, err := io.WriteString(w, ch.String()+"\n"); err != nil {
+			return &WriteError{Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		oldEnv map[string]string
+		newEnv map[string]string
+		want   []Change
+	}{
+		{
+			name:   "no differences",
+			oldEnv: map[string]string{"A": "1"},
+			newEnv: map[string]string{"A": "1"},
+			want:   nil,
+		},
+		{
+			name:   "addition",
+			oldEnv: map[string]string{"A": "1"},
+			newEnv: map[string]string{"A": "1", "B": "2"},
+			want:   []Change{{Kind: Added, Key: "B", New: "2"}},
+		},
+		{
+			name:   "removal",
+			oldEnv: map[string]string{"A": "1", "B": "2"},
+			newEnv: map[string]string{"A": "1"},
+			want:   []Change{{Kind: Removed, Key: "B", Old: "2"}},
+		},
+		{
+			name:   "value change",
+			oldEnv: map[string]string{"A": "1"},
+			newEnv: map[string]string{"A": "2"},
+			want:   []Change{{Kind: Changed, Key: "A", Old: "1", New: "2"}},
+		},
+		{
+			name:   "mixed changes sorted by key",
+			oldEnv: map[string]string{"C": "1", "B": "old", "D": "same"},
+			newEnv: map[string]string{"A": "new", "B": "new", "D": "same"},
+			want: []Change{
+				{Kind: Added, Key: "A", New: "new"},
+				{Kind: Changed, Key: "B", Old: "old", New: "new"},
+				{Kind: Removed, Key: "C", Old: "1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.oldEnv, tt.newEnv)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	changes := []Change{
+		{Kind: Added, Key: "A", New: "new"},
+		{Kind: Changed, Key: "B", Old: "old", New: "new"},
+		{Kind: Removed, Key: "C", Old: "1"},
+	}
+
+	var out bytes.Buffer
+	if err := WriteDiff(&out, changes); err != nil {
+		t.Fatalf("WriteDiff() error = %v", err)
+	}
+
+	want := "+A=new\n~B: old -> new\n-C\n"
+	if got := out.String(); got != want {
+		t.Errorf("WriteDiff() = %q, want %q", got, want)
+	}
+}
+
+func TestConverter_ConvertMap(t *testing.T) {
+	p := &testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data: map[string]string{
+			"database_host":     "localhost",
+			"database_password": "secret",
+			"api_url":           "https://api.example.com",
+		},
+	}
+
+	c := New(p)
+	c.SetFilterPatterns([]string{"DATABASE_*"}, nil, GlobMatcher{})
+
+	got, err := c.ConvertMap(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+
+	want := map[string]string{
+		"DATABASE_HOST":     "localhost",
+		"DATABASE_PASSWORD": "secret",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %v, want %v", got, want)
+	}
+
+	if _, err := c.ConvertMap(nil); err == nil {
+		t.Error("ConvertMap(nil) expected error, got nil")
+	}
+}
@@ -0,0 +1,25 @@
+package converter
+
+import "errors"
+
+// ErrEmptyOutput is returned when empty output is an error and there are no
+// pairs to write
+var ErrEmptyOutput = errors.New("no keys to write")
+
+// SetErrorOnEmpty controls whether Convert and WriteMap fail with
+// ErrEmptyOutput instead of writing no pairs, such as when the input is
+// empty, every key is filtered out or nothing changed from the baseline.
+// Removed baseline keys count as output. The header may already be written
+// when the error is returned.
+func (c *Converter) SetErrorOnEmpty(enabled bool) {
+	c.errorOnEmpty = enabled
+}
+
+// checkEmpty returns ErrEmptyOutput if empty output is an error and there
+// are neither keys nor removed keys to write
+func (c *Converter) checkEmpty(keys, removed []string) error {
+	if c.errorOnEmpty && len(keys) == 0 && len(removed) == 0 {
+		return ErrEmptyOutput
+	}
+	return nil
+}
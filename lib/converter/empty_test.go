@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_ErrorOnEmpty(t *testing.T) {
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return map[string]string{"host": "localhost"}, nil
+		},
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(c *Converter)
+		wantErr bool
+	}{
+		{name: "pairs to write"},
+		{
+			name:    "every key filtered out",
+			setup:   func(c *Converter) { c.SetFilterPatterns([]string{"PORT"}, nil, GlobMatcher{}) },
+			wantErr: true,
+		},
+		{
+			name:    "nothing changed from the baseline",
+			setup:   func(c *Converter) { c.SetBaseline(map[string]string{"HOST": "localhost"}) },
+			wantErr: true,
+		},
+		{
+			name:  "removed baseline keys",
+			setup: func(c *Converter) { c.SetBaseline(map[string]string{"HOST": "localhost", "PORT": "5432"}) },
+		},
+		{
+			name: "encoded output",
+			setup: func(c *Converter) {
+				c.SetFilterPatterns([]string{"PORT"}, nil, GlobMatcher{})
+				c.SetEncoder(JSONEncoder{})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(p)
+			c.SetErrorOnEmpty(true)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			err := c.Convert(strings.NewReader(""), io.Discard)
+			if tt.wantErr != errors.Is(err, ErrEmptyOutput) {
+				t.Errorf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Convert() error = %v", err)
+			}
+		})
+	}
+
+	// WriteMap checks the pairs it is given
+	c := New(nil)
+	c.SetErrorOnEmpty(true)
+	if err := c.WriteMap(&bytes.Buffer{}, nil, "yaml"); !errors.Is(err, ErrEmptyOutput) {
+		t.Errorf("WriteMap() error = %v, want ErrEmptyOutput", err)
+	}
+
+	// Empty output is not an error by default
+	if err := New(nil).WriteMap(&bytes.Buffer{}, nil, "yaml"); err != nil {
+		t.Errorf("WriteMap() error = %v", err)
+	}
+}
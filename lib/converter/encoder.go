@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Encoder writes converted pairs in an output format. It is the output
+// counterpart of plugin.Plugin.
+type Encoder interface {
+	// Encode writes the pairs of env named by keys to w, in the order of
+	// keys. Keys are already normalized, filtered, sorted and limited.
+	Encode(w io.Writer, env map[string]string, keys []string) error
+}
+
+// EncoderFunc adapts a function to the Encoder interface
+type EncoderFunc func(w io.Writer, env map[string]string, keys []string) error
+
+// Encode implements Encoder
+func (f EncoderFunc) Encode(w io.Writer, env map[string]string, keys []string) error {
+	return f(w, env, keys)
+}
+
+// EnvEncoder writes one KEY=value line per pair, like the default output
+// without its header or comments. Line breaks in values are escaped.
+type EnvEncoder struct {
+	// Separator is written between each key and value. Empty uses "=".
+	Separator string
+}
+
+// Encode implements Encoder
+func (e EnvEncoder) Encode(w io.Writer, env map[string]string, keys []string) error {
+	sep := e.Separator
+	if sep == "" {
+		sep = "="
+	}
+	for _, k := range keys {
+		if _, err := io.WriteString(w, k+sep+escapeNewlines(env[k])+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JSONEncoder writes the pairs as a single flat JSON object with string
+// values, keeping the order of keys
+type JSONEncoder struct {
+	// Indent, if set, puts each pair on its own line indented by Indent
+	Indent string
+}
+
+// Encode implements Encoder
+func (e JSONEncoder) Encode(w io.Writer, env map[string]string, keys []string) error {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if e.Indent != "" {
+			b.WriteString("\n" + e.Indent)
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(env[k])
+		if err != nil {
+			return err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		if e.Indent != "" {
+			b.WriteByte(' ')
+		}
+		b.Write(value)
+	}
+	if e.Indent != "" && len(keys) > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// YAMLEncoder writes the pairs as a flat YAML mapping, keeping the order of
+// keys. Every value is written as a string, quoted where YAML would
+// otherwise read it as another type, such as "true" or "5432".
+type YAMLEncoder struct{}
+
+// Encode implements Encoder
+func (YAMLEncoder) Encode(w io.Writer, env map[string]string, keys []string) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range keys {
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: env[k]},
+		)
+	}
+	if len(keys) == 0 {
+		doc.Style = yaml.FlowStyle
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ParseEncoder returns the built-in encoder for an output format name: env,
+// json or yaml
+func ParseEncoder(name string) (Encoder, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "env", "dotenv":
+		return EnvEncoder{}, nil
+	case "json":
+		return JSONEncoder{Indent: "  "}, nil
+	case "yaml", "yml":
+		return YAMLEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s (valid: env, json, yaml)", name)
+	}
+}
+
+// SetEncoder selects the output format written by Convert. With an encoder
+// set, the header, source comments and "no keys matched" note are not
+// written, and keys-only and values-only modes do not apply; the encoder
+// receives the pairs after filtering, sorting and limiting. A nil encoder
+// restores the default .env output.
+func (c *Converter) SetEncoder(e Encoder) {
+	c.encoder = e
+}
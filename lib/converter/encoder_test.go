@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestEncoders(t *testing.T) {
+	env := map[string]string{
+		"HOST":    "localhost",
+		"PORT":    "5432",
+		"DEBUG":   "true",
+		"EMPTY":   "",
+		"QUOTED":  `say "hi"`,
+		"NEWLINE": "a\nb",
+	}
+	keys := []string{"HOST", "PORT", "DEBUG", "EMPTY", "QUOTED", "NEWLINE"}
+
+	tests := []struct {
+		name    string
+		encoder Encoder
+		keys    []string
+		want    string
+	}{
+		{
+			name:    "env",
+			encoder: EnvEncoder{},
+			keys:    keys,
+			want:    "HOST=localhost\nPORT=5432\nDEBUG=true\nEMPTY=\nQUOTED=say \"hi\"\nNEWLINE=a\\nb\n",
+		},
+		{
+			name:    "env with separator",
+			encoder: EnvEncoder{Separator: ": "},
+			keys:    []string{"HOST"},
+			want:    "HOST: localhost\n",
+		},
+		{
+			name:    "json",
+			encoder: JSONEncoder{},
+			keys:    keys,
+			want:    `{"HOST":"localhost","PORT":"5432","DEBUG":"true","EMPTY":"","QUOTED":"say \"hi\"","NEWLINE":"a\nb"}` + "\n",
+		},
+		{
+			name:    "json indented",
+			encoder: JSONEncoder{Indent: "  "},
+			keys:    []string{"PORT", "HOST"},
+			want:    "{\n  \"PORT\": \"5432\",\n  \"HOST\": \"localhost\"\n}\n",
+		},
+		{
+			name:    "json empty",
+			encoder: JSONEncoder{Indent: "  "},
+			want:    "{}\n",
+		},
+		{
+			name:    "yaml quotes values that are not strings",
+			encoder: YAMLEncoder{},
+			keys:    keys,
+			want:    "HOST: localhost\nPORT: \"5432\"\nDEBUG: \"true\"\nEMPTY: \"\"\nQUOTED: say \"hi\"\nNEWLINE: |-\n  a\n  b\n",
+		},
+		{
+			name:    "yaml empty",
+			encoder: YAMLEncoder{},
+			want:    "{}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tt.encoder.Encode(&out, env, tt.keys); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Encode() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoders_RoundTrip(t *testing.T) {
+	env := map[string]string{"HOST": "localhost", "PORT": "5432", "DEBUG": "true", "EMPTY": "", "NOTE": "a: b"}
+	keys := []string{"DEBUG", "EMPTY", "HOST", "NOTE", "PORT"}
+
+	for name, encoder := range map[string]Encoder{"json": JSONEncoder{Indent: "  "}, "yaml": YAMLEncoder{}} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := encoder.Encode(&out, env, keys); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			// Reading the output back yields the same pairs
+			c := New(yaml.New())
+			if name == "json" {
+				c = New(json.New())
+			}
+			got, err := c.ConvertMap(&out)
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, env) {
+				t.Errorf("round trip = %v, want %v", got, env)
+			}
+		})
+	}
+}
+
+func TestParseEncoder(t *testing.T) {
+	for name, want := range map[string]Encoder{"": EnvEncoder{}, "env": EnvEncoder{}, "JSON": JSONEncoder{Indent: "  "}, "yml": YAMLEncoder{}} {
+		got, err := ParseEncoder(name)
+		if err != nil {
+			t.Errorf("ParseEncoder(%q) error = %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseEncoder(%q) = %#v, want %#v", name, got, want)
+		}
+	}
+	if _, err := ParseEncoder("toml"); err == nil {
+		t.Error("ParseEncoder(toml) succeeded, want error")
+	}
+}
+
+func TestConverter_SetEncoder(t *testing.T) {
+	input := "database:\n  host: localhost\n  port: 5432\napi:\n  key: secret\n"
+
+	// A custom encoder supplied by a library user sees the final pairs
+	var gotKeys []string
+	custom := EncoderFunc(func(w io.Writer, env map[string]string, keys []string) error {
+		gotKeys = keys
+		for _, k := range keys {
+			fmt.Fprintf(w, "export %s=%q\n", k, env[k])
+		}
+		return nil
+	})
+
+	c := New(yaml.New())
+	c.SetEncoder(custom)
+	c.SetFilterPatterns([]string{"DATABASE_*"}, nil, GlobMatcher{})
+	c.SetLimit(1)
+
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if want := "export DATABASE_HOST=\"localhost\"\n"; out.String() != want {
+		t.Errorf("Convert() = %q, want %q", out.String(), want)
+	}
+	if len(gotKeys) != 1 {
+		t.Errorf("encoder got keys %v, want one after the limit", gotKeys)
+	}
+
+	// Encoder failures are write errors
+	c.SetEncoder(EncoderFunc(func(io.Writer, map[string]string, []string) error {
+		return errors.New("disk full")
+	}))
+	var writeErr *WriteError
+	if err := c.Convert(strings.NewReader(input), &out); !errors.As(err, &writeErr) {
+		t.Errorf("Convert() error = %v, want WriteError", err)
+	}
+
+	// A nil encoder restores the default output with its header
+	c.SetEncoder(nil)
+	out.Reset()
+	if err := c.Convert(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "# This file was auto-generated by cfg2env") {
+		t.Errorf("Convert() = %q, want the default .env output", out.String())
+	}
+}
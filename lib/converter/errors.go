@@ -0,0 +1,116 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseError reports that the plugin failed to parse the input
+type ParseError struct {
+	// Plugin is the name of the plugin that failed
+	Plugin string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return "parsing error: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// WriteError reports that output could not be written
+type WriteError struct {
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return "writing error: " + e.Err.Error()
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError reports converted pairs that fail a check, such as invalid
+// keys, oversize values or conflicting values when merging
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateKeyError reports source keys that normalize to the same output key
+type DuplicateKeyError struct {
+	// Keys maps each duplicated output key to the source keys producing it
+	Keys map[string][]string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	msgs := make([]string, 0, len(e.Keys))
+	for key, originalKeys := range e.Keys {
+		// Check if it's an exact duplicate or case-insensitive duplicate
+		allSame := true
+		for i := 1; i < len(originalKeys); i++ {
+			if originalKeys[i] != originalKeys[0] {
+				allSame = false
+				break
+			}
+		}
+
+		if allSame {
+			msgs = append(msgs, fmt.Sprintf("duplicate key '%s'", originalKeys[0]))
+		} else {
+			// Sort for consistent error messages
+			sortedKeys := make([]string, len(originalKeys))
+			copy(sortedKeys, originalKeys)
+			sort.Strings(sortedKeys)
+			msgs = append(msgs, fmt.Sprintf("duplicate key '%s' (found as '%s')", key, strings.Join(sortedKeys, "' and '")))
+		}
+	}
+	sort.Strings(msgs)
+	return "duplicate keys found: " + strings.Join(msgs, "; ")
+}
+
+// FilterError reports a filter pattern the matcher cannot use
+type FilterError struct {
+	Pattern string
+	Err     error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("invalid filter pattern '%s': %v", e.Pattern, e.Err)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+// MissingKeysError reports required keys that are absent from the output or
+// have an empty value
+type MissingKeysError struct {
+	// Missing lists required keys absent from the output, sorted
+	Missing []string
+
+	// Empty lists required keys present with an empty value, sorted
+	Empty []string
+}
+
+func (e *MissingKeysError) Error() string {
+	var msgs []string
+	if len(e.Missing) > 0 {
+		msgs = append(msgs, "missing required keys: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Empty) > 0 {
+		msgs = append(msgs, "empty required keys: "+strings.Join(e.Empty, ", "))
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+)
+
+func TestConverter_ParseError(t *testing.T) {
+	c := New(json.New())
+
+	err := c.Convert(strings.NewReader(`{"a": `), io.Discard)
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Convert() error = %v (%T), want *ParseError", err, err)
+	}
+	if parseErr.Plugin != "json" {
+		t.Errorf("ParseError.Plugin = %q, want %q", parseErr.Plugin, "json")
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Convert() error = %v, want it to wrap io.ErrUnexpectedEOF", err)
+	}
+	if !strings.HasPrefix(err.Error(), "parsing error: ") {
+		t.Errorf("Convert() error = %q, want parsing error prefix", err.Error())
+	}
+}
+
+func TestConverter_WriteError(t *testing.T) {
+	c := New(json.New())
+
+	err := c.Convert(strings.NewReader(`{"a": "1"}`), &failingWriter{})
+
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("Convert() error = %v (%T), want *WriteError", err, err)
+	}
+	if writeErr.Unwrap() == nil {
+		t.Error("WriteError.Unwrap() = nil, want the writer's error")
+	}
+}
+
+func TestConverter_DuplicateKeyError(t *testing.T) {
+	c := New(&testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data: map[string]string{
+			"api_key": "a",
+			"API_KEY": "b",
+			"host":    "localhost",
+		},
+	})
+
+	_, err := c.ConvertMap(strings.NewReader(""))
+
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("ConvertMap() error = %v (%T), want *DuplicateKeyError", err, err)
+	}
+
+	got := dupErr.Keys["API_KEY"]
+	sort.Strings(got)
+	if want := []string{"API_KEY", "api_key"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DuplicateKeyError.Keys[API_KEY] = %v, want %v", got, want)
+	}
+	if len(dupErr.Keys) != 1 {
+		t.Errorf("DuplicateKeyError.Keys = %v, want only API_KEY", dupErr.Keys)
+	}
+
+	want := "duplicate keys found: duplicate key 'API_KEY' (found as 'API_KEY' and 'api_key')"
+	if err.Error() != want {
+		t.Errorf("ConvertMap() error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestConverter_FilterError(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		pattern string
+	}{
+		{"bad include", []string{"DB_["}, nil, "DB_["},
+		{"bad exclude", nil, []string{"*", "!API_["}, "!API_["},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(json.New())
+			c.SetFilterPatterns(tt.include, tt.exclude, GlobMatcher{})
+
+			_, err := c.ConvertMap(strings.NewReader(`{"db_host": "localhost"}`))
+
+			var filterErr *FilterError
+			if !errors.As(err, &filterErr) {
+				t.Fatalf("ConvertMap() error = %v (%T), want *FilterError", err, err)
+			}
+			if filterErr.Pattern != tt.pattern {
+				t.Errorf("FilterError.Pattern = %q, want %q", filterErr.Pattern, tt.pattern)
+			}
+			if !errors.Is(err, filepath.ErrBadPattern) {
+				t.Errorf("ConvertMap() error = %v, want it to wrap filepath.ErrBadPattern", err)
+			}
+		})
+	}
+}
+
+func TestConverter_FilterError_SubstringMatcher(t *testing.T) {
+	// Substring patterns are always valid
+	c := New(json.New())
+	c.SetFilterPatterns([]string{"DB_["}, nil, SubstringMatcher{})
+
+	if _, err := c.ConvertMap(strings.NewReader(`{"db_[x": "1"}`)); err != nil {
+		t.Errorf("ConvertMap() error = %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package converter
+
+import "github.com/handaber/cfg2env/plugin"
+
+// SetExtraPairs sets pairs that are added to the converted map after
+// parsing, as with a --set flag. Each key is uppercased unless SetCaseMode
+// keeps its case, and passes through the dunder, sanitize and reserved-name
+// rules like a parsed key, but is not prefixed, so it names the variable as
+// written. An extra pair replaces any parsed value for the same key without
+// counting as a duplicate, and a later pair replaces an earlier one. Extra
+// pairs are then filtered, sorted and templated like any other key.
+func (c *Converter) SetExtraPairs(pairs []plugin.KV) {
+	c.extra = pairs
+}
+
+// SetUnsetKeys sets keys that are removed from the output, as with an
+// --unset flag, whether or not the filters include them. Each key is
+// normalized like an extra pair's, so it names the variable as written.
+// Keys the output does not have are ignored.
+func (c *Converter) SetUnsetKeys(keys []string) {
+	c.unset = keys
+}
+
+// normalizeKey applies the dunder, sanitize and reserved-name rules to a key
+// in output case
+func (c *Converter) normalizeKey(key string) string {
+	key = c.processKey(key)
+	if c.sanitizeKeys {
+		key = SanitizeKey(key)
+	}
+	return c.reserveKey(key)
+}
+
+// addExtraPairs adds the extra pairs to keyMapping, replacing any parsed
+// candidates, and returns order with the new keys appended
+func (c *Converter) addExtraPairs(keyMapping map[string][]candidate, order []string) []string {
+	for _, kv := range c.extra {
+		key := c.normalizeKey(c.outputCase(kv.Key))
+		if _, ok := keyMapping[key]; !ok {
+			order = append(order, key)
+		}
+		keyMapping[key] = []candidate{{key: kv.Key, value: kv.Value, source: kv.Key}}
+	}
+	return order
+}
+
+// unsetKeys returns the normalized keys to remove from the output
+func (c *Converter) unsetKeys() map[string]bool {
+	if len(c.unset) == 0 {
+		return nil
+	}
+	keys := make(map[string]bool, len(c.unset))
+	for _, k := range c.unset {
+		keys[c.normalizeKey(c.outputCase(k))] = true
+	}
+	return keys
+}
@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_ExtraPairs(t *testing.T) {
+	data := map[string]string{
+		"database_host": "localhost",
+		"database_port": "5432",
+	}
+
+	tests := []struct {
+		name  string
+		extra []plugin.KV
+		setup func(c *Converter)
+		want  []string
+	}{
+		{
+			name:  "injects a new key",
+			extra: []plugin.KV{{Key: "api_url", Value: "https://api"}},
+			want:  []string{"API_URL=https://api", "DATABASE_HOST=localhost", "DATABASE_PORT=5432"},
+		},
+		{
+			name:  "overrides a parsed key",
+			extra: []plugin.KV{{Key: "database_port", Value: "6543"}},
+			want:  []string{"DATABASE_HOST=localhost", "DATABASE_PORT=6543"},
+		},
+		{
+			name:  "later pairs win",
+			extra: []plugin.KV{{Key: "DEBUG", Value: "false"}, {Key: "debug", Value: "true"}},
+			want:  []string{"DATABASE_HOST=localhost", "DATABASE_PORT=5432", "DEBUG=true"},
+		},
+		{
+			name:  "keys are normalized but not prefixed",
+			extra: []plugin.KV{{Key: "log-level", Value: "info"}},
+			setup: func(c *Converter) {
+				c.SetPrefix("APP")
+				c.SetSanitizeKeys(true)
+			},
+			want: []string{"APP_DATABASE_HOST=localhost", "APP_DATABASE_PORT=5432", "LOG_LEVEL=info"},
+		},
+		{
+			name:  "filtered like other keys",
+			extra: []plugin.KV{{Key: "api_url", Value: "https://api"}},
+			setup: func(c *Converter) { c.SetFilterPatterns([]string{"DATABASE_*"}, nil, GlobMatcher{}) },
+			want:  []string{"DATABASE_HOST=localhost", "DATABASE_PORT=5432"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			c.SetExtraPairs(tt.extra)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			var buf bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			got := outputPairs(buf.String())
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Convert() pairs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_UnsetKeys(t *testing.T) {
+	data := map[string]string{
+		"database_host": "localhost",
+		"database_port": "5432",
+	}
+
+	tests := []struct {
+		name  string
+		unset []string
+		setup func(c *Converter)
+		want  []string
+	}{
+		{
+			name:  "removes an existing key",
+			unset: []string{"database_port"},
+			want:  []string{"DATABASE_HOST=localhost"},
+		},
+		{
+			name:  "absent key is a no-op",
+			unset: []string{"MISSING"},
+			want:  []string{"DATABASE_HOST=localhost", "DATABASE_PORT=5432"},
+		},
+		{
+			name:  "regardless of filters",
+			unset: []string{"DATABASE_HOST"},
+			setup: func(c *Converter) { c.SetFilterPatterns([]string{"DATABASE_*"}, nil, GlobMatcher{}) },
+			want:  []string{"DATABASE_PORT=5432"},
+		},
+		{
+			name:  "normalized like output keys",
+			unset: []string{"database__port"},
+			setup: func(c *Converter) { c.SetDunderCollapse(true) },
+			want:  []string{"DATABASE_HOST=localhost"},
+		},
+		{
+			name:  "removes extra pairs",
+			unset: []string{"REGION"},
+			setup: func(c *Converter) { c.SetExtraPairs([]plugin.KV{{Key: "region", Value: "eu"}}) },
+			want:  []string{"DATABASE_HOST=localhost", "DATABASE_PORT=5432"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			c.SetUnsetKeys(tt.unset)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			var buf bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			got := outputPairs(buf.String())
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Convert() pairs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// outputPairs returns the KEY=VALUE lines of output, skipping comments and
+// blank lines
+func outputPairs(output string) []string {
+	var pairs []string
+	for _, line := range strings.Split(output, "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			pairs = append(pairs, line)
+		}
+	}
+	return pairs
+}
+
+// outputKeys returns the keys of the pairs outputPairs finds in output
+func outputKeys(output string) []string {
+	keys := outputPairs(output)
+	for i, pair := range keys {
+		keys[i], _, _ = strings.Cut(pair, "=")
+	}
+	return keys
+}
@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 )
@@ -19,15 +20,61 @@ func (g GlobMatcher) Match(pattern, key string) bool {
 	return matched
 }
 
+// Validate returns filepath.ErrBadPattern if pattern is malformed
+func (g GlobMatcher) Validate(pattern string) error {
+	_, err := filepath.Match(pattern, "")
+	return err
+}
+
+// SubstringMatcher matches keys that contain the pattern, ignoring case
+type SubstringMatcher struct{}
+
+// Match returns true if key contains pattern, ignoring case
+func (m SubstringMatcher) Match(pattern, key string) bool {
+	return strings.Contains(strings.ToUpper(key), strings.ToUpper(pattern))
+}
+
+// ParseMatcher converts a flag value into a Matcher
+func ParseMatcher(s string) (Matcher, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "glob":
+		return GlobMatcher{}, nil
+	case "substring":
+		return SubstringMatcher{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported matcher: %s (valid: glob, substring)", s)
+	}
+}
+
 // filter holds normalized patterns and applies include/exclude logic
 type filter struct {
 	include []string
 	exclude []string
 	matcher Matcher
+
+	// original matches patterns against source keys rather than output keys
+	original bool
+}
+
+// includes reports whether the output key, produced from candidates, passes
+// the filter. A filter on original keys passes it if any of its source keys
+// does, ignoring case.
+func (f *filter) includes(key string, candidates []candidate) bool {
+	if !f.original {
+		return f.shouldInclude(key)
+	}
+	for _, cand := range candidates {
+		if f.shouldInclude(strings.ToUpper(cand.source)) {
+			return true
+		}
+	}
+	return false
 }
 
 // shouldInclude determines if a key should be included based on filter rules
-// Precedence: include patterns first (whitelist), then exclude patterns (blacklist)
+// Precedence: include patterns first (whitelist), then exclude patterns (blacklist).
+// Exclude patterns are applied in order and the last one matching the key
+// wins; a pattern starting with "!" re-includes the keys it matches.
 func (f *filter) shouldInclude(key string) bool {
 	// If include patterns specified, key must match at least one
 	if len(f.include) > 0 {
@@ -43,38 +90,66 @@ func (f *filter) shouldInclude(key string) bool {
 		}
 	}
 
-	// Check exclude patterns - if any match, exclude the key
+	// Check exclude patterns in order, letting negated patterns re-include
+	excluded := false
 	for _, pattern := range f.exclude {
-		if f.matcher.Match(pattern, key) {
-			return false
+		if negated := strings.TrimPrefix(pattern, "!"); negated != pattern {
+			if excluded && f.matcher.Match(negated, key) {
+				excluded = false
+			}
+		} else if !excluded && f.matcher.Match(pattern, key) {
+			excluded = true
 		}
 	}
 
-	return true
+	return !excluded
 }
 
-// normalizePatterns applies the same normalization as keys (uppercase + dunder + trim)
-func (c *Converter) normalizePatterns(patterns []string) []string {
+// validate checks every pattern with the matcher, if it can validate them
+func (f *filter) validate() error {
+	v, ok := f.matcher.(interface{ Validate(pattern string) error })
+	if !ok {
+		return nil
+	}
+	for _, patterns := range [][]string{f.include, f.exclude} {
+		for _, pattern := range patterns {
+			if err := v.Validate(strings.TrimPrefix(pattern, "!")); err != nil {
+				return &FilterError{Pattern: pattern, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// normalizePatterns applies the same normalization as keys (uppercase + dunder + trim),
+// and sanitizes patterns for matcher when keys are sanitized
+func (c *Converter) normalizePatterns(patterns []string, matcher Matcher) []string {
 	if len(patterns) == 0 {
 		return nil
 	}
 
+	_, substring := matcher.(SubstringMatcher)
 	normalized := make([]string, 0, len(patterns))
 	for _, p := range patterns {
 		p = strings.TrimSpace(p)
 		if p == "" {
 			continue
 		}
-		normalized = append(normalized, c.processKey(strings.ToUpper(p)))
+		p = c.processKey(c.outputCase(p))
+		if c.sanitizeKeys {
+			p = sanitizePattern(p, !substring)
+		}
+		normalized = append(normalized, p)
 	}
 	return normalized
 }
 
 // SetFilterPatterns configures the converter to filter keys by include/exclude patterns
-// Patterns are normalized through the same pipeline as keys (uppercase + dunder processing)
+// Patterns are normalized through the same pipeline as keys (uppercase + dunder processing,
+// and sanitizing if set before them)
 func (c *Converter) SetFilterPatterns(include, exclude []string, matcher Matcher) {
-	normalizedInclude := c.normalizePatterns(include)
-	normalizedExclude := c.normalizePatterns(exclude)
+	normalizedInclude := c.normalizePatterns(include, matcher)
+	normalizedExclude := c.normalizePatterns(exclude, matcher)
 
 	// If no patterns remain after normalization, disable filtering
 	if len(normalizedInclude) == 0 && len(normalizedExclude) == 0 {
@@ -88,3 +163,32 @@ func (c *Converter) SetFilterPatterns(include, exclude []string, matcher Matcher
 		matcher: matcher,
 	}
 }
+
+// SetOriginalFilterPatterns is like SetFilterPatterns, but matches patterns
+// against keys as written in the source, before normalization: dotted paths
+// such as database.host for nested formats, and the keys themselves for flat
+// ones. Patterns are only trimmed and matched ignoring case, so database.*
+// selects every key under database.
+func (c *Converter) SetOriginalFilterPatterns(include, exclude []string, matcher Matcher) {
+	upper := func(patterns []string) []string {
+		var out []string
+		for _, p := range patterns {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, strings.ToUpper(p))
+			}
+		}
+		return out
+	}
+
+	include, exclude = upper(include), upper(exclude)
+	if len(include) == 0 && len(exclude) == 0 {
+		c.filter = nil
+		return
+	}
+	c.filter = &filter{
+		include:  include,
+		exclude:  exclude,
+		matcher:  matcher,
+		original: true,
+	}
+}
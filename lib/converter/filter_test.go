@@ -1,7 +1,14 @@
 package converter
 
 import (
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
 )
 
 func TestGlobMatcher(t *testing.T) {
@@ -236,7 +243,7 @@ func TestNormalizePatterns(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c.SetDunder(tt.dunder)
-			got := c.normalizePatterns(tt.patterns)
+			got := c.normalizePatterns(tt.patterns, GlobMatcher{})
 			if len(got) != len(tt.want) {
 				t.Errorf("normalizePatterns() length = %d, want %d\ngot:  %v\nwant: %v",
 					len(got), len(tt.want), got, tt.want)
@@ -303,3 +310,257 @@ func TestSetFilterPatterns(t *testing.T) {
 		})
 	}
 }
+
+func TestSubstringMatcher(t *testing.T) {
+	matcher := SubstringMatcher{}
+
+	tests := []struct {
+		name    string
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"in middle", "SECRET", "API_SECRET_KEY", true},
+		{"at start", "DATABASE", "DATABASE_HOST", true},
+		{"at end", "PASSWORD", "DATABASE_PASSWORD", true},
+		{"whole key", "API_KEY", "API_KEY", true},
+		{"lowercase pattern", "secret", "API_SECRET_KEY", true},
+		{"no match", "TOKEN", "DATABASE_HOST", false},
+		{"longer than key", "DATABASE_HOST_NAME", "DATABASE_HOST", false},
+		{"glob characters are literal", "DB_*", "DB_HOST", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matcher.Match(tt.pattern, tt.key); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMatcher(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Matcher
+		wantErr bool
+	}{
+		{"", GlobMatcher{}, false},
+		{"glob", GlobMatcher{}, false},
+		{"substring", SubstringMatcher{}, false},
+		{" Substring ", SubstringMatcher{}, false},
+		{"regex", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMatcher(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMatcher(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMatcher(%q) = %T, want %T", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverterWithSubstringMatcher(t *testing.T) {
+	c := New(&testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data: map[string]string{
+			"api_secret":   "s3cret",
+			"secret_token": "t0ken",
+			"api_host":     "localhost",
+		},
+	})
+	c.SetFilterPatterns(nil, []string{"secret"}, SubstringMatcher{})
+
+	got, err := c.ConvertMap(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	want := map[string]string{"API_HOST": "localhost"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %v, want %v", got, want)
+	}
+}
+
+func TestConverterWithNegatedExclude(t *testing.T) {
+	data := map[string]string{
+		"database_host":     "localhost",
+		"database_password": "secret",
+		"api_host":          "api.example.com",
+		"api_token":         "t0ken",
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "exclude everything except database keys",
+			exclude: []string{"*", "!DATABASE_*"},
+			want:    []string{"DATABASE_HOST", "DATABASE_PASSWORD"},
+		},
+		{
+			name:    "later exclude overrides earlier re-include",
+			exclude: []string{"*", "!DATABASE_*", "*_PASSWORD"},
+			want:    []string{"DATABASE_HOST"},
+		},
+		{
+			name:    "re-include after a narrower exclude",
+			exclude: []string{"*_HOST", "*_TOKEN", "!API_*"},
+			want:    []string{"API_HOST", "API_TOKEN", "DATABASE_PASSWORD"},
+		},
+		{
+			name:    "negation alone excludes nothing",
+			exclude: []string{"!DATABASE_*"},
+			want:    []string{"API_HOST", "API_TOKEN", "DATABASE_HOST", "DATABASE_PASSWORD"},
+		},
+		{
+			name:    "negation cannot bypass include",
+			include: []string{"API_*"},
+			exclude: []string{"*", "!DATABASE_*", "!API_HOST"},
+			want:    []string{"API_HOST"},
+		},
+		{
+			name:    "lowercase negated pattern is normalized",
+			exclude: []string{"*", "!database_h*"},
+			want:    []string{"DATABASE_HOST"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			c.SetFilterPatterns(tt.include, tt.exclude, GlobMatcher{})
+
+			got, err := c.ConvertMap(strings.NewReader(""))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			keys := make([]string, 0, len(got))
+			for k := range got {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("ConvertMap() keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverterWithOriginalFilter(t *testing.T) {
+	input := `database:
+  host: db
+  port: 5432
+database_url: postgres://
+servers:
+  - host: web
+api:
+  database: shared
+`
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		matcher Matcher
+		want    []string
+	}{
+		{
+			name:    "dotted path",
+			include: []string{"database.*"},
+			want:    []string{"DATABASE_HOST", "DATABASE_PORT"},
+		},
+		{
+			name:    "case is ignored",
+			include: []string{"DATABASE.HOST"},
+			want:    []string{"DATABASE_HOST"},
+		},
+		{
+			name:    "array index",
+			include: []string{"servers.*.host"},
+			want:    []string{"SERVERS_0_HOST"},
+		},
+		{
+			name:    "exclude with re-include",
+			exclude: []string{"*.*", "!database.port"},
+			want:    []string{"DATABASE_PORT", "DATABASE_URL"},
+		},
+		{
+			name:    "substring",
+			include: []string{"api.data"},
+			matcher: SubstringMatcher{},
+			want:    []string{"API_DATABASE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := tt.matcher
+			if matcher == nil {
+				matcher = GlobMatcher{}
+			}
+			c := New(yaml.New())
+			c.SetOriginalFilterPatterns(tt.include, tt.exclude, matcher)
+
+			got, err := c.ConvertMap(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			var keys []string
+			for k := range got {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("ConvertMap() keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverterWithOriginalFilter_Sources(t *testing.T) {
+	// Stream parsing reports paths too
+	c := New(json.New())
+	c.SetSort(SortNone)
+	c.SetOriginalFilterPatterns([]string{"1.database.*"}, nil, GlobMatcher{})
+	got, err := c.ConvertMap(strings.NewReader(`[{"database": {"host": "a"}}, {"database": {"host": "b"}}]`))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if want := map[string]string{"1_DATABASE_HOST": "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %v, want %v", got, want)
+	}
+
+	// Flat plugins are matched on their keys as written
+	c = New(&testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data:       map[string]string{"database.host": "db", "database_port": "5432"},
+	})
+	c.SetOriginalFilterPatterns([]string{"database.*"}, nil, GlobMatcher{})
+	got, err = c.ConvertMap(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if want := map[string]string{"DATABASE.HOST": "db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %v, want %v", got, want)
+	}
+
+	// Patterns are not normalized like keys
+	c = New(yaml.New())
+	c.SetDunder(1)
+	c.SetOriginalFilterPatterns([]string{"a__b"}, nil, GlobMatcher{})
+	got, err = c.ConvertMap(strings.NewReader("a__b: x\nab: y\n"))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if want := map[string]string{"A_B": "x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrKeyNotFound is returned by Get and Lookup when the key is not in the
+// converted output
+var ErrKeyNotFound = errors.New("key not found")
+
+// Get converts r and returns the value of key. The key is normalized like
+// output keys, uppercased and dunder-processed, so "database__host" finds
+// DATABASE_HOST with a dunder of 1. A key missing from the output, including
+// one dropped by filters, is reported with an error wrapping ErrKeyNotFound.
+func (c *Converter) Get(r io.Reader, key string) (string, error) {
+	env, err := c.ConvertMap(r)
+	if err != nil {
+		return "", err
+	}
+	return c.Lookup(env, key)
+}
+
+// Lookup returns the value of key in already converted pairs, normalizing
+// the key as Get does
+func (c *Converter) Lookup(env map[string]string, key string) (string, error) {
+	normalized := c.normalizeKey(c.outputCase(strings.TrimSpace(key)))
+	v, ok := env[normalized]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, normalized)
+	}
+	return v, nil
+}
@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestConverter_Get(t *testing.T) {
+	input := `database__host: localhost
+database:
+  port: 5432
+api_key: ""
+`
+
+	tests := []struct {
+		name    string
+		setup   func(c *Converter)
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{name: "present", key: "DATABASE_PORT", want: "5432"},
+		{name: "present but empty", key: "API_KEY", want: ""},
+		{name: "lowercase key", key: "database_port", want: "5432"},
+		{name: "absent", key: "DATABASE_USER", wantErr: true},
+		{
+			name:  "dunder-processed key",
+			setup: func(c *Converter) { c.SetDunder(1) },
+			key:   "database__host",
+			want:  "localhost",
+		},
+		{
+			name: "filtered out key is absent",
+			setup: func(c *Converter) {
+				c.SetFilterPatterns(nil, []string{"DATABASE_*"}, GlobMatcher{})
+			},
+			key:     "DATABASE_PORT",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(yaml.New())
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			got, err := c.Get(strings.NewReader(input), tt.key)
+			if tt.wantErr {
+				if !errors.Is(err, ErrKeyNotFound) {
+					t.Errorf("Get() error = %v, want ErrKeyNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_Get_ParseError(t *testing.T) {
+	_, err := New(yaml.New()).Get(strings.NewReader("a: [1"), "A")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("Get() error = %v, want *ParseError", err)
+	}
+}
@@ -0,0 +1,43 @@
+package converter
+
+import "bufio"
+
+// SetGroupBreaks separates groups of keys sharing a top-level prefix, as
+// ordered by SortGrouped, with a blank line wherever the prefix changes
+// between consecutive keys. With headers, each group also starts with a
+// comment naming its prefix, such as "# DATABASE". Breaks only change the
+// layout of env output; keys-only and values-only output has none.
+func (c *Converter) SetGroupBreaks(on, headers bool) {
+	c.groupBreaks = on
+	c.groupHeaders = on && headers
+}
+
+// writeGroupBreak writes the separator before key when it starts a new
+// group: prev is the key written before it, or empty for the first key,
+// which only gets the group's header
+func (c *Converter) writeGroupBreak(w *bufio.Writer, prev, key string) error {
+	if !c.groupBreaks || c.bare() {
+		return nil
+	}
+	group := topLevelPrefix(key)
+	if prev != "" {
+		if topLevelPrefix(prev) == group {
+			return nil
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return &WriteError{Err: err}
+		}
+	}
+	if c.groupHeaders {
+		return c.writeComment(w, group)
+	}
+	return nil
+}
+
+// previous returns the key before keys[i], or an empty string for the first
+func previous(keys []string, i int) string {
+	if i == 0 {
+		return ""
+	}
+	return keys[i-1]
+}
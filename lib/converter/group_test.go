@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_GroupBreaks(t *testing.T) {
+	data := map[string]string{
+		"database_host": "localhost",
+		"database_port": "5432",
+		"api_key":       "abc123",
+		"api_url":       "https://api",
+		"debug":         "true",
+	}
+	header := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: test\n#\n\n"
+
+	tests := []struct {
+		name  string
+		setup func(c *Converter)
+		want  string
+	}{
+		{
+			name:  "off by default",
+			setup: func(c *Converter) {},
+			want:  header + "API_KEY=abc123\nAPI_URL=https://api\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\nDEBUG=true\n",
+		},
+		{
+			name:  "blank lines between prefixes",
+			setup: func(c *Converter) { c.SetGroupBreaks(true, false) },
+			want:  header + "API_KEY=abc123\nAPI_URL=https://api\n\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\n\nDEBUG=true\n",
+		},
+		{
+			name:  "headers",
+			setup: func(c *Converter) { c.SetGroupBreaks(true, true) },
+			want: header + "# API\nAPI_KEY=abc123\nAPI_URL=https://api\n\n# DATABASE\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\n\n" +
+				"# DEBUG\nDEBUG=true\n",
+		},
+		{
+			name: "headers use the comment prefix",
+			setup: func(c *Converter) {
+				c.SetGroupBreaks(true, true)
+				c.SetCommentPrefix(";")
+				c.SetFilterPatterns([]string{"DATABASE_*"}, nil, GlobMatcher{})
+			},
+			want: "; This file was auto-generated by cfg2env\n; Version: dev\n; Plugin: test\n;\n\n" +
+				"; DATABASE\nDATABASE_HOST=localhost\nDATABASE_PORT=5432\n",
+		},
+		{
+			name: "headers need breaks",
+			setup: func(c *Converter) {
+				c.SetGroupBreaks(false, true)
+				c.SetFilterPatterns([]string{"API_*"}, nil, GlobMatcher{})
+			},
+			want: header + "API_KEY=abc123\nAPI_URL=https://api\n",
+		},
+		{
+			name: "keys-only output is not grouped",
+			setup: func(c *Converter) {
+				c.SetGroupBreaks(true, true)
+				c.SetKeysOnly(true)
+			},
+			want: "API_KEY\nAPI_URL\nDATABASE_HOST\nDATABASE_PORT\nDEBUG\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			tt.setup(c)
+			var buf bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Convert() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_GroupBreaksWriteMap(t *testing.T) {
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test")})
+	c.SetGroupBreaks(true, false)
+
+	var buf bytes.Buffer
+	env := map[string]string{"A_X": "1", "A_Y": "2", "B_X": "3"}
+	if err := c.WriteMap(&buf, env); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+	got := buf.String()
+	if !strings.HasSuffix(got, "\n\nA_X=1\nA_Y=2\n\nB_X=3\n") {
+		t.Errorf("WriteMap() = %q, want a break between A_ and B_ keys only", got)
+	}
+}
@@ -0,0 +1,70 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// contentHashLabel starts the header line holding the content hash
+const contentHashLabel = "Content-Hash: "
+
+// SetContentHash controls whether the header records a hash of the pairs
+// and comments written below it, as "# Content-Hash: sha256:<hex>". The
+// hash ignores the header itself, so output whose only change is the
+// --comment-header timestamp or the version keeps its hash. Compare it
+// with ReadContentHash to skip rewriting unchanged files. Output without a
+// header, such as keys-only output, records no hash.
+func (c *Converter) SetContentHash(enabled bool) {
+	c.contentHash = enabled
+}
+
+// ReadContentHash returns the content hash recorded in the header of output
+// written with SetContentHash, or "" if the header records none
+func ReadContentHash(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// The header ends at the first blank line
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, contentHashLabel); i >= 0 {
+			return strings.TrimSpace(line[i+len(contentHashLabel):]), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// writeWithHeader writes the header for pluginName followed by the output
+// of body. With a content hash, body is written to a buffer first so the
+// header can record its hash.
+func (c *Converter) writeWithHeader(w *bufio.Writer, pluginName string, body func(*bufio.Writer) error) error {
+	if !c.contentHash || c.bare() {
+		if err := c.writeHeader(w, pluginName, ""); err != nil {
+			return err
+		}
+		return body(w)
+	}
+
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	if err := body(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return &WriteError{Err: err}
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	if err := c.writeHeader(w, pluginName, "sha256:"+hex.EncodeToString(sum[:])); err != nil {
+		return err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_ContentHash(t *testing.T) {
+	env := map[string]string{"host": "localhost", "port": "5432"}
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return env, nil
+		},
+	}
+
+	convert := func(t *testing.T, setup func(c *Converter)) string {
+		t.Helper()
+		c := New(p)
+		c.SetContentHash(true)
+		if setup != nil {
+			setup(c)
+		}
+		var out bytes.Buffer
+		if err := c.Convert(strings.NewReader(""), &out); err != nil {
+			t.Fatalf("Convert() error = %v", err)
+		}
+		return out.String()
+	}
+	hashOf := func(t *testing.T, out string) string {
+		t.Helper()
+		hash, err := ReadContentHash(strings.NewReader(out))
+		if err != nil {
+			t.Fatalf("ReadContentHash() error = %v", err)
+		}
+		return hash
+	}
+
+	base := convert(t, nil)
+	hash := hashOf(t, base)
+	if !strings.HasPrefix(hash, "sha256:") {
+		t.Fatalf("ReadContentHash() = %q, want a sha256 hash in\n%s", hash, base)
+	}
+
+	t.Run("header changes keep the hash", func(t *testing.T) {
+		out := convert(t, func(c *Converter) {
+			c.SetVersion("9.9.9")
+			c.SetCommentHeader(true, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+		})
+		if got := hashOf(t, out); got != hash {
+			t.Errorf("hash = %q, want %q", got, hash)
+		}
+	})
+
+	t.Run("value changes change the hash", func(t *testing.T) {
+		env = map[string]string{"host": "localhost", "port": "5433"}
+		defer func() { env = map[string]string{"host": "localhost", "port": "5432"} }()
+		if got := hashOf(t, convert(t, nil)); got == hash {
+			t.Errorf("hash = %q, want it to differ", got)
+		}
+	})
+
+	t.Run("pairs follow the header", func(t *testing.T) {
+		if !strings.Contains(base, "HOST=localhost\nPORT=5432\n") {
+			t.Errorf("output is missing the pairs:\n%s", base)
+		}
+	})
+
+	t.Run("no hash without a header", func(t *testing.T) {
+		out := convert(t, func(c *Converter) { c.SetKeysOnly(true) })
+		if strings.Contains(out, contentHashLabel) {
+			t.Errorf("keys-only output records a hash:\n%s", out)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := New(p).Convert(strings.NewReader(""), &out); err != nil {
+			t.Fatalf("Convert() error = %v", err)
+		}
+		if got := hashOf(t, out.String()); got != "" {
+			t.Errorf("ReadContentHash() = %q, want none", got)
+		}
+	})
+}
+
+func TestConverter_ContentHashWriteMap(t *testing.T) {
+	env := map[string]string{"HOST": "localhost", "PORT": "5432"}
+	write := func(t *testing.T, names ...string) string {
+		t.Helper()
+		c := New(&mockPlugin{BasePlugin: plugin.NewBasePlugin("mock")})
+		c.SetContentHash(true)
+		var out bytes.Buffer
+		if err := c.WriteMap(&out, env, names...); err != nil {
+			t.Fatalf("WriteMap() error = %v", err)
+		}
+		hash, err := ReadContentHash(&out)
+		if err != nil {
+			t.Fatalf("ReadContentHash() error = %v", err)
+		}
+		return hash
+	}
+
+	// The source formats only appear in the header
+	yaml, merged := write(t, "yaml"), write(t, "yaml", "json")
+	if yaml == "" || yaml != merged {
+		t.Errorf("hashes = %q and %q, want equal", yaml, merged)
+	}
+}
+
+func TestReadContentHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"header hash", "# Generated by cfg2env\n# Content-Hash: sha256:abc\n\nA=1\n", "sha256:abc"},
+		{"no hash", "# Generated by cfg2env\n\nA=1\n", ""},
+		{"only the header counts", "# Generated by cfg2env\n\n# Content-Hash: sha256:abc\n", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadContentHash(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ReadContentHash() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadContentHash() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
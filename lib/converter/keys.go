@@ -0,0 +1,122 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SetSanitizeKeys controls whether characters that are not legal in shell
+// identifiers are replaced with underscores. Keys starting with a digit are
+// prefixed with an underscore. Filter, redact, template and schema patterns
+// are sanitized the same way, so set this before them.
+func (c *Converter) SetSanitizeKeys(sanitize bool) {
+	c.sanitizeKeys = sanitize
+}
+
+// SetStrictKeys controls whether output keys that are not legal shell
+// identifiers cause an error. Keys are checked after sanitizing. In strict
+// mode, plugin warnings about keys produced by more than one path are also
+// reported as errors.
+func (c *Converter) SetStrictKeys(strict bool) {
+	c.strictKeys = strict
+}
+
+// isIdentByte reports whether b may appear in a shell identifier
+func isIdentByte(b byte, first bool) bool {
+	switch {
+	case b == '_', b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z':
+		return true
+	case b >= '0' && b <= '9':
+		return !first
+	default:
+		return false
+	}
+}
+
+// IsValidKey reports whether key matches [A-Za-z_][A-Za-z0-9_]*
+func IsValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !isIdentByte(key[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizeKey replaces characters that are not legal in shell identifiers
+// with underscores and prefixes keys starting with a digit with an underscore
+func SanitizeKey(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if r < 0x80 && isIdentByte(byte(r), false) {
+			if i == 0 && r >= '0' && r <= '9' {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// sanitizePattern applies SanitizeKey to the literal characters of a
+// pattern so that it matches sanitized keys. A leading "!" negating an
+// exclude pattern is kept, and if glob is set so are the wildcards and
+// character classes of a glob, escaped characters being sanitized like
+// literal ones. The digit prefix is only added to globs, since a substring
+// may match anywhere in a key.
+func sanitizePattern(pattern string, glob bool) string {
+	var b strings.Builder
+	if rest := strings.TrimPrefix(pattern, "!"); rest != pattern {
+		b.WriteByte('!')
+		pattern = rest
+	}
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		switch {
+		case glob && (r == '*' || r == '?'):
+			b.WriteRune(r)
+		case glob && r == '[' && strings.IndexByte(pattern[i:], ']') > 0:
+			size = strings.IndexByte(pattern[i:], ']') + 1
+			b.WriteString(pattern[i : i+size])
+		case glob && r == '\\' && i+size < len(pattern):
+			next, n := utf8.DecodeRuneInString(pattern[i+size:])
+			if next < 0x80 && isIdentByte(byte(next), false) {
+				b.WriteString(pattern[i : i+size+n])
+			} else {
+				b.WriteByte('_')
+			}
+			size += n
+		case r < 0x80 && isIdentByte(byte(r), false):
+			if glob && i == 0 && r >= '0' && r <= '9' {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// checkKeys returns an error listing every key that is not a legal shell identifier
+func checkKeys(keys []string) error {
+	var invalid []string
+	for _, k := range keys {
+		if !IsValidKey(k) {
+			invalid = append(invalid, fmt.Sprintf("'%s'", k))
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	sort.Strings(invalid)
+	return &ValidationError{Err: fmt.Errorf("invalid keys found (must match [A-Za-z_][A-Za-z0-9_]*): %s", strings.Join(invalid, ", "))}
+}
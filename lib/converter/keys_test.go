@@ -0,0 +1,276 @@
+package converter
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestIsValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"DATABASE_HOST", true},
+		{"_PRIVATE", true},
+		{"KEY_1", true},
+		{"", false},
+		{"1KEY", false},
+		{"MY-KEY", false},
+		{"MY KEY", false},
+		{"MY.KEY", false},
+		{"KEY_日本", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := IsValidKey(tt.key); got != tt.want {
+				t.Errorf("IsValidKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"DATABASE_HOST", "DATABASE_HOST"},
+		{"MY-KEY", "MY_KEY"},
+		{"MY KEY", "MY_KEY"},
+		{"API.V2.URL", "API_V2_URL"},
+		{"1KEY", "_1KEY"},
+		{"9", "_9"},
+		{"KEY_日本", "KEY___"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got := SanitizeKey(tt.key)
+			if got != tt.want {
+				t.Errorf("SanitizeKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+			if !IsValidKey(got) {
+				t.Errorf("SanitizeKey(%q) = %q is not a valid key", tt.key, got)
+			}
+		})
+	}
+}
+
+func TestConverter_SanitizeAndStrictKeys(t *testing.T) {
+	input := map[string]string{
+		"my-service_url": "https://example.com",
+		"log level":      "debug",
+		"2fa_enabled":    "true",
+		"database_host":  "localhost",
+	}
+
+	tests := []struct {
+		name     string
+		sanitize bool
+		strict   bool
+		want     map[string]string
+		wantErr  string
+	}{
+		{
+			name:     "sanitize",
+			sanitize: true,
+			want: map[string]string{
+				"MY_SERVICE_URL": "https://example.com",
+				"LOG_LEVEL":      "debug",
+				"_2FA_ENABLED":   "true",
+				"DATABASE_HOST":  "localhost",
+			},
+		},
+		{
+			name:    "strict",
+			strict:  true,
+			wantErr: "'2FA_ENABLED', 'LOG LEVEL', 'MY-SERVICE_URL'",
+		},
+		{
+			name:     "strict after sanitize",
+			sanitize: true,
+			strict:   true,
+			want: map[string]string{
+				"MY_SERVICE_URL": "https://example.com",
+				"LOG_LEVEL":      "debug",
+				"_2FA_ENABLED":   "true",
+				"DATABASE_HOST":  "localhost",
+			},
+		},
+		{
+			name: "default keeps keys unchanged",
+			want: map[string]string{
+				"MY-SERVICE_URL": "https://example.com",
+				"LOG LEVEL":      "debug",
+				"2FA_ENABLED":    "true",
+				"DATABASE_HOST":  "localhost",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: input}
+
+			c := New(p)
+			c.SetSanitizeKeys(tt.sanitize)
+			c.SetStrictKeys(tt.strict)
+
+			got, err := c.ConvertMap(strings.NewReader(""))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ConvertMap() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		glob    bool
+		want    string
+	}{
+		{"API.V2.*", true, "API_V2_*"},
+		{"MY-KEY?", true, "MY_KEY?"},
+		{"!MY-KEY", true, "!MY_KEY"},
+		{"[AB]-*", true, "[AB]_*"},
+		{"A\\*B\\.C\\D", true, "A_B_C\\D"},
+		{"1KEY*", true, "_1KEY*"},
+		{"[A-", true, "_A_"},
+		{"V2.URL", false, "V2_URL"},
+		{"2*", false, "2_"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizePattern(tt.pattern, tt.glob); got != tt.want {
+			t.Errorf("sanitizePattern(%q, %v) = %q, want %q", tt.pattern, tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestConverter_SanitizeKeysFilter(t *testing.T) {
+	p := &testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data: map[string]string{
+			"api.v2.url": "https://example.com",
+			"api.v2.key": "secret",
+			"api.v1.url": "https://old.example.com",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		matcher Matcher
+		want    []string
+	}{
+		{"glob", []string{"api.v2.*"}, nil, GlobMatcher{}, []string{"API_V2_KEY", "API_V2_URL"}},
+		{"negated exclude", nil, []string{"api.*", "!api.v2.url"}, GlobMatcher{}, []string{"API_V2_URL"}},
+		{"substring", []string{"v2.u"}, nil, SubstringMatcher{}, []string{"API_V2_URL"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(p)
+			c.SetSanitizeKeys(true)
+			c.SetFilterPatterns(tt.include, tt.exclude, tt.matcher)
+			env, err := c.ConvertMap(strings.NewReader(""))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			var got []string
+			for k := range env {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_SanitizeKeysCollision(t *testing.T) {
+	p := &testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data: map[string]string{
+			"my-key": "dash",
+			"my.key": "dot",
+		},
+	}
+
+	c := New(p)
+	c.SetSanitizeKeys(true)
+
+	if _, err := c.ConvertMap(strings.NewReader("")); err == nil {
+		t.Error("ConvertMap() expected duplicate key error after sanitizing, got nil")
+	}
+}
+
+func TestConverter_SeparatorCollision(t *testing.T) {
+	input := `database_host: literal
+database:
+  host: nested
+  port: 5432
+`
+
+	t.Run("warns", func(t *testing.T) {
+		var warnings bytes.Buffer
+		c := New(yaml.New())
+		c.SetWarningWriter(&warnings)
+
+		got, err := c.ConvertMap(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("ConvertMap() error = %v", err)
+		}
+		want := "Warning: key 'DATABASE_HOST' is produced by more than one path\n"
+		if warnings.String() != want {
+			t.Errorf("warnings = %q, want %q", warnings.String(), want)
+		}
+
+		// Keys are visited in sorted order, so the result is deterministic
+		if got["DATABASE_HOST"] != "literal" {
+			t.Errorf("DATABASE_HOST = %q, want %q", got["DATABASE_HOST"], "literal")
+		}
+	})
+
+	t.Run("errors in strict mode", func(t *testing.T) {
+		c := New(yaml.New())
+		c.SetStrictKeys(true)
+
+		_, err := c.ConvertMap(strings.NewReader(input))
+		if err == nil || !strings.Contains(err.Error(), "key 'DATABASE_HOST' is produced by more than one path") {
+			t.Errorf("ConvertMap() error = %v, want key collision error", err)
+		}
+	})
+
+	t.Run("no warning without collision", func(t *testing.T) {
+		var warnings bytes.Buffer
+		c := New(yaml.New())
+		c.SetWarningWriter(&warnings)
+
+		if _, err := c.ConvertMap(strings.NewReader("database:\n  host: nested\n")); err != nil {
+			t.Fatalf("ConvertMap() error = %v", err)
+		}
+		if warnings.Len() != 0 {
+			t.Errorf("warnings = %q, want none", warnings.String())
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TruncationMarker is appended to values shortened by SetMaxValueLength
+const TruncationMarker = "..."
+
+// SetMaxValueLength truncates values longer than n characters to their first
+// n characters followed by TruncationMarker, which suits previews of configs
+// holding large blobs. Truncation applies after every other value transform,
+// such as trimming and template blanking, but before line breaks are
+// escaped. Zero or a negative n allows values of any length.
+func (c *Converter) SetMaxValueLength(n int) {
+	if n < 0 {
+		n = 0
+	}
+	c.maxValueLen = n
+}
+
+// SetErrorOnOversize controls whether values longer than the maximum set by
+// SetMaxValueLength are an error instead of being truncated
+func (c *Converter) SetErrorOnOversize(fail bool) {
+	c.failOversize = fail
+}
+
+// limitValues truncates the values of keys longer than the maximum length,
+// or with failOversize returns an error listing them
+func (c *Converter) limitValues(keys []string, values map[string]string) error {
+	if c.maxValueLen == 0 {
+		return nil
+	}
+
+	var oversize []string
+	for _, k := range keys {
+		v := values[k]
+		if utf8.RuneCountInString(v) <= c.maxValueLen {
+			continue
+		}
+		if c.failOversize {
+			oversize = append(oversize, fmt.Sprintf("'%s'", k))
+			continue
+		}
+		values[k] = truncate(v, c.maxValueLen)
+	}
+	if len(oversize) == 0 {
+		return nil
+	}
+	sort.Strings(oversize)
+	return &ValidationError{Err: fmt.Errorf("values longer than %d characters: %s", c.maxValueLen, strings.Join(oversize, ", "))}
+}
+
+// truncate returns the first n characters of value followed by
+// TruncationMarker
+func truncate(value string, n int) string {
+	for i := range value {
+		if n == 0 {
+			return value[:i] + TruncationMarker
+		}
+		n--
+	}
+	return value
+}
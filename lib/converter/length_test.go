@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_MaxValueLength(t *testing.T) {
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return map[string]string{
+				"under":  "abcd",
+				"at":     "abcde",
+				"over":   "abcdefgh",
+				"runes":  "héllo wörld",
+				"padded": "  abcde  ",
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name string
+		max  int
+		trim bool
+		want map[string]string
+	}{
+		{
+			name: "unlimited by default",
+			want: map[string]string{"UNDER": "abcd", "AT": "abcde", "OVER": "abcdefgh", "RUNES": "héllo wörld", "PADDED": "  abcde  "},
+		},
+		{
+			name: "under, at and over the limit",
+			max:  5,
+			want: map[string]string{"UNDER": "abcd", "AT": "abcde", "OVER": "abcde...", "RUNES": "héllo...", "PADDED": "  abc..."},
+		},
+		{
+			name: "applied after trimming",
+			max:  5,
+			trim: true,
+			want: map[string]string{"UNDER": "abcd", "AT": "abcde", "OVER": "abcde...", "RUNES": "héllo...", "PADDED": "abcde"},
+		},
+		{
+			name: "negative is unlimited",
+			max:  -1,
+			want: map[string]string{"UNDER": "abcd", "AT": "abcde", "OVER": "abcdefgh", "RUNES": "héllo wörld", "PADDED": "  abcde  "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(p)
+			c.SetMaxValueLength(tt.max)
+			c.SetTrimValues(tt.trim)
+
+			got, err := c.ConvertMap(strings.NewReader(""))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_ErrorOnOversize(t *testing.T) {
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return map[string]string{"under": "abcd", "at": "abcde", "over": "abcdef", "blob": strings.Repeat("x", 100)}, nil
+		},
+	}
+
+	c := New(p)
+	c.SetMaxValueLength(5)
+	c.SetErrorOnOversize(true)
+
+	_, err := c.ConvertMap(strings.NewReader(""))
+	if err == nil {
+		t.Fatal("ConvertMap() error = nil, want oversize error")
+	}
+	if want := "values longer than 5 characters: 'BLOB', 'OVER'"; err.Error() != want {
+		t.Errorf("ConvertMap() error = %q, want %q", err, want)
+	}
+
+	// Filtered keys are not checked
+	c.SetFilterPatterns(nil, []string{"BLOB", "OVER"}, GlobMatcher{})
+	got, err := c.ConvertMap(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if want := map[string]string{"UNDER": "abcd", "AT": "abcde"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %q, want %q", got, want)
+	}
+}
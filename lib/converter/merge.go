@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MergeStrategy controls how keys present in more than one input are combined
+type MergeStrategy int
+
+const (
+	// MergeOverride lets later inputs override keys from earlier ones (default)
+	MergeOverride MergeStrategy = iota
+
+	// MergeErrorOnConflict fails when inputs disagree on the value of a key
+	MergeErrorOnConflict
+)
+
+// String returns the flag value for the merge strategy
+func (s MergeStrategy) String() string {
+	if s == MergeErrorOnConflict {
+		return "error-on-conflict"
+	}
+	return "override"
+}
+
+// ParseMergeStrategy converts a flag value into a MergeStrategy
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "override":
+		return MergeOverride, nil
+	case "error-on-conflict":
+		return MergeErrorOnConflict, nil
+	default:
+		return MergeOverride, fmt.Errorf("unsupported merge strategy: %s (valid: override, error-on-conflict)", s)
+	}
+}
+
+// Merge combines converted configs in order. Later configs override earlier
+// keys unless the strategy reports conflicting values as an error.
+func Merge(strategy MergeStrategy, envs ...map[string]string) (map[string]string, error) {
+	merged := make(map[string]string)
+	var conflicts []string
+
+	for _, env := range envs {
+		for k, v := range env {
+			if old, ok := merged[k]; ok && old != v && strategy == MergeErrorOnConflict {
+				conflicts = append(conflicts, fmt.Sprintf("conflicting values for key '%s' ('%s' and '%s')", k, old, v))
+				continue
+			}
+			merged[k] = v
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, &ValidationError{Err: fmt.Errorf("merge conflicts found: %s", strings.Join(conflicts, "; "))}
+	}
+	return merged, nil
+}
+
+// WriteMap writes already converted pairs to w in .env format, or with the
+// encoder set by SetEncoder, using the configured sort mode. The header lists
+// pluginNames, defaulting to the converter's plugin. Maps carry no parse
+// order, so SortNone falls back to key order.
+func (c *Converter) WriteMap(w io.Writer, env map[string]string, pluginNames ...string) error {
+	if w == nil {
+		return fmt.Errorf("output writer is nil")
+	}
+
+	name := strings.Join(pluginNames, ", ")
+	if name == "" && c.plugin != nil {
+		name = c.plugin.Name()
+	}
+
+	bw := c.newWriter(w)
+	if err := c.writeMap(bw, env, name); err != nil {
+		bw.Flush()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return &WriteError{Err: err}
+	}
+	return nil
+}
+
+// writeMap writes the header and the pairs of env to w
+func (c *Converter) writeMap(w *bufio.Writer, env map[string]string, pluginName string) error {
+	if c.encoder != nil {
+		return c.encodePairs(w, c.mapKeys(env), env)
+	}
+	return c.writeWithHeader(w, pluginName, func(w *bufio.Writer) error {
+		return c.writeMapPairs(w, env)
+	})
+}
+
+// mapKeys returns the keys of env in the configured sort order
+func (c *Converter) mapKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	if !c.unsorted {
+		sort.Strings(keys)
+	}
+	c.orderKeys(keys)
+	return keys
+}
+
+// writeMapPairs writes the pairs of env to w
+func (c *Converter) writeMapPairs(w *bufio.Writer, env map[string]string) error {
+	keys, removed := c.delta(c.mapKeys(env), env)
+	if err := c.checkEmpty(keys, removed); err != nil {
+		return err
+	}
+	if err := c.checkSeparator(keys); err != nil {
+		return err
+	}
+
+	// Handle empty result
+	if c.filter != nil && len(env) == 0 && !c.bare() {
+		return c.writeComment(w, "No keys matched the specified filters")
+	}
+
+	for i, k := range keys {
+		if c.limit > 0 && i == c.limit {
+			break
+		}
+		if err := c.writeGroupBreak(w, previous(keys, i), k); err != nil {
+			return err
+		}
+		if err := c.writePair(w, k, env[k]); err != nil {
+			return err
+		}
+	}
+	return c.writeRemoved(w, removed)
+}
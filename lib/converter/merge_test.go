@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy MergeStrategy
+		envs     []map[string]string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "later overrides earlier",
+			strategy: MergeOverride,
+			envs: []map[string]string{
+				{"A": "1", "B": "base"},
+				{"B": "override", "C": "3"},
+			},
+			want: map[string]string{"A": "1", "B": "override", "C": "3"},
+		},
+		{
+			name:     "error on conflict",
+			strategy: MergeErrorOnConflict,
+			envs: []map[string]string{
+				{"A": "1", "B": "base"},
+				{"B": "override"},
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error on conflict allows equal values",
+			strategy: MergeErrorOnConflict,
+			envs: []map[string]string{
+				{"A": "1"},
+				{"A": "1", "B": "2"},
+			},
+			want: map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:     "no inputs",
+			strategy: MergeOverride,
+			want:     map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Merge(tt.strategy, tt.envs...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Merge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    MergeStrategy
+		wantErr bool
+	}{
+		{"", MergeOverride, false},
+		{"override", MergeOverride, false},
+		{"error-on-conflict", MergeErrorOnConflict, false},
+		{"first-wins", MergeOverride, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMergeStrategy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseMergeStrategy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseMergeStrategy(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge_YAMLBaseJSONOverride(t *testing.T) {
+	base := `database:
+  host: localhost
+  port: 5432
+api:
+  timeout: 30
+`
+	override := `{"database": {"host": "db.prod.internal"}, "api": {"retries": 3}}`
+
+	baseEnv, err := New(yaml.New()).ConvertMap(strings.NewReader(base))
+	if err != nil {
+		t.Fatalf("ConvertMap(yaml) error = %v", err)
+	}
+	overrideEnv, err := New(json.New()).ConvertMap(strings.NewReader(override))
+	if err != nil {
+		t.Fatalf("ConvertMap(json) error = %v", err)
+	}
+
+	merged, err := Merge(MergeOverride, baseEnv, overrideEnv)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := New(yaml.New()).WriteMap(&out, merged, "yaml", "json"); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+
+	want := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: yaml, json\n#\n\n" +
+		"API_RETRIES=3\nAPI_TIMEOUT=30\nDATABASE_HOST=db.prod.internal\nDATABASE_PORT=5432\n"
+	if got := out.String(); got != want {
+		t.Errorf("WriteMap() = %q, want %q", got, want)
+	}
+
+	if _, err := Merge(MergeErrorOnConflict, baseEnv, overrideEnv); err == nil {
+		t.Error("Merge(MergeErrorOnConflict) expected conflict error, got nil")
+	}
+}
@@ -0,0 +1,44 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// newlineEscaper writes embedded line breaks as \n and \r escape sequences,
+// doubling backslashes so that a literal \n in a value stays distinct
+var newlineEscaper = strings.NewReplacer(`\`, `\\`, "\r", `\r`, "\n", `\n`)
+
+// SetStrictNewlines controls whether values containing line breaks, such as
+// YAML block scalars, are an error. By default line breaks are written as
+// \n and \r escape sequences so that each pair stays on one line, and
+// backslashes already in values are doubled.
+func (c *Converter) SetStrictNewlines(strict bool) {
+	c.strictNL = strict
+}
+
+// escapeNewlines replaces line breaks and backslashes in value with escape
+// sequences
+func escapeNewlines(value string) string {
+	if !strings.ContainsAny(value, "\r\n\\") {
+		return value
+	}
+	return newlineEscaper.Replace(value)
+}
+
+// checkNewlines returns an error listing every key whose value contains a
+// line break
+func checkNewlines(keys []string, values map[string]string) error {
+	var multiline []string
+	for _, k := range keys {
+		if strings.ContainsAny(values[k], "\r\n") {
+			multiline = append(multiline, fmt.Sprintf("'%s'", k))
+		}
+	}
+	if len(multiline) == 0 {
+		return nil
+	}
+	sort.Strings(multiline)
+	return &ValidationError{Err: fmt.Errorf("values contain line breaks: %s", strings.Join(multiline, ", "))}
+}
@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestConverter_MultilineValues(t *testing.T) {
+	input := `certificate: |
+  -----BEGIN CERTIFICATE-----
+  MIIB
+  -----END CERTIFICATE-----
+motd: "line one\r\nline two"
+name: app
+`
+
+	t.Run("escaped by default", func(t *testing.T) {
+		c := New(yaml.New())
+		c.SetVersion("test")
+
+		var out strings.Builder
+		if err := c.Convert(strings.NewReader(input), &out); err != nil {
+			t.Fatalf("Convert() error = %v", err)
+		}
+
+		want := `# This file was auto-generated by cfg2env
+# Version: test
+# Plugin: yaml
+#
+
+CERTIFICATE=-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n
+MOTD=line one\r\nline two
+NAME=app
+`
+		if out.String() != want {
+			t.Errorf("Convert() = %q, want %q", out.String(), want)
+		}
+	})
+
+	t.Run("ConvertMap keeps raw values", func(t *testing.T) {
+		got, err := New(yaml.New()).ConvertMap(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("ConvertMap() error = %v", err)
+		}
+		if want := "line one\r\nline two"; got["MOTD"] != want {
+			t.Errorf("MOTD = %q, want %q", got["MOTD"], want)
+		}
+	})
+
+	t.Run("errors in strict mode", func(t *testing.T) {
+		c := New(yaml.New())
+		c.SetStrictNewlines(true)
+
+		_, err := c.ConvertMap(strings.NewReader(input))
+		want := "values contain line breaks: 'CERTIFICATE', 'MOTD'"
+		if err == nil || err.Error() != want {
+			t.Errorf("ConvertMap() error = %v, want %q", err, want)
+		}
+	})
+
+	t.Run("strict mode ignores blanked values", func(t *testing.T) {
+		c := New(yaml.New())
+		c.SetStrictNewlines(true)
+		c.SetTemplate(true, nil, GlobMatcher{})
+
+		if _, err := c.ConvertMap(strings.NewReader(input)); err != nil {
+			t.Errorf("ConvertMap() error = %v", err)
+		}
+	})
+}
+
+func TestConverter_EscapedBackslashes(t *testing.T) {
+	// A literal backslash-n must not look like an escaped line break
+	input := `literal: "x\\ny"
+block: |
+  x
+  y
+path: 'C:\tmp'
+`
+	c := New(yaml.New())
+	c.SetHeader(false)
+
+	var out strings.Builder
+	if err := c.Convert(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := `BLOCK=x\ny\n
+LITERAL=x\\ny
+PATH=C:\\tmp
+`
+	if out.String() != want {
+		t.Errorf("Convert() = %q, want %q", out.String(), want)
+	}
+}
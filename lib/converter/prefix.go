@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPrefixSeparator joins the prefix to each key, matching the
+// underscore that joins nested levels
+const DefaultPrefixSeparator = "_"
+
+// ErrUnnamedRoot is returned when the input's root is a scalar, or an array
+// joined with utils.ArrayJoin, and there is no prefix to use as its key
+var ErrUnnamedRoot = errors.New("input root is not an object and has no key; set a prefix to name it")
+
+// SetPrefix sets a prefix that is prepended with the prefix separator to
+// every key before normalization. A value at the input's root, such as a
+// scalar document, takes the prefix itself as its key. An empty prefix
+// disables prefixing.
+func (c *Converter) SetPrefix(prefix string) {
+	c.prefix = prefix
+}
+
+// SetPrefixSeparator sets the separator between the prefix and the rest of
+// each key, such as "__" for tools that use a double underscore for nesting.
+// Only the join after the prefix changes; nested levels are still joined
+// with an underscore. An empty sep restores DefaultPrefixSeparator.
+func (c *Converter) SetPrefixSeparator(sep string) {
+	c.prefixSep = sep
+}
+
+// prefixKey prepends the configured prefix to key. The empty key of a root
+// value is replaced by the prefix.
+func (c *Converter) prefixKey(key string) string {
+	if c.prefix == "" || key == "" {
+		return c.prefix + key
+	}
+	return c.prefix + c.separator() + key
+}
+
+// separator returns the prefix separator, or DefaultPrefixSeparator if it
+// is not set
+func (c *Converter) separator() string {
+	if c.prefixSep == "" {
+		return DefaultPrefixSeparator
+	}
+	return c.prefixSep
+}
+
+// outputPrefix returns the prefix and separator as they start output keys,
+// or "" without a prefix
+func (c *Converter) outputPrefix() string {
+	if c.prefix == "" {
+		return ""
+	}
+	return strings.ToUpper(c.prefix + c.separator())
+}
+
+// PrefixFromFilename derives a key prefix from a file path: the base name
+// without its extension, uppercased, with characters that are not letters or
+// digits replaced by underscores. For example "config/database.yaml" gives
+// "DATABASE".
+func PrefixFromFilename(path string) string {
+	base := filepath.Base(path)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, base)
+}
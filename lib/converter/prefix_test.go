@@ -0,0 +1,204 @@
+package converter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestPrefixFromFilename(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"database.yaml", "DATABASE"},
+		{"config/cache.json", "CACHE"},
+		{"/etc/app/my-service.prod.yml", "MY_SERVICE_PROD"},
+		{"noext", "NOEXT"},
+		{".env", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := PrefixFromFilename(tt.path); got != tt.want {
+				t.Errorf("PrefixFromFilename(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_PrefixFromFilename(t *testing.T) {
+	files := []struct {
+		path    string
+		content string
+	}{
+		{"database.yaml", "host: localhost\nport: 5432\n"},
+		{"cache.yaml", "host: redis\nttl: 60\n"},
+	}
+
+	var envs []map[string]string
+	for _, f := range files {
+		c := New(yaml.New())
+		c.SetPrefix(PrefixFromFilename(f.path))
+		env, err := c.ConvertMap(strings.NewReader(f.content))
+		if err != nil {
+			t.Fatalf("ConvertMap(%s) error = %v", f.path, err)
+		}
+		envs = append(envs, env)
+	}
+
+	got, err := Merge(MergeErrorOnConflict, envs...)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	want := map[string]string{
+		"DATABASE_HOST": "localhost",
+		"DATABASE_PORT": "5432",
+		"CACHE_HOST":    "redis",
+		"CACHE_TTL":     "60",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %v, want %v", got, want)
+	}
+}
+
+func TestConverter_PrefixWithFilter(t *testing.T) {
+	c := New(yaml.New())
+	c.SetPrefix("app")
+	c.SetFilterPatterns([]string{"APP_DB_*"}, nil, GlobMatcher{})
+
+	got, err := c.ConvertMap(strings.NewReader("db:\n  host: localhost\nname: demo\n"))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+
+	want := map[string]string{"APP_DB_HOST": "localhost"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ConvertMap() = %v, want %v", got, want)
+	}
+}
+
+func TestConverter_PrefixSeparator(t *testing.T) {
+	input := "database:\n  host: localhost\n"
+	tests := []struct {
+		name     string
+		prefix   string
+		sep      string
+		dunder   int
+		collapse bool
+		want     string
+	}{
+		{"default separator", "MYAPP", "", 0, false, "MYAPP_DATABASE_HOST"},
+		{"same as path separator", "MYAPP", "_", 0, false, "MYAPP_DATABASE_HOST"},
+		{"double underscore", "MYAPP", "__", 0, false, "MYAPP__DATABASE_HOST"},
+		{"other separator is uppercased", "myapp", "-x-", 0, false, "MYAPP-X-DATABASE_HOST"},
+		{"no prefix ignores separator", "", "__", 0, false, "DATABASE_HOST"},
+		// Dunder rules apply to the key after the prefix and separator
+		{"double underscore with dunder", "MYAPP", "__", 1, false, "MYAPP__DATABASEHOST"},
+		{"double underscore with collapse", "my", "__", 0, true, "MY__DATABASE_HOST"},
+		{"path separator with dunder", "MYAPP", "_", 1, false, "MYAPP_DATABASEHOST"},
+		{"prefix underscores with dunder", "MY__APP", "__", 1, false, "MY__APP__DATABASEHOST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(yaml.New())
+			c.SetPrefix(tt.prefix)
+			c.SetPrefixSeparator(tt.sep)
+			c.SetDunder(tt.dunder)
+			c.SetDunderCollapse(tt.collapse)
+
+			got, err := c.ConvertMap(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			want := map[string]string{tt.want: "localhost"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ConvertMap() = %v, want %v", got, want)
+			}
+
+			// Keys given in output form are normalized the same way
+			if v, err := c.Lookup(got, strings.ToLower(tt.want)); err != nil || v != "localhost" {
+				t.Errorf("Lookup(%q) = %q, %v", strings.ToLower(tt.want), v, err)
+			}
+		})
+	}
+}
+
+func TestConverter_RootValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		prefix  string
+		join    bool
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:  "root array indexes without a leading underscore",
+			input: `["a", "b"]`,
+			want:  map[string]string{"0": "a", "1": "b"},
+		},
+		{
+			name:   "prefix names a root array",
+			input:  `["a", {"x": 1}]`,
+			prefix: "ITEMS",
+			want:   map[string]string{"ITEMS_0": "a", "ITEMS_1_X": "1"},
+		},
+		{
+			name:   "prefix names a joined root array",
+			input:  `["a", "b"]`,
+			prefix: "ITEMS",
+			join:   true,
+			want:   map[string]string{"ITEMS": "a,b"},
+		},
+		{
+			name:   "prefix names a root scalar",
+			input:  `"hello"`,
+			prefix: "GREETING",
+			want:   map[string]string{"GREETING": "hello"},
+		},
+		{
+			name:    "root scalar needs a prefix",
+			input:   `"hello"`,
+			wantErr: ErrUnnamedRoot,
+		},
+		{
+			name:    "joined root array needs a prefix",
+			input:   `["a", "b"]`,
+			join:    true,
+			wantErr: ErrUnnamedRoot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := json.New()
+			if tt.join {
+				p.SetArrayMode(utils.ArrayJoin, utils.DefaultArraySep)
+			}
+			c := New(p)
+			c.SetPrefix(tt.prefix)
+
+			got, err := c.ConvertMap(strings.NewReader(tt.input))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ConvertMap() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
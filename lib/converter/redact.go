@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"io"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+)
+
+// RedactedValue replaces the values of redacted keys
+const RedactedValue = "********"
+
+// redact masks the values of secret keys so output can be shared
+type redact struct {
+	patterns []string
+	matcher  Matcher
+}
+
+// shouldRedact reports whether the value for key should be masked
+func (r *redact) shouldRedact(key string) bool {
+	for _, pattern := range r.patterns {
+		if r.matcher.Match(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRedactPatterns masks the value of every key matching one of patterns
+// with RedactedValue. Masking happens before template mode, so keys it
+// blanks stay empty, and before truncation, so no part of a secret is
+// written. Patterns are normalized through the same pipeline as keys. No
+// patterns disables masking.
+func (c *Converter) SetRedactPatterns(patterns []string, matcher Matcher) {
+	normalized := c.normalizePatterns(patterns, matcher)
+	if len(normalized) == 0 {
+		c.redact = nil
+		return
+	}
+
+	c.redact = &redact{
+		patterns: normalized,
+		matcher:  matcher,
+	}
+}
+
+// ReadPatterns reads key patterns from r, one per line. Blank lines and
+// lines starting with # are skipped, and surrounding whitespace is removed.
+func ReadPatterns(r io.Reader) ([]string, error) {
+	lines, err := utils.ReadLines(utils.StripBOM(r))
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
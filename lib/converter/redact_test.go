@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestReadPatterns(t *testing.T) {
+	input := "\xEF\xBB\xBF# Secrets shared by the team\n*_PASSWORD\n\n  api_*  \r\n# *_HOST\nTOKEN\n"
+	got, err := ReadPatterns(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadPatterns() error = %v", err)
+	}
+	if want := []string{"*_PASSWORD", "api_*", "TOKEN"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPatterns() = %q, want %q", got, want)
+	}
+}
+
+func TestConverter_Redact(t *testing.T) {
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return map[string]string{
+				"db_host":     "localhost",
+				"db_password": "hunter2",
+				"api_key":     "abc123",
+				"api_url":     "https://api",
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name  string
+		setup func(c *Converter)
+		want  map[string]string
+	}{
+		{
+			name:  "matching keys are masked",
+			setup: func(c *Converter) { c.SetRedactPatterns([]string{"*_password", "API_KEY"}, GlobMatcher{}) },
+			want: map[string]string{
+				"DB_HOST": "localhost", "DB_PASSWORD": RedactedValue,
+				"API_KEY": RedactedValue, "API_URL": "https://api",
+			},
+		},
+		{
+			name:  "substring matcher",
+			setup: func(c *Converter) { c.SetRedactPatterns([]string{"PASS"}, SubstringMatcher{}) },
+			want: map[string]string{
+				"DB_HOST": "localhost", "DB_PASSWORD": RedactedValue,
+				"API_KEY": "abc123", "API_URL": "https://api",
+			},
+		},
+		{
+			name: "template blanks masked keys",
+			setup: func(c *Converter) {
+				c.SetRedactPatterns([]string{"*_PASSWORD"}, GlobMatcher{})
+				c.SetTemplate(true, []string{"*_PASSWORD"}, GlobMatcher{})
+			},
+			want: map[string]string{
+				"DB_HOST": "localhost", "DB_PASSWORD": "",
+				"API_KEY": "abc123", "API_URL": "https://api",
+			},
+		},
+		{
+			name: "secrets are masked before truncation",
+			setup: func(c *Converter) {
+				c.SetRedactPatterns([]string{"API_KEY"}, GlobMatcher{})
+				c.SetMaxValueLength(3)
+			},
+			want: map[string]string{
+				"DB_HOST": "loc...", "DB_PASSWORD": "hun...",
+				"API_KEY": "***...", "API_URL": "htt...",
+			},
+		},
+		{
+			name:  "no patterns",
+			setup: func(c *Converter) { c.SetRedactPatterns([]string{" ", ""}, GlobMatcher{}) },
+			want: map[string]string{
+				"DB_HOST": "localhost", "DB_PASSWORD": "hunter2",
+				"API_KEY": "abc123", "API_URL": "https://api",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(p)
+			tt.setup(c)
+			got, err := c.ConvertMap(strings.NewReader(""))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"sort"
+	"strings"
+)
+
+// SetRequired sets keys that must be present with a non-empty value in the
+// output. Keys are matched against output keys after filtering, uppercased
+// and with surrounding whitespace removed. Values blanked by template mode
+// still count as set.
+func (c *Converter) SetRequired(keys []string) {
+	c.required = keys
+}
+
+// CheckRequired returns a *MissingKeysError if any of keys is absent from env
+// or has an empty value. Keys are uppercased before lookup.
+func CheckRequired(env map[string]string, keys []string) error {
+	var missing, empty []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		v, ok := env[key]
+		switch {
+		case !ok:
+			missing = append(missing, key)
+		case v == "":
+			empty = append(empty, key)
+		}
+	}
+
+	if len(missing) == 0 && len(empty) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	sort.Strings(empty)
+	return &MissingKeysError{Missing: missing, Empty: empty}
+}
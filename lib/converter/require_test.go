@@ -0,0 +1,153 @@
+package converter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestCheckRequired(t *testing.T) {
+	env := map[string]string{
+		"DATABASE_HOST": "localhost",
+		"DATABASE_PORT": "5432",
+		"API_KEY":       "",
+	}
+
+	tests := []struct {
+		name        string
+		keys        []string
+		wantMissing []string
+		wantEmpty   []string
+		wantMsg     string
+	}{
+		{
+			name: "all present",
+			keys: []string{"DATABASE_HOST", "DATABASE_PORT"},
+		},
+		{
+			name: "no required keys",
+			keys: nil,
+		},
+		{
+			name: "names are case-insensitive and trimmed",
+			keys: []string{" database_host", "Database_Port ", ""},
+		},
+		{
+			name:        "missing",
+			keys:        []string{"DATABASE_HOST", "REDIS_URL", "CACHE_TTL"},
+			wantMissing: []string{"CACHE_TTL", "REDIS_URL"},
+			wantMsg:     "missing required keys: CACHE_TTL, REDIS_URL",
+		},
+		{
+			name:      "present but empty",
+			keys:      []string{"API_KEY", "DATABASE_HOST"},
+			wantEmpty: []string{"API_KEY"},
+			wantMsg:   "empty required keys: API_KEY",
+		},
+		{
+			name:        "missing and empty",
+			keys:        []string{"API_KEY", "REDIS_URL", "redis_url"},
+			wantMissing: []string{"REDIS_URL"},
+			wantEmpty:   []string{"API_KEY"},
+			wantMsg:     "missing required keys: REDIS_URL; empty required keys: API_KEY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRequired(env, tt.keys)
+			if tt.wantMsg == "" {
+				if err != nil {
+					t.Fatalf("CheckRequired() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var missingErr *MissingKeysError
+			if !errors.As(err, &missingErr) {
+				t.Fatalf("CheckRequired() error = %v (%T), want *MissingKeysError", err, err)
+			}
+			if !reflect.DeepEqual(missingErr.Missing, tt.wantMissing) {
+				t.Errorf("Missing = %v, want %v", missingErr.Missing, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(missingErr.Empty, tt.wantEmpty) {
+				t.Errorf("Empty = %v, want %v", missingErr.Empty, tt.wantEmpty)
+			}
+			if err.Error() != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestConverter_Required(t *testing.T) {
+	data := map[string]string{
+		"DATABASE_HOST":     "localhost",
+		"DATABASE_PASSWORD": "secret",
+		"API_KEY":           "",
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(c *Converter)
+		wantErr string
+	}{
+		{
+			name: "all present",
+			setup: func(c *Converter) {
+				c.SetRequired([]string{"DATABASE_HOST", "DATABASE_PASSWORD"})
+			},
+		},
+		{
+			name: "missing",
+			setup: func(c *Converter) {
+				c.SetRequired([]string{"DATABASE_HOST", "DATABASE_PORT"})
+			},
+			wantErr: "missing required keys: DATABASE_PORT",
+		},
+		{
+			name: "present but empty",
+			setup: func(c *Converter) {
+				c.SetRequired([]string{"API_KEY"})
+			},
+			wantErr: "empty required keys: API_KEY",
+		},
+		{
+			name: "filtered out keys are missing",
+			setup: func(c *Converter) {
+				c.SetFilterPatterns(nil, []string{"*_PASSWORD"}, GlobMatcher{})
+				c.SetRequired([]string{"DATABASE_PASSWORD"})
+			},
+			wantErr: "missing required keys: DATABASE_PASSWORD",
+		},
+		{
+			name: "template blanking is not empty",
+			setup: func(c *Converter) {
+				c.SetTemplate(true, nil, GlobMatcher{})
+				c.SetRequired([]string{"DATABASE_PASSWORD"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			tt.setup(c)
+
+			var out strings.Builder
+			err := c.Convert(strings.NewReader(""), &out)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Convert() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("Convert() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package converter
+
+import "strings"
+
+// DefaultReservedKeys lists environment variables that shells and the
+// dynamic loader treat specially, so sourcing a .env that sets them can
+// break the shell or the programs it starts
+var DefaultReservedKeys = []string{
+	"BASH_ENV", "CDPATH", "ENV", "EUID", "HOME", "HOSTNAME", "IFS", "LANG",
+	"LD_LIBRARY_PATH", "LD_PRELOAD", "LOGNAME", "MAIL", "OLDPWD", "PATH",
+	"PPID", "PROMPT_COMMAND", "PS1", "PS2", "PS4", "PWD", "SHELL", "SHLVL",
+	"TERM", "TMPDIR", "UID", "USER",
+}
+
+// SetReservedPrefix prepends prefix, such as "APP_", to every output key in
+// reserved, so a config key like path is written as APP_PATH instead of
+// clobbering PATH when the output is sourced. Reserved keys are matched
+// case-insensitively after dunder processing and sanitizing; a nil reserved
+// uses DefaultReservedKeys. An empty prefix disables renaming.
+func (c *Converter) SetReservedPrefix(prefix string, reserved []string) {
+	if reserved == nil {
+		reserved = DefaultReservedKeys
+	}
+	c.reservedPrefix = prefix
+	c.reserved = make(map[string]bool, len(reserved))
+	for _, k := range reserved {
+		if k = strings.ToUpper(strings.TrimSpace(k)); k != "" {
+			c.reserved[k] = true
+		}
+	}
+}
+
+// reserveKey prefixes key if it is reserved
+func (c *Converter) reserveKey(key string) string {
+	if c.reservedPrefix == "" || !c.reserved[strings.ToUpper(key)] {
+		return key
+	}
+	return c.reservedPrefix + key
+}
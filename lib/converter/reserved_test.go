@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestConverter_ReservedPrefix(t *testing.T) {
+	input := "path: /srv/app\nhome: /home/app\nifs: ','\nport: 8080\ndb:\n  path: /var/db\n"
+
+	tests := []struct {
+		name     string
+		prefix   string
+		reserved []string
+		global   string
+		want     map[string]string
+	}{
+		{
+			name: "disabled by default",
+			want: map[string]string{"PATH": "/srv/app", "HOME": "/home/app", "IFS": ",", "PORT": "8080", "DB_PATH": "/var/db"},
+		},
+		{
+			name:   "default reserved keys",
+			prefix: "APP_",
+			want:   map[string]string{"APP_PATH": "/srv/app", "APP_HOME": "/home/app", "APP_IFS": ",", "PORT": "8080", "DB_PATH": "/var/db"},
+		},
+		{
+			name:     "custom reserved keys replace the defaults",
+			prefix:   "CFG_",
+			reserved: []string{"port", " db_path "},
+			want:     map[string]string{"PATH": "/srv/app", "HOME": "/home/app", "IFS": ",", "CFG_PORT": "8080", "CFG_DB_PATH": "/var/db"},
+		},
+		{
+			name:   "keys are checked after --prefix",
+			prefix: "APP_",
+			global: "MYAPP",
+			want:   map[string]string{"MYAPP_PATH": "/srv/app", "MYAPP_HOME": "/home/app", "MYAPP_IFS": ",", "MYAPP_PORT": "8080", "MYAPP_DB_PATH": "/var/db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(yaml.New())
+			c.SetPrefix(tt.global)
+			c.SetReservedPrefix(tt.prefix, tt.reserved)
+
+			got, err := c.ConvertMap(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ConvertMap() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertMap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_ReservedPrefixGet(t *testing.T) {
+	c := New(yaml.New())
+	c.SetReservedPrefix("APP_", nil)
+
+	// Both the config key and the renamed output key find the value
+	for _, key := range []string{"path", "APP_PATH"} {
+		got, err := c.Get(strings.NewReader("path: /srv/app\n"), key)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", key, err)
+		}
+		if got != "/srv/app" {
+			t.Errorf("Get(%q) = %q, want /srv/app", key, got)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Coercion converts the values of the keys a schema rule matches, for
+// consumers that expect values in a particular form
+type Coercion int
+
+const (
+	// CoerceBool01 writes true and false, in any case, as 1 and 0
+	CoerceBool01 Coercion = iota
+
+	// CoerceQuote writes the value in double quotes, escaping backslashes
+	// and double quotes. Only KEY=value lines are quoted.
+	CoerceQuote
+
+	// CoerceInt writes numbers as integers, so 5432.0 and 1e3 become 5432
+	// and 1000. Numbers with a fractional part are rejected.
+	CoerceInt
+
+	// CoerceLower lowercases the value
+	CoerceLower
+)
+
+// String returns the schema name of the coercion
+func (c Coercion) String() string {
+	switch c {
+	case CoerceQuote:
+		return "quote"
+	case CoerceInt:
+		return "int"
+	case CoerceLower:
+		return "lower"
+	default:
+		return "bool01"
+	}
+}
+
+// ParseCoercion converts a schema name into a Coercion
+func ParseCoercion(s string) (Coercion, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "bool01":
+		return CoerceBool01, nil
+	case "quote":
+		return CoerceQuote, nil
+	case "int":
+		return CoerceInt, nil
+	case "lower":
+		return CoerceLower, nil
+	default:
+		return CoerceBool01, fmt.Errorf("unsupported coercion: %s (valid: bool01, quote, int, lower)", s)
+	}
+}
+
+// SchemaRule applies coercions to the values of keys matching a glob pattern
+type SchemaRule struct {
+	Pattern   string
+	Coercions []Coercion
+}
+
+// ReadSchema reads schema rules from a YAML mapping of key patterns to a
+// coercion name or a list of them, in file order:
+//
+//	"*_ENABLED": bool01
+//	API_TOKEN: [lower, quote]
+func ReadSchema(r io.Reader) ([]SchemaRule, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(utils.StripBOM(r)).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("schema must map key patterns to coercions")
+	}
+
+	rules := make([]SchemaRule, 0, len(root.Content)/2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		pattern, val := root.Content[i], root.Content[i+1]
+		var names []string
+		if err := val.Decode(&names); err != nil {
+			var name string
+			if err := val.Decode(&name); err != nil {
+				return nil, fmt.Errorf("line %d: %s: want a coercion or a list of them", val.Line, pattern.Value)
+			}
+			names = []string{name}
+		}
+
+		rule := SchemaRule{Pattern: pattern.Value}
+		for _, name := range names {
+			coercion, err := ParseCoercion(name)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", val.Line, err)
+			}
+			rule.Coercions = append(rule.Coercions, coercion)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// SetSchema sets rules coercing the values of the keys their patterns match
+// with GlobMatcher. Coercions apply after filtering and the required-key
+// check, before redaction and template blanking, and every matching rule
+// applies in order. Empty values are left alone. A value a coercion cannot
+// convert, such as "yes" for bool01, fails the conversion with a
+// ValidationError. Patterns are normalized through the same pipeline as
+// keys. No rules disables coercion.
+func (c *Converter) SetSchema(rules []SchemaRule) {
+	c.schema = nil
+	for _, rule := range rules {
+		patterns := c.normalizePatterns([]string{rule.Pattern}, GlobMatcher{})
+		if len(patterns) == 0 {
+			continue
+		}
+		c.schema = append(c.schema, SchemaRule{Pattern: patterns[0], Coercions: rule.Coercions})
+	}
+}
+
+// coerceValues applies the schema's coercions to the values of keys, or
+// returns an error listing the values that could not be converted
+func (c *Converter) coerceValues(keys []string, values map[string]string) error {
+	var invalid []string
+	for _, k := range keys {
+		for _, coercion := range c.coercions(k) {
+			v, ok := coerce(coercion, values[k])
+			if !ok {
+				invalid = append(invalid, fmt.Sprintf("'%s' (%s)", k, coercion))
+				break
+			}
+			values[k] = v
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	sort.Strings(invalid)
+	return &ValidationError{Err: fmt.Errorf("values not matching schema: %s", strings.Join(invalid, ", "))}
+}
+
+// coercions returns the coercions of the schema rules matching key, in order
+func (c *Converter) coercions(key string) []Coercion {
+	var out []Coercion
+	for _, rule := range c.schema {
+		if (GlobMatcher{}).Match(rule.Pattern, key) {
+			out = append(out, rule.Coercions...)
+		}
+	}
+	return out
+}
+
+// quoted reports whether a schema rule quotes the value of key
+func (c *Converter) quoted(key string) bool {
+	for _, coercion := range c.coercions(key) {
+		if coercion == CoerceQuote {
+			return true
+		}
+	}
+	return false
+}
+
+// coerce converts value with coercion, reporting false if it cannot.
+// Quoting happens when the pair is written, so it leaves value unchanged.
+func coerce(coercion Coercion, value string) (string, bool) {
+	if value == "" {
+		return value, true
+	}
+	switch coercion {
+	case CoerceBool01:
+		switch strings.ToLower(value) {
+		case "true", "1":
+			return "1", true
+		case "false", "0":
+			return "0", true
+		}
+		return value, false
+	case CoerceInt:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return strconv.FormatInt(n, 10), true
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return value, false
+		}
+		return strconv.FormatFloat(f, 'f', 0, 64), true
+	case CoerceLower:
+		return strings.ToLower(value), true
+	default:
+		return value, true
+	}
+}
+
+// quoteValue wraps value in double quotes, escaping backslashes, line breaks
+// and double quotes, for a CoerceQuote rule
+func quoteValue(value string) string {
+	value = escapeNewlines(value)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
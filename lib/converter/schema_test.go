@@ -0,0 +1,121 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestReadSchema(t *testing.T) {
+	input := "\"*_ENABLED\": bool01\nAPI_TOKEN: [lower, quote]\nport: INT\n"
+	got, err := ReadSchema(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadSchema() error = %v", err)
+	}
+	want := []SchemaRule{
+		{Pattern: "*_ENABLED", Coercions: []Coercion{CoerceBool01}},
+		{Pattern: "API_TOKEN", Coercions: []Coercion{CoerceLower, CoerceQuote}},
+		{Pattern: "port", Coercions: []Coercion{CoerceInt}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSchema() = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{"- a\n", "KEY: upper\n", "KEY: {a: b}\n"} {
+		if _, err := ReadSchema(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadSchema(%q) error = nil, want error", bad)
+		}
+	}
+	for _, empty := range []string{"", "# no rules yet\n"} {
+		if rules, err := ReadSchema(strings.NewReader(empty)); err != nil || rules != nil {
+			t.Errorf("ReadSchema(%q) = %v, %v, want no rules", empty, rules, err)
+		}
+	}
+}
+
+func TestConverter_Schema(t *testing.T) {
+	data := map[string]string{
+		"cache_enabled": "TRUE",
+		"log_enabled":   "false",
+		"db_port":       "5432.0",
+		"api_token":     `AbC"d`,
+		"empty_enabled": "",
+	}
+	rules := []SchemaRule{
+		{Pattern: "*_enabled", Coercions: []Coercion{CoerceBool01}},
+		{Pattern: "db_port", Coercions: []Coercion{CoerceInt}},
+		{Pattern: "API_*", Coercions: []Coercion{CoerceLower}},
+		{Pattern: "api_token", Coercions: []Coercion{CoerceQuote}},
+	}
+
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+	c.SetSchema(rules)
+	var buf bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &buf); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	want := []string{`API_TOKEN="abc\"d"`, "CACHE_ENABLED=1", "DB_PORT=5432", "EMPTY_ENABLED=", "LOG_ENABLED=0"}
+	if got := outputPairs(buf.String()); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Convert() pairs = %q, want %q", got, want)
+	}
+
+	// Quoting is part of the .env line, not the value
+	env, err := c.ConvertMap(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertMap() error = %v", err)
+	}
+	if env["API_TOKEN"] != `abc"d` {
+		t.Errorf("ConvertMap() API_TOKEN = %q, want %q", env["API_TOKEN"], `abc"d`)
+	}
+}
+
+func TestConverter_SchemaInvalid(t *testing.T) {
+	data := map[string]string{"cache_enabled": "yes", "db_port": "54.5", "db_host": "x"}
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+	c.SetSchema([]SchemaRule{
+		{Pattern: "*_ENABLED", Coercions: []Coercion{CoerceBool01}},
+		{Pattern: "DB_PORT", Coercions: []Coercion{CoerceInt}},
+	})
+
+	_, err := c.ConvertMap(strings.NewReader(""))
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("ConvertMap() error = %v, want ValidationError", err)
+	}
+	for _, want := range []string{"'CACHE_ENABLED' (bool01)", "'DB_PORT' (int)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		coercion Coercion
+		value    string
+		want     string
+		ok       bool
+	}{
+		{CoerceBool01, "True", "1", true},
+		{CoerceBool01, "0", "0", true},
+		{CoerceBool01, "on", "on", false},
+		{CoerceInt, "007", "7", true},
+		{CoerceInt, "1e3", "1000", true},
+		{CoerceInt, "-2.0", "-2", true},
+		{CoerceInt, "NaN", "NaN", false},
+		{CoerceInt, "abc", "abc", false},
+		{CoerceLower, "MiXeD", "mixed", true},
+		{CoerceQuote, "a b", "a b", true},
+	}
+
+	for _, tt := range tests {
+		got, ok := coerce(tt.coercion, tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("coerce(%v, %q) = %q, %v, want %q, %v", tt.coercion, tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SetErrorOnAmbiguousKeys controls whether keys that make their line
+// ambiguous with the key-value separator are an error rather than a
+// warning. Readers split each line at the first separator, so with the
+// default "=" a key "A=B" with value "c", written as A=B=c, reads back as
+// "A" with value "B=c".
+func (c *Converter) SetErrorOnAmbiguousKeys(enabled bool) {
+	c.errorOnAmbig = enabled
+}
+
+// IsKeySeparator reports whether sep is made only of characters that are
+// legal in keys, such as "_" or "x", so that many keys are likely to contain
+// it. An empty sep, which selects the default, is not.
+func IsKeySeparator(sep string) bool {
+	if sep == "" {
+		return false
+	}
+	for i := 0; i < len(sep); i++ {
+		if !isIdentByte(sep[i], false) {
+			return false
+		}
+	}
+	return true
+}
+
+// ambiguous reports whether a line holding key would be split elsewhere
+// than after key, because key contains the separator or ends with the
+// start of it
+func (c *Converter) ambiguous(key string) bool {
+	return strings.Index(key+c.kvSep, c.kvSep) != len(key)
+}
+
+// checkSeparator reports the keys whose lines the key-value separator makes
+// ambiguous, as a warning or, with SetErrorOnAmbiguousKeys, as an error.
+// Keys-only and values-only output has no separator to check.
+func (c *Converter) checkSeparator(keys []string) error {
+	if c.bare() {
+		return nil
+	}
+	var found []string
+	for _, k := range keys {
+		if c.ambiguous(k) {
+			found = append(found, fmt.Sprintf("'%s'", k))
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+
+	sort.Strings(found)
+	msg := fmt.Sprintf("keys are ambiguous with the key-value separator %q: %s", c.kvSep, strings.Join(found, ", "))
+	if c.errorOnAmbig {
+		return &ValidationError{Err: errors.New(msg)}
+	}
+	if c.warnings != nil {
+		if _, err := fmt.Fprintf(c.warnings, "Warning: %s\n", msg); err != nil {
+			return &WriteError{Err: err}
+		}
+	}
+	return nil
+}
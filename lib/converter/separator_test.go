@@ -0,0 +1,104 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestIsKeySeparator(t *testing.T) {
+	tests := []struct {
+		sep  string
+		want bool
+	}{
+		{"", false},
+		{"=", false},
+		{": ", false},
+		{" = ", false},
+		{"_", true},
+		{"__", true},
+		{"x", true},
+		{"_=", false},
+	}
+	for _, tt := range tests {
+		if got := IsKeySeparator(tt.sep); got != tt.want {
+			t.Errorf("IsKeySeparator(%q) = %v, want %v", tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestConverter_AmbiguousKeys(t *testing.T) {
+	data := map[string]string{
+		"a=b":   "1",
+		"c:":    "2",
+		"d_e":   "3",
+		"plain": "4",
+		"f: g":  "5",
+		"x==":   "6",
+	}
+
+	tests := []struct {
+		name string
+		sep  string
+		want string
+	}{
+		{"default separator", "", `"=": 'A=B', 'X=='`},
+		{"colon", ": ", `": ": 'F: G'`},
+		{"separator start at the end of a key", "::", `"::": 'C:'`},
+		{"key characters", "_", `"_": 'D_E'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			c.SetKVSeparator(tt.sep)
+			var warnings bytes.Buffer
+			c.SetWarningWriter(&warnings)
+
+			if err := c.Convert(strings.NewReader(""), &bytes.Buffer{}); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			want := "Warning: keys are ambiguous with the key-value separator " + tt.want + "\n"
+			if warnings.String() != want {
+				t.Errorf("warnings = %q, want %q", warnings.String(), want)
+			}
+
+			c.SetErrorOnAmbiguousKeys(true)
+			err := c.Convert(strings.NewReader(""), &bytes.Buffer{})
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Convert() error = %v, want a ValidationError naming %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_AmbiguousKeysUnchecked(t *testing.T) {
+	data := map[string]string{"a=b": "1"}
+
+	for name, setup := range map[string]func(c *Converter){
+		"keys only":   func(c *Converter) { c.SetKeysOnly(true) },
+		"values only": func(c *Converter) { c.SetValuesOnly(true) },
+		"filtered":    func(c *Converter) { c.SetFilterPatterns(nil, []string{"A=B"}, GlobMatcher{}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+			c.SetErrorOnAmbiguousKeys(true)
+			setup(c)
+			if err := c.Convert(strings.NewReader(""), &bytes.Buffer{}); err != nil {
+				t.Errorf("Convert() error = %v", err)
+			}
+		})
+	}
+
+	// Merged output is checked too
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test")})
+	c.SetErrorOnAmbiguousKeys(true)
+	var invalid *ValidationError
+	if err := c.WriteMap(&bytes.Buffer{}, data); !errors.As(err, &invalid) {
+		t.Errorf("WriteMap() error = %v, want a ValidationError", err)
+	}
+}
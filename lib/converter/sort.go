@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortMode controls the order in which keys are written to the output
+type SortMode int
+
+const (
+	// SortKey orders keys lexicographically (default)
+	SortKey SortMode = iota
+
+	// SortNone keeps the order in which the plugin produced the keys.
+	// Source order is only preserved by plugins implementing
+	// plugin.OrderedPlugin; plain maps have no defined order.
+	SortNone
+
+	// SortGrouped orders keys by their top-level prefix, then by key
+	SortGrouped
+)
+
+// String returns the flag value for the sort mode
+func (m SortMode) String() string {
+	switch m {
+	case SortNone:
+		return "none"
+	case SortGrouped:
+		return "grouped"
+	default:
+		return "key"
+	}
+}
+
+// ParseSortMode converts a flag value into a SortMode
+func ParseSortMode(s string) (SortMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "key":
+		return SortKey, nil
+	case "none":
+		return SortNone, nil
+	case "grouped":
+		return SortGrouped, nil
+	default:
+		return SortKey, fmt.Errorf("unsupported sort mode: %s (valid: key, none, grouped)", s)
+	}
+}
+
+// SetSort sets the order in which keys are written
+func (c *Converter) SetSort(mode SortMode) {
+	c.sort = mode
+}
+
+// SetSorted controls whether keys are ordered at all. With sorted set to
+// false, keys are written in map iteration order without sorting, which is
+// faster for very large inputs but makes output order nondeterministic.
+func (c *Converter) SetSorted(sorted bool) {
+	c.unsorted = !sorted
+}
+
+// SetNaturalSort controls whether SortKey and SortGrouped compare runs of
+// digits in keys by their numeric value, so that WORKER_2 sorts before
+// WORKER_10. Keys are otherwise compared byte by byte.
+func (c *Converter) SetNaturalSort(natural bool) {
+	c.naturalSort = natural
+}
+
+// NaturalLess reports whether a sorts before b when each is split into runs
+// of digits and runs of other bytes: digit runs compare by numeric value and
+// other runs byte by byte. Keys that only differ in leading zeros, such as
+// A_01 and A_1, fall back to a plain comparison so the order is total.
+func NaturalLess(a, b string) bool {
+	x, y := a, b
+	for x != "" && y != "" {
+		var rx, ry string
+		rx, x = nextRun(x)
+		ry, y = nextRun(y)
+		if isDigit(rx[0]) && isDigit(ry[0]) {
+			if c := compareNumbers(rx, ry); c != 0 {
+				return c < 0
+			}
+			continue
+		}
+		if rx != ry {
+			return rx < ry
+		}
+	}
+	if x != "" || y != "" {
+		return x == ""
+	}
+	return a < b
+}
+
+// nextRun splits s after its leading run of digits or of other bytes
+func nextRun(s string) (string, string) {
+	digits := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// isDigit reports whether b is an ASCII digit
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// compareNumbers compares two runs of digits by value without converting
+// them, so runs of any length work
+func compareNumbers(x, y string) int {
+	x = strings.TrimLeft(x, "0")
+	y = strings.TrimLeft(y, "0")
+	if len(x) != len(y) {
+		if len(x) < len(y) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+// less returns the key comparison for the sort modes that order by key
+func (c *Converter) less() func(a, b string) bool {
+	if c.naturalSort {
+		return NaturalLess
+	}
+	return func(a, b string) bool { return a < b }
+}
+
+// topLevelPrefix returns the portion of key before the first underscore
+func topLevelPrefix(key string) string {
+	if i := strings.Index(key, "_"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// orderKeys sorts keys in place according to the sort mode
+func (c *Converter) orderKeys(keys []string) {
+	if c.unsorted {
+		return
+	}
+
+	switch c.sort {
+	case SortNone:
+		return
+	case SortGrouped:
+		less := c.less()
+		sort.SliceStable(keys, func(i, j int) bool {
+			pi, pj := topLevelPrefix(keys[i]), topLevelPrefix(keys[j])
+			if pi != pj {
+				return less(pi, pj)
+			}
+			return less(keys[i], keys[j])
+		})
+	default:
+		if c.naturalSort {
+			sort.Slice(keys, func(i, j int) bool { return NaturalLess(keys[i], keys[j]) })
+		} else {
+			sort.Strings(keys)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestParseSortMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SortMode
+		wantErr bool
+	}{
+		{"", SortKey, false},
+		{"key", SortKey, false},
+		{"none", SortNone, false},
+		{"grouped", SortGrouped, false},
+		{"GROUPED", SortGrouped, false},
+		{" none ", SortNone, false},
+		{"random", SortKey, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSortMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSortMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseSortMode(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_Sort(t *testing.T) {
+	input := map[string]string{
+		"api_url":       "https://api.example.com",
+		"api2_url":      "https://api2.example.com",
+		"database_host": "localhost",
+		"api_timeout":   "30",
+		"cache":         "redis",
+	}
+
+	tests := []struct {
+		name string
+		mode SortMode
+		want []string
+	}{
+		{
+			name: "key",
+			mode: SortKey,
+			want: []string{"API2_URL", "API_TIMEOUT", "API_URL", "CACHE", "DATABASE_HOST"},
+		},
+		{
+			name: "grouped",
+			mode: SortGrouped,
+			want: []string{"API_TIMEOUT", "API_URL", "API2_URL", "CACHE", "DATABASE_HOST"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &mockPlugin{
+				BasePlugin: plugin.NewBasePlugin("mock"),
+				parseFunc: func(r io.Reader) (map[string]string, error) {
+					return input, nil
+				},
+			}
+
+			c := New(p)
+			c.SetSort(tt.mode)
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			got := outputKeys(out.String())
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Convert() keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"WORKER_2", "WORKER_10", true},
+		{"WORKER_10", "WORKER_2", false},
+		{"WORKER_9_HOST", "WORKER_10_HOST", true},
+		{"NODE_2_DISK_10", "NODE_2_DISK_9", false},
+		{"V1_2", "V1_10", true},
+		{"A_1", "A_1", false},
+		{"A_01", "A_1", true}, // equal values fall back to a plain comparison
+		{"A_1", "A_01", false},
+		{"A", "A_1", true},
+		{"A_1", "A_B", true},
+		{"A_99999999999999999999", "A_100000000000000000000", true},
+		{"2", "10", true},
+		{"", "A", true},
+		{"A", "", false},
+	}
+	for _, tt := range tests {
+		if got := NaturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("NaturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestConverter_NaturalSort(t *testing.T) {
+	input := map[string]string{
+		"worker_10_host": "j",
+		"worker_2_host":  "b",
+		"worker_1_host":  "a",
+		"worker_100":     "z",
+		"shard10":        "10",
+		"shard9":         "9",
+		"api_v2":         "2",
+		"api_v11":        "11",
+	}
+
+	tests := []struct {
+		name    string
+		mode    SortMode
+		natural bool
+		want    []string
+	}{
+		{
+			name: "plain by default",
+			mode: SortKey,
+			want: []string{"API_V11", "API_V2", "SHARD10", "SHARD9", "WORKER_100", "WORKER_10_HOST", "WORKER_1_HOST", "WORKER_2_HOST"},
+		},
+		{
+			name:    "natural",
+			mode:    SortKey,
+			natural: true,
+			want:    []string{"API_V2", "API_V11", "SHARD9", "SHARD10", "WORKER_1_HOST", "WORKER_2_HOST", "WORKER_10_HOST", "WORKER_100"},
+		},
+		{
+			name:    "natural grouped",
+			mode:    SortGrouped,
+			natural: true,
+			want:    []string{"API_V2", "API_V11", "SHARD9", "SHARD10", "WORKER_1_HOST", "WORKER_2_HOST", "WORKER_10_HOST", "WORKER_100"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: input})
+			c.SetSort(tt.mode)
+			c.SetNaturalSort(tt.natural)
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got := outputKeys(out.String()); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Convert() keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Merged output is ordered the same way
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test")})
+	c.SetNaturalSort(true)
+	var out bytes.Buffer
+	if err := c.WriteMap(&out, map[string]string{"N_10": "", "N_9": "", "N_100": ""}); err != nil {
+		t.Fatalf("WriteMap() error = %v", err)
+	}
+	if got := strings.Join(outputKeys(out.String()), ","); got != "N_9,N_10,N_100" {
+		t.Errorf("WriteMap() keys = %s", got)
+	}
+}
+
+func TestConverter_SortNone(t *testing.T) {
+	input := map[string]string{
+		"b": "2",
+		"a": "1",
+		"c": "3",
+	}
+
+	p := &mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(r io.Reader) (map[string]string, error) {
+			return input, nil
+		},
+	}
+
+	c := New(p)
+	c.SetSort(SortNone)
+
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	// A plain map has no defined order, so only check that every key is written
+	got := outputKeys(out.String())
+	if len(got) != len(input) {
+		t.Fatalf("got %d keys, want %d: %v", len(got), len(input), got)
+	}
+	for _, k := range []string{"A", "B", "C"} {
+		if !strings.Contains(out.String(), k+"=") {
+			t.Errorf("missing key %q in output", k)
+		}
+	}
+}
+
+// orderedPlugin implements plugin.OrderedPlugin for testing
+type orderedPlugin struct {
+	plugin.BasePlugin
+	pairs []plugin.KV
+}
+
+func (p *orderedPlugin) Parse(r io.Reader) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, kv := range p.pairs {
+		env[kv.Key] = kv.Value
+	}
+	return env, nil
+}
+
+func (p *orderedPlugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	return p.pairs, nil
+}
+
+func TestConverter_SortNoneOrdered(t *testing.T) {
+	pairs := []plugin.KV{
+		{Key: "zebra", Value: "1"},
+		{Key: "apple", Value: "2"},
+		{Key: "mango_b", Value: "3"},
+		{Key: "mango_a", Value: "4"},
+	}
+
+	tests := []struct {
+		name    string
+		mode    SortMode
+		include []string
+		want    []string
+	}{
+		{
+			name: "none keeps parse order",
+			mode: SortNone,
+			want: []string{"ZEBRA", "APPLE", "MANGO_B", "MANGO_A"},
+		},
+		{
+			name:    "none with filter keeps parse order",
+			mode:    SortNone,
+			include: []string{"MANGO_*", "ZEBRA"},
+			want:    []string{"ZEBRA", "MANGO_B", "MANGO_A"},
+		},
+		{
+			name: "key ignores parse order",
+			mode: SortKey,
+			want: []string{"APPLE", "MANGO_A", "MANGO_B", "ZEBRA"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &orderedPlugin{
+				BasePlugin: plugin.NewBasePlugin("ordered"),
+				pairs:      pairs,
+			}
+
+			c := New(p)
+			c.SetSort(tt.mode)
+			if len(tt.include) > 0 {
+				c.SetFilterPatterns(tt.include, nil, GlobMatcher{})
+			}
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			got := outputKeys(out.String())
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Convert() keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_SortNoneOrderedDuplicates(t *testing.T) {
+	p := &orderedPlugin{
+		BasePlugin: plugin.NewBasePlugin("ordered"),
+		pairs: []plugin.KV{
+			{Key: "KEY", Value: "1"},
+			{Key: "KEY", Value: "2"},
+		},
+	}
+
+	c := New(p)
+	c.SetSort(SortNone)
+
+	var out bytes.Buffer
+	err := c.Convert(strings.NewReader(""), &out)
+	if err == nil {
+		t.Fatal("Convert() expected duplicate key error, got nil")
+	}
+	if !strings.Contains(err.Error(), "duplicate key 'KEY'") {
+		t.Errorf("Convert() error = %v, want duplicate key 'KEY'", err)
+	}
+}
+
+func TestConverter_SetSorted(t *testing.T) {
+	input := map[string]string{"c": "3", "a": "1", "b": "2"}
+	p := &testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: input}
+
+	c := New(p)
+	c.SetSort(SortGrouped)
+	c.SetSorted(false)
+
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if got := outputKeys(out.String()); len(got) != len(input) {
+		t.Errorf("Convert() keys = %v, want %d keys", got, len(input))
+	}
+
+	// Re-enabling sorting restores the configured sort mode
+	c.SetSorted(true)
+	out.Reset()
+	if err := c.Convert(strings.NewReader(""), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if got := strings.Join(outputKeys(out.String()), ","); got != "A,B,C" {
+		t.Errorf("Convert() keys = %v, want A,B,C", got)
+	}
+}
+
+func benchmarkConvertSorted(b *testing.B, sorted bool) {
+	data := make(map[string]string, 100000)
+	for i := 0; i < 100000; i++ {
+		data[fmt.Sprintf("key_%06d", i)] = "value"
+	}
+	p := &testPlugin{BasePlugin: plugin.NewBasePlugin("bench"), data: data}
+
+	c := New(p)
+	c.SetSorted(sorted)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Convert(strings.NewReader(""), io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConvert_Sorted(b *testing.B) {
+	benchmarkConvertSorted(b, true)
+}
+
+func BenchmarkConvert_Unsorted(b *testing.B) {
+	benchmarkConvertSorted(b, false)
+}
+
+// streamPlugin implements plugin.StreamPlugin for testing, recording which
+// parse method the converter called
+type streamPlugin struct {
+	orderedPlugin
+	streamed bool
+}
+
+func (p *streamPlugin) ParseStream(r io.Reader, emit func(plugin.KV) error) error {
+	p.streamed = true
+	for _, kv := range p.pairs {
+		if err := emit(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestConverter_StreamPlugin(t *testing.T) {
+	pairs := []plugin.KV{
+		{Key: "zebra", Value: "1"},
+		{Key: "apple", Value: "2"},
+		{Key: "zebra", Value: "3"},
+	}
+
+	tests := []struct {
+		name     string
+		mode     SortMode
+		streamed bool
+		want     string
+	}{
+		{"none streams in parse order", SortNone, true, "ZEBRA=3\nAPPLE=2\n"},
+		{"key sorts the parsed map", SortKey, false, "APPLE=2\nZEBRA=3\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &streamPlugin{orderedPlugin: orderedPlugin{
+				BasePlugin: plugin.NewBasePlugin("stream"),
+				pairs:      pairs,
+			}}
+
+			c := New(p)
+			c.SetSort(tt.mode)
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if p.streamed != tt.streamed {
+				t.Errorf("streamed = %v, want %v", p.streamed, tt.streamed)
+			}
+			if !strings.HasSuffix(out.String(), "\n\n"+tt.want) {
+				t.Errorf("Convert() output = %q, want pairs %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ConvertReader returns a reader producing the output Convert would write
+// for r. Conversion runs in a goroutine writing to an io.Pipe as the reader
+// is consumed, so the output can be streamed, for example as an HTTP
+// response body, without buffering it first. An error from the conversion
+// is returned by Read once the output written before it is consumed.
+// Closing the reader early stops the conversion; the caller must close it,
+// or read it to the end, so the goroutine exits.
+func (c *Converter) ConvertReader(r io.Reader) (io.ReadCloser, error) {
+	if r == nil {
+		return nil, fmt.Errorf("input reader is nil")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(c.ConvertContext(ctx, r, pw))
+	}()
+	return &streamReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// streamReader is the read end of a ConvertReader pipe
+type streamReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+// Close cancels the conversion and closes the pipe, so that the goroutine
+// writing to it returns
+func (s *streamReader) Close() error {
+	s.cancel()
+	return s.PipeReader.Close()
+}
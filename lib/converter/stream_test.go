@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_ConvertReader(t *testing.T) {
+	data := map[string]string{"database_host": "localhost", "debug": "true"}
+	c := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test"), data: data})
+
+	var want bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &want); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	rc, err := c.ConvertReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertReader() error = %v", err)
+	}
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(got) != want.String() {
+		t.Errorf("ConvertReader() output = %q, want %q", got, want.String())
+	}
+}
+
+func TestConverter_ConvertReader_Error(t *testing.T) {
+	parseErr := errors.New("bad input")
+	c := New(&mockPlugin{
+		BasePlugin: plugin.NewBasePlugin("mock"),
+		parseFunc: func(io.Reader) (map[string]string, error) {
+			return nil, parseErr
+		},
+	})
+
+	rc, err := c.ConvertReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertReader() error = %v", err)
+	}
+	defer rc.Close()
+
+	_, err = io.ReadAll(rc)
+	var pe *ParseError
+	if !errors.As(err, &pe) || !errors.Is(err, parseErr) {
+		t.Errorf("ReadAll() error = %v, want ParseError wrapping %v", err, parseErr)
+	}
+}
+
+func TestConverter_ConvertReader_NilInput(t *testing.T) {
+	if _, err := New(&testPlugin{BasePlugin: plugin.NewBasePlugin("test")}).ConvertReader(nil); err == nil {
+		t.Error("ConvertReader(nil) error = nil, want error")
+	}
+}
+
+func TestConverter_ConvertReader_Close(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	p := &contextPlugin{
+		BasePlugin: plugin.NewBasePlugin("ctx"),
+		onParse: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return ctx.Err()
+		},
+	}
+
+	rc, err := New(p).ConvertReader(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("ConvertReader() error = %v", err)
+	}
+	<-started
+	if err := rc.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("parse context error = %v, want context.Canceled", err)
+	}
+}
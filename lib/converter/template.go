@@ -0,0 +1,37 @@
+package converter
+
+// template blanks values to produce a committable .env.example
+type template struct {
+	secrets []string
+	matcher Matcher
+}
+
+// shouldBlank determines if the value for key should be emptied.
+// With no secret patterns every value is blanked.
+func (t *template) shouldBlank(key string) bool {
+	if len(t.secrets) == 0 {
+		return true
+	}
+	for _, pattern := range t.secrets {
+		if t.matcher.Match(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTemplate enables template mode, which writes keys with empty values.
+// If secret patterns are given, only keys matching one of them are blanked
+// and all other values are kept as defaults. Patterns are normalized through
+// the same pipeline as keys.
+func (c *Converter) SetTemplate(enabled bool, secrets []string, matcher Matcher) {
+	if !enabled {
+		c.template = nil
+		return
+	}
+
+	c.template = &template{
+		secrets: c.normalizePatterns(secrets, matcher),
+		matcher: matcher,
+	}
+}
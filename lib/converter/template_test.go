@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestConverter_Template(t *testing.T) {
+	input := map[string]string{
+		"database_host":     "localhost",
+		"database_password": "hunter2",
+		"api_token":         "abc123",
+		"api_url":           "https://api.example.com",
+	}
+
+	tests := []struct {
+		name    string
+		secrets []string
+		include []string
+		want    string
+	}{
+		{
+			name: "all values blanked",
+			want: "API_TOKEN=\nAPI_URL=\nDATABASE_HOST=\nDATABASE_PASSWORD=\n",
+		},
+		{
+			name:    "only secrets blanked",
+			secrets: []string{"*_password", "*_TOKEN"},
+			want:    "API_TOKEN=\nAPI_URL=https://api.example.com\nDATABASE_HOST=localhost\nDATABASE_PASSWORD=\n",
+		},
+		{
+			name:    "filtering still applies",
+			include: []string{"DATABASE_*"},
+			want:    "DATABASE_HOST=\nDATABASE_PASSWORD=\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &mockPlugin{
+				BasePlugin: plugin.NewBasePlugin("mock"),
+				parseFunc: func(r io.Reader) (map[string]string, error) {
+					return input, nil
+				},
+			}
+
+			c := New(p)
+			c.SetTemplate(true, tt.secrets, GlobMatcher{})
+			if len(tt.include) > 0 {
+				c.SetFilterPatterns(tt.include, nil, GlobMatcher{})
+			}
+
+			var out bytes.Buffer
+			if err := c.Convert(strings.NewReader(""), &out); err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+
+			want := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: mock\n#\n\n" + tt.want
+			if got := out.String(); got != want {
+				t.Errorf("Convert() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSetTemplateDisabled(t *testing.T) {
+	c := New(nil)
+	c.SetTemplate(true, nil, GlobMatcher{})
+	if c.template == nil {
+		t.Fatal("SetTemplate(true) template should not be nil")
+	}
+	c.SetTemplate(false, nil, GlobMatcher{})
+	if c.template != nil {
+		t.Error("SetTemplate(false) template should be nil")
+	}
+}
@@ -0,0 +1,34 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// Validate checks r without writing any output. Plugins implementing
+// plugin.Validator only check syntax; input for other plugins is converted
+// in full and the result discarded.
+func (c *Converter) Validate(r io.Reader) error {
+	if r == nil {
+		return fmt.Errorf("input reader is nil")
+	}
+
+	if v, ok := c.plugin.(plugin.Validator); ok {
+		limited := utils.LimitInput(r, c.maxInput)
+		err := v.Validate(limited)
+		if limited.Err() != nil {
+			return limited.Err()
+		}
+		if err != nil {
+			return &ParseError{Plugin: c.plugin.Name(), Err: err}
+		}
+		return nil
+	}
+
+	_, err := c.convert(context.Background(), r)
+	return err
+}
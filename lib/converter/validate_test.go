@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestConverter_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugin  plugin.Plugin
+		input   string
+		wantErr bool
+	}{
+		{"valid json", json.New(), `{"database": {"host": "localhost"}}`, false},
+		{"invalid json", json.New(), `{"database": {"host": "localhost"}`, true},
+		{"valid yaml", yaml.New(), "database:\n  host: localhost\n", false},
+		{"invalid yaml", yaml.New(), "database: [localhost\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.plugin).Validate(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var parseErr *ParseError
+			if err != nil && !errors.As(err, &parseErr) {
+				t.Errorf("Validate() error = %v, want ParseError", err)
+			}
+		})
+	}
+}
+
+func TestConverter_Validate_FallsBackToConvert(t *testing.T) {
+	// testPlugin does not implement plugin.Validator, so input is converted
+	c := New(&testPlugin{
+		BasePlugin: plugin.NewBasePlugin("test"),
+		data:       map[string]string{"api_key": "a", "API_KEY": "b"},
+	})
+
+	err := c.Validate(strings.NewReader(""))
+	if err == nil || !strings.Contains(err.Error(), "duplicate key") {
+		t.Errorf("Validate() error = %v, want duplicate key error", err)
+	}
+
+	if err := c.Validate(nil); err == nil {
+		t.Error("Validate(nil) error = nil, want error")
+	}
+}
@@ -0,0 +1,107 @@
+// Package plugintest provides helpers for testing format plugins, such as
+// checking that a plugin flattens a document exactly like the built-in
+// plugins do.
+package plugintest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	jsonplugin "github.com/handaber/cfg2env/plugins/json"
+	yamlplugin "github.com/handaber/cfg2env/plugins/yaml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format pairs a plugin with a function rendering a value in the syntax the
+// plugin reads
+type Format struct {
+	// Plugin parses the rendered document
+	Plugin plugin.Plugin
+
+	// Render encodes a value built from maps, slices and scalars, as
+	// produced by encoding/json or yaml.v3, in the plugin's format
+	Render func(v interface{}) ([]byte, error)
+}
+
+// YAML returns the built-in YAML plugin with a yaml.v3 renderer
+func YAML() Format {
+	return Format{Plugin: yamlplugin.New(), Render: yaml.Marshal}
+}
+
+// JSON returns the built-in JSON plugin with an encoding/json renderer
+func JSON() Format {
+	return Format{Plugin: jsonplugin.New(), Render: json.Marshal}
+}
+
+// RoundTrip renders v in each format, parses it back with the format's
+// plugin and reports, through t, any format whose flattened pairs differ
+// from those of the first format. Plugin authors can pass their own Format
+// after YAML() or JSON() to check that their plugin flattens like the
+// built-ins. It returns the pairs of the first format.
+func RoundTrip(t testing.TB, v interface{}, formats ...Format) map[string]string {
+	t.Helper()
+	if len(formats) == 0 {
+		t.Fatalf("RoundTrip: no formats given")
+		return nil
+	}
+
+	var want map[string]string
+	var wantName string
+	for _, f := range formats {
+		name := f.Plugin.Name()
+		doc, err := f.Render(v)
+		if err != nil {
+			t.Errorf("%s: rendering: %v", name, err)
+			continue
+		}
+		got, err := f.Plugin.Parse(bytes.NewReader(doc))
+		if err != nil {
+			t.Errorf("%s: parsing rendered document: %v\n%s", name, err, doc)
+			continue
+		}
+
+		if want == nil {
+			want, wantName = got, name
+			continue
+		}
+		if diff := Diff(want, got); diff != "" {
+			t.Errorf("%s flattens differently from %s:\n%s", name, wantName, diff)
+		}
+	}
+	return want
+}
+
+// Diff describes the differences between two sets of flattened pairs, one
+// line per key, or returns "" if they are equal
+func Diff(want, got map[string]string) string {
+	keys := make([]string, 0, len(want)+len(got))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		w, inWant := want[k]
+		g, inGot := got[k]
+		switch {
+		case !inGot:
+			lines = append(lines, fmt.Sprintf("  missing %s (want %q)", k, w))
+		case !inWant:
+			lines = append(lines, fmt.Sprintf("  extra %s=%q", k, g))
+		case w != g:
+			lines = append(lines, fmt.Sprintf("  %s = %q, want %q", k, g, w))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
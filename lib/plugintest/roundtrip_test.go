@@ -0,0 +1,113 @@
+package plugintest
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// document returns a config exercising nesting, arrays of maps and every
+// scalar type both built-in formats share
+func document() map[string]interface{} {
+	return map[string]interface{}{
+		"database": map[string]interface{}{
+			"host":    "localhost",
+			"port":    5432,
+			"ratio":   0.75,
+			"enabled": true,
+			"replica": nil,
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "a", "tags": []interface{}{"web", "edge"}},
+			map[string]interface{}{"name": "b", "tls": map[string]interface{}{"enabled": false}},
+		},
+		"empty":   map[string]interface{}{},
+		"message": "line one\nline two",
+		"quoted":  "true",
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	got := RoundTrip(t, document(), YAML(), JSON())
+
+	want := map[string]string{
+		"DATABASE_HOST":         "localhost",
+		"DATABASE_PORT":         "5432",
+		"DATABASE_RATIO":        "0.75",
+		"DATABASE_ENABLED":      "true",
+		"DATABASE_REPLICA":      "",
+		"SERVERS_0_NAME":        "a",
+		"SERVERS_0_TAGS_0":      "web",
+		"SERVERS_0_TAGS_1":      "edge",
+		"SERVERS_1_NAME":        "b",
+		"SERVERS_1_TLS_ENABLED": "false",
+		"EMPTY":                 "",
+		"MESSAGE":               "line one\nline two",
+		"QUOTED":                "true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RoundTrip() = %v, want %v\n%s", got, want, Diff(want, got))
+	}
+}
+
+// recorder captures the errors RoundTrip reports
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// upperPlugin flattens like JSON but uppercases every value, standing in
+// for a third-party plugin that diverges from the built-ins
+type upperPlugin struct {
+	plugin.BasePlugin
+	json plugin.Plugin
+}
+
+func (p upperPlugin) Parse(r io.Reader) (map[string]string, error) {
+	env, err := p.json.Parse(r)
+	for k, v := range env {
+		env[k] = strings.ToUpper(v)
+	}
+	return env, err
+}
+
+func TestRoundTrip_ReportsDivergence(t *testing.T) {
+	upper := Format{
+		Plugin: upperPlugin{plugin.NewBasePlugin("upper"), JSON().Plugin},
+		Render: JSON().Render,
+	}
+
+	rec := &recorder{TB: t}
+	RoundTrip(rec, map[string]interface{}{"host": "localhost", "port": 80}, YAML(), upper)
+
+	if len(rec.errors) != 1 {
+		t.Fatalf("RoundTrip() reported %d errors, want 1: %v", len(rec.errors), rec.errors)
+	}
+	want := "upper flattens differently from yaml:\n  HOST = \"LOCALHOST\", want \"localhost\""
+	if rec.errors[0] != want {
+		t.Errorf("RoundTrip() error = %q, want %q", rec.errors[0], want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want := map[string]string{"A": "1", "B": "2", "C": "3"}
+	got := map[string]string{"A": "1", "B": "x", "D": "4"}
+
+	if d := Diff(want, want); d != "" {
+		t.Errorf("Diff() of equal maps = %q, want empty", d)
+	}
+	wantDiff := "  B = \"x\", want \"2\"\n  missing C (want \"3\")\n  extra D=\"4\""
+	if d := Diff(want, got); d != wantDiff {
+		t.Errorf("Diff() = %q, want %q", d, wantDiff)
+	}
+}
@@ -2,45 +2,323 @@ package utils
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// ArrayMode controls how arrays are flattened
+type ArrayMode int
+
+const (
+	// ArrayIndex flattens each array element into its own indexed key (default)
+	ArrayIndex ArrayMode = iota
+
+	// ArrayJoin joins arrays of scalars into a single separated value.
+	// Arrays containing maps or arrays fall back to indexed keys.
+	ArrayJoin
 )
 
+// DefaultArraySep is the separator used to join array elements
+const DefaultArraySep = ","
+
+// String returns the flag value for the array mode
+func (m ArrayMode) String() string {
+	if m == ArrayJoin {
+		return "join"
+	}
+	return "index"
+}
+
+// ParseArrayMode converts a flag value into an ArrayMode
+func ParseArrayMode(s string) (ArrayMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "index":
+		return ArrayIndex, nil
+	case "join":
+		return ArrayJoin, nil
+	default:
+		return ArrayIndex, fmt.Errorf("unsupported array mode: %s (valid: index, join)", s)
+	}
+}
+
+// FlattenOptions configures how values are flattened
+type FlattenOptions struct {
+	// Arrays selects indexed or joined array flattening
+	Arrays ArrayMode
+
+	// ArraySep separates elements in ArrayJoin mode
+	ArraySep string
+
+	// TimeFormat is the layout for time.Time values, such as YAML
+	// timestamps. Empty uses time.RFC3339.
+	TimeFormat string
+
+	// OmitEmptyContainers drops empty maps and arrays instead of writing
+	// them as a key with an empty value
+	OmitEmptyContainers bool
+
+	// CompactArrays drops null elements from arrays before they are
+	// indexed or joined, so [a, null, c] flattens to KEY_0=a and KEY_1=c
+	// rather than leaving KEY_1 empty
+	CompactArrays bool
+
+	// KeyField, if set, names a field of the maps in an array whose scalar
+	// value is used in place of the element's index, so that
+	// [{name: db, host: x}] flattens to DB_HOST rather than 0_HOST and
+	// 0_NAME. The field itself is not flattened. Elements without it keep
+	// their index.
+	KeyField string
+
+	// Format, if set, converts scalars to strings in place of the default
+	// formatting, for plugins whose decoders represent values differently
+	Format func(interface{}) string
+
+	// OnCollision, if set, is called with each flattened key that was
+	// already produced by a different path, such as a literal "db_host"
+	// key next to a nested "db: {host: ...}" map
+	OnCollision func(key string)
+
+	// OnPath, if set, is called with each flattened key and its source
+	// path: the map keys and array indices leading to the value, as written
+	// and joined with dots, such as database.host for DATABASE_HOST
+	OnPath func(key, path string)
+
+	// Path is the source path of the value being flattened, which the
+	// paths passed to OnPath extend. It is only tracked while OnPath is set.
+	Path string
+}
+
+// JoinArray joins the elements of val with sep, formatting each with format.
+// It reports false if any element is a map or an array.
+func JoinArray(val []interface{}, sep string, format func(interface{}) string) (string, bool) {
+	parts := make([]string, 0, len(val))
+	for _, v := range val {
+		switch v.(type) {
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			return "", false
+		}
+		parts = append(parts, format(v))
+	}
+	return strings.Join(parts, sep), true
+}
+
 // Flatten recursively flattens nested maps into dot-separated keys
 func Flatten(prefix string, v interface{}, env map[string]string) {
+	FlattenWith(prefix, v, env, FlattenOptions{})
+}
+
+// FlattenWith recursively flattens nested maps into underscore-separated keys
+// using opts. Map keys are visited in sorted order so that keys produced by
+// more than one path resolve deterministically.
+func FlattenWith(prefix string, v interface{}, env map[string]string, opts FlattenOptions) {
 	switch val := v.(type) {
 	case map[string]interface{}:
 		if len(val) == 0 {
-			env[strings.ToUpper(prefix)] = ""
+			if !opts.OmitEmptyContainers {
+				opts.Set(env, prefix, "")
+			}
 			return
 		}
-		for k, v := range val {
-			newKey := k
-			if prefix != "" {
-				newKey = prefix + "_" + k
-			}
-			Flatten(newKey, v, env)
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			FlattenWith(JoinKey(prefix, k), val[k], env, opts.Descend(k))
 		}
 	case map[interface{}]interface{}:
 		if len(val) == 0 {
-			env[strings.ToUpper(prefix)] = ""
+			if !opts.OmitEmptyContainers {
+				opts.Set(env, prefix, "")
+			}
 			return
 		}
-		for k, v := range val {
-			strKey := k.(string)
-			newKey := strKey
-			if prefix != "" {
-				newKey = prefix + "_" + strKey
+		// Keys such as 1 and "1" format alike. Each is still flattened, in
+		// order of its type name, so the keys they share resolve
+		// deterministically and are reported through opts.OnCollision.
+		keys := make([]mapKey, 0, len(val))
+		for k := range val {
+			keys = append(keys, mapKey{key: k, str: fmt.Sprint(k), typ: fmt.Sprintf("%T", k)})
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].str != keys[j].str {
+				return keys[i].str < keys[j].str
 			}
-			Flatten(newKey, v, env)
+			return keys[i].typ < keys[j].typ
+		})
+		for _, k := range keys {
+			FlattenWith(JoinKey(prefix, k.str), val[k.key], env, opts.Descend(k.str))
 		}
 	case []interface{}:
+		val = opts.Elements(val)
+		if len(val) == 0 && opts.OmitEmptyContainers {
+			return
+		}
+		if opts.Arrays == ArrayJoin {
+			if joined, ok := JoinArray(val, opts.ArraySep, opts.FormatValue); ok {
+				opts.Set(env, prefix, joined)
+				return
+			}
+		}
 		for i, v := range val {
-			newKey := prefix + "_" + fmt.Sprintf("%d", i)
-			Flatten(newKey, v, env)
+			key, rest := opts.ElementKey(i, v)
+			FlattenWith(JoinKey(prefix, key), rest, env, opts.Descend(key))
+		}
+	case string, int, float64, bool, nil, time.Time:
+		opts.Set(env, prefix, opts.FormatValue(val))
+	}
+}
+
+// mapKey is a key of a map[interface{}]interface{} with its formatted value
+// and type name, for sorting
+type mapKey struct {
+	key interface{}
+	str string
+	typ string
+}
+
+// JoinKey joins a nested key to its parent's prefix with an underscore. At
+// the root, where prefix is empty, the key is used as is, so the elements of
+// a root array flatten to 0, 1, ... rather than _0, _1, ...
+func JoinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+// Elements returns the elements of val to flatten: all of them, or with
+// opts.CompactArrays those that are not null
+func (opts FlattenOptions) Elements(val []interface{}) []interface{} {
+	if !opts.CompactArrays {
+		return val
+	}
+	kept := make([]interface{}, 0, len(val))
+	for _, v := range val {
+		if v != nil {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// ElementKey returns the key of element v at index i of an array, along with
+// what is left of v to flatten under it. If v is a map holding opts.KeyField
+// as a non-empty scalar, the key is that value and the field is dropped from
+// the rest; otherwise the key is the index and v is returned unchanged.
+func (opts FlattenOptions) ElementKey(i int, v interface{}) (string, interface{}) {
+	index := fmt.Sprintf("%d", i)
+	if opts.KeyField == "" {
+		return index, v
+	}
+
+	switch m := v.(type) {
+	case map[string]interface{}:
+		key, ok := opts.scalarKey(m[opts.KeyField])
+		if !ok {
+			return index, v
+		}
+		rest := make(map[string]interface{}, len(m)-1)
+		for k, val := range m {
+			if k != opts.KeyField {
+				rest[k] = val
+			}
+		}
+		return key, rest
+	case map[interface{}]interface{}:
+		key, ok := opts.scalarKey(m[opts.KeyField])
+		if !ok {
+			return index, v
+		}
+		rest := make(map[interface{}]interface{}, len(m)-1)
+		for k, val := range m {
+			if k != opts.KeyField {
+				rest[k] = val
+			}
 		}
-	case string, int, float64, bool, nil:
-		env[strings.ToUpper(prefix)] = ToString(val)
+		return key, rest
+	}
+	return index, v
+}
+
+// scalarKey formats v for use as a key, reporting false if v is missing,
+// empty, or a map or array
+func (opts FlattenOptions) scalarKey(v interface{}) (string, bool) {
+	switch v.(type) {
+	case nil, map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		return "", false
+	}
+	key := opts.FormatValue(v)
+	return key, key != ""
+}
+
+// FormatValue converts a scalar to its string representation using
+// opts.Format if set, and otherwise formatting time.Time values with
+// opts.TimeFormat
+func (opts FlattenOptions) FormatValue(v interface{}) string {
+	if opts.Format != nil {
+		return opts.Format(v)
+	}
+	if t, ok := v.(time.Time); ok && opts.TimeFormat != "" {
+		return t.Format(opts.TimeFormat)
+	}
+	return ToString(v)
+}
+
+// Set stores value under the uppercased key, reporting keys that were
+// already produced by another path
+func (opts FlattenOptions) Set(env map[string]string, key, value string) {
+	key = strings.ToUpper(key)
+	if _, exists := env[key]; exists && opts.OnCollision != nil {
+		opts.OnCollision(key)
+	}
+	if opts.OnPath != nil {
+		opts.OnPath(key, opts.Path)
+	}
+	env[key] = value
+}
+
+// CollisionWarning returns the warning plugins report for a key produced by
+// more than one path
+func CollisionWarning(key string) string {
+	return fmt.Sprintf("key '%s' is produced by more than one path", key)
+}
+
+// Collisions records the pairs flattened to keys produced by more than one
+// path, for plugins implementing plugin.CollisionReporter
+type Collisions map[string][]plugin.KV
+
+// Shadow records the value env holds for key, with its path in paths, before
+// another path overwrites it. It is meant to be called from OnCollision,
+// which runs before the new value is stored.
+func (c Collisions) Shadow(key string, env, paths map[string]string) {
+	c[key] = append(c[key], plugin.KV{Key: key, Value: env[key], Path: paths[key]})
+}
+
+// Complete appends the value and path each recorded key ends with, once
+// flattening is done
+func (c Collisions) Complete(env, paths map[string]string) {
+	for key := range c {
+		c[key] = append(c[key], plugin.KV{Key: key, Value: env[key], Path: paths[key]})
+	}
+}
+
+// Descend returns opts for flattening the child named name, extending Path
+// while OnPath is set
+func (opts FlattenOptions) Descend(name string) FlattenOptions {
+	if opts.OnPath == nil {
+		return opts
+	}
+	if opts.Path == "" {
+		opts.Path = name
+	} else {
+		opts.Path += "." + name
 	}
+	return opts
 }
 
 // ToString converts various types to their string representation
@@ -63,7 +341,137 @@ func ToString(v interface{}) string {
 			return "true"
 		}
 		return "false"
+	case time.Time:
+		return val.Format(time.RFC3339)
 	default:
 		return fmt.Sprintf("%v", val)
 	}
 }
+
+// PrunePaths removes the subtrees at paths from the decoded value v before it
+// is flattened. Each path is a dot-separated list of map keys, such as
+// "logging" or "app.metadata", matched case-insensitively. Maps left empty
+// by pruning are removed as well, so pruning never produces an empty KEY=
+// for the parent. Paths that do not exist are ignored.
+func PrunePaths(v interface{}, paths []string) interface{} {
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		prunePath(v, strings.Split(path, "."))
+	}
+	return v
+}
+
+// prunePath removes the subtree at segs from v, reporting whether v was a
+// non-empty map that pruning left empty
+func prunePath(v interface{}, segs []string) bool {
+	pruned := false
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if !strings.EqualFold(k, segs[0]) {
+				continue
+			}
+			if len(segs) == 1 || prunePath(child, segs[1:]) {
+				delete(val, k)
+				pruned = true
+			}
+		}
+		return pruned && len(val) == 0
+	case map[interface{}]interface{}:
+		for k, child := range val {
+			if s, ok := k.(string); !ok || !strings.EqualFold(s, segs[0]) {
+				continue
+			}
+			if len(segs) == 1 || prunePath(child, segs[1:]) {
+				delete(val, k)
+				pruned = true
+			}
+		}
+		return pruned && len(val) == 0
+	}
+	return false
+}
+
+// Selection is a subtree of a decoded value chosen by SelectPath
+type Selection struct {
+	// Value is the subtree, or nil if the path does not exist
+	Value interface{}
+
+	// Prefix is the prefix to flatten Value under
+	Prefix string
+
+	// Path is the dotted path to Value as written in the source, which
+	// stays whole when the prefix is stripped
+	Path string
+
+	// from is the uppercased prefix the subtree's keys carry when the
+	// whole value is flattened
+	from string
+}
+
+// SelectPath returns the subtree of v at the dot-separated path, such as
+// "database" or "app.database", matched case-insensitively like PrunePaths.
+// Keys flattened from the subtree keep their full path unless strip is set,
+// in which case the path is dropped from keys below a map; a scalar or array
+// at the path keeps its own name. A missing path selects nothing.
+func SelectPath(v interface{}, path string, strip bool) Selection {
+	var names []string
+	for _, seg := range strings.Split(path, ".") {
+		var ok bool
+		var name string
+		v, name, ok = child(v, strings.TrimSpace(seg))
+		if !ok {
+			return Selection{}
+		}
+		names = append(names, name)
+	}
+
+	full := strings.Join(names, "_")
+	sel := Selection{Value: v, Prefix: full, Path: strings.Join(names, "."), from: strings.ToUpper(full)}
+	if strip {
+		switch v.(type) {
+		case map[string]interface{}, map[interface{}]interface{}:
+			sel.Prefix = ""
+			sel.from += "_"
+		default:
+			sel.Prefix = names[len(names)-1]
+		}
+	}
+	return sel
+}
+
+// Rekey maps a key flattened from the whole value to the key flattened from
+// the selection, so that plugins can reuse the source order of the whole
+// document. Keys outside the selection are returned unchanged.
+func (s Selection) Rekey(key string) string {
+	if s.from == "" || !strings.HasPrefix(key, s.from) {
+		return key
+	}
+	return strings.ToUpper(s.Prefix) + key[len(s.from):]
+}
+
+// child returns the value under the map key matching name case-insensitively,
+// along with the key as written in the source
+func child(v interface{}, name string) (interface{}, string, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if c, ok := val[name]; ok {
+			return c, name, true
+		}
+		for k, c := range val {
+			if strings.EqualFold(k, name) {
+				return c, k, true
+			}
+		}
+	case map[interface{}]interface{}:
+		for k, c := range val {
+			if s, ok := k.(string); ok && strings.EqualFold(s, name) {
+				return c, s, true
+			}
+		}
+	}
+	return nil, "", false
+}
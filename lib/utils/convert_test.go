@@ -3,6 +3,7 @@ package utils
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestFlatten(t *testing.T) {
@@ -75,6 +76,27 @@ func TestFlatten(t *testing.T) {
 				"MIXED_KEY":  "value",
 			},
 		},
+		{
+			name:   "non-string keys",
+			prefix: "",
+			input: map[interface{}]interface{}{
+				1:      "a",
+				true:   "b",
+				"port": map[interface{}]interface{}{8080: "http", false: "off"},
+			},
+			want: map[string]string{
+				"1":          "a",
+				"TRUE":       "b",
+				"PORT_8080":  "http",
+				"PORT_FALSE": "off",
+			},
+		},
+		{
+			name:   "root array",
+			prefix: "",
+			input:  []interface{}{"a", map[string]interface{}{"x": 1}, []interface{}{true}},
+			want:   map[string]string{"0": "a", "1_X": "1", "2_0": "true"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -129,6 +151,11 @@ func TestToString(t *testing.T) {
 			input: nil,
 			want:  "",
 		},
+		{
+			name:  "time",
+			input: time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 2*60*60)),
+			want:  "2024-01-02T03:04:05+02:00",
+		},
 		{
 			name:  "custom type",
 			input: struct{ name string }{"test"},
@@ -144,3 +171,699 @@ func TestToString(t *testing.T) {
 		})
 	}
 }
+
+func TestFlattenWith_ArrayModes(t *testing.T) {
+	input := map[string]interface{}{
+		"features": []interface{}{"logging", "metrics"},
+		"ports":    []interface{}{80, 443},
+		"servers": []interface{}{
+			map[string]interface{}{"host": "a"},
+			"b",
+		},
+		"empty": []interface{}{},
+	}
+
+	tests := []struct {
+		name string
+		opts FlattenOptions
+		want map[string]string
+	}{
+		{
+			name: "index",
+			opts: FlattenOptions{Arrays: ArrayIndex},
+			want: map[string]string{
+				"FEATURES_0":     "logging",
+				"FEATURES_1":     "metrics",
+				"PORTS_0":        "80",
+				"PORTS_1":        "443",
+				"SERVERS_0_HOST": "a",
+				"SERVERS_1":      "b",
+			},
+		},
+		{
+			name: "join",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ","},
+			want: map[string]string{
+				"FEATURES":       "logging,metrics",
+				"PORTS":          "80,443",
+				"SERVERS_0_HOST": "a",
+				"SERVERS_1":      "b",
+				"EMPTY":          "",
+			},
+		},
+		{
+			name: "join with custom separator",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ":"},
+			want: map[string]string{
+				"FEATURES":       "logging:metrics",
+				"PORTS":          "80:443",
+				"SERVERS_0_HOST": "a",
+				"SERVERS_1":      "b",
+				"EMPTY":          "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			FlattenWith("", input, got, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlattenWith() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArrayMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ArrayMode
+		wantErr bool
+	}{
+		{"", ArrayIndex, false},
+		{"index", ArrayIndex, false},
+		{"join", ArrayJoin, false},
+		{"JOIN", ArrayJoin, false},
+		{"csv", ArrayIndex, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseArrayMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseArrayMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseArrayMode(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenWith_OnCollision(t *testing.T) {
+	input := map[string]interface{}{
+		"database_host": "literal",
+		"database": map[string]interface{}{
+			"host": "nested",
+		},
+	}
+
+	var collisions []string
+	got := make(map[string]string)
+	FlattenWith("", input, got, FlattenOptions{
+		OnCollision: func(key string) { collisions = append(collisions, key) },
+	})
+
+	if !reflect.DeepEqual(collisions, []string{"DATABASE_HOST"}) {
+		t.Errorf("collisions = %v, want [DATABASE_HOST]", collisions)
+	}
+	if got["DATABASE_HOST"] != "literal" {
+		t.Errorf("DATABASE_HOST = %q, want %q", got["DATABASE_HOST"], "literal")
+	}
+}
+
+func TestFlattenWith_CaseVariants(t *testing.T) {
+	// Keys differing only in case collapse to one output key. Map keys are
+	// visited in sorted order, so the key sorting last always wins no matter
+	// how the map iterates.
+	input := map[string]interface{}{
+		"api_key": "lower",
+		"Api_Key": "mixed",
+		"API_KEY": "upper",
+	}
+
+	for i := 0; i < 20; i++ {
+		var collisions []string
+		got := make(map[string]string)
+		FlattenWith("", input, got, FlattenOptions{
+			OnCollision: func(key string) { collisions = append(collisions, key) },
+		})
+
+		if got["API_KEY"] != "lower" {
+			t.Fatalf("run %d: API_KEY = %q, want %q", i, got["API_KEY"], "lower")
+		}
+		if !reflect.DeepEqual(collisions, []string{"API_KEY", "API_KEY"}) {
+			t.Fatalf("run %d: collisions = %v, want two for API_KEY", i, collisions)
+		}
+	}
+}
+
+func TestFlattenWith_InterfaceKeyCollision(t *testing.T) {
+	// 1 and "1" both flatten to key 1: the collision is reported and the
+	// key whose type sorts last, string, always wins
+	input := map[interface{}]interface{}{
+		1:   "int",
+		"1": "string",
+		2:   "two",
+	}
+
+	for i := 0; i < 20; i++ {
+		var collisions []string
+		got := make(map[string]string)
+		FlattenWith("", input, got, FlattenOptions{
+			OnCollision: func(key string) { collisions = append(collisions, key) },
+		})
+
+		want := map[string]string{"1": "string", "2": "two"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: FlattenWith() = %v, want %v", i, got, want)
+		}
+		if !reflect.DeepEqual(collisions, []string{"1"}) {
+			t.Fatalf("run %d: collisions = %v, want [1]", i, collisions)
+		}
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	input := map[string]interface{}{
+		"api_key": "lower",
+		"Api_Key": "mixed",
+		"API_KEY": "upper",
+		"port":    1,
+	}
+
+	env := make(map[string]string)
+	paths := make(map[string]string)
+	collisions := make(Collisions)
+	FlattenWith("", input, env, FlattenOptions{
+		OnCollision: func(key string) { collisions.Shadow(key, env, paths) },
+		OnPath:      func(key, path string) { paths[key] = path },
+	})
+	collisions.Complete(env, paths)
+
+	want := Collisions{"API_KEY": {
+		{Key: "API_KEY", Value: "upper", Path: "API_KEY"},
+		{Key: "API_KEY", Value: "mixed", Path: "Api_Key"},
+		{Key: "API_KEY", Value: "lower", Path: "api_key"},
+	}}
+	if !reflect.DeepEqual(collisions, want) {
+		t.Errorf("collisions = %v, want %v", collisions, want)
+	}
+}
+
+func TestPrunePaths(t *testing.T) {
+	newInput := func() map[string]interface{} {
+		return map[string]interface{}{
+			"app": map[string]interface{}{
+				"name":     "svc",
+				"metadata": map[string]interface{}{"owner": "team"},
+			},
+			"logging": map[string]interface{}{"level": "debug"},
+			"extra":   map[string]interface{}{"tags": map[string]interface{}{"a": "1"}},
+			"empty":   map[string]interface{}{},
+			"list":    []interface{}{"a"},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  map[string]string
+	}{
+		{
+			name:  "no paths",
+			paths: nil,
+			want:  map[string]string{"APP_NAME": "svc", "APP_METADATA_OWNER": "team", "LOGGING_LEVEL": "debug", "EXTRA_TAGS_A": "1", "EMPTY": "", "LIST_0": "a"},
+		},
+		{
+			name:  "top-level section",
+			paths: []string{"logging"},
+			want:  map[string]string{"APP_NAME": "svc", "APP_METADATA_OWNER": "team", "EXTRA_TAGS_A": "1", "EMPTY": "", "LIST_0": "a"},
+		},
+		{
+			name:  "nested path, case-insensitive",
+			paths: []string{"APP.Metadata", " logging "},
+			want:  map[string]string{"APP_NAME": "svc", "EXTRA_TAGS_A": "1", "EMPTY": "", "LIST_0": "a"},
+		},
+		{
+			name:  "parent emptied by pruning is dropped",
+			paths: []string{"extra.tags"},
+			want:  map[string]string{"APP_NAME": "svc", "APP_METADATA_OWNER": "team", "LOGGING_LEVEL": "debug", "EMPTY": "", "LIST_0": "a"},
+		},
+		{
+			name:  "missing paths and paths through non-maps are ignored",
+			paths: []string{"nope", "empty.x", "list.0", "app.name.x", ""},
+			want:  map[string]string{"APP_NAME": "svc", "APP_METADATA_OWNER": "team", "LOGGING_LEVEL": "debug", "EXTRA_TAGS_A": "1", "EMPTY": "", "LIST_0": "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			Flatten("", PrunePaths(newInput(), tt.paths), got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PrunePaths() flattened = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrunePaths_InterfaceKeys(t *testing.T) {
+	input := map[interface{}]interface{}{
+		"db":      map[interface{}]interface{}{"host": "h", "password": "p"},
+		"logging": "x",
+	}
+	got := make(map[string]string)
+	Flatten("", PrunePaths(input, []string{"db.password", "logging"}), got)
+	want := map[string]string{"DB_HOST": "h"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PrunePaths() flattened = %v, want %v", got, want)
+	}
+}
+
+func TestSelectPath(t *testing.T) {
+	input := map[string]interface{}{
+		"app": map[string]interface{}{
+			"Database": map[string]interface{}{
+				"host":  "db",
+				"ports": []interface{}{5432, 5433},
+			},
+			"name": "svc",
+		},
+		"database_url": "postgres://",
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		strip bool
+		want  map[string]string
+	}{
+		{"nested map", "app.database", false, map[string]string{"APP_DATABASE_HOST": "db", "APP_DATABASE_PORTS_0": "5432", "APP_DATABASE_PORTS_1": "5433"}},
+		{"nested map stripped", "APP.Database", true, map[string]string{"HOST": "db", "PORTS_0": "5432", "PORTS_1": "5433"}},
+		{"scalar keeps its name when stripped", "app.name", true, map[string]string{"NAME": "svc"}},
+		{"array keeps its name when stripped", "app.database.ports", true, map[string]string{"PORTS_0": "5432", "PORTS_1": "5433"}},
+		{"missing path", "app.cache", false, map[string]string{}},
+		{"path through a scalar", "app.name.first", true, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel := SelectPath(input, tt.path, tt.strip)
+			got := make(map[string]string)
+			if sel.Value != nil {
+				Flatten(sel.Prefix, sel.Value, got)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectPath() flattened = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelection_Rekey(t *testing.T) {
+	input := map[string]interface{}{
+		"database": map[string]interface{}{"host": "db"},
+	}
+
+	tests := []struct {
+		name  string
+		strip bool
+		key   string
+		want  string
+	}{
+		{"unstripped keys are unchanged", false, "DATABASE_HOST", "DATABASE_HOST"},
+		{"stripped keys lose the path", true, "DATABASE_HOST", "HOST"},
+		{"keys outside the selection are unchanged", true, "CACHE_HOST", "CACHE_HOST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel := SelectPath(input, "database", tt.strip)
+			if got := sel.Rekey(tt.key); got != tt.want {
+				t.Errorf("Rekey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenWith_TimeFormat(t *testing.T) {
+	input := map[string]interface{}{
+		"released": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		"windows":  []interface{}{time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name string
+		opts FlattenOptions
+		want map[string]string
+	}{
+		{
+			name: "default RFC3339",
+			opts: FlattenOptions{},
+			want: map[string]string{"RELEASED": "2024-01-02T00:00:00Z", "WINDOWS_0": "2024-03-04T00:00:00Z"},
+		},
+		{
+			name: "custom layout",
+			opts: FlattenOptions{TimeFormat: "2006-01-02"},
+			want: map[string]string{"RELEASED": "2024-01-02", "WINDOWS_0": "2024-03-04"},
+		},
+		{
+			name: "custom layout in joined arrays",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ",", TimeFormat: "2006-01-02"},
+			want: map[string]string{"RELEASED": "2024-01-02", "WINDOWS": "2024-03-04"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			FlattenWith("", input, got, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlattenWith() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenWith_ArrayOfMaps(t *testing.T) {
+	inputs := map[string]interface{}{
+		"string keyed maps": map[string]interface{}{
+			"servers": []interface{}{
+				map[string]interface{}{"host": "a", "ports": []interface{}{80}},
+				map[string]interface{}{"host": "b", "ports": []interface{}{443}},
+			},
+		},
+		"interface keyed maps": map[interface{}]interface{}{
+			"servers": []interface{}{
+				map[interface{}]interface{}{"host": "a", "ports": []interface{}{80}},
+				map[interface{}]interface{}{"host": "b", "ports": []interface{}{443}},
+			},
+		},
+	}
+
+	wants := map[ArrayMode]map[string]string{
+		ArrayIndex: {
+			"SERVERS_0_HOST": "a", "SERVERS_0_PORTS_0": "80",
+			"SERVERS_1_HOST": "b", "SERVERS_1_PORTS_0": "443",
+		},
+		// Arrays of maps keep indexed keys; nested scalar arrays are joined
+		ArrayJoin: {
+			"SERVERS_0_HOST": "a", "SERVERS_0_PORTS": "80",
+			"SERVERS_1_HOST": "b", "SERVERS_1_PORTS": "443",
+		},
+	}
+
+	for name, input := range inputs {
+		for mode, want := range wants {
+			t.Run(name+"/"+mode.String(), func(t *testing.T) {
+				got := make(map[string]string)
+				FlattenWith("", input, got, FlattenOptions{Arrays: mode, ArraySep: ","})
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("FlattenWith() = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestFlattenWith_Format(t *testing.T) {
+	input := map[string]interface{}{"a": 1.5, "b": []interface{}{true, "x"}}
+	opts := FlattenOptions{
+		Arrays:   ArrayJoin,
+		ArraySep: ";",
+		Format:   func(v interface{}) string { return "<" + ToString(v) + ">" },
+	}
+
+	got := make(map[string]string)
+	FlattenWith("", input, got, opts)
+	want := map[string]string{"A": "<1.5>", "B": "<true>;<x>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FlattenWith() = %v, want %v", got, want)
+	}
+}
+
+func TestFlattenWith_OmitEmptyContainers(t *testing.T) {
+	input := map[string]interface{}{
+		"labels":  map[string]interface{}{},
+		"tags":    []interface{}{},
+		"name":    "",
+		"nothing": nil,
+		"nested":  map[string]interface{}{"inner": map[interface{}]interface{}{}},
+	}
+
+	tests := []struct {
+		name string
+		opts FlattenOptions
+		want map[string]string
+	}{
+		{
+			name: "empty containers written by default",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ","},
+			want: map[string]string{"LABELS": "", "TAGS": "", "NAME": "", "NOTHING": "", "NESTED_INNER": ""},
+		},
+		{
+			name: "omitted",
+			opts: FlattenOptions{OmitEmptyContainers: true},
+			want: map[string]string{"NAME": "", "NOTHING": ""},
+		},
+		{
+			name: "omitted in join mode",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ",", OmitEmptyContainers: true},
+			want: map[string]string{"NAME": "", "NOTHING": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			FlattenWith("", input, got, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlattenWith() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenWith_CompactArrays(t *testing.T) {
+	input := map[string]interface{}{
+		"interior": []interface{}{"a", nil, "c"},
+		"trailing": []interface{}{"a", "b", nil, nil},
+		"leading":  []interface{}{nil, map[string]interface{}{"host": "x"}},
+		"nulls":    []interface{}{nil, nil},
+		"blanks":   []interface{}{"", nil, "z"},
+	}
+
+	tests := []struct {
+		name string
+		opts FlattenOptions
+		want map[string]string
+	}{
+		{
+			name: "positions kept by default",
+			want: map[string]string{
+				"INTERIOR_0": "a", "INTERIOR_1": "", "INTERIOR_2": "c",
+				"TRAILING_0": "a", "TRAILING_1": "b", "TRAILING_2": "", "TRAILING_3": "",
+				"LEADING_0": "", "LEADING_1_HOST": "x",
+				"NULLS_0": "", "NULLS_1": "",
+				"BLANKS_0": "", "BLANKS_1": "", "BLANKS_2": "z",
+			},
+		},
+		{
+			// Like an empty array, an array of nulls leaves no indexed keys
+			name: "compacted",
+			opts: FlattenOptions{CompactArrays: true},
+			want: map[string]string{
+				"INTERIOR_0": "a", "INTERIOR_1": "c",
+				"TRAILING_0": "a", "TRAILING_1": "b",
+				"LEADING_0_HOST": "x",
+				"BLANKS_0":       "", "BLANKS_1": "z",
+			},
+		},
+		{
+			name: "compacted and omitted",
+			opts: FlattenOptions{CompactArrays: true, OmitEmptyContainers: true},
+			want: map[string]string{
+				"INTERIOR_0": "a", "INTERIOR_1": "c",
+				"TRAILING_0": "a", "TRAILING_1": "b",
+				"LEADING_0_HOST": "x",
+				"BLANKS_0":       "", "BLANKS_1": "z",
+			},
+		},
+		{
+			name: "compacted before joining",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ",", CompactArrays: true},
+			want: map[string]string{
+				"INTERIOR": "a,c", "TRAILING": "a,b",
+				"LEADING_0_HOST": "x",
+				"NULLS":          "", "BLANKS": ",z",
+			},
+		},
+		{
+			name: "joined with nulls",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ","},
+			want: map[string]string{
+				"INTERIOR": "a,,c", "TRAILING": "a,b,,",
+				"LEADING_0": "", "LEADING_1_HOST": "x",
+				"NULLS": ",", "BLANKS": ",,z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			FlattenWith("", input, got, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlattenWith() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenWith_KeyField(t *testing.T) {
+	input := map[string]interface{}{
+		"servers": []interface{}{
+			map[string]interface{}{"name": "db", "host": "x", "port": 5432},
+			map[string]interface{}{"host": "y"},
+			map[interface{}]interface{}{"name": "cache", "host": "z"},
+			map[string]interface{}{"name": "", "host": "empty"},
+			map[string]interface{}{"name": map[string]interface{}{"first": "a"}, "host": "nested"},
+			map[string]interface{}{"name": 7, "host": "numeric"},
+			"scalar",
+		},
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		want  map[string]string
+	}{
+		{
+			name: "indexed by default",
+			want: map[string]string{
+				"SERVERS_0_NAME": "db", "SERVERS_0_HOST": "x", "SERVERS_0_PORT": "5432",
+				"SERVERS_1_HOST": "y",
+				"SERVERS_2_NAME": "cache", "SERVERS_2_HOST": "z",
+				"SERVERS_3_NAME": "", "SERVERS_3_HOST": "empty",
+				"SERVERS_4_NAME_FIRST": "a", "SERVERS_4_HOST": "nested",
+				"SERVERS_5_NAME": "7", "SERVERS_5_HOST": "numeric",
+				"SERVERS_6": "scalar",
+			},
+		},
+		{
+			name:  "keyed by name, falling back to the index",
+			field: "name",
+			want: map[string]string{
+				"SERVERS_DB_HOST": "x", "SERVERS_DB_PORT": "5432",
+				"SERVERS_1_HOST":     "y",
+				"SERVERS_CACHE_HOST": "z",
+				"SERVERS_3_NAME":     "", "SERVERS_3_HOST": "empty",
+				"SERVERS_4_NAME_FIRST": "a", "SERVERS_4_HOST": "nested",
+				"SERVERS_7_HOST": "numeric",
+				"SERVERS_6":      "scalar",
+			},
+		},
+		{
+			name:  "field absent everywhere",
+			field: "id",
+			want: map[string]string{
+				"SERVERS_0_NAME": "db", "SERVERS_0_HOST": "x", "SERVERS_0_PORT": "5432",
+				"SERVERS_1_HOST": "y",
+				"SERVERS_2_NAME": "cache", "SERVERS_2_HOST": "z",
+				"SERVERS_3_NAME": "", "SERVERS_3_HOST": "empty",
+				"SERVERS_4_NAME_FIRST": "a", "SERVERS_4_HOST": "nested",
+				"SERVERS_5_NAME": "7", "SERVERS_5_HOST": "numeric",
+				"SERVERS_6": "scalar",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			FlattenWith("", input, got, FlattenOptions{KeyField: tt.field})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FlattenWith() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The input is not modified
+	if _, ok := input["servers"].([]interface{})[0].(map[string]interface{})["name"]; !ok {
+		t.Error("FlattenWith() removed the key field from the input")
+	}
+}
+
+func TestFlattenWith_KeyFieldCollision(t *testing.T) {
+	input := []interface{}{
+		map[string]interface{}{"name": "db", "host": "x"},
+		map[string]interface{}{"name": "db", "host": "y"},
+	}
+	var collisions []string
+	got := make(map[string]string)
+	FlattenWith("", input, got, FlattenOptions{KeyField: "name", OnCollision: func(key string) {
+		collisions = append(collisions, key)
+	}})
+	if want := map[string]string{"DB_HOST": "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FlattenWith() = %v, want %v", got, want)
+	}
+	if want := []string{"DB_HOST"}; !reflect.DeepEqual(collisions, want) {
+		t.Errorf("collisions = %v, want %v", collisions, want)
+	}
+}
+
+func TestFlattenWith_OnPath(t *testing.T) {
+	input := map[string]interface{}{
+		"database": map[string]interface{}{
+			"host":  "db",
+			"Ports": []interface{}{5432},
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"name": "web", "port": 80},
+		},
+		"empty": map[string]interface{}{},
+	}
+
+	tests := []struct {
+		name string
+		opts FlattenOptions
+		want map[string]string
+	}{
+		{
+			name: "indexed",
+			want: map[string]string{
+				"DATABASE_HOST":    "database.host",
+				"DATABASE_PORTS_0": "database.Ports.0",
+				"SERVERS_0_NAME":   "servers.0.name",
+				"SERVERS_0_PORT":   "servers.0.port",
+				"EMPTY":            "empty",
+			},
+		},
+		{
+			name: "joined and keyed",
+			opts: FlattenOptions{Arrays: ArrayJoin, ArraySep: ",", KeyField: "name"},
+			want: map[string]string{
+				"DATABASE_HOST":    "database.host",
+				"DATABASE_PORTS":   "database.Ports",
+				"SERVERS_WEB_PORT": "servers.web.port",
+				"EMPTY":            "empty",
+			},
+		},
+		{
+			name: "under a path",
+			opts: FlattenOptions{Path: "app"},
+			want: map[string]string{
+				"DATABASE_HOST":    "app.database.host",
+				"DATABASE_PORTS_0": "app.database.Ports.0",
+				"SERVERS_0_NAME":   "app.servers.0.name",
+				"SERVERS_0_PORT":   "app.servers.0.port",
+				"EMPTY":            "app.empty",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := make(map[string]string)
+			opts := tt.opts
+			opts.OnPath = func(key, path string) { paths[key] = path }
+			FlattenWith("", input, make(map[string]string), opts)
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("paths = %v, want %v", paths, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultMaxDepth is the default limit on how deeply maps and arrays may nest.
+// Plugins that flatten nested documents, such as the JSON, YAML and CUE
+// plugins, check it with CheckDepth and fail Parse rather than flatten a
+// deeper document; their SetMaxDepth replaces it, and 0 disables the limit.
+const DefaultMaxDepth = 100
+
+// ErrMaxDepth is wrapped by the error CheckDepth returns for values nested
+// too deeply
+var ErrMaxDepth = errors.New("maximum nesting depth exceeded")
+
+// CheckDepth returns an error wrapping ErrMaxDepth if maps and arrays in v
+// nest more than max levels deep. A scalar has depth 0 and {"a": 1} has
+// depth 1. A max of 0 or less disables the check. Call it before Flatten,
+// which recurses once per level.
+func CheckDepth(v interface{}, max int) error {
+	if max <= 0 {
+		return nil
+	}
+	if path, ok := deeper(v, max, ""); ok {
+		return fmt.Errorf("%w: %s nests more than %d levels", ErrMaxDepth, path, max)
+	}
+	return nil
+}
+
+// deeper reports whether v nests more than budget levels deep, along with
+// the path of the first container past the limit in sorted key order
+func deeper(v interface{}, budget int, path string) (string, bool) {
+	switch v.(type) {
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+	default:
+		return "", false
+	}
+	if budget == 0 {
+		if path == "" {
+			path = "value"
+		}
+		return path, true
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p, ok := deeper(val[k], budget-1, joinPath(path, k)); ok {
+				return p, true
+			}
+		}
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(val))
+		values := make(map[string]interface{}, len(val))
+		for k, c := range val {
+			key := fmt.Sprint(k)
+			keys = append(keys, key)
+			values[key] = c
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p, ok := deeper(values[k], budget-1, joinPath(path, k)); ok {
+				return p, true
+			}
+		}
+	case []interface{}:
+		for i, c := range val {
+			if p, ok := deeper(c, budget-1, fmt.Sprintf("%s[%d]", path, i)); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// joinPath appends key to a dotted path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// nested returns a value with depth levels of maps, alternating with arrays
+// every third level
+func nested(depth int) interface{} {
+	var v interface{} = "leaf"
+	for i := 0; i < depth; i++ {
+		if i%3 == 2 {
+			v = []interface{}{v}
+		} else {
+			v = map[string]interface{}{"a": v}
+		}
+	}
+	return v
+}
+
+func TestCheckDepth(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		max     int
+		wantErr string
+	}{
+		{"scalar", "x", 1, ""},
+		{"at the limit", nested(5), 5, ""},
+		{"past the limit", nested(6), 5, "maximum nesting depth exceeded: [0].a.a[0].a nests more than 5 levels"},
+		{"far past the limit", nested(10000), DefaultMaxDepth, "maximum nesting depth exceeded"},
+		{"empty container counts", map[string]interface{}{"a": map[string]interface{}{}}, 1, "maximum nesting depth exceeded: a nests more than 1 levels"},
+		{"top-level container", []interface{}{}, -1, ""},
+		{"disabled", nested(1000), 0, ""},
+		{"yaml map keys", map[interface{}]interface{}{1: map[interface{}]interface{}{"b": []interface{}{}}}, 2, "maximum nesting depth exceeded: 1.b nests more than 2 levels"},
+		{"zero depth container", map[string]interface{}{}, -5, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckDepth(tt.value, tt.max)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckDepth() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrMaxDepth) {
+				t.Fatalf("CheckDepth() error = %v, want ErrMaxDepth", err)
+			}
+			if !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("CheckDepth() error = %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
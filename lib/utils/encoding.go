@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Encoding is a character encoding that input is decoded from
+type Encoding int
+
+const (
+	// EncodingUTF8 reads input unchanged, unless it starts with a UTF-16
+	// byte order mark (default)
+	EncodingUTF8 Encoding = iota
+
+	// EncodingLatin1 decodes ISO-8859-1, where each byte is the code point
+	// of the same value
+	EncodingLatin1
+
+	// EncodingUTF16 decodes UTF-16 in the byte order given by its byte
+	// order mark, or little-endian without one, as Windows tools write it
+	EncodingUTF16
+
+	// EncodingUTF16LE decodes little-endian UTF-16
+	EncodingUTF16LE
+
+	// EncodingUTF16BE decodes big-endian UTF-16
+	EncodingUTF16BE
+)
+
+// String returns the flag value for the encoding
+func (e Encoding) String() string {
+	switch e {
+	case EncodingLatin1:
+		return "latin1"
+	case EncodingUTF16:
+		return "utf-16"
+	case EncodingUTF16LE:
+		return "utf-16le"
+	case EncodingUTF16BE:
+		return "utf-16be"
+	default:
+		return "utf-8"
+	}
+}
+
+// ParseEncoding converts a flag value into an Encoding
+func ParseEncoding(s string) (Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "utf-8", "utf8":
+		return EncodingUTF8, nil
+	case "latin1", "latin-1", "iso-8859-1":
+		return EncodingLatin1, nil
+	case "utf-16", "utf16":
+		return EncodingUTF16, nil
+	case "utf-16le", "utf16le":
+		return EncodingUTF16LE, nil
+	case "utf-16be", "utf16be":
+		return EncodingUTF16BE, nil
+	default:
+		return EncodingUTF8, fmt.Errorf("unsupported input encoding: %s (valid: utf-8, latin1, utf-16, utf-16le, utf-16be)", s)
+	}
+}
+
+// DecodeInput returns a reader yielding r converted from enc to UTF-8 as it
+// is read. Input with a byte order mark is decoded in the encoding the mark
+// gives whatever enc says, and the mark is dropped. Invalid input, such as
+// an unpaired UTF-16 surrogate, decodes to U+FFFD. Nothing is read from r
+// until the returned reader is.
+func DecodeInput(r io.Reader, enc Encoding) io.Reader {
+	return transform.NewReader(r, decoder(enc))
+}
+
+// decoder returns the transformer converting enc to UTF-8
+func decoder(enc Encoding) transform.Transformer {
+	switch enc {
+	case EncodingLatin1:
+		return unicode.BOMOverride(charmap.ISO8859_1.NewDecoder())
+	case EncodingUTF16, EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
+	default:
+		return unicode.BOMOverride(transform.Nop)
+	}
+}
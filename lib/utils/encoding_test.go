@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+// encodeUTF16 encodes s as UTF-16 in byte order order
+func encodeUTF16(s string, order binary.ByteOrder) string {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(b[2*i:], u)
+	}
+	return string(b)
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Encoding
+		wantErr bool
+	}{
+		{"", EncodingUTF8, false},
+		{"UTF-8", EncodingUTF8, false},
+		{"iso-8859-1", EncodingLatin1, false},
+		{"utf16", EncodingUTF16, false},
+		{" utf-16le ", EncodingUTF16LE, false},
+		{"utf-16be", EncodingUTF16BE, false},
+		{"ebcdic", EncodingUTF8, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseEncoding(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEncoding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEncoding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInput(t *testing.T) {
+	const text = "name: Zoë 🎉\nport: 80\n"
+	le, be := binary.LittleEndian, binary.BigEndian
+
+	tests := []struct {
+		name  string
+		input string
+		enc   Encoding
+		want  string
+	}{
+		{"utf-8 unchanged", text, EncodingUTF8, text},
+		{"utf-8 bom dropped", "\xEF\xBB\xBF" + text, EncodingUTF8, text},
+		{"utf-16le bom detected", "\xFF\xFE" + encodeUTF16(text, le), EncodingUTF8, text},
+		{"utf-16be bom detected", "\xFE\xFF" + encodeUTF16(text, be), EncodingUTF8, text},
+		{"bom overrides encoding", "\xFE\xFF" + encodeUTF16(text, be), EncodingUTF16LE, text},
+		{"utf-16 without bom", encodeUTF16(text, le), EncodingUTF16, text},
+		{"utf-16be without bom", encodeUTF16(text, be), EncodingUTF16BE, text},
+		{"unpaired surrogate", encodeUTF16("a", le) + "\x00\xD8" + encodeUTF16("b", le), EncodingUTF16LE, "a�b"},
+		{"latin1", "name: Zo\xEB \xA310\n", EncodingLatin1, "name: Zoë £10\n"},
+		{"empty", "", EncodingUTF16, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte at a time exercises runes split across reads
+			got, err := io.ReadAll(DecodeInput(iotest.OneByteReader(strings.NewReader(tt.input)), tt.enc))
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("DecodeInput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingReader counts the calls to Read
+type countingReader struct {
+	io.Reader
+	reads int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	r.reads++
+	return r.Reader.Read(p)
+}
+
+func TestDecodeInput_Lazy(t *testing.T) {
+	// Input such as stdin must not be touched until it is read
+	r := &countingReader{Reader: strings.NewReader("\xFF\xFEa\x00")}
+	dec := DecodeInput(r, EncodingUTF8)
+	if r.reads != 0 {
+		t.Fatalf("DecodeInput() read its input %d times before Read", r.reads)
+	}
+	if got, err := io.ReadAll(dec); err != nil || string(got) != "a" {
+		t.Errorf("ReadAll() = %q, %v, want \"a\"", got, err)
+	}
+}
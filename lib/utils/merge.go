@@ -0,0 +1,88 @@
+package utils
+
+import "fmt"
+
+// ArrayMerge controls how two arrays at the same path are merged
+type ArrayMerge int
+
+const (
+	// ArrayReplace replaces the base array with the override array whole
+	// (default), as environment overlays expect
+	ArrayReplace ArrayMerge = iota
+
+	// ArrayConcat appends the elements of the override array to those of
+	// the base array
+	ArrayConcat
+)
+
+// MergeOptions configures how decoded values are merged
+type MergeOptions struct {
+	// Arrays selects whether an override array replaces or extends the
+	// base array
+	Arrays ArrayMerge
+}
+
+// DeepMerge returns override merged over base, as DeepMergeWith does with
+// arrays replaced
+func DeepMerge(base, override map[string]interface{}) map[string]interface{} {
+	return DeepMergeWith(base, override, MergeOptions{})
+}
+
+// DeepMergeWith returns override merged over base using opts. Maps present
+// in both are merged key by key, recursively, and arrays in both are merged
+// as opts.Arrays selects. Otherwise the override value wins, whatever its
+// type: a scalar replaces a map and a map replaces a scalar, and a null
+// replaces any value. Neither argument is modified, though the result
+// shares the subtrees that were not merged.
+func DeepMergeWith(base, override map[string]interface{}, opts MergeOptions) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		if old, ok := merged[k]; ok {
+			v = MergeValues(old, v, opts)
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// MergeValues merges two decoded values of any type, such as whole
+// documents, as DeepMergeWith merges the values of a key. Maps decoded by
+// YAML with interface{} keys are merged as maps with string keys.
+func MergeValues(base, override interface{}, opts MergeOptions) interface{} {
+	if baseMap, ok := stringMap(base); ok {
+		if overrideMap, ok := stringMap(override); ok {
+			return DeepMergeWith(baseMap, overrideMap, opts)
+		}
+		return override
+	}
+
+	if opts.Arrays == ArrayConcat {
+		baseArr, ok := base.([]interface{})
+		overrideArr, ok2 := override.([]interface{})
+		if ok && ok2 {
+			merged := make([]interface{}, 0, len(baseArr)+len(overrideArr))
+			merged = append(merged, baseArr...)
+			return append(merged, overrideArr...)
+		}
+	}
+	return override
+}
+
+// stringMap returns v as a map with string keys, converting the
+// map[interface{}]interface{} some YAML decoders produce
+func stringMap(v interface{}) (map[string]interface{}, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return val, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[fmt.Sprint(k)] = v
+		}
+		return m, true
+	}
+	return nil, false
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+// m is shorthand for a decoded map
+type m = map[string]interface{}
+
+// a is shorthand for a decoded array
+type a = []interface{}
+
+func TestDeepMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     m
+		override m
+		want     m
+	}{
+		{
+			name:     "new keys are added",
+			base:     m{"a": 1},
+			override: m{"b": 2},
+			want:     m{"a": 1, "b": 2},
+		},
+		{
+			name:     "scalars are overridden",
+			base:     m{"a": 1, "b": "x"},
+			override: m{"a": 2},
+			want:     m{"a": 2, "b": "x"},
+		},
+		{
+			name:     "nested maps merge recursively",
+			base:     m{"db": m{"host": "localhost", "port": 5432, "opts": m{"ssl": false, "timeout": 5}}, "name": "app"},
+			override: m{"db": m{"host": "prod", "opts": m{"ssl": true}}},
+			want:     m{"db": m{"host": "prod", "port": 5432, "opts": m{"ssl": true, "timeout": 5}}, "name": "app"},
+		},
+		{
+			name:     "arrays are replaced",
+			base:     m{"hosts": a{"a", "b", "c"}},
+			override: m{"hosts": a{"x"}},
+			want:     m{"hosts": a{"x"}},
+		},
+		{
+			name:     "map over scalar",
+			base:     m{"db": "postgres://localhost"},
+			override: m{"db": m{"host": "prod"}},
+			want:     m{"db": m{"host": "prod"}},
+		},
+		{
+			name:     "scalar over map",
+			base:     m{"db": m{"host": "localhost"}},
+			override: m{"db": "postgres://prod"},
+			want:     m{"db": "postgres://prod"},
+		},
+		{
+			name:     "array over map",
+			base:     m{"hosts": m{"primary": "a"}},
+			override: m{"hosts": a{"x"}},
+			want:     m{"hosts": a{"x"}},
+		},
+		{
+			name:     "null replaces a value",
+			base:     m{"debug": true, "db": m{"host": "localhost"}},
+			override: m{"debug": nil, "db": nil},
+			want:     m{"debug": nil, "db": nil},
+		},
+		{
+			name:     "yaml maps with interface keys",
+			base:     m{"db": map[interface{}]interface{}{"host": "localhost", "port": 5432}},
+			override: m{"db": map[interface{}]interface{}{"host": "prod"}},
+			want:     m{"db": m{"host": "prod", "port": 5432}},
+		},
+		{
+			name:     "nil base",
+			override: m{"a": 1},
+			want:     m{"a": 1},
+		},
+		{
+			name: "nil override",
+			base: m{"a": 1},
+			want: m{"a": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeepMerge(tt.base, tt.override); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeepMerge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeepMergeWith_Concat(t *testing.T) {
+	opts := MergeOptions{Arrays: ArrayConcat}
+	tests := []struct {
+		name     string
+		base     m
+		override m
+		want     m
+	}{
+		{
+			name:     "arrays are appended",
+			base:     m{"hosts": a{"a", "b"}},
+			override: m{"hosts": a{"c"}},
+			want:     m{"hosts": a{"a", "b", "c"}},
+		},
+		{
+			name:     "nested arrays are appended",
+			base:     m{"app": m{"plugins": a{m{"name": "auth"}}}},
+			override: m{"app": m{"plugins": a{m{"name": "cache"}}}},
+			want:     m{"app": m{"plugins": a{m{"name": "auth"}, m{"name": "cache"}}}},
+		},
+		{
+			name:     "scalar over array",
+			base:     m{"hosts": a{"a"}},
+			override: m{"hosts": "x"},
+			want:     m{"hosts": "x"},
+		},
+		{
+			name:     "array over scalar",
+			base:     m{"hosts": "a"},
+			override: m{"hosts": a{"x"}},
+			want:     m{"hosts": a{"x"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeepMergeWith(tt.base, tt.override, opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeepMergeWith() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeepMerge_LeavesInputs(t *testing.T) {
+	inner := m{"host": "localhost"}
+	hosts := make(a, 1, 4)
+	hosts[0] = "a"
+	base := m{"db": inner, "hosts": hosts}
+	override := m{"db": m{"host": "prod"}, "hosts": a{"b"}}
+
+	DeepMergeWith(base, override, MergeOptions{Arrays: ArrayConcat})
+	if inner["host"] != "localhost" {
+		t.Errorf("DeepMergeWith() modified a base map: %v", base)
+	}
+	// Appending must not write into the spare capacity of the base array
+	if extended := hosts[:2]; extended[1] != nil {
+		t.Errorf("DeepMergeWith() modified a base array: %v", extended)
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     interface{}
+		override interface{}
+		want     interface{}
+	}{
+		{"documents", m{"a": 1}, m{"b": 2}, m{"a": 1, "b": 2}},
+		{"no base", nil, m{"a": 1}, m{"a": 1}},
+		{"scalar documents", "x", "y", "y"},
+		{"root arrays", a{1}, a{2}, a{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeValues(tt.base, tt.override, MergeOptions{}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
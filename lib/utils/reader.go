@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// bom is the UTF-8 byte order mark
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// maxLineLength is the longest line ReadLines accepts
+const maxLineLength = 1024 * 1024
+
+// StripBOM returns a reader that skips a leading UTF-8 byte order mark in r
+func StripBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(len(bom)); err == nil && bytes.Equal(head, bom) {
+		br.Discard(len(bom))
+	}
+	return br
+}
+
+// ReadLines reads r and returns its lines without line terminators. Both
+// "\n" and Windows "\r\n" endings are accepted, so values never carry a
+// trailing carriage return. Line-based plugins should split input with it.
+func ReadLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+// ErrInputTooLarge is returned by readers from LimitInput once the input is
+// longer than the limit
+var ErrInputTooLarge = errors.New("input too large")
+
+// LimitedReader yields at most a fixed number of bytes from its reader
+type LimitedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+	exceeded  bool
+}
+
+// LimitInput returns a reader that yields at most n bytes of r and fails
+// with an error wrapping ErrInputTooLarge if r holds more. Unlike
+// io.LimitReader, input longer than the limit is never silently truncated.
+// A limit of zero or less reads r unchanged.
+func LimitInput(r io.Reader, n int64) *LimitedReader {
+	return &LimitedReader{r: r, limit: n, remaining: n}
+}
+
+// Read implements io.Reader
+func (l *LimitedReader) Read(p []byte) (int, error) {
+	if l.limit <= 0 {
+		return l.r.Read(p)
+	}
+	if l.exceeded {
+		return 0, l.Err()
+	}
+
+	// Read one byte past the limit to tell a full input from a longer one
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = 0
+		l.exceeded = true
+		return n, l.Err()
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// Err returns an error wrapping ErrInputTooLarge if the input was found to
+// be longer than the limit, and nil otherwise
+func (l *LimitedReader) Err() error {
+	if !l.exceeded {
+		return nil
+	}
+	return fmt.Errorf("%w: exceeds the limit of %d bytes", ErrInputTooLarge, l.limit)
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStripBOM(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"with bom", "\xEF\xBB\xBF{\"key\": \"value\"}", "{\"key\": \"value\"}"},
+		{"without bom", "{\"key\": \"value\"}", "{\"key\": \"value\"}"},
+		{"bom only", "\xEF\xBB\xBF", ""},
+		{"empty", "", ""},
+		{"short input", "a", "a"},
+		{"bom in middle kept", "a\xEF\xBB\xBFb", "a\xEF\xBB\xBFb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(StripBOM(strings.NewReader(tt.input)))
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("StripBOM() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"lf", "a=1\nb=2\n", []string{"a=1", "b=2"}},
+		{"crlf", "a=1\r\nb=2\r\n", []string{"a=1", "b=2"}},
+		{"crlf without final newline", "a=1\r\nb=2\r", []string{"a=1", "b=2"}},
+		{"mixed endings", "a=1\r\nb=2\nc=3", []string{"a=1", "b=2", "c=3"}},
+		{"blank lines kept", "a=1\r\n\r\nb=2", []string{"a=1", "", "b=2"}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadLines(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ReadLines() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadLines() = %q, want %q", got, tt.want)
+			}
+			for _, line := range got {
+				if strings.Contains(line, "\r") {
+					t.Errorf("ReadLines() line %q contains a carriage return", line)
+				}
+			}
+		})
+	}
+}
+
+func TestLimitInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		limit    int64
+		want     string
+		exceeded bool
+	}{
+		{"under limit", "abc", 5, "abc", false},
+		{"exactly at limit", "abcde", 5, "abcde", false},
+		{"one byte over", "abcdef", 5, "abcde", true},
+		{"far over", strings.Repeat("x", 10000), 100, strings.Repeat("x", 100), true},
+		{"empty input", "", 5, "", false},
+		{"unlimited", strings.Repeat("x", 10000), 0, strings.Repeat("x", 10000), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := LimitInput(strings.NewReader(tt.input), tt.limit)
+			got, err := io.ReadAll(r)
+			if tt.exceeded {
+				if !errors.Is(err, ErrInputTooLarge) {
+					t.Fatalf("ReadAll() error = %v, want ErrInputTooLarge", err)
+				}
+				if !errors.Is(r.Err(), ErrInputTooLarge) {
+					t.Errorf("Err() = %v, want ErrInputTooLarge", r.Err())
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("ReadAll() error = %v", err)
+				}
+				if r.Err() != nil {
+					t.Errorf("Err() = %v, want nil", r.Err())
+				}
+			}
+			if string(got) != tt.want {
+				t.Errorf("ReadAll() read %d bytes, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestLimitInput_SmallReads(t *testing.T) {
+	// iotest.OneByteReader forces a read per byte around the limit
+	r := LimitInput(iotest.OneByteReader(strings.NewReader("abcdef")), 3)
+	got, err := io.ReadAll(r)
+	if !errors.Is(err, ErrInputTooLarge) {
+		t.Fatalf("ReadAll() error = %v, want ErrInputTooLarge", err)
+	}
+	if string(got) != "abc" {
+		t.Errorf("ReadAll() = %q, want %q", got, "abc")
+	}
+}
@@ -0,0 +1,10 @@
+package version
+
+// version is set at build time with
+// -ldflags "-X github.com/handaber/cfg2env/lib/version.version=v1.2.3"
+var version = "dev"
+
+// Version returns the cfg2env version, or "dev" for builds without one
+func Version() string {
+	return version
+}
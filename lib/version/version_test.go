@@ -0,0 +1,16 @@
+package version
+
+import "testing"
+
+func TestVersion(t *testing.T) {
+	if got := Version(); got != "dev" {
+		t.Errorf("Version() = %q, want %q", got, "dev")
+	}
+
+	old := version
+	defer func() { version = old }()
+	version = "v1.2.3"
+	if got := Version(); got != "v1.2.3" {
+		t.Errorf("Version() = %q, want %q", got, "v1.2.3")
+	}
+}
@@ -0,0 +1,59 @@
+package plugin
+
+// Capabilities describes the optional features a plugin supports, so that
+// callers can tell which options apply to it before parsing
+type Capabilities struct {
+	// Query is set for plugins reading the results of a custom query,
+	// set with SetQuery
+	Query bool
+
+	// Table is set for plugins that read a named table, set with SetTable
+	Table bool
+
+	// Ordered is set for plugins implementing OrderedPlugin
+	Ordered bool
+
+	// Comments is set for plugins whose ordered output carries source
+	// comments
+	Comments bool
+
+	// Paths is set for plugins that can skip or select subtrees by dotted
+	// path before flattening
+	Paths bool
+
+	// Arrays is set for plugins whose array flattening can be configured
+	Arrays bool
+
+	// Cancel is set for plugins implementing ContextPlugin
+	Cancel bool
+
+	// Concat is set for plugins that can read several concatenated
+	// documents from one input, set with SetConcat
+	Concat bool
+
+	// PathPrefix is set for plugins reading slash-separated names whose
+	// leading path can be stripped, set with SetPathPrefix
+	PathPrefix bool
+}
+
+// Capable is implemented by plugins that report their Capabilities
+type Capable interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns the capabilities of p. Plugins not implementing
+// Capable are reported from the optional interfaces they implement.
+func CapabilitiesOf(p Plugin) Capabilities {
+	if c, ok := p.(Capable); ok {
+		return c.Capabilities()
+	}
+
+	var caps Capabilities
+	_, caps.Query = p.(interface{ SetQuery(string) })
+	_, caps.Table = p.(interface{ SetTable(string) })
+	_, caps.Ordered = p.(OrderedPlugin)
+	_, caps.Cancel = p.(ContextPlugin)
+	_, caps.Concat = p.(interface{ SetConcat(bool) })
+	_, caps.PathPrefix = p.(interface{ SetPathPrefix(string) })
+	return caps
+}
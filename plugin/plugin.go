@@ -1,6 +1,11 @@
 package plugin
 
-import "io"
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+)
 
 // Plugin defines the interface for configuration format plugins
 type Plugin interface {
@@ -23,11 +28,23 @@ type BasePlugin struct {
 	extensions []string
 }
 
-// NewBasePlugin creates a new BasePlugin with the given name and extensions
+// NewBasePlugin creates a new BasePlugin with the given name and extensions.
+// Extensions are lowercased, and duplicates or repeats of the name are
+// dropped, since the plugin is already registered under its name.
 func NewBasePlugin(name string, extensions ...string) BasePlugin {
+	seen := map[string]bool{name: true}
+	exts := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		if ext == "" || seen[ext] {
+			continue
+		}
+		seen[ext] = true
+		exts = append(exts, ext)
+	}
 	return BasePlugin{
 		name:       name,
-		extensions: extensions,
+		extensions: exts,
 	}
 }
 
@@ -53,3 +70,142 @@ func (p BasePlugin) CanHandle(format string) bool {
 	}
 	return false
 }
+
+// Warner is implemented by plugins that report non-fatal problems found
+// during the most recent Parse, such as keys produced by more than one path
+type Warner interface {
+	Warnings() []string
+}
+
+// PathReporter is implemented by plugins that flatten nested documents and
+// can report the source path of each key from the most recent Parse
+type PathReporter interface {
+	// Paths maps each flattened key to the dotted path it was flattened
+	// from, as written in the source, such as database.host for
+	// DATABASE_HOST
+	Paths() map[string]string
+}
+
+// CollisionReporter is implemented by plugins that flatten nested documents
+// and can report every value flattened to a key produced by more than one
+// path, so that callers can resolve the collision rather than take the
+// value Parse kept
+type CollisionReporter interface {
+	// Collisions maps each key produced by more than one path during the
+	// most recent Parse to the pairs flattened to it, with their paths, in
+	// flatten order. The last pair holds the value Parse returned.
+	Collisions() map[string][]KV
+}
+
+// Resetter is implemented by plugins that keep state from the most recent
+// Parse, such as warnings, so that one plugin can be reused across inputs
+type Resetter interface {
+	// Reset discards state from the most recent Parse. Options set on the
+	// plugin are kept.
+	Reset()
+}
+
+// ContextPlugin is implemented by plugins whose parsing can be cancelled,
+// such as plugins that run database queries
+type ContextPlugin interface {
+	Plugin
+
+	// ParseContext is like Parse but stops early with ctx.Err() once ctx is done
+	ParseContext(ctx context.Context, r io.Reader) (map[string]string, error)
+}
+
+// Validator is implemented by plugins that can check that input is well
+// formed more cheaply than a full Parse
+type Validator interface {
+	// Validate reports the first syntax error in r, if any
+	Validate(r io.Reader) error
+}
+
+// KV is a single flattened key-value pair
+type KV struct {
+	Key   string
+	Value string
+
+	// Comment holds any source comment attached to the value, one line per
+	// comment line and without comment markers
+	Comment string
+
+	// Path is the dotted source path of the key, if known, as reported
+	// by plugins implementing PathReporter
+	Path string
+}
+
+// Source returns the key as written in the source: its Path when known,
+// and otherwise its Key
+func (kv KV) Source() string {
+	if kv.Path != "" {
+		return kv.Path
+	}
+	return kv.Key
+}
+
+// OrderedPlugin is implemented by plugins that can preserve the order of keys
+// as they appear in the source
+type OrderedPlugin interface {
+	Plugin
+
+	// ParseOrdered reads configuration data and returns flattened key-value
+	// pairs in source order
+	ParseOrdered(r io.Reader) ([]KV, error)
+}
+
+// StreamPlugin is implemented by plugins that can hand over pairs while
+// reading, rather than after the whole input is decoded, so that large
+// inputs need not be held in memory at once
+type StreamPlugin interface {
+	Plugin
+
+	// ParseStream reads configuration data and calls emit with each
+	// flattened pair in source order, stopping with the first error emit
+	// returns. A key may be emitted more than once; the last value wins and
+	// the key keeps its first position, as in ParseOrdered.
+	ParseStream(r io.Reader, emit func(KV) error) error
+}
+
+// TreePlugin is implemented by plugins that decode input into a tree of
+// maps, arrays and scalars before flattening it, so that several documents
+// can be merged as trees rather than as flattened keys
+type TreePlugin interface {
+	Plugin
+
+	// DecodeTree reads configuration data and returns the decoded
+	// document, or nil for empty input
+	DecodeTree(r io.Reader) (interface{}, error)
+
+	// FlattenTree flattens a document returned by DecodeTree, or a merge
+	// of several, as Parse would flatten the input it came from
+	FlattenTree(data interface{}) (map[string]string, error)
+}
+
+// OrderPairs returns the entries of env as pairs following order. Keys in
+// order that are missing from env or repeated are skipped, and any keys of
+// env not listed in order are appended in sorted order.
+func OrderPairs(env map[string]string, order []string) []KV {
+	pairs := make([]KV, 0, len(env))
+	seen := make(map[string]bool, len(env))
+	for _, k := range order {
+		v, ok := env[k]
+		if !ok || seen[k] {
+			continue
+		}
+		seen[k] = true
+		pairs = append(pairs, KV{Key: k, Value: v})
+	}
+
+	var rest []string
+	for k := range env {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		pairs = append(pairs, KV{Key: k, Value: env[k]})
+	}
+	return pairs
+}
@@ -0,0 +1,77 @@
+package plugins
+
+import (
+	"io"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/dotenv"
+	"github.com/handaber/cfg2env/plugins/environ"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/k8s"
+	"github.com/handaber/cfg2env/plugins/systemd"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+// checkCapabilities compares the capabilities p reports with want and with
+// the optional interfaces p implements
+func checkCapabilities(t *testing.T, p plugin.Plugin, want plugin.Capabilities) {
+	t.Helper()
+	got := plugin.CapabilitiesOf(p)
+	if got != want {
+		t.Errorf("CapabilitiesOf(%s) = %+v, want %+v", p.Name(), got, want)
+	}
+
+	_, query := p.(interface{ SetQuery(string) })
+	_, table := p.(interface{ SetTable(string) })
+	_, ordered := p.(plugin.OrderedPlugin)
+	_, paths := p.(interface{ SetSkipPaths([]string) })
+	_, cancel := p.(plugin.ContextPlugin)
+	_, concat := p.(interface{ SetConcat(bool) })
+	if got.Query != query || got.Table != table || got.Ordered != ordered || got.Paths != paths || got.Cancel != cancel || got.Concat != concat {
+		t.Errorf("CapabilitiesOf(%s) = %+v, which does not match the interfaces it implements", p.Name(), got)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		plugin plugin.Plugin
+		want   plugin.Capabilities
+	}{
+		{yaml.New(), plugin.Capabilities{Ordered: true, Comments: true, Paths: true, Arrays: true}},
+		{json.New(), plugin.Capabilities{Ordered: true, Paths: true, Arrays: true, Concat: true}},
+		{dotenv.New(), plugin.Capabilities{Ordered: true}},
+		{environ.New(), plugin.Capabilities{}},
+		{systemd.New(), plugin.Capabilities{Ordered: true}},
+		{k8s.New(), plugin.Capabilities{Ordered: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.plugin.Name(), func(t *testing.T) {
+			checkCapabilities(t, tt.plugin, tt.want)
+		})
+	}
+}
+
+// queryPlugin supports SetQuery without implementing plugin.Capable
+type queryPlugin struct {
+	mockPlugin
+}
+
+func (p queryPlugin) SetQuery(string) {}
+
+func (p queryPlugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	return nil, nil
+}
+
+func TestCapabilitiesOf_Derived(t *testing.T) {
+	got := plugin.CapabilitiesOf(queryPlugin{mockPlugin{plugin.NewBasePlugin("query")}})
+	want := plugin.Capabilities{Query: true, Ordered: true}
+	if got != want {
+		t.Errorf("CapabilitiesOf() = %+v, want %+v", got, want)
+	}
+
+	if got := plugin.CapabilitiesOf(mockPlugin{plugin.NewBasePlugin("mock")}); got != (plugin.Capabilities{}) {
+		t.Errorf("CapabilitiesOf(mock) = %+v, want none", got)
+	}
+}
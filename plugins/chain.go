@@ -0,0 +1,50 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// Chain picks the first of formats whose plugin parses r without error and
+// returns it with a reader that yields all of r. The input is buffered so
+// each plugin reads it from the start. Formats are tried in order, so put
+// the strictest first: YAML is a superset of JSON and also accepts most
+// plain text, so a chain of "yaml,json" never reaches json. If configure is
+// not nil, it is called with each plugin before the plugin is tried, so
+// options such as relaxed JSON affect which one parses.
+func Chain(formats []string, r io.Reader, configure func(plugin.Plugin) error) (plugin.Plugin, io.Reader, error) {
+	if len(formats) == 0 {
+		return nil, nil, fmt.Errorf("empty format chain")
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var failures []string
+	for _, format := range formats {
+		p, err := Get(format)
+		if err != nil {
+			return nil, nil, err
+		}
+		if configure != nil {
+			if err := configure(p); err != nil {
+				return nil, nil, err
+			}
+		}
+		if _, err := p.Parse(bytes.NewReader(data)); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", format, err))
+			continue
+		}
+		if rs, ok := p.(plugin.Resetter); ok {
+			rs.Reset()
+		}
+		return p, bytes.NewReader(data), nil
+	}
+	return nil, nil, fmt.Errorf("no format in chain parsed the input (%s)", strings.Join(failures, "; "))
+}
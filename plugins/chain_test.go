@@ -0,0 +1,88 @@
+package plugins
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestChain(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	tests := []struct {
+		name    string
+		formats []string
+		input   string
+		want    string
+		wantErr string
+	}{
+		// YAML parses JSON too, so yaml wins when it comes first
+		{"json input, yaml first", []string{"yaml", "json"}, `{"database": {"host": "localhost"}}`, "yaml", ""},
+		{"json input, json first", []string{"json", "yaml"}, `{"database": {"host": "localhost"}}`, "json", ""},
+		{"invalid for first, valid for second", []string{"json", "yaml"}, "database:\n  host: localhost\n", "yaml", ""},
+		{"case-insensitive formats", []string{"JSON", "Yaml"}, "database:\n  host: localhost\n", "yaml", ""},
+		{"invalid for all", []string{"json", "yaml"}, "database: [unclosed\n", "", "no format in chain parsed the input (json: "},
+		{"unknown format", []string{"toml", "yaml"}, "a: 1\n", "", "unsupported format: toml"},
+		{"empty chain", nil, "a: 1\n", "", "empty format chain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, r, err := Chain(tt.formats, strings.NewReader(tt.input), nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Chain() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Chain() error = %v", err)
+			}
+			if p.Name() != tt.want {
+				t.Errorf("Chain() = %v, want %v", p.Name(), tt.want)
+			}
+
+			// The returned reader replays the whole input
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != tt.input {
+				t.Errorf("reader = %q, want %q", got, tt.input)
+			}
+
+			env, err := p.Parse(strings.NewReader(tt.input))
+			if err != nil || env["DATABASE_HOST"] != "localhost" {
+				t.Errorf("Parse() = %v, %v; want DATABASE_HOST=localhost", env, err)
+			}
+		})
+	}
+}
+
+func TestChain_Configure(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	// Relaxed JSON is only accepted once the plugin is configured for it
+	input := `{"a": 1,}`
+	configure := func(p plugin.Plugin) error {
+		if j, ok := p.(*json.Plugin); ok {
+			j.SetRelaxed(true)
+		}
+		return nil
+	}
+	p, _, err := Chain([]string{"json"}, strings.NewReader(input), configure)
+	if err != nil || p.Name() != "json" {
+		t.Fatalf("Chain() = %v, %v; want json", p, err)
+	}
+	if _, _, err := Chain([]string{"json"}, strings.NewReader(input), nil); err == nil {
+		t.Error("Chain() without configure succeeded, want a parse error")
+	}
+}
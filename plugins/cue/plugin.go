@@ -0,0 +1,131 @@
+//go:build cue
+
+// Package cue provides a plugin for CUE configuration files. It depends on
+// cuelang.org/go and is only built with the "cue" build tag:
+//
+//	go build -tags cue
+package cue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// Plugin implements the plugin.Plugin interface for CUE format
+type Plugin struct {
+	plugin.BasePlugin
+	flatten       utils.FlattenOptions
+	skipPaths     []string
+	onlyPath      string
+	stripOnlyPath bool
+	maxDepth      int
+}
+
+// New creates a new CUE plugin
+func New() *Plugin {
+	return &Plugin{
+		BasePlugin: plugin.NewBasePlugin("cue", "cue"),
+		flatten:    utils.FlattenOptions{ArraySep: utils.DefaultArraySep},
+		maxDepth:   utils.DefaultMaxDepth,
+	}
+}
+
+// SetArrayMode sets how arrays are flattened and the separator for joined arrays
+func (p *Plugin) SetArrayMode(mode utils.ArrayMode, sep string) {
+	p.flatten.Arrays = mode
+	p.flatten.ArraySep = sep
+}
+
+// SetArrayKeyField names a field of the maps in arrays whose value replaces
+// the element's index in keys, such as "name" to flatten
+// [{name: db, host: x}] to DB_HOST. Elements without it keep their index.
+// Empty disables it.
+func (p *Plugin) SetArrayKeyField(field string) {
+	p.flatten.KeyField = field
+}
+
+// SetOmitEmptyContainers controls whether empty maps and arrays are dropped
+// instead of being written as a key with an empty value
+func (p *Plugin) SetOmitEmptyContainers(omit bool) {
+	p.flatten.OmitEmptyContainers = omit
+}
+
+// SetSkipPaths sets dot-separated paths, such as "logging" or
+// "app.metadata", whose subtrees are dropped before flattening
+func (p *Plugin) SetSkipPaths(paths []string) {
+	p.skipPaths = paths
+}
+
+// SetOnlyPath limits flattening to the subtree at the dot-separated path,
+// such as "database". With strip, the path is dropped from the keys.
+func (p *Plugin) SetOnlyPath(path string, strip bool) {
+	p.onlyPath = path
+	p.stripOnlyPath = strip
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Paths: true, Arrays: true}
+}
+
+// SetMaxDepth replaces utils.DefaultMaxDepth as the nesting limit
+func (p *Plugin) SetMaxDepth(depth int) {
+	p.maxDepth = depth
+}
+
+// Parse implements plugin.Plugin. The CUE source is evaluated and must be
+// fully concrete; incomplete values are reported as errors rather than
+// emitting partial output.
+func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	// Handle empty input
+	if r == nil {
+		return make(map[string]string), nil
+	}
+
+	src, err := io.ReadAll(utils.StripBOM(r))
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(src)) == 0 {
+		return make(map[string]string), nil
+	}
+
+	v := cuecontext.New().CompileBytes(src)
+	if err := v.Err(); err != nil {
+		return nil, fmt.Errorf("evaluating cue: %w", err)
+	}
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, fmt.Errorf("cue value is not concrete: %w", err)
+	}
+
+	// Render through JSON so values flatten exactly like the JSON plugin's input
+	out, err := v.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("rendering cue: %w", err)
+	}
+	var data interface{}
+	if err := json.Unmarshal(out, &data); err != nil {
+		return nil, err
+	}
+
+	if err := utils.CheckDepth(data, p.maxDepth); err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	sel := utils.Selection{Value: utils.PrunePaths(data, p.skipPaths)}
+	if p.onlyPath != "" {
+		sel = utils.SelectPath(sel.Value, p.onlyPath, p.stripOnlyPath)
+	}
+	if sel.Value != nil {
+		utils.FlattenWith(sel.Prefix, sel.Value, env, p.flatten)
+	}
+	return env, nil
+}
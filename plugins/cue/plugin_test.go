@@ -0,0 +1,78 @@
+//go:build cue
+
+package cue
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlugin_Parse(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "config.cue"))
+	if err != nil {
+		t.Fatalf("Failed to open test file: %v", err)
+	}
+	defer f.Close()
+
+	got, err := New().Parse(f)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]string{
+		"DATABASE_HOST":                 "localhost",
+		"DATABASE_PORT":                 "5432",
+		"DATABASE_CREDENTIALS_USERNAME": "admin",
+		"DATABASE_CREDENTIALS_PASSWORD": "secret with spaces",
+		"API_URL":                       "https://localhost:8080",
+		"API_TIMEOUT":                   "30",
+		"API_FEATURES_0":                "logging",
+		"API_FEATURES_1":                "metrics",
+		"API_FEATURES_2":                "tracing",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_Parse_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "incomplete value",
+			input: "database: host: string\n",
+		},
+		{
+			name:  "conflicting values",
+			input: "port: 5432\nport: 8080\n",
+		},
+		{
+			name:  "syntax error",
+			input: "database: {\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New().Parse(strings.NewReader(tt.input))
+			if err == nil {
+				t.Errorf("Parse() = %v, want error", got)
+			}
+		})
+	}
+}
+
+func TestPlugin_Parse_Empty(t *testing.T) {
+	got, err := New().Parse(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Parse() = %v, want empty map", got)
+	}
+}
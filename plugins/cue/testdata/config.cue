@@ -0,0 +1,16 @@
+#Port: int & >0 & <65536
+
+database: {
+	host: "localhost"
+	port: #Port & 5432
+	credentials: {
+		username: "admin"
+		password: "secret with spaces"
+	}
+}
+
+api: {
+	url:     "https://\(database.host):8080"
+	timeout: 30
+	features: ["logging", "metrics", "tracing"]
+}
@@ -0,0 +1,82 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// sqliteHeader starts every SQLite database file
+const sqliteHeader = "SQLite format 3\x00"
+
+// sniffLen is the number of bytes Detect inspects
+const sniffLen = 512
+
+// ErrUnknownFormat is returned by DetectStrict and ForFile in strict mode
+// when the format cannot be told and the default plugin would be used
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Detect picks a plugin for r from its content and returns it with a reader
+// that still yields all of r. SQLite databases are recognized by their file
+// header and input starting with '{' or '[' is treated as JSON. Anything
+// else uses the default plugin.
+func Detect(r io.Reader) (plugin.Plugin, io.Reader, error) {
+	return detect(r, false)
+}
+
+// DetectStrict is like Detect but returns ErrUnknownFormat instead of
+// falling back to the default plugin when the content is not recognized
+func DetectStrict(r io.Reader) (plugin.Plugin, io.Reader, error) {
+	return detect(r, true)
+}
+
+// detect implements Detect and DetectStrict
+func detect(r io.Reader, strict bool) (plugin.Plugin, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	format := sniff(head)
+	if format == "" && strict {
+		return nil, nil, fmt.Errorf("%w: input is neither JSON nor SQLite", ErrUnknownFormat)
+	}
+	p, err := Get(format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, br, nil
+}
+
+// ForFile returns the plugin for path from its extension. A path without an
+// extension uses the default plugin, or returns ErrUnknownFormat if strict
+// is set. Unregistered extensions are an error either way.
+func ForFile(path string, strict bool) (plugin.Plugin, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "" && strict {
+		return nil, fmt.Errorf("%w: no file extension", ErrUnknownFormat)
+	}
+	return Get(ext)
+}
+
+// sniff returns the format of content starting with head, or "" if it is
+// not recognized
+func sniff(head []byte) string {
+	if bytes.HasPrefix(head, []byte(sqliteHeader)) {
+		return "sqlite"
+	}
+
+	head = bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF"))
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) > 0 && (head[0] == '{' || head[0] == '[') {
+		return "json"
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package plugins
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestDetect(t *testing.T) {
+	// Reset registry to ensure clean state
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"json object", `{"database": {"host": "localhost"}}`, "json"},
+		{"json array after whitespace and BOM", "\xEF\xBB\xBF\n  [1, 2]", "json"},
+		{"yaml", "database:\n  host: localhost\n", "yaml"},
+		{"empty input", "", "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, r, err := Detect(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if p.Name() != tt.want {
+				t.Errorf("Detect() = %v, want %v", p.Name(), tt.want)
+			}
+
+			// The returned reader replays the sniffed bytes
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != tt.input {
+				t.Errorf("Detect() reader lost data: got %d bytes, want %d", len(got), len(tt.input))
+			}
+		})
+	}
+}
+
+func TestDetectStrict(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	p, r, err := DetectStrict(strings.NewReader(`{"a": 1}`))
+	if err != nil {
+		t.Fatalf("DetectStrict() error = %v", err)
+	}
+	if p.Name() != "json" {
+		t.Errorf("DetectStrict() = %v, want json", p.Name())
+	}
+	if got, _ := io.ReadAll(r); string(got) != `{"a": 1}` {
+		t.Errorf("DetectStrict() reader = %q", got)
+	}
+
+	for _, input := range []string{"a: 1\n", ""} {
+		if _, _, err := DetectStrict(strings.NewReader(input)); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("DetectStrict(%q) error = %v, want ErrUnknownFormat", input, err)
+		}
+	}
+}
+
+func TestForFile(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	tests := []struct {
+		name    string
+		path    string
+		strict  bool
+		want    string
+		wantErr error
+	}{
+		{"known extension", "config/app.json", false, "json", nil},
+		{"known extension, strict", "config/app.JSON", true, "json", nil},
+		{"no extension falls back", "config/app", false, "yaml", nil},
+		{"no extension, strict", "config/app", true, "", ErrUnknownFormat},
+		{"unknown extension", "app.conf", false, "", nil},
+		{"unknown extension, strict", "app.conf", true, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ForFile(tt.path, tt.strict)
+			if tt.want == "" {
+				// Unregistered extensions fail in both modes
+				if err == nil {
+					t.Fatalf("ForFile() = %v, want an error", p.Name())
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("ForFile() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ForFile() error = %v", err)
+			}
+			if p.Name() != tt.want {
+				t.Errorf("ForFile() = %v, want %v", p.Name(), tt.want)
+			}
+		})
+	}
+}
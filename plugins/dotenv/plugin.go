@@ -0,0 +1,152 @@
+package dotenv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// Plugin implements the plugin.Plugin interface for .env files. Values may
+// reference keys defined on earlier lines with $KEY or ${KEY}; references to
+// keys not yet defined fall back to the process environment, then to empty.
+type Plugin struct {
+	plugin.BasePlugin
+}
+
+// New creates a new dotenv plugin
+func New() *Plugin {
+	return &Plugin{
+		BasePlugin: plugin.NewBasePlugin("dotenv", "env"),
+	}
+}
+
+// Parse implements plugin.Plugin
+func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	env, _, err := parse(r)
+	return env, err
+}
+
+// ParseOrdered implements plugin.OrderedPlugin
+func (p *Plugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	env, order, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return plugin.OrderPairs(env, order), nil
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Ordered: true}
+}
+
+// parse reads KEY=value lines from r, expanding references as it goes, and
+// returns the pairs along with the keys in the order they were defined
+func parse(r io.Reader) (map[string]string, []string, error) {
+	env := make(map[string]string)
+	if r == nil {
+		return env, nil, nil
+	}
+
+	lines, err := utils.ReadLines(utils.StripBOM(r))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var order []string
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, nil, fmt.Errorf("line %d: expected KEY=value", i+1)
+		}
+
+		if _, seen := env[key]; !seen {
+			order = append(order, key)
+		}
+		env[key] = parseValue(strings.TrimSpace(raw), env)
+	}
+	return env, order, nil
+}
+
+// parseValue unquotes raw and expands references to keys in env. Single
+// quoted values are taken literally. Anything after the closing quote, or
+// after " #" in unquoted values, is a comment.
+func parseValue(raw string, env map[string]string) string {
+	if len(raw) > 0 && (raw[0] == '\'' || raw[0] == '"') {
+		if end := strings.IndexByte(raw[1:], raw[0]); end >= 0 {
+			if raw[0] == '\'' {
+				return raw[1 : end+1]
+			}
+			return expand(raw[1:end+1], env)
+		}
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return expand(raw, env)
+}
+
+// expand replaces $KEY and ${KEY} in s with the value of KEY from env, the
+// process environment or the empty string, in that order. "\$" is a
+// literal dollar sign.
+func expand(s string, env map[string]string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '$':
+			b.WriteByte('$')
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(lookup(s[i+2:i+2+end], env))
+			i += end + 2
+		case s[i] == '$' && i+1 < len(s) && isNameStart(s[i+1]):
+			end := i + 2
+			for end < len(s) && isNameChar(s[end]) {
+				end++
+			}
+			b.WriteString(lookup(s[i+1:end], env))
+			i = end - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// lookup returns the value of name from env, then the process environment
+func lookup(name string, env map[string]string) string {
+	if v, ok := env[name]; ok {
+		return v
+	}
+	return os.Getenv(name)
+}
+
+// isNameStart reports whether c may start a variable name
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// isNameChar reports whether c may appear in a variable name
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,172 @@
+package dotenv
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/lib/converter"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestPlugin_Parse(t *testing.T) {
+	f, err := os.Open("testdata/config.env")
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+
+	got, err := New().Parse(f)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]string{
+		"BASE":     "/opt/app",
+		"BIN":      "/opt/app/bin",
+		"LOG_DIR":  "/opt/app/logs",
+		"LITERAL":  "${BASE}/raw",
+		"GREETING": "hello world",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_Parse_Expansion(t *testing.T) {
+	t.Setenv("CFG2ENV_TEST_HOME", "/home/test")
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{
+			name:  "backward reference expands",
+			input: "BASE=/opt\nBIN=${BASE}/bin\n",
+			want:  map[string]string{"BASE": "/opt", "BIN": "/opt/bin"},
+		},
+		{
+			name:  "bare reference expands",
+			input: "HOST=localhost\nURL=http://$HOST:8080\n",
+			want:  map[string]string{"HOST": "localhost", "URL": "http://localhost:8080"},
+		},
+		{
+			name:  "forward reference does not expand",
+			input: "BIN=${BASE}/bin\nBASE=/opt\n",
+			want:  map[string]string{"BIN": "/bin", "BASE": "/opt"},
+		},
+		{
+			name:  "chained references",
+			input: "A=1\nB=${A}2\nC=${B}3\n",
+			want:  map[string]string{"A": "1", "B": "12", "C": "123"},
+		},
+		{
+			name:  "redefinition uses the value at that point",
+			input: "A=1\nB=$A\nA=2\nC=$A\n",
+			want:  map[string]string{"A": "2", "B": "1", "C": "2"},
+		},
+		{
+			name:  "falls back to process environment",
+			input: "CACHE=${CFG2ENV_TEST_HOME}/.cache\n",
+			want:  map[string]string{"CACHE": "/home/test/.cache"},
+		},
+		{
+			name:  "file keys shadow process environment",
+			input: "CFG2ENV_TEST_HOME=/srv\nCACHE=$CFG2ENV_TEST_HOME/.cache\n",
+			want:  map[string]string{"CFG2ENV_TEST_HOME": "/srv", "CACHE": "/srv/.cache"},
+		},
+		{
+			name:  "undefined reference is empty",
+			input: "PATH_X=${CFG2ENV_TEST_UNDEFINED}/bin\n",
+			want:  map[string]string{"PATH_X": "/bin"},
+		},
+		{
+			name:  "single quotes and escapes are literal",
+			input: "A=1\nB='$A'\nC=\\$A\nD=cost $5\n",
+			want:  map[string]string{"A": "1", "B": "$A", "C": "$A", "D": "cost $5"},
+		},
+		{
+			name:  "comment after quoted value",
+			input: "A=1\nB='$A' # literal\nC=\"$A\" # expanded\n",
+			want:  map[string]string{"A": "1", "B": "$A", "C": "1"},
+		},
+		{
+			name:  "unclosed brace is kept",
+			input: "A=${B\n",
+			want:  map[string]string{"A": "${B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New().Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_Parse_EdgeCases(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty input", "", map[string]string{}, false},
+		{"comments and blank lines", "# comment\n\n  # indented\n", map[string]string{}, false},
+		{"CRLF line endings", "A=1\r\nB=$A\r\n", map[string]string{"A": "1", "B": "1"}, false},
+		{"BOM", "\xEF\xBB\xBFA=1\n", map[string]string{"A": "1"}, false},
+		{"empty value", "A=\n", map[string]string{"A": ""}, false},
+		{"missing equals", "A=1\nNOT_A_PAIR\n", nil, true},
+		{"missing key", "=value\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New().Parse(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_ParseOrdered(t *testing.T) {
+	got, err := New().ParseOrdered(strings.NewReader("ZED=1\nALPHA=$ZED\nZED=2\nMID=3\n"))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+
+	want := []plugin.KV{
+		{Key: "ZED", Value: "2"},
+		{Key: "ALPHA", Value: "1"},
+		{Key: "MID", Value: "3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_Convert(t *testing.T) {
+	c := converter.New(New())
+	c.SetSort(converter.SortNone)
+
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader("base=/opt\nbin=${base}/bin\n"), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "\n\nBASE=/opt\nBIN=/opt/bin\n") {
+		t.Errorf("Convert() = %q", out.String())
+	}
+}
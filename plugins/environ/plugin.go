@@ -0,0 +1,42 @@
+package environ
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// Plugin implements the plugin.Plugin interface for the process environment.
+// It ignores its input reader and reads os.Environ instead.
+type Plugin struct {
+	plugin.BasePlugin
+}
+
+// New creates a new environment plugin
+func New() *Plugin {
+	return &Plugin{
+		BasePlugin: plugin.NewBasePlugin("environ"),
+	}
+}
+
+// Capabilities implements plugin.Capable. The environment has no order or
+// structure, so no optional features apply.
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{}
+}
+
+// Parse implements plugin.Plugin. The reader is not used.
+func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		key, value, ok := strings.Cut(entry, "=")
+		// Skip malformed entries and Windows per-drive entries like "=C:"
+		if !ok || key == "" {
+			continue
+		}
+		env[key] = value
+	}
+	return env, nil
+}
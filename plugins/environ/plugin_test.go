@@ -0,0 +1,64 @@
+package environ
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/lib/converter"
+)
+
+func TestPlugin_Parse(t *testing.T) {
+	t.Setenv("CFG2ENV_TEST_HOST", "localhost")
+	t.Setenv("CFG2ENV_TEST_EMPTY", "")
+	t.Setenv("CFG2ENV_TEST_EQUALS", "a=b=c")
+
+	p := New()
+	got, err := p.Parse(strings.NewReader("ignored"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]string{
+		"CFG2ENV_TEST_HOST":   "localhost",
+		"CFG2ENV_TEST_EMPTY":  "",
+		"CFG2ENV_TEST_EQUALS": "a=b=c",
+	}
+	for k, v := range want {
+		if gotV, ok := got[k]; !ok || gotV != v {
+			t.Errorf("Parse()[%s] = %q (present %v), want %q", k, gotV, ok, v)
+		}
+	}
+}
+
+func TestPlugin_ParseNilReader(t *testing.T) {
+	t.Setenv("CFG2ENV_TEST_KEY", "value")
+
+	got, err := New().Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse(nil) error = %v", err)
+	}
+	if got["CFG2ENV_TEST_KEY"] != "value" {
+		t.Errorf("Parse(nil)[CFG2ENV_TEST_KEY] = %q, want %q", got["CFG2ENV_TEST_KEY"], "value")
+	}
+}
+
+func TestPlugin_ConvertFiltered(t *testing.T) {
+	t.Setenv("CFG2ENV_TEST_HOST", "localhost")
+	t.Setenv("CFG2ENV_TEST_PASSWORD", "secret")
+	t.Setenv("cfg2env_test_lower", "value")
+
+	c := converter.New(New())
+	c.SetFilterPatterns([]string{"CFG2ENV_TEST_*"}, []string{"*_PASSWORD"}, converter.GlobMatcher{})
+
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader(""), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: environ\n#\n\n" +
+		"CFG2ENV_TEST_HOST=localhost\nCFG2ENV_TEST_LOWER=value\n"
+	if got := out.String(); got != want {
+		t.Errorf("Convert() = %q, want %q", got, want)
+	}
+}
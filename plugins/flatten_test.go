@@ -0,0 +1,85 @@
+package plugins
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+// TestFlatten_ArrayOfObjects checks that YAML and JSON flatten the same
+// array of objects to identical keys
+func TestFlatten_ArrayOfObjects(t *testing.T) {
+	jsonInput := `{"servers": [
+		{"host": "a", "port": 80, "tags": ["web", "edge"]},
+		{"host": "b", "port": 443, "tags": [], "tls": {"enabled": true}}
+	]}`
+	yamlInput := `servers:
+  - host: a
+    port: 80
+    tags: [web, edge]
+  - host: b
+    port: 443
+    tags: []
+    tls:
+      enabled: true
+`
+
+	tests := []struct {
+		mode utils.ArrayMode
+		want map[string]string
+	}{
+		{
+			mode: utils.ArrayIndex,
+			want: map[string]string{
+				"SERVERS_0_HOST":        "a",
+				"SERVERS_0_PORT":        "80",
+				"SERVERS_0_TAGS_0":      "web",
+				"SERVERS_0_TAGS_1":      "edge",
+				"SERVERS_1_HOST":        "b",
+				"SERVERS_1_PORT":        "443",
+				"SERVERS_1_TLS_ENABLED": "true",
+			},
+		},
+		{
+			mode: utils.ArrayJoin,
+			want: map[string]string{
+				"SERVERS_0_HOST":        "a",
+				"SERVERS_0_PORT":        "80",
+				"SERVERS_0_TAGS":        "web,edge",
+				"SERVERS_1_HOST":        "b",
+				"SERVERS_1_PORT":        "443",
+				"SERVERS_1_TAGS":        "",
+				"SERVERS_1_TLS_ENABLED": "true",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			jp := json.New()
+			jp.SetArrayMode(tt.mode, ",")
+			fromJSON, err := jp.Parse(strings.NewReader(jsonInput))
+			if err != nil {
+				t.Fatalf("json Parse() error = %v", err)
+			}
+
+			yp := yaml.New()
+			yp.SetArrayMode(tt.mode, ",")
+			fromYAML, err := yp.Parse(strings.NewReader(yamlInput))
+			if err != nil {
+				t.Fatalf("yaml Parse() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(fromJSON, tt.want) {
+				t.Errorf("json Parse() = %v, want %v", fromJSON, tt.want)
+			}
+			if !reflect.DeepEqual(fromYAML, fromJSON) {
+				t.Errorf("yaml Parse() = %v, want the json result %v", fromYAML, fromJSON)
+			}
+		})
+	}
+}
@@ -1,83 +1,539 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
 
+	"github.com/handaber/cfg2env/lib/utils"
 	"github.com/handaber/cfg2env/plugin"
 )
 
 // Plugin implements the plugin.Plugin interface for JSON format
 type Plugin struct {
 	plugin.BasePlugin
+	flatten    utils.FlattenOptions
+	warnings   []string
+	paths      map[string]string
+	collisions utils.Collisions
+
+	strictDuplicates bool
+	relaxed          bool
+	concat           bool
+	skipPaths        []string
+	onlyPath         string
+	stripOnlyPath    bool
+	maxDepth         int
 }
 
 // New creates a new JSON plugin
 func New() *Plugin {
 	return &Plugin{
 		BasePlugin: plugin.NewBasePlugin("json", "json"),
+		flatten:    utils.FlattenOptions{ArraySep: utils.DefaultArraySep, Format: formatScalar},
+		maxDepth:   utils.DefaultMaxDepth,
 	}
 }
 
+// SetArrayMode sets how arrays are flattened and the separator for joined arrays
+func (p *Plugin) SetArrayMode(mode utils.ArrayMode, sep string) {
+	p.flatten.Arrays = mode
+	p.flatten.ArraySep = sep
+}
+
+// SetArrayKeyField names a field of the maps in arrays whose value replaces
+// the element's index in keys, such as "name" to flatten
+// [{name: db, host: x}] to DB_HOST. Elements without it keep their index.
+// Empty disables it.
+func (p *Plugin) SetArrayKeyField(field string) {
+	p.flatten.KeyField = field
+}
+
+// SetCompactArrays controls whether null elements are dropped from arrays
+// and the rest reindexed, so [a, null, c] flattens to KEY_0=a and KEY_1=c.
+// By default every element keeps its position.
+func (p *Plugin) SetCompactArrays(compact bool) {
+	p.flatten.CompactArrays = compact
+}
+
+// SetOmitEmptyContainers controls whether empty maps and arrays are dropped
+// instead of being written as a key with an empty value
+func (p *Plugin) SetOmitEmptyContainers(omit bool) {
+	p.flatten.OmitEmptyContainers = omit
+}
+
+// SetSkipPaths sets dot-separated paths, such as "logging" or
+// "app.metadata", whose subtrees are dropped before flattening
+func (p *Plugin) SetSkipPaths(paths []string) {
+	p.skipPaths = paths
+}
+
+// SetOnlyPath limits flattening to the subtree at the dot-separated path,
+// such as "database". With strip, the path is dropped from the keys.
+func (p *Plugin) SetOnlyPath(path string, strip bool) {
+	p.onlyPath = path
+	p.stripOnlyPath = strip
+}
+
+// SetStrictDuplicates controls whether an object repeating a key is an
+// error. By default the last value for a repeated key wins.
+func (p *Plugin) SetStrictDuplicates(strict bool) {
+	p.strictDuplicates = strict
+}
+
+// SetRelaxed controls whether JSONC input is accepted: // and /* */
+// comments and trailing commas in objects and arrays.
+func (p *Plugin) SetRelaxed(relaxed bool) {
+	p.relaxed = relaxed
+}
+
+// SetMaxDepth replaces utils.DefaultMaxDepth as the nesting limit
+func (p *Plugin) SetMaxDepth(depth int) {
+	p.maxDepth = depth
+}
+
+// SetConcat controls whether input holding several concatenated JSON
+// values, such as the output of cat a.json b.json, is read in full. Each
+// value is flattened in turn and keys from later values override earlier
+// ones. By default the input must hold a single value, and anything after
+// it is an error, as Validate reports.
+func (p *Plugin) SetConcat(concat bool) {
+	p.concat = concat
+}
+
+// prepare strips any BOM from r and applies relaxed parsing and duplicate
+// key checks when they are enabled
+func (p *Plugin) prepare(r io.Reader) (io.Reader, error) {
+	r = utils.StripBOM(r)
+	if !p.relaxed && !p.strictDuplicates {
+		return r, nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if p.relaxed {
+		data = relax(data)
+	}
+	if p.strictDuplicates {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for {
+			err := checkDuplicates("", decoder)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !p.concat {
+				break
+			}
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
 // Parse implements plugin.Plugin
 func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	env, _, err := p.parse(r)
+	return env, err
+}
+
+// parse decodes and flattens r, returning the flattened keys along with the
+// part of the document they were flattened from
+func (p *Plugin) parse(r io.Reader) (map[string]string, utils.Selection, error) {
+	p.warnings = nil
+	p.paths = nil
+	p.collisions = nil
+
 	// Handle empty input
 	if r == nil {
-		return make(map[string]string), nil
+		return make(map[string]string), utils.Selection{}, nil
+	}
+
+	r, err := p.prepare(r)
+	if err != nil {
+		return nil, utils.Selection{}, err
+	}
+
+	env := make(map[string]string)
+	p.collisions = make(utils.Collisions)
+	var sel utils.Selection
+	decoder := json.NewDecoder(r)
+	for {
+		var data interface{}
+		if err := decoder.Decode(&data); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, utils.Selection{}, err
+		}
+		if err := utils.CheckDepth(data, p.maxDepth); err != nil {
+			return nil, utils.Selection{}, err
+		}
+
+		// Later values override keys from earlier ones without counting
+		// as collisions
+		values, valueSel, collisions := p.flattenValue(data)
+		for k, v := range values {
+			env[k] = v
+			if pairs, ok := collisions[k]; ok {
+				p.collisions[k] = pairs
+			} else {
+				delete(p.collisions, k)
+			}
+		}
+		if sel.Value == nil {
+			sel = valueSel
+		}
+		if !p.concat {
+			break
+		}
+	}
+	if err := checkEnd(decoder); err != nil {
+		return nil, utils.Selection{}, err
+	}
+	return env, sel, nil
+}
+
+// DecodeTree implements plugin.TreePlugin. With SetConcat, the
+// concatenated values are merged as by utils.MergeValues, so unlike Parse a
+// later value replaces an earlier array rather than its indexed keys.
+func (p *Plugin) DecodeTree(r io.Reader) (interface{}, error) {
+	if r == nil {
+		return nil, nil
 	}
 
-	var data interface{}
+	r, err := p.prepare(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree interface{}
 	decoder := json.NewDecoder(r)
-	if err := decoder.Decode(&data); err != nil {
-		if err == io.EOF {
-			return make(map[string]string), nil
+	for {
+		var data interface{}
+		if err := decoder.Decode(&data); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if err := utils.CheckDepth(data, p.maxDepth); err != nil {
+			return nil, err
+		}
+		tree = utils.MergeValues(tree, data, utils.MergeOptions{})
+		if !p.concat {
+			break
 		}
+	}
+	if err := checkEnd(decoder); err != nil {
 		return nil, err
 	}
+	return tree, nil
+}
 
-	env := make(map[string]string)
-	if data != nil {
-		flatten("", data, env)
+// FlattenTree implements plugin.TreePlugin
+func (p *Plugin) FlattenTree(data interface{}) (map[string]string, error) {
+	p.warnings = nil
+	p.paths = nil
+	p.collisions = nil
+	if err := utils.CheckDepth(data, p.maxDepth); err != nil {
+		return nil, err
 	}
+	env, _, collisions := p.flattenValue(data)
+	p.collisions = collisions
 	return env, nil
 }
 
-// flatten recursively flattens nested maps into underscore-separated keys
-func flatten(prefix string, v interface{}, env map[string]string) {
-	switch val := v.(type) {
-	case map[string]interface{}:
-		if len(val) == 0 {
-			env[strings.ToUpper(prefix)] = ""
-			return
+// flattenValue flattens a single decoded JSON value, returning the keys
+// along with the part of the value they were flattened from and the keys
+// produced by more than one path
+func (p *Plugin) flattenValue(data interface{}) (map[string]string, utils.Selection, utils.Collisions) {
+	env := make(map[string]string)
+	collisions := make(utils.Collisions)
+	sel := utils.Selection{Value: utils.PrunePaths(data, p.skipPaths)}
+	if p.onlyPath != "" {
+		sel = utils.SelectPath(sel.Value, p.onlyPath, p.stripOnlyPath)
+	}
+	if sel.Value != nil {
+		opts := p.flatten
+		opts.OnCollision = func(key string) {
+			p.warnings = append(p.warnings, utils.CollisionWarning(key))
+			collisions.Shadow(key, env, p.paths)
 		}
-		for k, v := range val {
-			newKey := k
-			if prefix != "" {
-				newKey = prefix + "_" + k
+		opts.OnPath = p.recordPath
+		opts.Path = sel.Path
+		utils.FlattenWith(sel.Prefix, sel.Value, env, opts)
+		collisions.Complete(env, p.paths)
+	}
+	return env, sel, collisions
+}
+
+// Validate implements plugin.Validator. It checks that r holds a single
+// well-formed JSON value, or any number of them with SetConcat, without
+// building the flattened map.
+func (p *Plugin) Validate(r io.Reader) error {
+	if r == nil {
+		return nil
+	}
+
+	r, err := p.prepare(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
 			}
-			flatten(strings.ToUpper(newKey), v, env)
+			return err
 		}
-	case []interface{}:
-		for i, v := range val {
-			newKey := fmt.Sprintf("%s_%d", prefix, i)
-			flatten(strings.ToUpper(newKey), v, env)
+		if !p.concat {
+			break
 		}
+	}
+	return checkEnd(decoder)
+}
+
+// checkEnd returns an error if decoder has input left after the values it
+// decoded, so that Parse and Validate agree on such input
+func checkEnd(decoder *json.Decoder) error {
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value at offset %d", decoder.InputOffset())
+	}
+	return nil
+}
+
+// Warnings implements plugin.Warner
+func (p *Plugin) Warnings() []string {
+	return p.warnings
+}
+
+// Paths implements plugin.PathReporter
+func (p *Plugin) Paths() map[string]string {
+	return p.paths
+}
+
+// Collisions implements plugin.CollisionReporter
+func (p *Plugin) Collisions() map[string][]plugin.KV {
+	return p.collisions
+}
+
+// recordPath notes the source path of a flattened key for Paths
+func (p *Plugin) recordPath(key, path string) {
+	if p.paths == nil {
+		p.paths = make(map[string]string)
+	}
+	p.paths[key] = path
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Ordered: true, Paths: true, Arrays: true, Concat: true}
+}
+
+// Reset implements plugin.Resetter
+func (p *Plugin) Reset() {
+	p.warnings = nil
+	p.paths = nil
+	p.collisions = nil
+}
+
+// ParseOrdered implements plugin.OrderedPlugin
+func (p *Plugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	// Handle empty input
+	if r == nil {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(utils.StripBOM(r))
+	if err != nil {
+		return nil, err
+	}
+
+	env, sel, err := p.parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if p.relaxed {
+		data = relax(data)
+	}
+
+	// Walk the token stream to recover the source order of keys
+	var order []string
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		err := walkOrder("", decoder, &order, p.flatten)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !p.concat {
+			break
+		}
+	}
+	for i, k := range order {
+		order[i] = sel.Rekey(k)
+	}
+	return plugin.OrderPairs(env, order), nil
+}
+
+// checkDuplicates returns an error for the first object in the token stream
+// that repeats a key. path names the enclosing value in dotted form.
+func checkDuplicates(path string, decoder *json.Decoder) error {
+	tok, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		seen := make(map[string]bool)
+		for decoder.More() {
+			keyTok, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			if seen[key] {
+				if path == "" {
+					return fmt.Errorf("duplicate key %q", key)
+				}
+				return fmt.Errorf("duplicate key %q in %s", key, path)
+			}
+			seen[key] = true
+
+			newPath := key
+			if path != "" {
+				newPath = path + "." + key
+			}
+			if err := checkDuplicates(newPath, decoder); err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; decoder.More(); i++ {
+			if err := checkDuplicates(fmt.Sprintf("%s[%d]", path, i), decoder); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Consume the closing delimiter
+	_, err = decoder.Token()
+	return err
+}
+
+// walkOrder appends flattened keys to order in the order they appear in the
+// token stream. Array elements holding keyField are keyed by its value.
+func walkOrder(prefix string, decoder *json.Decoder, order *[]string, opts utils.FlattenOptions) error {
+	tok, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		*order = append(*order, strings.ToUpper(prefix))
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		empty := true
+		for decoder.More() {
+			empty = false
+			keyTok, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			if err := walkOrder(strings.ToUpper(utils.JoinKey(prefix, key)), decoder, order, opts); err != nil {
+				return err
+			}
+		}
+		if empty {
+			*order = append(*order, strings.ToUpper(prefix))
+		}
+	case '[':
+		// Joined arrays are emitted under the array's own key
+		*order = append(*order, strings.ToUpper(prefix))
+		for i := 0; decoder.More(); {
+			if opts.KeyField != "" || opts.CompactArrays {
+				walked, err := walkElement(prefix, i, decoder, order, opts)
+				if err != nil {
+					return err
+				}
+				if walked {
+					i++
+				}
+				continue
+			}
+			if err := walkOrder(utils.JoinKey(prefix, fmt.Sprintf("%d", i)), decoder, order, opts); err != nil {
+				return err
+			}
+			i++
+		}
+	}
+
+	// Consume the closing delimiter
+	_, err = decoder.Token()
+	return err
+}
+
+// walkElement walks element i of an array, keyed by its opts.KeyField value
+// if it is an object holding one. The element is decoded whole to look
+// ahead for the field, then walked from its own token stream. It reports
+// false for a null element skipped by opts.CompactArrays.
+func walkElement(prefix string, i int, decoder *json.Decoder, order *[]string, opts utils.FlattenOptions) (bool, error) {
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return false, err
+	}
+
+	var element interface{}
+	if err := json.Unmarshal(raw, &element); err != nil {
+		return false, err
+	}
+	if element == nil && opts.CompactArrays {
+		return false, nil
+	}
+	key, _ := opts.ElementKey(i, element)
+
+	sub := json.NewDecoder(bytes.NewReader(raw))
+	return true, walkOrder(strings.ToUpper(utils.JoinKey(prefix, key)), sub, order, opts)
+}
+
+// formatScalar converts a decoded JSON scalar to its string representation
+func formatScalar(v interface{}) string {
+	switch val := v.(type) {
 	case string:
-		env[strings.ToUpper(prefix)] = val
+		return val
 	case float64:
 		if float64(int64(val)) == val {
-			env[strings.ToUpper(prefix)] = strconv.FormatInt(int64(val), 10)
-		} else {
-			env[strings.ToUpper(prefix)] = strconv.FormatFloat(val, 'f', -1, 64)
+			return strconv.FormatInt(int64(val), 10)
 		}
+		return strconv.FormatFloat(val, 'f', -1, 64)
 	case bool:
-		env[strings.ToUpper(prefix)] = strconv.FormatBool(val)
+		return strconv.FormatBool(val)
 	case nil:
-		env[strings.ToUpper(prefix)] = ""
+		return ""
 	default:
-		env[strings.ToUpper(prefix)] = fmt.Sprintf("%v", val)
+		return fmt.Sprintf("%v", val)
 	}
 }
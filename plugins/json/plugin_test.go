@@ -1,8 +1,13 @@
 package json
 
 import (
+	"errors"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
 )
 
 func TestPlugin_Parse(t *testing.T) {
@@ -128,3 +133,617 @@ func TestPlugin_Parse_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestPlugin_ParseOrdered(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []plugin.KV
+		wantErr bool
+	}{
+		{
+			name: "source order",
+			input: `{
+				"zebra": 1,
+				"apple": {"second": "b", "first": "a"},
+				"list": ["x", {"inner": true}],
+				"empty": {},
+				"nothing": null
+			}`,
+			want: []plugin.KV{
+				{Key: "ZEBRA", Value: "1"},
+				{Key: "APPLE_SECOND", Value: "b"},
+				{Key: "APPLE_FIRST", Value: "a"},
+				{Key: "LIST_0", Value: "x"},
+				{Key: "LIST_1_INNER", Value: "true"},
+				{Key: "EMPTY", Value: ""},
+				{Key: "NOTHING", Value: ""},
+			},
+		},
+		{
+			name:  "empty input",
+			input: "",
+			want:  []plugin.KV{},
+		},
+		{
+			name:    "invalid structure",
+			input:   `{"key": [1,2,3`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			got, err := p.ParseOrdered(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOrdered() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrdered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_BOM(t *testing.T) {
+	input := `{"database": {"host": "localhost", "port": 5432}, "features": ["a", "b"]}`
+
+	p := New()
+	want, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	got, err := p.Parse(strings.NewReader("\xEF\xBB\xBF" + input))
+	if err != nil {
+		t.Fatalf("Parse() with BOM error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() with BOM = %v, want %v", got, want)
+	}
+
+	wantOrdered, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	gotOrdered, err := p.ParseOrdered(strings.NewReader("\xEF\xBB\xBF" + input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() with BOM error = %v", err)
+	}
+	if !reflect.DeepEqual(gotOrdered, wantOrdered) {
+		t.Errorf("ParseOrdered() with BOM = %v, want %v", gotOrdered, wantOrdered)
+	}
+}
+
+func TestPlugin_Parse_CRLF(t *testing.T) {
+	input := "{\r\n  \"database\": {\r\n    \"host\": \"localhost\"\r\n  },\r\n  \"port\": 5432\r\n}\r\n"
+	want := map[string]string{
+		"DATABASE_HOST": "localhost",
+		"PORT":          "5432",
+	}
+
+	got, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+}
+
+func TestPlugin_ArrayJoin(t *testing.T) {
+	input := `{"features": ["logging", "metrics"], "weights": [1, 2.5, true], "servers": [{"host": "a"}, "b"]}`
+
+	p := New()
+	p.SetArrayMode(utils.ArrayJoin, ";")
+
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{
+		"FEATURES":       "logging;metrics",
+		"WEIGHTS":        "1;2.5;true",
+		"SERVERS_0_HOST": "a",
+		"SERVERS_1":      "b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+
+	ordered, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	wantOrdered := []plugin.KV{
+		{Key: "FEATURES", Value: "logging;metrics"},
+		{Key: "WEIGHTS", Value: "1;2.5;true"},
+		{Key: "SERVERS_0_HOST", Value: "a"},
+		{Key: "SERVERS_1", Value: "b"},
+	}
+	if !reflect.DeepEqual(ordered, wantOrdered) {
+		t.Errorf("ParseOrdered() = %v, want %v", ordered, wantOrdered)
+	}
+}
+
+func TestPlugin_Warnings(t *testing.T) {
+	p := New()
+
+	if _, err := p.Parse(strings.NewReader(`{"api_url": "a", "api": {"url": "b"}, "Key": 1, "key": 2}`)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []string{
+		"key 'API_URL' is produced by more than one path",
+		"key 'KEY' is produced by more than one path",
+	}
+	if !reflect.DeepEqual(p.Warnings(), want) {
+		t.Errorf("Warnings() = %v, want %v", p.Warnings(), want)
+	}
+
+	// Warnings are reset on every parse
+	if _, err := p.Parse(strings.NewReader(`{"api": {"url": "b"}}`)); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(p.Warnings()) != 0 {
+		t.Errorf("Warnings() = %v, want none", p.Warnings())
+	}
+}
+
+func TestPlugin_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"object", `{"database": {"host": "localhost", "ports": [1, 2]}}`, false},
+		{"empty input", "", false},
+		{"scalar", `"value"`, false},
+		{"missing brace", `{"database": {"host": "localhost"}`, true},
+		{"trailing comma", `{"a": 1,}`, true},
+		{"trailing data", `{"a": 1} {"b": 2}`, true},
+		{"unquoted key", `{a: 1}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().Validate(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlugin_ValidateAgreesWithParse(t *testing.T) {
+	// Every way of reading a document accepts or rejects it alike
+	for _, input := range []string{
+		`{"a": 1}`,
+		"{\"a\": 1}\n",
+		`{"a": 1} garbage`,
+		`{"a": 1} {"b": 2}`,
+		`[{"a": 1}] x`,
+	} {
+		p := New()
+		validateErr := p.Validate(strings.NewReader(input))
+		_, parseErr := p.Parse(strings.NewReader(input))
+		_, orderedErr := p.ParseOrdered(strings.NewReader(input))
+		_, treeErr := p.DecodeTree(strings.NewReader(input))
+		streamErr := p.ParseStream(strings.NewReader(input), func(plugin.KV) error { return nil })
+		for name, err := range map[string]error{"Parse": parseErr, "ParseOrdered": orderedErr, "DecodeTree": treeErr, "ParseStream": streamErr} {
+			if (err == nil) != (validateErr == nil) {
+				t.Errorf("%s(%q) error = %v, but Validate() error = %v", name, input, err, validateErr)
+			}
+		}
+	}
+}
+
+func TestPlugin_StrictDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "top-level repeat",
+			input:   `{"a": 1, "b": 2, "a": 3}`,
+			want:    map[string]string{"A": "3", "B": "2"},
+			wantErr: `duplicate key "a"`,
+		},
+		{
+			name:    "nested repeat",
+			input:   `{"database": {"host": "a", "port": 5432, "host": "b"}}`,
+			want:    map[string]string{"DATABASE_HOST": "b", "DATABASE_PORT": "5432"},
+			wantErr: `duplicate key "host" in database`,
+		},
+		{
+			name:    "repeat inside array element",
+			input:   `{"servers": [{"name": "a"}, {"name": "b", "name": "c"}]}`,
+			want:    map[string]string{"SERVERS_0_NAME": "a", "SERVERS_1_NAME": "c"},
+			wantErr: `duplicate key "name" in servers[1]`,
+		},
+		{
+			name:  "same key in sibling objects",
+			input: `{"db": {"host": "a"}, "cache": {"host": "b"}}`,
+			want:  map[string]string{"DB_HOST": "a", "CACHE_HOST": "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Last value wins by default
+			got, err := New().Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+
+			p := New()
+			p.SetStrictDuplicates(true)
+			got, err = p.Parse(strings.NewReader(tt.input))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("strict Parse() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("strict Parse() = %v, want %v", got, tt.want)
+				}
+			} else if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("strict Parse() error = %v, want %q", err, tt.wantErr)
+			}
+
+			err = p.Validate(strings.NewReader(tt.input))
+			if (err != nil) != (tt.wantErr != "") {
+				t.Errorf("strict Validate() error = %v, wantErr %v", err, tt.wantErr != "")
+			}
+		})
+	}
+}
+
+func TestPlugin_Relaxed(t *testing.T) {
+	input := `// Service configuration
+{
+  "database": {
+    "host": "localhost", // primary
+    /* "port": 5432, */
+    "user": "app",
+  },
+  "servers": [
+    "a",
+    "b", /* last */
+  ],
+}
+`
+	want := map[string]string{
+		"DATABASE_HOST": "localhost",
+		"DATABASE_USER": "app",
+		"SERVERS_0":     "a",
+		"SERVERS_1":     "b",
+	}
+
+	if _, err := New().Parse(strings.NewReader(input)); err == nil {
+		t.Error("Parse() without relaxed mode succeeded, want error")
+	}
+
+	p := New()
+	p.SetRelaxed(true)
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+
+	pairs, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	var keys []string
+	for _, kv := range pairs {
+		keys = append(keys, kv.Key)
+	}
+	wantKeys := []string{"DATABASE_HOST", "DATABASE_USER", "SERVERS_0", "SERVERS_1"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("ParseOrdered() keys = %v, want %v", keys, wantKeys)
+	}
+
+	if err := p.Validate(strings.NewReader(input)); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := p.Validate(strings.NewReader(`{"a": 1,, }`)); err == nil {
+		t.Error("Validate() with a double comma succeeded, want error")
+	}
+}
+
+func TestPlugin_SkipPaths(t *testing.T) {
+	p := New()
+	p.SetSkipPaths([]string{"metadata", "database.replica"})
+
+	got, err := p.Parse(strings.NewReader(`{
+		"database": {"host": "db", "replica": {"host": "ro"}},
+		"metadata": {"build": {"sha": "abc"}}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{"DATABASE_HOST": "db"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_OnlyPath(t *testing.T) {
+	input := `{"app": {"name": "svc"}, "database": {"port": 5432, "host": "db"}}`
+
+	tests := []struct {
+		name  string
+		path  string
+		strip bool
+		want  []plugin.KV
+	}{
+		{"nested path", "database", false, []plugin.KV{{Key: "DATABASE_PORT", Value: "5432"}, {Key: "DATABASE_HOST", Value: "db"}}},
+		{"stripped prefix", "database", true, []plugin.KV{{Key: "PORT", Value: "5432"}, {Key: "HOST", Value: "db"}}},
+		{"missing path", "cache", false, []plugin.KV{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetOnlyPath(tt.path, tt.strip)
+
+			got, err := p.ParseOrdered(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ParseOrdered() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrdered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_OmitEmptyContainers(t *testing.T) {
+	input := `{"metadata": {}, "tags": [], "description": "", "name": "app"}`
+
+	got, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{"METADATA": "", "DESCRIPTION": "", "NAME": "app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+
+	// The empty object is dropped but the empty string value is kept
+	p := New()
+	p.SetOmitEmptyContainers(true)
+	got, err = p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want = map[string]string{"DESCRIPTION": "", "NAME": "app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() with omitted containers = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_Concat(t *testing.T) {
+	input := "{\"database\": {\"host\": \"a\", \"port\": 5432}}\n{\"database\": {\"host\": \"b\"}, \"debug\": true}\n"
+
+	// By default a second value is an error
+	if _, err := New().Parse(strings.NewReader(input)); err == nil {
+		t.Error("Parse() succeeded on concatenated values without concat")
+	}
+	if err := New().Validate(strings.NewReader(input)); err == nil {
+		t.Error("Validate() succeeded on concatenated values without concat")
+	}
+
+	// Later values override keys from earlier ones without warnings
+	p := New()
+	p.SetConcat(true)
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() with concat error = %v", err)
+	}
+	want := map[string]string{"DATABASE_HOST": "b", "DATABASE_PORT": "5432", "DEBUG": "true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() with concat = %v, want %v", got, want)
+	}
+	if len(p.Warnings()) != 0 {
+		t.Errorf("Warnings() = %v, want none", p.Warnings())
+	}
+	if err := p.Validate(strings.NewReader(input)); err != nil {
+		t.Errorf("Validate() with concat error = %v", err)
+	}
+
+	// Source order covers every value
+	pairs, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	var keys []string
+	for _, kv := range pairs {
+		keys = append(keys, kv.Key)
+	}
+	if want := []string{"DATABASE_HOST", "DATABASE_PORT", "DEBUG"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ParseOrdered() keys = %v, want %v", keys, want)
+	}
+
+	// A malformed later value is an error
+	if _, err := p.Parse(strings.NewReader(input + "{\"broken\": ")); err == nil {
+		t.Error("Parse() succeeded with a truncated second value")
+	}
+
+	// Duplicate checks apply to every value
+	p.SetStrictDuplicates(true)
+	if _, err := p.Parse(strings.NewReader(input + `{"a": 1, "a": 2}`)); err == nil || !strings.Contains(err.Error(), `duplicate key "a"`) {
+		t.Errorf("Parse() error = %v, want duplicate key in the third value", err)
+	}
+}
+
+func TestPlugin_MaxDepth(t *testing.T) {
+	deep := func(depth int) string {
+		return strings.Repeat(`{"a": `, depth) + "1" + strings.Repeat("}", depth)
+	}
+
+	// The default limit is generous enough for real configs
+	if _, err := New().Parse(strings.NewReader(deep(utils.DefaultMaxDepth))); err != nil {
+		t.Errorf("Parse() at the default limit error = %v", err)
+	}
+
+	_, err := New().Parse(strings.NewReader(deep(utils.DefaultMaxDepth + 1)))
+	if !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("Parse() past the default limit error = %v, want ErrMaxDepth", err)
+	}
+
+	p := New()
+	p.SetMaxDepth(3)
+	if _, err := p.Parse(strings.NewReader(deep(4))); !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("Parse() past a limit of 3 error = %v, want ErrMaxDepth", err)
+	}
+	if _, err := p.ParseOrdered(strings.NewReader(deep(4))); !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("ParseOrdered() past a limit of 3 error = %v, want ErrMaxDepth", err)
+	}
+
+	p.SetMaxDepth(0)
+	got, err := p.Parse(strings.NewReader(deep(500)))
+	if err != nil {
+		t.Fatalf("Parse() without a limit error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("Parse() without a limit = %d keys, want 1", len(got))
+	}
+}
+
+func TestPlugin_RootArray(t *testing.T) {
+	input := `["a", {"x": 1, "y": [true]}, [2, 3], null]`
+	want := []plugin.KV{
+		{Key: "0", Value: "a"},
+		{Key: "1_X", Value: "1"},
+		{Key: "1_Y_0", Value: "true"},
+		{Key: "2_0", Value: "2"},
+		{Key: "2_1", Value: "3"},
+		{Key: "3", Value: ""},
+	}
+
+	got, err := New().ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+
+	env, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for _, kv := range want {
+		if v, ok := env[kv.Key]; !ok || v != kv.Value {
+			t.Errorf("Parse()[%q] = %q, want %q", kv.Key, v, kv.Value)
+		}
+	}
+	if len(env) != len(want) {
+		t.Errorf("Parse() = %v, want %d keys", env, len(want))
+	}
+}
+
+func TestPlugin_ArrayKeyField(t *testing.T) {
+	input := `{
+		"servers": [
+			{"name": "db", "host": "db.internal", "port": 5432},
+			{"host": "cache.internal"},
+			{"host": "numeric", "name": 42},
+			{"name": "", "host": "empty"}
+		],
+		"tags": ["a"]
+	}`
+	want := []plugin.KV{
+		{Key: "SERVERS_DB_HOST", Value: "db.internal"},
+		{Key: "SERVERS_DB_PORT", Value: "5432"},
+		{Key: "SERVERS_1_HOST", Value: "cache.internal"},
+		{Key: "SERVERS_42_HOST", Value: "numeric"},
+		{Key: "SERVERS_3_NAME", Value: ""},
+		{Key: "SERVERS_3_HOST", Value: "empty"},
+		{Key: "TAGS_0", Value: "a"},
+	}
+
+	p := New()
+	p.SetArrayKeyField("name")
+	got, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_CompactArrays(t *testing.T) {
+	input := `{
+		"hosts": ["a", null, "c"],
+		"ports": [80, null, 443, null],
+		"servers": [null, {"name": "db", "host": "db.internal"}],
+		"tags": ["x", null]
+	}`
+
+	tests := []struct {
+		name    string
+		compact bool
+		want    []plugin.KV
+	}{
+		{
+			name: "positions kept by default",
+			want: []plugin.KV{
+				{Key: "HOSTS_0", Value: "a"},
+				{Key: "HOSTS_1", Value: ""},
+				{Key: "HOSTS_2", Value: "c"},
+				{Key: "PORTS_0", Value: "80"},
+				{Key: "PORTS_1", Value: ""},
+				{Key: "PORTS_2", Value: "443"},
+				{Key: "PORTS_3", Value: ""},
+				{Key: "SERVERS_0", Value: ""},
+				{Key: "SERVERS_1_NAME", Value: "db"},
+				{Key: "SERVERS_1_HOST", Value: "db.internal"},
+				{Key: "TAGS_0", Value: "x"},
+				{Key: "TAGS_1", Value: ""},
+			},
+		},
+		{
+			name:    "compacted",
+			compact: true,
+			want: []plugin.KV{
+				{Key: "HOSTS_0", Value: "a"},
+				{Key: "HOSTS_1", Value: "c"},
+				{Key: "PORTS_0", Value: "80"},
+				{Key: "PORTS_1", Value: "443"},
+				{Key: "SERVERS_0_NAME", Value: "db"},
+				{Key: "SERVERS_0_HOST", Value: "db.internal"},
+				{Key: "TAGS_0", Value: "x"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetCompactArrays(tt.compact)
+			got, err := p.ParseOrdered(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ParseOrdered() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrdered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
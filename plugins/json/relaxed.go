@@ -0,0 +1,93 @@
+package json
+
+// relax rewrites JSONC input as strict JSON. Line (//) and block (/* */)
+// comments and commas before a closing } or ] are replaced with spaces, so
+// offsets in decoder errors still point into the original input. Newlines
+// inside comments are kept. An unterminated block comment is left for the
+// decoder to reject.
+func relax(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '/':
+			if end := commentEnd(out, i); end > i {
+				blank(out[i:end])
+				i = end - 1
+			}
+		case ',':
+			if j := skipSpace(out, i+1); j < len(out) && (out[j] == '}' || out[j] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+	return out
+}
+
+// commentEnd returns the index just past the comment starting at data[i],
+// or i if no complete comment starts there
+func commentEnd(data []byte, i int) int {
+	if i+1 >= len(data) {
+		return i
+	}
+	switch data[i+1] {
+	case '/':
+		for j := i + 2; j < len(data); j++ {
+			if data[j] == '\n' {
+				return j
+			}
+		}
+		return len(data)
+	case '*':
+		for j := i + 2; j+1 < len(data); j++ {
+			if data[j] == '*' && data[j+1] == '/' {
+				return j + 2
+			}
+		}
+	}
+	return i
+}
+
+// skipSpace returns the index of the first byte at or after i that is
+// neither whitespace nor part of a comment
+func skipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		case '/':
+			end := commentEnd(data, i)
+			if end == i {
+				return i
+			}
+			i = end
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// blank replaces everything but newlines in b with spaces
+func blank(b []byte) {
+	for i := range b {
+		if b[i] != '\n' {
+			b[i] = ' '
+		}
+	}
+}
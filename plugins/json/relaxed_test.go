@@ -0,0 +1,30 @@
+package json
+
+import "testing"
+
+func TestRelax(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"strict json unchanged", `{"a": [1, 2]}`, `{"a": [1, 2]}`},
+		{"line comment", "{\"a\": 1 // note\n}", "{\"a\": 1        \n}"},
+		{"block comment", `{/* c */"a": 1}`, `{       "a": 1}`},
+		{"multiline block comment keeps newlines", "/* a\nb */{}", "    \n    {}"},
+		{"trailing comma in object", `{"a": 1,}`, `{"a": 1 }`},
+		{"trailing comma in array", `[1, 2, ]`, `[1, 2  ]`},
+		{"trailing comma before comment", "[1, // last\n]", "[1         \n]"},
+		{"comment markers in strings", `{"url": "http://x/*y*/", "s": "a,]"}`, `{"url": "http://x/*y*/", "s": "a,]"}`},
+		{"escaped quote in string", `{"a": "\",}", "b": 1,}`, `{"a": "\",}", "b": 1 }`},
+		{"unterminated block comment", `{"a": 1} /* x`, `{"a": 1} /* x`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(relax([]byte(tt.input))); got != tt.want {
+				t.Errorf("relax() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
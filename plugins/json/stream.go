@@ -0,0 +1,150 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// ParseStream implements plugin.StreamPlugin. A top-level array is read one
+// element at a time with the decoder's token stream, and each element's
+// pairs are emitted before the next one is decoded, so only one element is
+// held in memory. Other documents, and arrays read with options that need
+// the whole document (relaxed parsing, duplicate checks, concatenated
+// values, path selection and joined arrays), are parsed in full with
+// ParseOrdered and emitted pair by pair.
+func (p *Plugin) ParseStream(r io.Reader, emit func(plugin.KV) error) error {
+	p.warnings = nil
+	p.paths = nil
+	p.collisions = nil
+
+	// Handle empty input
+	if r == nil {
+		return nil
+	}
+
+	br := bufio.NewReader(utils.StripBOM(r))
+	if !p.streamable() || !startsArray(br) {
+		pairs, err := p.ParseOrdered(br)
+		if err != nil {
+			return err
+		}
+		for _, kv := range pairs {
+			if err := emit(kv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(br)
+	// Consume the opening bracket
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	p.collisions = make(utils.Collisions)
+	opts := p.flatten
+	opts.OnCollision = func(key string) {
+		p.warnings = append(p.warnings, utils.CollisionWarning(key))
+	}
+	opts.OnPath = p.recordPath
+	for i := 0; decoder.More(); i++ {
+		pairs, err := p.streamElement(decoder, i, opts)
+		if err != nil {
+			return err
+		}
+		for _, kv := range pairs {
+			// Elements keyed by the same --array-key-field value collide
+			// across elements as well as within one
+			if seen[kv.Key] {
+				opts.OnCollision(kv.Key)
+			}
+			seen[kv.Key] = true
+			if err := emit(kv); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Consume the closing bracket
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	return checkEnd(decoder)
+}
+
+// streamElement decodes element i of a top-level array and returns its
+// flattened pairs in source order
+func (p *Plugin) streamElement(decoder *json.Decoder, i int, opts utils.FlattenOptions) ([]plugin.KV, error) {
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var element interface{}
+	if err := json.Unmarshal(raw, &element); err != nil {
+		return nil, err
+	}
+	// The element sits one level below the array
+	if err := utils.CheckDepth([]interface{}{element}, p.maxDepth); err != nil {
+		return nil, fmt.Errorf("element %d: %w", i, err)
+	}
+
+	key, rest := opts.ElementKey(i, element)
+	env := make(map[string]string)
+	collisions := make(utils.Collisions)
+	elementOpts := opts.Descend(key)
+	elementOpts.OnCollision = func(k string) {
+		opts.OnCollision(k)
+		collisions.Shadow(k, env, p.paths)
+	}
+	utils.FlattenWith(key, rest, env, elementOpts)
+	collisions.Complete(env, p.paths)
+	for k := range env {
+		if pairs, ok := collisions[k]; ok {
+			p.collisions[k] = pairs
+		} else {
+			delete(p.collisions, k)
+		}
+	}
+
+	// The key field is walked too, but has no value in env to order
+	var order []string
+	if err := walkOrder(strings.ToUpper(key), json.NewDecoder(bytes.NewReader(raw)), &order, opts); err != nil {
+		return nil, err
+	}
+	return plugin.OrderPairs(env, order), nil
+}
+
+// streamable reports whether a top-level array can be read element by
+// element with the current options
+func (p *Plugin) streamable() bool {
+	return !p.relaxed && !p.strictDuplicates && !p.concat &&
+		p.onlyPath == "" && len(p.skipPaths) == 0 && p.flatten.Arrays != utils.ArrayJoin &&
+		!p.flatten.CompactArrays
+}
+
+// startsArray reports whether the first byte after any whitespace in br
+// opens an array, consuming only the whitespace
+func startsArray(br *bufio.Reader) bool {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0] == '['
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// streamPairs collects the pairs ParseStream emits for input, keeping the
+// first position and last value of repeated keys as the converter does
+func streamPairs(p *Plugin, input string) ([]plugin.KV, error) {
+	var pairs []plugin.KV
+	index := make(map[string]int)
+	err := p.ParseStream(strings.NewReader(input), func(kv plugin.KV) error {
+		if i, ok := index[kv.Key]; ok {
+			pairs[i] = kv
+			return nil
+		}
+		index[kv.Key] = len(pairs)
+		pairs = append(pairs, kv)
+		return nil
+	})
+	return pairs, err
+}
+
+func TestPlugin_ParseStream(t *testing.T) {
+	records := `[
+		{"name": "db", "host": "localhost", "ports": [5432, 5433]},
+		{"name": "cache", "host": "redis", "opts": {"ttl": 60, "lru": true}},
+		"plain",
+		null,
+		[],
+		{},
+		{"name": "db", "host": "replica"}
+	]`
+
+	tests := []struct {
+		name  string
+		input string
+		setup func(p *Plugin)
+	}{
+		{name: "array of records", input: records},
+		{name: "keyed by field", input: records, setup: func(p *Plugin) { p.SetArrayKeyField("name") }},
+		{name: "omit empty containers", input: records, setup: func(p *Plugin) { p.SetOmitEmptyContainers(true) }},
+		{name: "joined arrays", input: records, setup: func(p *Plugin) { p.SetArrayMode(utils.ArrayJoin, ";") }},
+		{name: "skip paths", input: records, setup: func(p *Plugin) { p.SetSkipPaths([]string{"1.opts"}) }},
+		{name: "relaxed", input: "[{\"a\": 1,}, // note\n 2,]", setup: func(p *Plugin) { p.SetRelaxed(true) }},
+		{name: "object", input: `{"b": 1, "a": [1, {"c": 2}]}`},
+		{name: "empty array", input: " \n[]"},
+		{name: "empty input", input: ""},
+		{name: "BOM", input: "\xEF\xBB\xBF[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffered, streamed := New(), New()
+			if tt.setup != nil {
+				tt.setup(buffered)
+				tt.setup(streamed)
+			}
+
+			want, err := buffered.ParseOrdered(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseOrdered() error = %v", err)
+			}
+			got, err := streamPairs(streamed, tt.input)
+			if err != nil {
+				t.Fatalf("ParseStream() error = %v", err)
+			}
+			if (len(got) != 0 || len(want) != 0) && !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseStream() = %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(streamed.Warnings(), buffered.Warnings()) {
+				t.Errorf("Warnings() = %v, want %v", streamed.Warnings(), buffered.Warnings())
+			}
+		})
+	}
+}
+
+func TestPlugin_ParseStream_Incremental(t *testing.T) {
+	// Pairs from the first element are emitted before the malformed second
+	// element is read
+	var keys []string
+	err := New().ParseStream(strings.NewReader(`[{"a": 1}, {"b": ]`), func(kv plugin.KV) error {
+		keys = append(keys, kv.Key)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("ParseStream() error = nil, want a syntax error")
+	}
+	if !reflect.DeepEqual(keys, []string{"0_A"}) {
+		t.Errorf("emitted %v before the error, want [0_A]", keys)
+	}
+
+	// An error from emit stops the stream
+	stop := errors.New("stop")
+	calls := 0
+	err = New().ParseStream(strings.NewReader(`[1, 2, 3]`), func(kv plugin.KV) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ParseStream() = %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func TestPlugin_ParseStream_MaxDepth(t *testing.T) {
+	p := New()
+	p.SetMaxDepth(2)
+	if _, err := streamPairs(p, `[{"a": 1}, {"a": {"b": 1}}]`); !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("ParseStream() error = %v, want ErrMaxDepth", err)
+	}
+	if _, err := streamPairs(p, `[{"a": 1}]`); err != nil {
+		t.Errorf("ParseStream() error = %v", err)
+	}
+}
+
+// largeArray returns a JSON array of n records
+func largeArray(n int) string {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "record-%d", "tags": ["a", "b"], "meta": {"active": true, "score": %d.5}}`, i, i, i)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func BenchmarkPlugin_LargeArray(b *testing.B) {
+	input := largeArray(10000)
+
+	b.Run("ParseOrdered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := New().ParseOrdered(strings.NewReader(input)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ParseStream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			err := New().ParseStream(strings.NewReader(input), func(plugin.KV) error { return nil })
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,199 @@
+// Package k8s reads the data of Kubernetes ConfigMap and Secret manifests.
+//
+// The input is a YAML stream of one or more documents, such as the output
+// of kubectl get -o yaml; documents of kind List are searched for items.
+// From a ConfigMap, data is read as is and binaryData is base64-decoded.
+// From a Secret, data is base64-decoded and stringData is read as is,
+// overriding data as the API server does. Keys are taken as written, and
+// documents of other kinds are skipped with a warning.
+package k8s
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoData is returned when the input holds no ConfigMap or Secret
+var ErrNoData = errors.New("no ConfigMap or Secret found")
+
+// Plugin implements the plugin.Plugin interface for Kubernetes ConfigMap and
+// Secret manifests
+type Plugin struct {
+	plugin.BasePlugin
+	warnings []string
+}
+
+// New creates a new Kubernetes manifest plugin
+func New() *Plugin {
+	return &Plugin{
+		BasePlugin: plugin.NewBasePlugin("k8s", "kubernetes"),
+	}
+}
+
+// manifest holds the fields read from each document. Data fields are kept
+// as nodes so that keys can be read in source order.
+type manifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Data       yaml.Node  `yaml:"data"`
+	StringData yaml.Node  `yaml:"stringData"`
+	BinaryData yaml.Node  `yaml:"binaryData"`
+	Items      []manifest `yaml:"items"`
+}
+
+// Parse implements plugin.Plugin
+func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	kvs, err := p.ParseOrdered(r)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		env[kv.Key] = kv.Value
+	}
+	return env, nil
+}
+
+// ParseOrdered implements plugin.OrderedPlugin. Keys are returned in the
+// order they appear; a key set by more than one document keeps its first
+// position and the value of the last.
+func (p *Plugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	p.warnings = nil
+	if r == nil {
+		return nil, nil
+	}
+
+	c := &collector{env: make(map[string]string), from: make(map[string]string), warn: p.warn}
+	decoder := yaml.NewDecoder(utils.StripBOM(r))
+	for {
+		var m manifest
+		if err := decoder.Decode(&m); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if err := c.add(m); err != nil {
+			return nil, err
+		}
+	}
+	if c.found == 0 {
+		return nil, ErrNoData
+	}
+	return plugin.OrderPairs(c.env, c.order), nil
+}
+
+// Warnings implements plugin.Warner
+func (p *Plugin) Warnings() []string {
+	return p.warnings
+}
+
+// Reset implements plugin.Resetter
+func (p *Plugin) Reset() {
+	p.warnings = nil
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Ordered: true}
+}
+
+// warn records a warning for the current parse
+func (p *Plugin) warn(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
+
+// collector gathers the data of each ConfigMap and Secret
+type collector struct {
+	env   map[string]string
+	order []string
+	found int
+	warn  func(format string, args ...interface{})
+
+	// from maps each key to the manifest that set it
+	from map[string]string
+}
+
+// add collects the data of m, or of its items if it is a List
+func (c *collector) add(m manifest) error {
+	switch m.Kind {
+	case "List":
+		for _, item := range m.Items {
+			if err := c.add(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "ConfigMap":
+		c.found++
+		if err := c.set(m, "data", &m.Data, false); err != nil {
+			return err
+		}
+		return c.set(m, "binaryData", &m.BinaryData, true)
+	case "Secret":
+		c.found++
+		if err := c.set(m, "data", &m.Data, true); err != nil {
+			return err
+		}
+		return c.set(m, "stringData", &m.StringData, false)
+	case "":
+		// Empty documents, such as a trailing ---
+		return nil
+	default:
+		c.warn("skipping %s", describe(m))
+		return nil
+	}
+}
+
+// set stores the pairs of the field mapping n, decoding base64 values if
+// encoded is set
+func (c *collector) set(m manifest, field string, n *yaml.Node, encoded bool) error {
+	if n.Kind == 0 || n.Tag == "!!null" {
+		return nil
+	}
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: %s must be a map", describe(m), field)
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i].Value, n.Content[i+1]
+		if val.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s: %s.%s must be a string", describe(m), field, key)
+		}
+
+		value := val.Value
+		if encoded {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return fmt.Errorf("%s: %s.%s is not valid base64: %w", describe(m), field, key, err)
+			}
+			value = string(decoded)
+		}
+
+		name := describe(m)
+		if prev, seen := c.from[key]; !seen {
+			c.order = append(c.order, key)
+		} else if prev != name {
+			c.warn("key '%s' is set by both %s and %s", key, prev, name)
+		}
+		c.from[key] = name
+		c.env[key] = value
+	}
+	return nil
+}
+
+// describe names m in messages, such as "Secret/db-credentials"
+func describe(m manifest) string {
+	if m.Metadata.Name == "" {
+		return m.Kind
+	}
+	return m.Kind + "/" + m.Metadata.Name
+}
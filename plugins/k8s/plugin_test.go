@@ -0,0 +1,171 @@
+package k8s
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func parseFile(t *testing.T, name string) map[string]string {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+
+	got, err := New().Parse(f)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return got
+}
+
+func TestPlugin_ConfigMap(t *testing.T) {
+	want := map[string]string{
+		"LOG_LEVEL":      "debug",
+		"DATABASE_HOST":  "db.internal",
+		"app.properties": "color=blue\nsize=10\n",
+		"logo.txt":       "hello",
+	}
+	if got := parseFile(t, "configmap.yaml"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+}
+
+func TestPlugin_Secret(t *testing.T) {
+	want := map[string]string{
+		"DB_USER":     "admin",
+		"DB_PASSWORD": "override",
+		"API_TOKEN":   "plain-token",
+	}
+	if got := parseFile(t, "secret.yaml"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+}
+
+func TestPlugin_ParseOrdered(t *testing.T) {
+	input := `kind: ConfigMap
+data:
+  ZEBRA: "1"
+  APPLE: "2"
+---
+kind: Secret
+data:
+  MANGO: Mw==
+`
+	got, err := New().ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	want := []plugin.KV{{Key: "ZEBRA", Value: "1"}, {Key: "APPLE", Value: "2"}, {Key: "MANGO", Value: "3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_MultipleDocuments(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: List
+items:
+  - kind: ConfigMap
+    metadata:
+      name: base
+    data:
+      LEVEL: info
+      PORT: "8080"
+  - kind: ConfigMap
+    metadata:
+      name: override
+    data:
+      LEVEL: debug
+---
+`
+	p := New()
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := map[string]string{"LEVEL": "debug", "PORT": "8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+
+	wantWarnings := []string{
+		"skipping Deployment/app",
+		"key 'LEVEL' is set by both ConfigMap/base and ConfigMap/override",
+	}
+	if !reflect.DeepEqual(p.Warnings(), wantWarnings) {
+		t.Errorf("Warnings() = %q, want %q", p.Warnings(), wantWarnings)
+	}
+}
+
+func TestPlugin_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "no ConfigMap or Secret",
+			input:   "kind: Service\nmetadata:\n  name: web\n",
+			wantErr: ErrNoData.Error(),
+		},
+		{
+			name:    "empty input",
+			input:   "",
+			wantErr: ErrNoData.Error(),
+		},
+		{
+			name:    "invalid base64",
+			input:   "kind: Secret\nmetadata:\n  name: db\ndata:\n  PASSWORD: not base64!\n",
+			wantErr: "Secret/db: data.PASSWORD is not valid base64",
+		},
+		{
+			name:    "nested value",
+			input:   "kind: ConfigMap\nmetadata:\n  name: app\ndata:\n  DB:\n    host: x\n",
+			wantErr: "ConfigMap/app: data.DB must be a string",
+		},
+		{
+			name:    "data is not a map",
+			input:   "kind: ConfigMap\ndata: [a, b]\n",
+			wantErr: "ConfigMap: data must be a map",
+		},
+		{
+			name:    "invalid YAML",
+			input:   "kind: [ConfigMap\n",
+			wantErr: "yaml:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New().Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := New().Parse(strings.NewReader("kind: Pod\n")); !errors.Is(err, ErrNoData) {
+		t.Errorf("Parse() error = %v, want ErrNoData", err)
+	}
+}
+
+func TestPlugin_EmptyData(t *testing.T) {
+	got, err := New().Parse(strings.NewReader("kind: ConfigMap\nmetadata:\n  name: empty\ndata:\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Parse() = %q, want no keys", got)
+	}
+}
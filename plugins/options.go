@@ -0,0 +1,51 @@
+package plugins
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// Option is a format-specific option, such as a command-line flag, that
+// only applies to plugins with a given capability
+type Option struct {
+	// Name identifies the option in messages, such as "--query"
+	Name string
+
+	// Set reports whether the option was supplied
+	Set bool
+
+	// Supported reports whether a plugin with the given capabilities
+	// honours the option
+	Supported func(plugin.Capabilities) bool
+}
+
+// CheckOptions reports each supplied option in opts that p does not
+// support, and would otherwise silently ignore. A warning is written to w
+// for each one, or with strict, an error naming all of them is returned
+// instead.
+func CheckOptions(p plugin.Plugin, opts []Option, w io.Writer, strict bool) error {
+	caps := plugin.CapabilitiesOf(p)
+
+	var unsupported []string
+	for _, opt := range opts {
+		if opt.Set && !opt.Supported(caps) {
+			unsupported = append(unsupported, opt.Name)
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("not supported by the %s format: %s", p.Name(), strings.Join(unsupported, ", "))
+	}
+	for _, name := range unsupported {
+		if _, err := fmt.Fprintf(w, "Warning: %s is ignored by the %s format\n", name, p.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package plugins
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestCheckOptions(t *testing.T) {
+	query := func(set bool) Option {
+		return Option{Name: "--query", Set: set, Supported: func(c plugin.Capabilities) bool { return c.Query }}
+	}
+	paths := func(set bool) Option {
+		return Option{Name: "--skip-path", Set: set, Supported: func(c plugin.Capabilities) bool { return c.Paths }}
+	}
+
+	tests := []struct {
+		name     string
+		plugin   plugin.Plugin
+		opts     []Option
+		strict   bool
+		wantWarn string
+		wantErr  string
+	}{
+		{
+			name:     "query with yaml warns",
+			plugin:   yaml.New(),
+			opts:     []Option{query(true), paths(true)},
+			wantWarn: "Warning: --query is ignored by the yaml format\n",
+		},
+		{
+			name:    "query with yaml in strict mode fails",
+			plugin:  yaml.New(),
+			opts:    []Option{query(true)},
+			strict:  true,
+			wantErr: "not supported by the yaml format: --query",
+		},
+		{
+			name:    "every unsupported option is named",
+			plugin:  &mockPlugin{plugin.NewBasePlugin("mock")},
+			opts:    []Option{query(true), paths(true)},
+			strict:  true,
+			wantErr: "not supported by the mock format: --query, --skip-path",
+		},
+		{
+			name:   "unset options are not checked",
+			plugin: json.New(),
+			opts:   []Option{query(false), paths(true)},
+			strict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warn bytes.Buffer
+			err := CheckOptions(tt.plugin, tt.opts, &warn, tt.strict)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("CheckOptions() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("CheckOptions() error = %v", err)
+			}
+			if warn.String() != tt.wantWarn {
+				t.Errorf("warnings = %q, want %q", warn.String(), tt.wantWarn)
+			}
+		})
+	}
+}
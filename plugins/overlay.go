@@ -0,0 +1,113 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// OverlayPath returns the path of the overlay for env next to the config
+// file at path, with env inserted before the extension: config.prod.yaml
+// for config.yaml and env prod
+func OverlayPath(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// Overlay returns a plugin that parses input with p after merging each of
+// overlays over it in order, such as environment-specific settings over a
+// base config. Plugins implementing plugin.TreePlugin merge the decoded
+// documents, so maps merge recursively while scalars and arrays are
+// replaced; for other plugins the flattened keys of later documents
+// override earlier ones. Overlays are parsed with p's format and options,
+// which must be set on p before it is wrapped. The returned plugin reports
+// p's warnings, but not its source order.
+func Overlay(p plugin.Plugin, overlays ...[]byte) plugin.Plugin {
+	return &overlayPlugin{Plugin: p, overlays: overlays}
+}
+
+// overlayPlugin merges overlays over the input of the wrapped plugin
+type overlayPlugin struct {
+	plugin.Plugin
+	overlays [][]byte
+}
+
+// Parse implements plugin.Plugin
+func (o *overlayPlugin) Parse(r io.Reader) (map[string]string, error) {
+	t, ok := o.Plugin.(plugin.TreePlugin)
+	if !ok {
+		return o.parseFlat(r)
+	}
+
+	tree, err := t.DecodeTree(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range o.overlays {
+		overlay, err := t.DecodeTree(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("overlay: %w", err)
+		}
+		// An empty overlay leaves the input as it is
+		if overlay != nil {
+			tree = utils.MergeValues(tree, overlay, utils.MergeOptions{})
+		}
+	}
+	return t.FlattenTree(tree)
+}
+
+// parseFlat merges the flattened keys of the overlays over those of r
+func (o *overlayPlugin) parseFlat(r io.Reader) (map[string]string, error) {
+	env, err := o.Plugin.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range o.overlays {
+		overlay, err := o.Plugin.Parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("overlay: %w", err)
+		}
+		for k, v := range overlay {
+			env[k] = v
+		}
+	}
+	return env, nil
+}
+
+// Warnings implements plugin.Warner
+func (o *overlayPlugin) Warnings() []string {
+	if w, ok := o.Plugin.(plugin.Warner); ok {
+		return w.Warnings()
+	}
+	return nil
+}
+
+// Paths implements plugin.PathReporter
+func (o *overlayPlugin) Paths() map[string]string {
+	if pr, ok := o.Plugin.(plugin.PathReporter); ok {
+		return pr.Paths()
+	}
+	return nil
+}
+
+// Reset implements plugin.Resetter
+func (o *overlayPlugin) Reset() {
+	if rs, ok := o.Plugin.(plugin.Resetter); ok {
+		rs.Reset()
+	}
+}
+
+// Capabilities implements plugin.Capable. Merged input has no source order,
+// so neither order nor comments are reported.
+func (o *overlayPlugin) Capabilities() plugin.Capabilities {
+	caps := plugin.CapabilitiesOf(o.Plugin)
+	caps.Ordered = false
+	caps.Comments = false
+	caps.Cancel = false
+	return caps
+}
@@ -0,0 +1,105 @@
+package plugins
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/dotenv"
+	"github.com/handaber/cfg2env/plugins/json"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+func TestOverlayPath(t *testing.T) {
+	tests := []struct {
+		path, env, want string
+	}{
+		{"config.yaml", "prod", "config.prod.yaml"},
+		{"configs/app.json", "staging", "configs/app.staging.json"},
+		{"settings", "prod", "settings.prod"},
+	}
+	for _, tt := range tests {
+		if got := OverlayPath(tt.path, tt.env); got != tt.want {
+			t.Errorf("OverlayPath(%q, %q) = %q, want %q", tt.path, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   plugin.Plugin
+		base     string
+		overlays []string
+		want     map[string]string
+	}{
+		{
+			name:     "yaml adds, overrides and leaves keys",
+			plugin:   yaml.New(),
+			base:     "db:\n  host: localhost\n  port: 5432\nhosts: [a, b, c]\nname: app\n",
+			overlays: []string{"db:\n  host: prod-db\n  user: admin\nhosts: [x]\n"},
+			want: map[string]string{
+				"DB_HOST": "prod-db", "DB_PORT": "5432", "DB_USER": "admin",
+				"HOSTS_0": "x", "NAME": "app",
+			},
+		},
+		{
+			name:     "json overlays apply in order",
+			plugin:   json.New(),
+			base:     `{"db": {"host": "localhost", "port": 5432}}`,
+			overlays: []string{`{"db": {"host": "staging"}}`, `{"db": {"port": 6543}}`},
+			want:     map[string]string{"DB_HOST": "staging", "DB_PORT": "6543"},
+		},
+		{
+			name:     "empty overlay",
+			plugin:   yaml.New(),
+			base:     "a: 1\n",
+			overlays: []string{""},
+			want:     map[string]string{"A": "1"},
+		},
+		{
+			name:     "flat formats override keys",
+			plugin:   dotenv.New(),
+			base:     "HOST=localhost\nPORT=5432\n",
+			overlays: []string{"HOST=prod-db\n"},
+			want:     map[string]string{"HOST": "prod-db", "PORT": "5432"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var overlays [][]byte
+			for _, o := range tt.overlays {
+				overlays = append(overlays, []byte(o))
+			}
+			p := Overlay(tt.plugin, overlays...)
+			got, err := p.Parse(strings.NewReader(tt.base))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			// Flat formats keep the keys as written
+			upper := make(map[string]string, len(got))
+			for k, v := range got {
+				upper[strings.ToUpper(k)] = v
+			}
+			if !reflect.DeepEqual(upper, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+			if p.Name() != tt.plugin.Name() {
+				t.Errorf("Name() = %q, want %q", p.Name(), tt.plugin.Name())
+			}
+		})
+	}
+}
+
+func TestOverlay_Errors(t *testing.T) {
+	p := Overlay(yaml.New(), []byte("a: [unclosed\n"))
+	if _, err := p.Parse(strings.NewReader("a: 1\n")); err == nil || !strings.Contains(err.Error(), "overlay") {
+		t.Errorf("Parse() error = %v, want an overlay error", err)
+	}
+
+	if plugin.CapabilitiesOf(p).Ordered {
+		t.Error("Capabilities() reports source order for merged input")
+	}
+}
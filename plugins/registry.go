@@ -1,59 +1,158 @@
+// Package plugins holds the registry of format plugins.
+//
+// The built-in plugins are registered with RegisterConstructor, so Get
+// returns a new plugin each time and options set on it, such as a SQLite
+// query, never carry over to another conversion. They are registered by
+// this package's init functions, which Go runs before the init functions of
+// any package importing it. A program embedding cfg2env can therefore call
+// Register or RegisterConstructor from its own init or main to add a
+// format, or to replace a built-in one by registering a plugin under the
+// same name; the last registration of a name or extension wins,
+// and a plugin replacing the default YAML plugin also becomes the default
+// for stdin. The registry is safe for concurrent use.
 package plugins
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/dotenv"
 	"github.com/handaber/cfg2env/plugins/json"
-	"github.com/handaber/cfg2env/plugins/sqlite"
+	"github.com/handaber/cfg2env/plugins/k8s"
+	"github.com/handaber/cfg2env/plugins/ssm"
+	"github.com/handaber/cfg2env/plugins/systemd"
 	"github.com/handaber/cfg2env/plugins/yaml"
 )
 
+// Constructor returns a new plugin, configured with its defaults
+type Constructor func() plugin.Plugin
+
+// entry is a registered plugin: its name and the constructor creating it
+type entry struct {
+	name       string
+	extensions []string
+	newPlugin  Constructor
+}
+
 var (
-	// registry holds all registered plugins
-	registry = make(map[string]plugin.Plugin)
+	// mu guards registry and defaultPlugin
+	mu sync.RWMutex
+
+	// registry maps plugin names and extensions to their entries
+	registry = make(map[string]*entry)
 
 	// defaultPlugin is the plugin to use when no format is specified
-	defaultPlugin plugin.Plugin
+	defaultPlugin *entry
 )
 
-// Register adds a plugin to the registry
+// Register adds p to the registry under its name and extensions. Get
+// returns p itself, shared by every conversion, so p should not keep
+// options or state that one conversion sets for itself; use
+// RegisterConstructor for plugins that do. Entries are lowercased, and
+// extensions repeating the name or an earlier extension are skipped, so
+// plugins not built on plugin.BasePlugin get the same entries.
 func Register(p plugin.Plugin) {
+	RegisterConstructor(func() plugin.Plugin { return p })
+}
+
+// RegisterConstructor adds the plugin created by newPlugin to the registry
+// as Register does, except that Get calls newPlugin for a new plugin each
+// time. newPlugin is also called once here to read the name and extensions.
+func RegisterConstructor(newPlugin Constructor) {
+	p := newPlugin()
+
+	mu.Lock()
+	defer mu.Unlock()
+
 	// Register by name
-	registry[p.Name()] = p
+	name := strings.ToLower(p.Name())
+	e := &entry{name: name, newPlugin: newPlugin}
+	replacesDefault := isDefault(name)
+	registry[name] = e
 
 	// Register by extensions
+	seen := map[string]bool{name: true}
 	for _, ext := range p.Extensions() {
-		registry[ext] = p
+		ext = strings.ToLower(ext)
+		if ext == "" || seen[ext] {
+			continue
+		}
+		seen[ext] = true
+		e.extensions = append(e.extensions, ext)
+		replacesDefault = replacesDefault || isDefault(ext)
+		registry[ext] = e
 	}
 
-	// Set as default if it's the first YAML plugin
-	if defaultPlugin == nil && p.CanHandle("yaml") {
-		defaultPlugin = p
+	// Set as default if it's the first YAML plugin, or if it takes over the
+	// name or an extension of the default plugin
+	if (defaultPlugin == nil && p.CanHandle("yaml")) || replacesDefault {
+		defaultPlugin = e
 	}
 }
 
-// Get returns a plugin for the specified format
+// isDefault reports whether the registry entry for format is the default
+// plugin. The caller must hold mu.
+func isDefault(format string) bool {
+	e, ok := registry[format]
+	return ok && defaultPlugin != nil && e.name == defaultPlugin.name
+}
+
+// Get returns a new plugin for the specified format. Formats are matched
+// case-insensitively, so "JSON" and "Json" both find the JSON plugin.
 func Get(format string) (plugin.Plugin, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	// If no format specified, use default
 	if format == "" {
 		if defaultPlugin == nil {
 			return nil, fmt.Errorf("no default plugin available")
 		}
-		return defaultPlugin, nil
+		return defaultPlugin.newPlugin(), nil
 	}
 
 	// Look up plugin by format
-	if p, ok := registry[format]; ok {
-		return p, nil
+	if e, ok := registry[strings.ToLower(format)]; ok {
+		return e.newPlugin(), nil
 	}
 
 	return nil, fmt.Errorf("unsupported format: %s", format)
 }
 
-// init registers all built-in plugins
+// Unregister removes the plugin registered under name, along with the
+// extensions still pointing to it, so that tests or embedding programs can
+// drop or replace a format. If it was the default plugin, Get("") fails
+// until another YAML plugin is registered. Unknown names are ignored.
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	name = strings.ToLower(name)
+	e, ok := registry[name]
+	if !ok || e.name != name {
+		return
+	}
+
+	delete(registry, name)
+	for _, ext := range e.extensions {
+		if q, ok := registry[ext]; ok && q.name == e.name {
+			delete(registry, ext)
+		}
+	}
+	if defaultPlugin != nil && defaultPlugin.name == e.name {
+		defaultPlugin = nil
+	}
+}
+
+// init registers the built-in pure-Go plugins. SQLite is registered in
+// registry_sqlite.go unless built with the "nosqlite" tag.
 func init() {
-	Register(yaml.New())
-	Register(json.New())
-	Register(sqlite.New())
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+	RegisterConstructor(func() plugin.Plugin { return dotenv.New() })
+	RegisterConstructor(func() plugin.Plugin { return systemd.New() })
+	RegisterConstructor(func() plugin.Plugin { return k8s.New() })
+	RegisterConstructor(func() plugin.Plugin { return ssm.New() })
 }
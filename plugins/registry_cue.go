@@ -0,0 +1,13 @@
+//go:build cue
+
+package plugins
+
+import (
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/cue"
+)
+
+// init registers the CUE plugin when built with the "cue" tag
+func init() {
+	RegisterConstructor(func() plugin.Plugin { return cue.New() })
+}
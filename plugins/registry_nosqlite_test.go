@@ -0,0 +1,13 @@
+//go:build nosqlite
+
+package plugins
+
+import "testing"
+
+func TestBuiltinPlugins_NoSQLite(t *testing.T) {
+	for _, format := range []string{"sqlite", "db", "sqlite3"} {
+		if _, err := Get(format); err == nil {
+			t.Errorf("Get(%q) succeeded in a nosqlite build, want unsupported format error", format)
+		}
+	}
+}
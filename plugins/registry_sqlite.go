@@ -0,0 +1,19 @@
+//go:build !nosqlite
+
+package plugins
+
+import (
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/sqlite"
+)
+
+// init registers the SQLite plugin, which needs cgo. Build with the
+// "nosqlite" tag to leave it out of a pure-Go binary.
+func init() {
+	registerSQLite()
+}
+
+// registerSQLite adds the SQLite plugin to the registry
+func registerSQLite() {
+	RegisterConstructor(func() plugin.Plugin { return sqlite.New() })
+}
@@ -0,0 +1,82 @@
+//go:build !nosqlite
+
+package plugins
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/sqlite"
+	"github.com/handaber/cfg2env/plugins/yaml"
+)
+
+// sqliteBlob returns the bytes of a small SQLite config database
+func sqliteBlob(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.db")
+	db, err := sql.Open(sqlite.DriverName, path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE config (key TEXT, value TEXT); INSERT INTO config VALUES ('host', 'localhost')"); err != nil {
+		db.Close()
+		t.Fatalf("Failed to set up test data: %v", err)
+	}
+	db.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+	return string(data)
+}
+
+func TestBuiltinPlugins_SQLite(t *testing.T) {
+	for _, format := range []string{"sqlite", "db", "sqlite3"} {
+		got, err := Get(format)
+		if err != nil {
+			t.Errorf("Get(%q) error = %v", format, err)
+			continue
+		}
+		if got.Name() != "sqlite" {
+			t.Errorf("Get(%q) = %v, want sqlite", format, got.Name())
+		}
+	}
+
+	// SQLite does not replace YAML as the default
+	if got, err := Get(""); err != nil || got.Name() != "yaml" {
+		t.Errorf("Get(\"\") = %v, %v, want yaml", got, err)
+	}
+}
+
+func TestDetect_SQLite(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	registerSQLite()
+
+	blob := sqliteBlob(t)
+	p, r, err := Detect(strings.NewReader(blob))
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if p.Name() != "sqlite" {
+		t.Errorf("Detect() = %v, want sqlite", p.Name())
+	}
+
+	// The returned reader replays the sniffed bytes
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(got) != blob {
+		t.Errorf("Detect() reader lost data: got %d bytes, want %d", len(got), len(blob))
+	}
+}
+
+func TestCapabilities_SQLite(t *testing.T) {
+	checkCapabilities(t, sqlite.New(), plugin.Capabilities{Query: true, Table: true, Cancel: true})
+}
@@ -2,11 +2,13 @@ package plugins
 
 import (
 	"io"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/handaber/cfg2env/plugin"
+	"github.com/handaber/cfg2env/plugins/dotenv"
 	"github.com/handaber/cfg2env/plugins/json"
-	"github.com/handaber/cfg2env/plugins/sqlite"
 	"github.com/handaber/cfg2env/plugins/yaml"
 )
 
@@ -19,10 +21,21 @@ func (p mockPlugin) Parse(r io.Reader) (map[string]string, error) {
 	return make(map[string]string), nil
 }
 
+// resetRegistry clears the registry for the duration of the test, restoring
+// the plugins registered by init afterwards
+func resetRegistry(t *testing.T) {
+	t.Helper()
+	saved, savedDefault := registry, defaultPlugin
+	t.Cleanup(func() {
+		registry, defaultPlugin = saved, savedDefault
+	})
+	registry = make(map[string]*entry)
+	defaultPlugin = nil
+}
+
 func TestRegistry(t *testing.T) {
 	// Clear registry for testing
-	registry = make(map[string]plugin.Plugin)
-	defaultPlugin = nil
+	resetRegistry(t)
 
 	// Create test plugins
 	yamlPlugin := mockPlugin{plugin.NewBasePlugin("yaml", "yml", "yaml")}
@@ -76,8 +89,7 @@ func TestRegistry(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Reset registry
-			registry = make(map[string]plugin.Plugin)
-			defaultPlugin = nil
+			resetRegistry(t)
 
 			// Register plugins
 			for _, p := range tt.register {
@@ -101,16 +113,7 @@ func TestRegistry(t *testing.T) {
 }
 
 func TestBuiltinPlugins(t *testing.T) {
-	// Reset registry to ensure clean state
-	registry = make(map[string]plugin.Plugin)
-	defaultPlugin = nil
-
-	// Register built-in plugins
-	Register(yaml.New())
-	Register(json.New())
-	Register(sqlite.New())
-
-	// Test that all built-in plugins are registered
+	// Test that the pure-Go plugins are registered by init
 	tests := []struct {
 		format string
 		want   string
@@ -118,9 +121,12 @@ func TestBuiltinPlugins(t *testing.T) {
 		{"yaml", "yaml"},
 		{"yml", "yaml"},
 		{"json", "json"},
-		{"sqlite", "sqlite"},
-		{"db", "sqlite"},
-		{"sqlite3", "sqlite"},
+		{"dotenv", "dotenv"},
+		{"env", "dotenv"},
+		{"systemd", "systemd"},
+		{"envfile", "systemd"},
+		{"k8s", "k8s"},
+		{"kubernetes", "k8s"},
 		{"", "yaml"}, // default plugin
 	}
 
@@ -137,3 +143,242 @@ func TestBuiltinPlugins(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistry_WithoutSQLite(t *testing.T) {
+	// Register only what a binary built with -tags nosqlite has
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+	RegisterConstructor(func() plugin.Plugin { return dotenv.New() })
+
+	for _, format := range []string{"sqlite", "db", "sqlite3"} {
+		if _, err := Get(format); err == nil {
+			t.Errorf("Get(%q) succeeded, want unsupported format error", format)
+		}
+	}
+	for format, want := range map[string]string{"": "yaml", "json": "json", "env": "dotenv"} {
+		got, err := Get(format)
+		if err != nil {
+			t.Errorf("Get(%q) error = %v", format, err)
+			continue
+		}
+		if got.Name() != want {
+			t.Errorf("Get(%q) = %v, want %v", format, got.Name(), want)
+		}
+	}
+
+	// SQLite input is still recognized, but reported as unsupported
+	_, _, err := Detect(strings.NewReader(sqliteHeader + "rest of the database"))
+	if err == nil || err.Error() != "unsupported format: sqlite" {
+		t.Errorf("Detect() error = %v, want unsupported format: sqlite", err)
+	}
+}
+
+// rawPlugin reports its extensions as given, bypassing the normalization in
+// plugin.NewBasePlugin
+type rawPlugin struct {
+	mockPlugin
+	exts []string
+}
+
+func (p rawPlugin) Extensions() []string {
+	return p.exts
+}
+
+func TestRegistry_DuplicateExtensions(t *testing.T) {
+	p := mockPlugin{plugin.NewBasePlugin("yaml", "YML", "yaml", "yml", "Yaml")}
+	if got := p.Extensions(); len(got) != 1 || got[0] != "yml" {
+		t.Errorf("Extensions() = %q, want [yml]", got)
+	}
+
+	for _, p := range []plugin.Plugin{p, rawPlugin{p, []string{"YML", "yaml", "yml", ""}}} {
+		resetRegistry(t)
+		Register(p)
+
+		if len(registry) != 2 {
+			t.Errorf("registry has %d entries, want 2 (yaml, yml): %v", len(registry), registry)
+		}
+		for _, format := range []string{"yaml", "yml"} {
+			if got, err := Get(format); err != nil || got.Name() != "yaml" {
+				t.Errorf("Get(%q) = %v, %v; want yaml", format, got, err)
+			}
+		}
+		if _, ok := registry[""]; ok {
+			t.Error("registry has an entry for the empty format")
+		}
+	}
+}
+
+func TestGet_CaseInsensitive(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"YAML", "yaml"},
+		{"Yml", "yaml"},
+		{"JSON", "json"},
+		{"Json", "json"},
+		{"DotEnv", "dotenv"},
+		{"ENV", "dotenv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := Get(tt.format)
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", tt.format, err)
+			}
+			if got.Name() != tt.want {
+				t.Errorf("Get(%q) = %v, want %v", tt.format, got.Name(), tt.want)
+			}
+		})
+	}
+
+	// Unknown formats are reported as given
+	if _, err := Get("TOML"); err == nil || err.Error() != "unsupported format: TOML" {
+		t.Errorf("Get(TOML) error = %v, want unsupported format: TOML", err)
+	}
+}
+
+func TestRegister_Unregister(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	// A custom format registered after the built-ins
+	custom := mockPlugin{plugin.NewBasePlugin("props", "properties", "props")}
+	Register(custom)
+	for _, format := range []string{"props", "properties", "PROPS"} {
+		if got, err := Get(format); err != nil || got.Name() != "props" {
+			t.Fatalf("Get(%q) = %v, %v; want props", format, got, err)
+		}
+	}
+
+	Unregister("props")
+	for _, format := range []string{"props", "properties"} {
+		if _, err := Get(format); err == nil {
+			t.Errorf("Get(%q) succeeded after Unregister", format)
+		}
+	}
+	if got, err := Get("json"); err != nil || got.Name() != "json" {
+		t.Errorf("Get(json) = %v, %v; want json to stay registered", got, err)
+	}
+
+	// Unknown names and extensions leave the registry alone
+	Unregister("props")
+	Unregister("yml")
+	if got, err := Get("yml"); err != nil || got.Name() != "yaml" {
+		t.Errorf("Get(yml) = %v, %v; want yaml", got, err)
+	}
+
+	// Removing the default plugin leaves no default
+	Unregister("YAML")
+	if _, err := Get(""); err == nil {
+		t.Error("Get(\"\") succeeded after unregistering the default plugin")
+	}
+	if _, err := Get("yml"); err == nil {
+		t.Error("Get(yml) succeeded after unregistering yaml")
+	}
+}
+
+func TestRegister_Override(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	// Registering the same name again replaces the built-in plugin
+	override := &mockPlugin{plugin.NewBasePlugin("json", "json5")}
+	Register(override)
+	for _, format := range []string{"json", "json5"} {
+		got, err := Get(format)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", format, err)
+		}
+		if got != plugin.Plugin(override) {
+			t.Errorf("Get(%q) = %T, want the override", format, got)
+		}
+	}
+}
+
+func TestRegister_OverrideDefault(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	// Replacing yaml also replaces the default used for stdin
+	override := &mockPlugin{plugin.NewBasePlugin("yaml", "yml")}
+	Register(override)
+	if got, err := Get(""); err != nil || got != plugin.Plugin(override) {
+		t.Errorf("Get(\"\") = %T, %v; want the override", got, err)
+	}
+	if got, _, err := Detect(strings.NewReader("a: 1\n")); err != nil || got != plugin.Plugin(override) {
+		t.Errorf("Detect() = %T, %v; want the override", got, err)
+	}
+
+	// Taking over only an extension of the default replaces it too
+	yml := &mockPlugin{plugin.NewBasePlugin("yml2", "yml")}
+	Register(yml)
+	if got, err := Get(""); err != nil || got != plugin.Plugin(yml) {
+		t.Errorf("Get(\"\") = %T, %v; want the plugin registered for yml", got, err)
+	}
+
+	// Other plugins leave the default alone
+	Register(mockPlugin{plugin.NewBasePlugin("props", "properties")})
+	if got, err := Get(""); err != nil || got != plugin.Plugin(yml) {
+		t.Errorf("Get(\"\") = %T, %v; want the default unchanged", got, err)
+	}
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return yaml.New() })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Register(mockPlugin{plugin.NewBasePlugin("custom", "cst")})
+			Unregister("custom")
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := Get("yaml"); err != nil {
+				t.Errorf("Get(yaml) error = %v", err)
+			}
+			Get("custom")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGet_NewInstance(t *testing.T) {
+	resetRegistry(t)
+	RegisterConstructor(func() plugin.Plugin { return json.New() })
+
+	// Options set on one plugin do not reach the next one Get returns
+	first, err := Get("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.(*json.Plugin).SetSkipPaths([]string{"skip"})
+	second, err := Get("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("Get() returned the same plugin twice")
+	}
+	env, err := second.Parse(strings.NewReader(`{"skip": 1, "keep": 2}`))
+	if err != nil || env["SKIP"] != "1" {
+		t.Errorf("Parse() = %v, %v; want SKIP kept by a new plugin", env, err)
+	}
+
+	// A plugin registered as a value is shared by every Get
+	shared := &mockPlugin{plugin.NewBasePlugin("props", "properties")}
+	Register(shared)
+	for _, format := range []string{"props", "properties"} {
+		if got, err := Get(format); err != nil || got != plugin.Plugin(shared) {
+			t.Errorf("Get(%q) = %v, %v; want the registered plugin", format, got, err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !modernc
+
+package sqlite
+
+import _ "github.com/mattn/go-sqlite3"
+
+// DriverName is the database/sql driver the plugin opens databases with.
+// The default cgo driver, github.com/mattn/go-sqlite3, is the fastest; build
+// with the "modernc" tag to use the pure-Go modernc.org/sqlite instead.
+const DriverName = "sqlite3"
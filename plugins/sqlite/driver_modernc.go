@@ -0,0 +1,12 @@
+//go:build modernc
+
+package sqlite
+
+import _ "modernc.org/sqlite"
+
+// DriverName is the database/sql driver the plugin opens databases with.
+// Built with the "modernc" tag, the plugin uses the pure-Go
+// modernc.org/sqlite driver, which needs no cgo:
+//
+//	CGO_ENABLED=0 go build -tags modernc
+const DriverName = "sqlite"
@@ -0,0 +1,13 @@
+//go:build modernc
+
+package sqlite
+
+import "testing"
+
+// The rest of the package's tests run against the driver the plugin is
+// built with, so "go test -tags modernc" exercises the pure-Go driver
+func TestDriverName_Modernc(t *testing.T) {
+	if DriverName != "sqlite" {
+		t.Errorf("DriverName = %q, want the modernc.org/sqlite driver %q", DriverName, "sqlite")
+	}
+}
@@ -1,32 +1,80 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 
+	"github.com/handaber/cfg2env/lib/utils"
 	"github.com/handaber/cfg2env/plugin"
-	_ "github.com/mattn/go-sqlite3"
 )
 
+// defaultTable is the table read when no table or query is set
+const defaultTable = "config"
+
+// columnPairs lists the key and value column names tried, in order, when
+// the table has no key and value columns
+var columnPairs = [][2]string{
+	{"key", "value"},
+	{"name", "val"},
+	{"setting", "data"},
+}
+
 // Plugin implements the plugin.Plugin interface for SQLite format
 type Plugin struct {
 	plugin.BasePlugin
-	query string
+	queries []string
+	table   string
+}
+
+// Option configures a Plugin created with New
+type Option func(*Plugin)
+
+// WithQuery sets custom queries, as SetQuery does
+func WithQuery(query string) Option {
+	return func(p *Plugin) {
+		p.SetQuery(query)
+	}
+}
+
+// WithTable sets the table to read, as SetTable does
+func WithTable(table string) Option {
+	return func(p *Plugin) {
+		p.SetTable(table)
+	}
 }
 
-// New creates a new SQLite plugin
-func New() *Plugin {
-	return &Plugin{
+// New creates a new SQLite plugin configured with opts, such as
+// New(WithTable("settings")). Options are applied in order, and the
+// setters may still be called afterwards.
+func New(opts ...Option) *Plugin {
+	p := &Plugin{
 		BasePlugin: plugin.NewBasePlugin("sqlite", "db", "sqlite", "sqlite3"),
-		query:      "SELECT key, value FROM config",
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Query: true, Table: true, Cancel: true}
 }
 
 // Parse implements plugin.Plugin
 func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	return p.ParseContext(context.Background(), r)
+}
+
+// ParseContext implements plugin.ContextPlugin. Queries are cancelled when
+// ctx is done.
+func (p *Plugin) ParseContext(ctx context.Context, r io.Reader) (map[string]string, error) {
 	// Handle empty input
 	if r == nil {
 		return make(map[string]string), nil
@@ -57,35 +105,223 @@ func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
 	}
 
 	// Open the database
-	db, err := sql.Open("sqlite3", tmpfile.Name())
+	db, err := sql.Open(DriverName, tmpfile.Name())
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	// Query the database
-	rows, err := db.Query(p.query)
+	// Read the results into a map, with later queries overriding earlier ones
+	env := make(map[string]string)
+	if len(p.queries) == 0 {
+		rows, err := p.queryRows(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		return env, readRows(rows, env)
+	}
+	for i, query := range p.queries {
+		rows, err := db.QueryContext(ctx, query)
+		if err == nil {
+			err = readRows(rows, env)
+		}
+		if err != nil {
+			if len(p.queries) == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("query %d (%s): %w", i+1, query, err)
+		}
+	}
+	return env, nil
+}
+
+// readRows reads key and value pairs from rows into env and closes rows
+func readRows(rows *sql.Rows, env map[string]string) error {
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var value interface{}
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		env[strings.ToUpper(key)] = formatValue(value)
+	}
+	return rows.Err()
+}
+
+// formatValue converts a scanned column value to a string. NULL becomes the
+// empty string, blobs are hex-encoded and other types use utils.ToString.
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return hex.EncodeToString(val)
+	default:
+		return utils.ToString(val)
+	}
+}
+
+// queryRows reads the key and value columns of the table, falling back to
+// detecting the columns, and the table when none was set, from the schema
+func (p *Plugin) queryRows(ctx context.Context, db *sql.DB) (*sql.Rows, error) {
+	table := p.table
+	if table == "" {
+		table = defaultTable
+	}
+	// Column names are left unquoted here, since SQLite reads a quoted name
+	// that matches no column as a string literal
+	rows, err := db.QueryContext(ctx, "SELECT key, value FROM "+quoteIdent(table))
+	if err == nil {
+		return rows, nil
+	}
+
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	query, derr := p.detectQuery(ctx, db)
+	if derr != nil {
+		return nil, derr
+	}
+	return db.QueryContext(ctx, query)
+}
+
+// detectQuery inspects the schema for a table with a known pair of key and
+// value columns. Only the configured table is inspected when one is set;
+// otherwise all tables are tried in name order.
+func (p *Plugin) detectQuery(ctx context.Context, db *sql.DB) (string, error) {
+	tables := []string{p.table}
+	if p.table == "" {
+		var err error
+		if tables, err = listTables(ctx, db); err != nil {
+			return "", err
+		}
+	}
+
+	for _, table := range tables {
+		columns, err := tableColumns(ctx, db, table)
+		if err != nil {
+			return "", err
+		}
+		for _, pair := range columnPairs {
+			key, kok := columns[pair[0]]
+			value, vok := columns[pair[1]]
+			if kok && vok {
+				return selectQuery(table, key, value), nil
+			}
+		}
+	}
+
+	if p.table != "" {
+		return "", fmt.Errorf("no key/value columns found in table %q (tried %s)", p.table, pairNames())
+	}
+	return "", fmt.Errorf("no table with key/value columns found (tried %s); use --table or --query", pairNames())
+}
+
+// listTables returns the names of the user tables in db, sorted by name
+func listTables(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Read the results into a map
-	env := make(map[string]string)
+	var tables []string
 	for rows.Next() {
-		var key, value string
-		if err := rows.Scan(&key, &value); err != nil {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// tableColumns maps the lowercased column names of table to their declared names
+func tableColumns(ctx context.Context, db *sql.DB, table string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
-		env[strings.ToUpper(key)] = value
+		columns[strings.ToLower(name)] = name
 	}
+	return columns, rows.Err()
+}
+
+// selectQuery builds a query reading the key and value columns of table
+func selectQuery(table, key, value string) string {
+	return fmt.Sprintf("SELECT %s, %s FROM %s", quoteIdent(key), quoteIdent(value), quoteIdent(table))
+}
+
+// quoteIdent quotes an SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
 
-	return env, rows.Err()
+// pairNames lists the column pairs tried during detection
+func pairNames() string {
+	names := make([]string, len(columnPairs))
+	for i, pair := range columnPairs {
+		names[i] = pair[0] + "/" + pair[1]
+	}
+	return strings.Join(names, ", ")
 }
 
-// SetQuery sets a custom query for the plugin
+// SetQuery sets custom queries for the plugin, replacing any set before.
+// Several queries may be separated by ";"; each must select a key and a value
+// column and later queries override keys from earlier ones. An empty query
+// restores the default queries.
 func (p *Plugin) SetQuery(query string) {
-	if query != "" {
-		p.query = query
+	p.queries = splitQueries(query)
+}
+
+// AddQuery appends custom queries, separated by ";", to those already set
+func (p *Plugin) AddQuery(query string) {
+	p.queries = append(p.queries, splitQueries(query)...)
+}
+
+// splitQueries splits s into statements on semicolons outside quoted strings
+// and identifiers, dropping empty statements
+func splitQueries(s string) []string {
+	var queries []string
+	var quote rune
+	start := 0
+	add := func(end int) {
+		if q := strings.TrimSpace(s[start:end]); q != "" {
+			queries = append(queries, q)
+		}
+	}
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			// A doubled quote closes and reopens the string, so it needs no
+			// special handling
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '[':
+			quote = ']'
+		case r == ';':
+			add(i)
+			start = i + 1
+		}
 	}
+	add(len(s))
+	return queries
+}
+
+// SetTable sets the table to read key and value columns from. It is
+// ignored when a custom query is set.
+func (p *Plugin) SetTable(table string) {
+	p.table = table
 }
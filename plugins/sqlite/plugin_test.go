@@ -1,15 +1,26 @@
+//go:build !nosqlite
+
 package sqlite
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/handaber/cfg2env/lib/converter"
 )
 
 func setupTestDB(t *testing.T) string {
+	return setupTestDBFrom(t, "testdata/config.sql")
+}
+
+// setupTestDBFrom creates a temporary database from the SQL script at path
+func setupTestDBFrom(t *testing.T, path string) string {
 	// Create a temporary database file
 	tmpfile, err := os.CreateTemp("", "cfg2env-test-*.db")
 	if err != nil {
@@ -18,7 +29,7 @@ func setupTestDB(t *testing.T) string {
 	tmpfile.Close()
 
 	// Open the database
-	db, err := sql.Open("sqlite3", tmpfile.Name())
+	db, err := sql.Open(DriverName, tmpfile.Name())
 	if err != nil {
 		os.Remove(tmpfile.Name())
 		t.Fatalf("Failed to open database: %v", err)
@@ -26,7 +37,7 @@ func setupTestDB(t *testing.T) string {
 	defer db.Close()
 
 	// Read and execute SQL script
-	sqlScript, err := os.ReadFile("testdata/config.sql")
+	sqlScript, err := os.ReadFile(path)
 	if err != nil {
 		os.Remove(tmpfile.Name())
 		t.Fatalf("Failed to read SQL script: %v", err)
@@ -136,3 +147,427 @@ func TestPlugin_Parse_InvalidDatabase(t *testing.T) {
 		t.Error("Parse() error = nil, want error for invalid database")
 	}
 }
+
+func TestPlugin_Parse_TypedColumns(t *testing.T) {
+	dbPath := setupTestDBFrom(t, "testdata/typed.sql")
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  map[string]string
+	}{
+		{
+			name:  "integer column",
+			query: "SELECT key, value FROM int_config",
+			want: map[string]string{
+				"DATABASE_PORT": "5432",
+				"MAX_BYTES":     "9007199254740993",
+				"OFFSET":        "-1",
+			},
+		},
+		{
+			name:  "real column",
+			query: "SELECT key, value FROM real_config",
+			want: map[string]string{
+				"RATIO":   "0.75",
+				"TIMEOUT": "30",
+			},
+		},
+		{
+			name:  "blob column",
+			query: "SELECT key, value FROM blob_config",
+			want: map[string]string{
+				"SIGNATURE": "deadbeef",
+				"EMPTY":     "",
+			},
+		},
+		{
+			name:  "null values",
+			query: "SELECT key, value FROM null_config",
+			want: map[string]string{
+				"DATABASE_HOST":     "localhost",
+				"DATABASE_PASSWORD": "",
+				"API_TOKEN":         "",
+			},
+		},
+		{
+			name:  "untyped column",
+			query: "SELECT key, value FROM mixed_config",
+			want: map[string]string{
+				"NAME":    "demo",
+				"RETRIES": "3",
+				"WEIGHT":  "1.5",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetQuery(tt.query)
+
+			got, err := p.Parse(strings.NewReader(string(dbContent)))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_Parse_NullPruneEmpty(t *testing.T) {
+	dbPath := setupTestDBFrom(t, "testdata/typed.sql")
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	p := New()
+	p.SetQuery("SELECT key, value FROM null_config")
+
+	c := converter.New(p)
+	c.SetPruneEmpty(true)
+
+	var out bytes.Buffer
+	if err := c.Convert(bytes.NewReader(dbContent), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	got := out.String()
+	if !strings.Contains(got, "DATABASE_HOST=localhost\n") {
+		t.Errorf("output missing DATABASE_HOST:\n%s", got)
+	}
+	for _, key := range []string{"DATABASE_PASSWORD", "API_TOKEN"} {
+		if strings.Contains(got, key+"=") {
+			t.Errorf("NULL key %s was not pruned:\n%s", key, got)
+		}
+	}
+}
+
+func TestPlugin_Parse_DetectColumns(t *testing.T) {
+	dbPath := setupTestDBFrom(t, "testdata/columns.sql")
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		table   string
+		query   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "no table detects first matching table",
+			table: "",
+			want: map[string]string{
+				"DATABASE_HOST": "localhost",
+				"DATABASE_PORT": "5432",
+			},
+		},
+		{
+			name:  "key and value columns",
+			table: "overrides",
+			want:  map[string]string{"LOG_LEVEL": "debug"},
+		},
+		{
+			name:  "setting and data columns in any case",
+			table: "prefs",
+			want:  map[string]string{"THEME": "dark"},
+		},
+		{
+			name:    "no matching columns",
+			table:   "misc",
+			wantErr: `no key/value columns found in table "misc"`,
+		},
+		{
+			name:    "missing table",
+			table:   "nope",
+			wantErr: `no key/value columns found in table "nope"`,
+		},
+		{
+			name:  "query takes precedence over table",
+			table: "misc",
+			query: "SELECT key, value FROM overrides",
+			want:  map[string]string{"LOG_LEVEL": "debug"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetTable(tt.table)
+			p.SetQuery(tt.query)
+
+			got, err := p.Parse(bytes.NewReader(dbContent))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_Options(t *testing.T) {
+	dbPath := setupTestDBFrom(t, "testdata/columns.sql")
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]string
+	}{
+		{
+			name: "no options",
+			want: map[string]string{"DATABASE_HOST": "localhost", "DATABASE_PORT": "5432"},
+		},
+		{
+			name: "with table",
+			opts: []Option{WithTable("prefs")},
+			want: map[string]string{"THEME": "dark"},
+		},
+		{
+			name: "with query",
+			opts: []Option{WithQuery("SELECT key, value FROM overrides WHERE key = 'log_level'")},
+			want: map[string]string{"LOG_LEVEL": "debug"},
+		},
+		{
+			name: "query takes precedence over table",
+			opts: []Option{WithTable("prefs"), WithQuery("SELECT key, value FROM overrides")},
+			want: map[string]string{"LOG_LEVEL": "debug"},
+		},
+		{
+			name: "later options win",
+			opts: []Option{WithTable("prefs"), WithTable("overrides")},
+			want: map[string]string{"LOG_LEVEL": "debug"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.opts...).Parse(bytes.NewReader(dbContent))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_Parse_NoMatchingTable(t *testing.T) {
+	dbPath := setupTestDBFrom(t, "testdata/columns.sql")
+	defer os.Remove(dbPath)
+
+	db, err := sql.Open(DriverName, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, table := range []string{"app_settings", "prefs", "overrides"} {
+		if _, err := db.Exec("DROP TABLE " + table); err != nil {
+			t.Fatalf("Failed to drop %s: %v", table, err)
+		}
+	}
+	db.Close()
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	_, err = New().Parse(bytes.NewReader(dbContent))
+	if err == nil || !strings.Contains(err.Error(), "no table with key/value columns found") {
+		t.Errorf("Parse() error = %v, want no matching table error", err)
+	}
+}
+
+func TestPlugin_Parse_MultipleQueries(t *testing.T) {
+	dbPath := setupTestDBFrom(t, "testdata/queries.sql")
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		queries []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "later query overrides earlier",
+			queries: []string{"SELECT name, val FROM app_settings; SELECT key, value FROM overrides"},
+			want: map[string]string{
+				"DATABASE_HOST": "localhost",
+				"DATABASE_PORT": "6543",
+				"LOG_LEVEL":     "debug",
+			},
+		},
+		{
+			name:    "order decides precedence",
+			queries: []string{"SELECT key, value FROM overrides;", "SELECT name, val FROM app_settings"},
+			want: map[string]string{
+				"DATABASE_HOST": "localhost",
+				"DATABASE_PORT": "5432",
+				"LOG_LEVEL":     "debug",
+			},
+		},
+		{
+			name:    "semicolon inside string literal",
+			queries: []string{"SELECT 'a;b', val FROM app_settings WHERE name = 'database_host'; SELECT Setting, Data FROM prefs"},
+			want: map[string]string{
+				"A;B":   "localhost",
+				"THEME": "dark",
+			},
+		},
+		{
+			name:    "failing query is reported",
+			queries: []string{"SELECT name, val FROM app_settings; SELECT key, value FROM missing"},
+			wantErr: "query 2 (SELECT key, value FROM missing)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetQuery(tt.queries[0])
+			for _, q := range tt.queries[1:] {
+				p.AddQuery(q)
+			}
+
+			got, err := p.Parse(bytes.NewReader(dbContent))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_SetQuery_Empty(t *testing.T) {
+	dbPath := setupTestDB(t)
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	p := New()
+	p.SetQuery("SELECT key, value FROM config WHERE key = 'api_url'")
+	if got, err := p.Parse(bytes.NewReader(dbContent)); err != nil || len(got) != 1 {
+		t.Fatalf("Parse() with query = %v, %v, want one key", got, err)
+	}
+
+	// An empty query restores the default queries
+	p.SetQuery("")
+	got, err := p.Parse(bytes.NewReader(dbContent))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(got) != 6 || got["DATABASE_HOST"] != "localhost" {
+		t.Errorf("Parse() after SetQuery(\"\") = %v, want every config row", got)
+	}
+}
+
+func TestSplitQueries(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"SELECT key, value FROM config", []string{"SELECT key, value FROM config"}},
+		{"SELECT 1, 2; SELECT 3, 4;", []string{"SELECT 1, 2", "SELECT 3, 4"}},
+		{" ; ;", nil},
+		{"SELECT ';', 'it''s;'; SELECT 2, 3", []string{"SELECT ';', 'it''s;'", "SELECT 2, 3"}},
+		{`SELECT "a;b", [c;d] FROM t; SELECT 1, 2`, []string{`SELECT "a;b", [c;d] FROM t`, "SELECT 1, 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := splitQueries(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitQueries(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_ParseContext_Canceled(t *testing.T) {
+	dbPath := setupTestDB(t)
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	for _, query := range []string{"", "SELECT key, value FROM config"} {
+		t.Run("query "+query, func(t *testing.T) {
+			p := New()
+			p.SetQuery(query)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := p.ParseContext(ctx, bytes.NewReader(dbContent))
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("ParseContext() error = %v, want context.Canceled", err)
+			}
+		})
+	}
+}
+
+func TestPlugin_ConvertContext_Canceled(t *testing.T) {
+	dbPath := setupTestDB(t)
+	defer os.Remove(dbPath)
+
+	dbContent, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to read database file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	err = converter.New(New()).ConvertContext(ctx, bytes.NewReader(dbContent), &out)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ConvertContext() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,32 @@
+CREATE TABLE app_settings (
+    name TEXT PRIMARY KEY,
+    val TEXT
+);
+
+INSERT INTO app_settings (name, val) VALUES
+    ('database_host', 'localhost'),
+    ('database_port', '5432');
+
+CREATE TABLE prefs (
+    Setting TEXT PRIMARY KEY,
+    Data TEXT
+);
+
+INSERT INTO prefs (Setting, Data) VALUES
+    ('theme', 'dark');
+
+CREATE TABLE overrides (
+    key TEXT PRIMARY KEY,
+    value TEXT
+);
+
+INSERT INTO overrides (key, value) VALUES
+    ('log_level', 'debug');
+
+CREATE TABLE misc (
+    id INTEGER PRIMARY KEY,
+    label TEXT
+);
+
+INSERT INTO misc (id, label) VALUES
+    (1, 'unused');
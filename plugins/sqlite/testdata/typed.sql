@@ -0,0 +1,47 @@
+CREATE TABLE int_config (
+    key TEXT PRIMARY KEY,
+    value INTEGER
+);
+
+INSERT INTO int_config (key, value) VALUES
+    ('database_port', 5432),
+    ('max_bytes', 9007199254740993),
+    ('offset', -1);
+
+CREATE TABLE real_config (
+    key TEXT PRIMARY KEY,
+    value REAL
+);
+
+INSERT INTO real_config (key, value) VALUES
+    ('ratio', 0.75),
+    ('timeout', 30.0);
+
+CREATE TABLE blob_config (
+    key TEXT PRIMARY KEY,
+    value BLOB
+);
+
+INSERT INTO blob_config (key, value) VALUES
+    ('signature', X'DEADBEEF'),
+    ('empty', X'');
+
+CREATE TABLE mixed_config (
+    key TEXT PRIMARY KEY,
+    value
+);
+
+INSERT INTO mixed_config (key, value) VALUES
+    ('name', 'demo'),
+    ('retries', 3),
+    ('weight', 1.5);
+
+CREATE TABLE null_config (
+    key TEXT PRIMARY KEY,
+    value TEXT
+);
+
+INSERT INTO null_config (key, value) VALUES
+    ('database_host', 'localhost'),
+    ('database_password', NULL),
+    ('api_token', NULL);
@@ -0,0 +1,199 @@
+// Package ssm reads AWS Systems Manager Parameter Store exports.
+//
+// The input is the JSON printed by aws ssm get-parameters-by-path or
+// get-parameters, an object whose Parameters array holds Name and Value
+// records; the single Parameter of get-parameter and a bare array of
+// records are read too, as are several such values one after another, like
+// the pages of a paginated export. Hierarchical names such as
+// /app/database/host become keys like database_host once the leading path
+// set with SetPathPrefix is stripped.
+package ssm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// ErrNoParameters is returned when the input holds no parameter records
+// and no Parameters or Parameter field, so is not an SSM export
+var ErrNoParameters = errors.New("no SSM parameters found")
+
+// Plugin implements the plugin.Plugin interface for AWS SSM Parameter Store
+// exports
+type Plugin struct {
+	plugin.BasePlugin
+	warnings []string
+
+	prefix string
+}
+
+// New creates a new SSM Parameter Store plugin
+func New() *Plugin {
+	return &Plugin{
+		BasePlugin: plugin.NewBasePlugin("ssm", "parameter-store"),
+	}
+}
+
+// SetPathPrefix sets the leading path stripped from parameter names, such as
+// /app so that /app/database/host becomes database_host. A parameter named
+// by the prefix itself keeps its last segment, and parameters outside it
+// are skipped with a warning. An empty prefix keeps every name whole.
+func (p *Plugin) SetPathPrefix(prefix string) {
+	p.prefix = strings.Trim(prefix, "/")
+}
+
+// parameter is one record of an export. Value is left raw so that a
+// missing or non-string value can be reported.
+type parameter struct {
+	Name  string          `json:"Name"`
+	Value json.RawMessage `json:"Value"`
+}
+
+// export holds the shapes of the aws ssm commands' output
+type export struct {
+	Parameters []parameter `json:"Parameters"`
+	Parameter  *parameter  `json:"Parameter"`
+}
+
+// Parse implements plugin.Plugin
+func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	kvs, err := p.ParseOrdered(r)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		env[kv.Key] = kv.Value
+	}
+	return env, nil
+}
+
+// ParseOrdered implements plugin.OrderedPlugin. Keys are returned in the
+// order their parameters appear; a key set by more than one parameter keeps
+// its first position and the value of the last.
+func (p *Plugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	p.warnings = nil
+	if r == nil {
+		return nil, nil
+	}
+
+	env := make(map[string]string)
+	from := make(map[string]string)
+	var order []string
+	found := false
+	decoder := json.NewDecoder(utils.StripBOM(r))
+	for {
+		params, ok, err := decodeValue(decoder)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = found || ok
+
+		for _, param := range params {
+			value, err := param.value()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := p.key(param.Name)
+			if !ok {
+				p.warn("skipping parameter '%s' outside /%s", param.Name, p.prefix)
+				continue
+			}
+			if prev, seen := from[key]; !seen {
+				order = append(order, key)
+			} else if prev != param.Name {
+				p.warn("key '%s' is set by both %s and %s", key, prev, param.Name)
+			}
+			from[key] = param.Name
+			env[key] = value
+		}
+	}
+	if !found {
+		return nil, ErrNoParameters
+	}
+	return plugin.OrderPairs(env, order), nil
+}
+
+// decodeValue decodes the next JSON value and returns the parameters it
+// holds, and whether it is an export: an array holding parameters or an
+// object with a Parameters or Parameter field, even an empty one
+func decodeValue(decoder *json.Decoder) ([]parameter, bool, error) {
+	var raw json.RawMessage
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, false, err
+	}
+
+	var params []parameter
+	if err := json.Unmarshal(raw, &params); err == nil {
+		return params, len(params) > 0, nil
+	}
+	var e export
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, false, fmt.Errorf("expected an object with Parameters or an array of parameters: %w", err)
+	}
+	ok := e.Parameters != nil || e.Parameter != nil
+	if e.Parameter != nil {
+		e.Parameters = append(e.Parameters, *e.Parameter)
+	}
+	return e.Parameters, ok, nil
+}
+
+// value checks that the parameter is named and returns its value, which
+// must be a string
+func (param parameter) value() (string, error) {
+	if param.Name == "" {
+		return "", errors.New("parameter without a Name")
+	}
+	var s string
+	if param.Value == nil || json.Unmarshal(param.Value, &s) != nil {
+		return "", fmt.Errorf("parameter %s: Value must be a string", param.Name)
+	}
+	return s, nil
+}
+
+// key converts a parameter name into a key: the prefix is stripped, and the
+// remaining path segments are joined with underscores. It reports false for
+// names outside the prefix.
+func (p *Plugin) key(name string) (string, bool) {
+	path := strings.Trim(name, "/")
+	if p.prefix != "" {
+		switch {
+		case path == p.prefix:
+			path = path[strings.LastIndex(path, "/")+1:]
+		case strings.HasPrefix(path, p.prefix+"/"):
+			path = path[len(p.prefix)+1:]
+		default:
+			return "", false
+		}
+	}
+	return strings.ReplaceAll(path, "/", "_"), true
+}
+
+// Warnings implements plugin.Warner
+func (p *Plugin) Warnings() []string {
+	return p.warnings
+}
+
+// Reset implements plugin.Resetter
+func (p *Plugin) Reset() {
+	p.warnings = nil
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Ordered: true, PathPrefix: true}
+}
+
+// warn records a warning for the current parse
+func (p *Plugin) warn(format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,191 @@
+package ssm
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func parseFile(t *testing.T, p *Plugin, name string) []plugin.KV {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+
+	got, err := p.ParseOrdered(f)
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	return got
+}
+
+func TestPlugin_Export(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		want   []plugin.KV
+	}{
+		{
+			name: "full names",
+			want: []plugin.KV{
+				{Key: "app_database_host", Value: "db.internal"},
+				{Key: "app_database_password", Value: "hunter2"},
+				{Key: "app_allowed-origins", Value: "https://a.example,https://b.example"},
+				{Key: "app_log_level", Value: "info"},
+			},
+		},
+		{
+			name:   "prefix stripped",
+			prefix: "/app",
+			want: []plugin.KV{
+				{Key: "database_host", Value: "db.internal"},
+				{Key: "database_password", Value: "hunter2"},
+				{Key: "allowed-origins", Value: "https://a.example,https://b.example"},
+				{Key: "log_level", Value: "info"},
+			},
+		},
+		{
+			name:   "deeper prefix with slashes",
+			prefix: "/app/database/",
+			want: []plugin.KV{
+				{Key: "host", Value: "db.internal"},
+				{Key: "password", Value: "hunter2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetPathPrefix(tt.prefix)
+			if got := parseFile(t, p, "parameters.json"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrdered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_OutsidePrefix(t *testing.T) {
+	p := New()
+	p.SetPathPrefix("app/database")
+	parseFile(t, p, "parameters.json")
+
+	want := []string{
+		"skipping parameter '/app/allowed-origins' outside /app/database",
+		"skipping parameter '/app/log_level' outside /app/database",
+	}
+	if got := p.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %q, want %q", got, want)
+	}
+
+	p.Reset()
+	if got := p.Warnings(); got != nil {
+		t.Errorf("Warnings() after Reset() = %q", got)
+	}
+}
+
+func TestPlugin_Shapes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{
+			name:  "get-parameter",
+			input: `{"Parameter": {"Name": "/app/port", "Type": "String", "Value": "8080"}}`,
+			want:  map[string]string{"port": "8080"},
+		},
+		{
+			name:  "bare array",
+			input: `[{"Name": "/app/a", "Value": "1"}, {"Name": "/app/b/c", "Value": "2"}]`,
+			want:  map[string]string{"a": "1", "b_c": "2"},
+		},
+		{
+			name:  "pages",
+			input: `{"Parameters": [{"Name": "/app/a", "Value": "1"}], "NextToken": "x"}` + "\n" + `{"Parameters": [{"Name": "/app/b", "Value": "2"}]}`,
+			want:  map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:  "parameter named by the prefix",
+			input: `{"Parameters": [{"Name": "/app", "Value": "root"}]}`,
+			want:  map[string]string{"app": "root"},
+		},
+		{
+			name:  "name without a hierarchy",
+			input: `{"Parameters": [{"Name": "plain", "Value": "x"}, {"Name": "/app/b", "Value": ""}]}`,
+			want:  map[string]string{"b": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetPathPrefix("/app")
+			got, err := p.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_Collision(t *testing.T) {
+	p := New()
+	got, err := p.Parse(strings.NewReader(`{"Parameters": [{"Name": "/a/b", "Value": "1"}, {"Name": "/a_b", "Value": "2"}]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := map[string]string{"a_b": "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+	if want := []string{"key 'a_b' is set by both /a/b and /a_b"}; !reflect.DeepEqual(p.Warnings(), want) {
+		t.Errorf("Warnings() = %q, want %q", p.Warnings(), want)
+	}
+}
+
+func TestPlugin_EmptyExport(t *testing.T) {
+	// An export of a path holding no parameters gives no keys
+	for _, input := range []string{`{"Parameters": []}`, `{"Parameters": []}{"Parameters": []}`} {
+		env, err := New().Parse(strings.NewReader(input))
+		if err != nil || len(env) != 0 {
+			t.Errorf("Parse(%s) = %v, %v; want no keys", input, env, err)
+		}
+	}
+}
+
+func TestPlugin_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"other json", `{"database": {"host": "x"}}`, ErrNoParameters.Error()},
+		{"empty input", ``, ErrNoParameters.Error()},
+		{"scalar", `"x"`, "expected an object with Parameters"},
+		{"missing value", `{"Parameters": [{"Name": "/a"}]}`, "parameter /a: Value must be a string"},
+		{"number value", `[{"Name": "/a", "Value": 1}]`, "parameter /a: Value must be a string"},
+		{"missing name", `[{"Value": "1"}]`, "parameter without a Name"},
+		{"syntax error", `{"Parameters": [`, "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New().Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := New().Parse(strings.NewReader("[]")); !errors.Is(err, ErrNoParameters) {
+		t.Errorf("Parse() error = %v, want ErrNoParameters", err)
+	}
+}
@@ -0,0 +1,288 @@
+// Package systemd parses systemd EnvironmentFile= files.
+//
+// The rules follow systemd.exec(5) and systemd's own parser:
+//
+//   - Lines whose first non-blank character is # or ; are comments. A
+//     backslash at the end of a comment line continues the comment.
+//   - Assignments are KEY=VALUE. Blanks around the key and after the = are
+//     ignored, and trailing blanks of unquoted values are dropped. A # later
+//     in a line is part of the value, not a comment.
+//   - Outside quotes, a backslash takes the next character literally, and a
+//     backslash at the end of a line joins the next line.
+//   - Quotes start a quoted part only at the start of a value or right
+//     after a closing quote, so "a" 'b'c is abc but a "b" is a "b". Single
+//     quotes are literal. Inside double quotes, a backslash escapes only ",
+//     \, ` and $, and a backslash before a newline joins the lines; other
+//     backslashes are kept. Quoted values may span lines.
+//   - Variables are not expanded, and there is no "export" keyword.
+//
+// Lines without an = and keys that are not valid variable names are skipped
+// with a warning, as systemd does.
+package systemd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// Plugin implements the plugin.Plugin interface for systemd EnvironmentFile
+// files
+type Plugin struct {
+	plugin.BasePlugin
+	warnings []string
+}
+
+// New creates a new systemd EnvironmentFile plugin
+func New() *Plugin {
+	return &Plugin{
+		BasePlugin: plugin.NewBasePlugin("systemd", "envfile"),
+	}
+}
+
+// Parse implements plugin.Plugin
+func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
+	kvs, err := p.ParseOrdered(r)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		env[kv.Key] = kv.Value
+	}
+	return env, nil
+}
+
+// ParseOrdered implements plugin.OrderedPlugin. A key assigned more than
+// once keeps its first position and its last value.
+func (p *Plugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	p.warnings = nil
+	if r == nil {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(utils.StripBOM(r))
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	var order []string
+	for _, a := range parse(string(data)) {
+		if a.key == "" || !a.hasValue {
+			p.warnings = append(p.warnings, fmt.Sprintf("line %d: ignoring line without '='", a.line))
+			continue
+		}
+		if !validName(a.key) {
+			p.warnings = append(p.warnings, fmt.Sprintf("line %d: ignoring invalid variable name '%s'", a.line, a.key))
+			continue
+		}
+		if _, seen := env[a.key]; !seen {
+			order = append(order, a.key)
+		}
+		env[a.key] = a.value
+	}
+	return plugin.OrderPairs(env, order), nil
+}
+
+// Warnings implements plugin.Warner
+func (p *Plugin) Warnings() []string {
+	return p.warnings
+}
+
+// Reset implements plugin.Resetter
+func (p *Plugin) Reset() {
+	p.warnings = nil
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Ordered: true}
+}
+
+// assignment is one parsed line, or several joined by continuations
+type assignment struct {
+	key      string
+	value    string
+	hasValue bool
+	line     int
+}
+
+// state is a state of the parser in parse
+type state int
+
+const (
+	preKey state = iota
+	inKey
+	preValue
+	inValue
+	valueEscape
+	singleQuote
+	doubleQuote
+	doubleQuoteEscape
+	comment
+	commentEscape
+)
+
+// parse splits s into assignments, mirroring the state machine of systemd's
+// parse_env_file
+func parse(s string) []assignment {
+	var (
+		out   []assignment
+		key   strings.Builder
+		value strings.Builder
+		st    = preKey
+		line  = 1
+		start = 1
+		// trailing is the length of value before a run of unquoted
+		// trailing blanks, or -1 when the value does not end in one
+		trailing = -1
+	)
+
+	push := func(hasValue bool) {
+		v := value.String()
+		if trailing >= 0 {
+			v = v[:trailing]
+		}
+		k := strings.TrimRight(key.String(), " \t")
+		out = append(out, assignment{key: k, value: v, hasValue: hasValue, line: start})
+		key.Reset()
+		value.Reset()
+		trailing = -1
+	}
+
+	for _, c := range s {
+		switch st {
+		case preKey:
+			switch {
+			case c == '#' || c == ';':
+				st = comment
+			case isBlank(c) || isNewline(c):
+			default:
+				st = inKey
+				start = line
+				key.WriteRune(c)
+			}
+		case inKey:
+			switch {
+			case isNewline(c):
+				st = preKey
+				push(false)
+			case c == '=':
+				st = preValue
+			default:
+				key.WriteRune(c)
+			}
+		case preValue:
+			switch {
+			case isNewline(c):
+				st = preKey
+				push(true)
+			case c == '\'':
+				st = singleQuote
+			case c == '"':
+				st = doubleQuote
+			case c == '\\':
+				st = valueEscape
+			case isBlank(c):
+			default:
+				st = inValue
+				value.WriteRune(c)
+			}
+		case inValue:
+			switch {
+			case isNewline(c):
+				st = preKey
+				push(true)
+			case c == '\\':
+				st = valueEscape
+				trailing = -1
+			default:
+				if !isBlank(c) {
+					trailing = -1
+				} else if trailing < 0 {
+					trailing = value.Len()
+				}
+				value.WriteRune(c)
+			}
+		case valueEscape:
+			st = inValue
+			if !isNewline(c) {
+				value.WriteRune(c)
+			}
+		case singleQuote:
+			if c == '\'' {
+				st = preValue
+			} else {
+				value.WriteRune(c)
+			}
+		case doubleQuote:
+			switch c {
+			case '"':
+				st = preValue
+			case '\\':
+				st = doubleQuoteEscape
+			default:
+				value.WriteRune(c)
+			}
+		case doubleQuoteEscape:
+			st = doubleQuote
+			switch {
+			case strings.ContainsRune("\"\\`$", c):
+				value.WriteRune(c)
+			case !isNewline(c):
+				value.WriteByte('\\')
+				value.WriteRune(c)
+			}
+		case comment:
+			if c == '\\' {
+				st = commentEscape
+			} else if isNewline(c) {
+				st = preKey
+			}
+		case commentEscape:
+			st = comment
+		}
+
+		if c == '\n' {
+			line++
+		}
+	}
+
+	// Like systemd, accept a final line without a newline, including an
+	// unterminated quoted value
+	switch st {
+	case inKey:
+		push(false)
+	case preValue, inValue, valueEscape, singleQuote, doubleQuote, doubleQuoteEscape:
+		push(true)
+	}
+	return out
+}
+
+// isBlank reports whether c is a blank that separates tokens
+func isBlank(c rune) bool {
+	return c == ' ' || c == '\t'
+}
+
+// isNewline reports whether c ends a line
+func isNewline(c rune) bool {
+	return c == '\n' || c == '\r'
+}
+
+// validName reports whether name is a valid environment variable name
+func validName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '_' && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package systemd
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+func TestPlugin_Parse(t *testing.T) {
+	f, err := os.Open("testdata/app.env")
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+
+	got, err := New().Parse(f)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]string{
+		"LISTEN_ADDR": "0.0.0.0:8080",
+		"LOG_LEVEL":   "debug",
+		"GREETING":    `hello "world"`,
+		"RAW":         `C:\path\$HOME`,
+		"ARGS":        "--verbose   --color=always",
+		"MOTD":        "line one\nline two",
+		"URL":         "http://example.com/#anchor",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+}
+
+func TestPlugin_Parse_Semantics(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{
+			name:  "comments need to start the line",
+			input: "# A=1\n  ; B=2\nC=3 # not a comment\n",
+			want:  map[string]string{"C": "3 # not a comment"},
+		},
+		{
+			name:  "backslash continues a comment",
+			input: "# comment \\\nA=1\nB=2\n",
+			want:  map[string]string{"B": "2"},
+		},
+		{
+			name:  "unquoted escapes",
+			input: `A=a\ b\\c\"d` + "\n",
+			want:  map[string]string{"A": `a b\c"d`},
+		},
+		{
+			name:  "unquoted continuation keeps leading blanks",
+			input: "A=one\\\n two\n",
+			want:  map[string]string{"A": "one two"},
+		},
+		{
+			name:  "double quoted escapes",
+			input: `A="\$HOME \` + "`x` \\n \\\\\"\n",
+			want:  map[string]string{"A": "$HOME `x` \\n \\"},
+		},
+		{
+			name:  "double quoted continuation",
+			input: "A=\"one \\\ntwo\"\n",
+			want:  map[string]string{"A": "one two"},
+		},
+		{
+			name:  "single quoted multiline",
+			input: "A='one\n  two'\nB=3\n",
+			want:  map[string]string{"A": "one\n  two", "B": "3"},
+		},
+		{
+			name:  "quoted parts concatenate",
+			input: `A="a b" 'c'd` + "\n",
+			want:  map[string]string{"A": "a bcd"},
+		},
+		{
+			name:  "quotes after unquoted text are literal",
+			input: `A=a "b"` + "\n",
+			want:  map[string]string{"A": `a "b"`},
+		},
+		{
+			name:  "quoted blanks are kept",
+			input: "A=\"  padded  \"  \n",
+			want:  map[string]string{"A": "  padded  "},
+		},
+		{
+			name:  "empty values",
+			input: "A=\nB=\"\"\nC=  \n",
+			want:  map[string]string{"A": "", "B": "", "C": ""},
+		},
+		{
+			name:  "later assignments win",
+			input: "A=1\nA=2\n",
+			want:  map[string]string{"A": "2"},
+		},
+		{
+			name:  "CRLF line endings",
+			input: "A=1\r\nB=\"x\"\r\n",
+			want:  map[string]string{"A": "1", "B": "x"},
+		},
+		{
+			name:  "final line without newline",
+			input: "A=1\nB=\"unterminated",
+			want:  map[string]string{"A": "1", "B": "unterminated"},
+		},
+		{
+			name:  "variables are not expanded",
+			input: "A=1\nB=${A}/$A\n",
+			want:  map[string]string{"A": "1", "B": "${A}/$A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New().Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_Warnings(t *testing.T) {
+	p := New()
+	input := "A=1\nNO_EQUALS\nexport B=2\n2C=3\nD=\"multi\nline\"\nbad-key=4\n"
+
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := map[string]string{"A": "1", "D": "multi\nline"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+
+	wantWarnings := []string{
+		"line 2: ignoring line without '='",
+		"line 3: ignoring invalid variable name 'export B'",
+		"line 4: ignoring invalid variable name '2C'",
+		"line 7: ignoring invalid variable name 'bad-key'",
+	}
+	if !reflect.DeepEqual(p.Warnings(), wantWarnings) {
+		t.Errorf("Warnings() = %q, want %q", p.Warnings(), wantWarnings)
+	}
+
+	p.Reset()
+	if len(p.Warnings()) != 0 {
+		t.Errorf("Warnings() after Reset = %q, want none", p.Warnings())
+	}
+}
+
+func TestPlugin_ParseOrdered(t *testing.T) {
+	got, err := New().ParseOrdered(strings.NewReader("Z=1\nA=2\nM=3\nZ=4\n"))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	want := []plugin.KV{{Key: "Z", Value: "4"}, {Key: "A", Value: "2"}, {Key: "M", Value: "3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+}
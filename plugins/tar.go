@@ -0,0 +1,72 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// WalkTar calls fn for each regular file in the tar archive read from r, in
+// archive order, with the plugin for its format and a reader for its
+// content. The archive may be gzip-compressed. Files use the plugin for
+// format, or when format is empty the plugin registered for their
+// extension; files with no such plugin, such as a README, are skipped. An
+// error from fn stops the walk and is returned.
+func WalkTar(r io.Reader, format string, fn func(name string, p plugin.Plugin, r io.Reader) error) error {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(gzipMagic)); bytes.Equal(head, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		var p plugin.Plugin
+		if format != "" {
+			if p, err = Get(format); err != nil {
+				return err
+			}
+		} else {
+			// Skip files that are not configs, including those without an
+			// extension, which would otherwise get the default plugin
+			ext := strings.TrimPrefix(path.Ext(hdr.Name), ".")
+			if ext == "" {
+				continue
+			}
+			if p, err = Get(ext); err != nil {
+				continue
+			}
+		}
+
+		if err := fn(hdr.Name, p, tr); err != nil {
+			return err
+		}
+	}
+}
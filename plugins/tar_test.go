@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/handaber/cfg2env/lib/converter"
+	"github.com/handaber/cfg2env/plugin"
+)
+
+// tarEntry is a file or directory written by makeTar
+type tarEntry struct {
+	name    string
+	content string
+	dir     bool
+}
+
+// makeTar returns an in-memory tar archive of entries
+func makeTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.dir {
+			hdr = &tar.Header{Name: e.name, Mode: 0o755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gzipped returns data compressed with gzip
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// convertTar converts and merges the files of archive as the --tar mode
+// does, returning the merged pairs and the files visited with their formats
+func convertTar(t *testing.T, archive []byte, format string) (map[string]string, []string) {
+	t.Helper()
+	var envs []map[string]string
+	var visited []string
+	err := WalkTar(bytes.NewReader(archive), format, func(name string, p plugin.Plugin, r io.Reader) error {
+		visited = append(visited, name+":"+p.Name())
+		c := converter.New(p)
+		c.SetPrefix(converter.PrefixFromFilename(name))
+		env, err := c.ConvertMap(r)
+		if err != nil {
+			return err
+		}
+		envs = append(envs, env)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkTar() error = %v", err)
+	}
+	merged, err := converter.Merge(converter.MergeOverride, envs...)
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	return merged, visited
+}
+
+func TestWalkTar(t *testing.T) {
+	archive := makeTar(t,
+		tarEntry{name: "configs/", dir: true},
+		tarEntry{name: "configs/database.yaml", content: "host: localhost\nport: 5432\n"},
+		tarEntry{name: "configs/cache.json", content: `{"host": "redis", "ttl": 60}`},
+		tarEntry{name: "configs/README.md", content: "# Configs\n"},
+		tarEntry{name: "configs/Makefile", content: "all:\n"},
+	)
+	want := map[string]string{
+		"DATABASE_HOST": "localhost",
+		"DATABASE_PORT": "5432",
+		"CACHE_HOST":    "redis",
+		"CACHE_TTL":     "60",
+	}
+	wantVisited := []string{"configs/database.yaml:yaml", "configs/cache.json:json"}
+
+	for name, data := range map[string][]byte{"plain": archive, "gzip": gzipped(t, archive)} {
+		t.Run(name, func(t *testing.T) {
+			got, visited := convertTar(t, data, "")
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("merged = %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(visited, wantVisited) {
+				t.Errorf("visited = %v, want %v", visited, wantVisited)
+			}
+		})
+	}
+}
+
+func TestWalkTar_Format(t *testing.T) {
+	// --format applies to every file, whatever its extension
+	archive := makeTar(t,
+		tarEntry{name: "app.conf", content: "name: demo\n"},
+		tarEntry{name: "extra", content: "debug: true\n"},
+	)
+	got, visited := convertTar(t, archive, "yaml")
+	if want := map[string]string{"APP_NAME": "demo", "EXTRA_DEBUG": "true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %v, want %v", got, want)
+	}
+	if want := []string{"app.conf:yaml", "extra:yaml"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited = %v, want %v", visited, want)
+	}
+}
+
+func TestWalkTar_Errors(t *testing.T) {
+	archive := makeTar(t,
+		tarEntry{name: "a.yaml", content: "a: 1\n"},
+		tarEntry{name: "b.yaml", content: "b: 2\n"},
+	)
+
+	// An error from fn stops the walk
+	errStop := errors.New("stop")
+	calls := 0
+	err := WalkTar(bytes.NewReader(archive), "", func(string, plugin.Plugin, io.Reader) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("WalkTar() error = %v after %d calls, want errStop after 1", err, calls)
+	}
+
+	// Unknown formats are an error rather than a skip
+	err = WalkTar(bytes.NewReader(archive), "toml", func(string, plugin.Plugin, io.Reader) error { return nil })
+	if err == nil {
+		t.Error("WalkTar() with an unknown format succeeded")
+	}
+
+	// Truncated archives are reported
+	err = WalkTar(bytes.NewReader(archive[:1100]), "", func(string, plugin.Plugin, io.Reader) error { return nil })
+	if err == nil {
+		t.Error("WalkTar() on a truncated archive succeeded")
+	}
+}
@@ -1,7 +1,10 @@
 package yaml
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/handaber/cfg2env/lib/utils"
 	"github.com/handaber/cfg2env/plugin"
@@ -11,29 +14,433 @@ import (
 // Plugin implements the plugin.Plugin interface for YAML format
 type Plugin struct {
 	plugin.BasePlugin
+	flatten    utils.FlattenOptions
+	warnings   []string
+	paths      map[string]string
+	collisions utils.Collisions
+
+	skipPaths     []string
+	onlyPath      string
+	stripOnlyPath bool
+	maxDepth      int
+	literals      bool
 }
 
 // New creates a new YAML plugin
 func New() *Plugin {
 	return &Plugin{
 		BasePlugin: plugin.NewBasePlugin("yaml", "yml", "yaml"),
+		flatten:    utils.FlattenOptions{ArraySep: utils.DefaultArraySep},
+		maxDepth:   utils.DefaultMaxDepth,
 	}
 }
 
+// SetArrayMode sets how arrays are flattened and the separator for joined arrays
+func (p *Plugin) SetArrayMode(mode utils.ArrayMode, sep string) {
+	p.flatten.Arrays = mode
+	p.flatten.ArraySep = sep
+}
+
+// SetArrayKeyField names a field of the maps in arrays whose value replaces
+// the element's index in keys, such as "name" to flatten
+// [{name: db, host: x}] to DB_HOST. Elements without it keep their index.
+// Empty disables it.
+func (p *Plugin) SetArrayKeyField(field string) {
+	p.flatten.KeyField = field
+}
+
+// SetCompactArrays controls whether null elements are dropped from arrays
+// and the rest reindexed, so [a, null, c] flattens to KEY_0=a and KEY_1=c.
+// By default every element keeps its position.
+func (p *Plugin) SetCompactArrays(compact bool) {
+	p.flatten.CompactArrays = compact
+}
+
+// SetOmitEmptyContainers controls whether empty maps and arrays are dropped
+// instead of being written as a key with an empty value
+func (p *Plugin) SetOmitEmptyContainers(omit bool) {
+	p.flatten.OmitEmptyContainers = omit
+}
+
+// SetTimeFormat sets the layout for unquoted timestamps, which YAML decodes
+// as times rather than strings. Empty uses time.RFC3339.
+func (p *Plugin) SetTimeFormat(layout string) {
+	p.flatten.TimeFormat = layout
+}
+
+// SetSkipPaths sets dot-separated paths, such as "logging" or
+// "app.metadata", whose subtrees are dropped before flattening
+func (p *Plugin) SetSkipPaths(paths []string) {
+	p.skipPaths = paths
+}
+
+// SetOnlyPath limits flattening to the subtree at the dot-separated path,
+// such as "database". With strip, the path is dropped from the keys.
+func (p *Plugin) SetOnlyPath(path string, strip bool) {
+	p.onlyPath = path
+	p.stripOnlyPath = strip
+}
+
+// SetMaxDepth replaces utils.DefaultMaxDepth as the nesting limit
+func (p *Plugin) SetMaxDepth(depth int) {
+	p.maxDepth = depth
+}
+
+// SetPreserveLiterals controls whether booleans and nulls keep the text they
+// were written with, so True, FALSE, ~ and Null are written as is instead of
+// as true, false and the empty string. yes, no, on and off are strings in
+// YAML 1.2 and are always kept.
+func (p *Plugin) SetPreserveLiterals(preserve bool) {
+	p.literals = preserve
+}
+
 // Parse implements plugin.Plugin
 func (p *Plugin) Parse(r io.Reader) (map[string]string, error) {
-	var data interface{}
-	decoder := yaml.NewDecoder(r)
-	if err := decoder.Decode(&data); err != nil {
+	env, _, err := p.parse(r)
+	return env, err
+}
+
+// parse decodes and flattens r, returning the flattened keys along with the
+// part of the document they were flattened from
+func (p *Plugin) parse(r io.Reader) (map[string]string, utils.Selection, error) {
+	p.warnings = nil
+	p.paths = nil
+	p.collisions = nil
+
+	data, err := p.decode(r)
+	if err != nil {
 		if err == io.EOF {
-			return make(map[string]string), nil
+			return make(map[string]string), utils.Selection{}, nil
 		}
-		return nil, err
+		return nil, utils.Selection{}, err
+	}
+
+	return p.flattenTree(data)
+}
+
+// flattenTree flattens the decoded document data, returning the flattened
+// keys along with the part of the document they were flattened from
+func (p *Plugin) flattenTree(data interface{}) (map[string]string, utils.Selection, error) {
+	if err := utils.CheckDepth(data, p.maxDepth); err != nil {
+		return nil, utils.Selection{}, err
 	}
 
 	env := make(map[string]string)
-	if data != nil {
-		utils.Flatten("", data, env)
+	sel := utils.Selection{Value: utils.PrunePaths(data, p.skipPaths)}
+	if p.onlyPath != "" {
+		sel = utils.SelectPath(sel.Value, p.onlyPath, p.stripOnlyPath)
+	}
+	if sel.Value != nil {
+		p.collisions = make(utils.Collisions)
+		opts := p.flatten
+		opts.OnCollision = func(key string) {
+			p.warnings = append(p.warnings, utils.CollisionWarning(key))
+			p.collisions.Shadow(key, env, p.paths)
+		}
+		opts.OnPath = p.recordPath
+		opts.Path = sel.Path
+		utils.FlattenWith(sel.Prefix, sel.Value, env, opts)
+		p.collisions.Complete(env, p.paths)
+	}
+	return env, sel, nil
+}
+
+// DecodeTree implements plugin.TreePlugin
+func (p *Plugin) DecodeTree(r io.Reader) (interface{}, error) {
+	data, err := p.decode(r)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.CheckDepth(data, p.maxDepth); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FlattenTree implements plugin.TreePlugin
+func (p *Plugin) FlattenTree(data interface{}) (map[string]string, error) {
+	p.warnings = nil
+	p.paths = nil
+	p.collisions = nil
+	env, _, err := p.flattenTree(data)
+	return env, err
+}
+
+// decode decodes the first document in r through its node tree, so that
+// explicitly tagged scalars can be resolved per their tag and, with literals
+// preserved, booleans and nulls can be retagged as strings holding their
+// source text.
+func (p *Plugin) decode(r io.Reader) (interface{}, error) {
+	var node yaml.Node
+	if err := yaml.NewDecoder(utils.StripBOM(r)).Decode(&node); err != nil {
+		return nil, err
+	}
+	if p.literals {
+		keepLiterals(&node)
+	} else {
+		resolveTags(&node)
+	}
+
+	var data interface{}
+	err := node.Decode(&data)
+	return data, err
+}
+
+// keepLiterals retags the boolean and null scalars under n as strings, so
+// they decode to the text they were written with. Aliases are skipped, as
+// the nodes they point to are retagged where they are defined.
+func keepLiterals(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode {
+		if tag := n.ShortTag(); tag == "!!bool" || tag == "!!null" {
+			n.Tag = "!!str"
+		}
+		return
+	}
+	for _, c := range n.Content {
+		keepLiterals(c)
+	}
+}
+
+// yaml11Bools maps the YAML 1.1 boolean words that YAML 1.2 reads as
+// strings to their values
+var yaml11Bools = map[string]string{
+	"y": "true", "yes": "true", "on": "true",
+	"n": "false", "no": "false", "off": "false",
+}
+
+// resolveTags rewrites explicitly tagged scalars under n that the decoder
+// would reject, so they decode per their tag: !!bool yes is true rather than
+// an error. Other tags, such as !!str 007 or !!int 0x1F, already decode per
+// their tag, and custom tags such as !secret keep the scalar's text. Aliases
+// are skipped, as the nodes they point to are rewritten where they are
+// defined.
+func resolveTags(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode {
+		if n.Style&yaml.TaggedStyle != 0 && n.ShortTag() == "!!bool" {
+			if v, ok := yaml11Bools[strings.ToLower(n.Value)]; ok {
+				n.Value = v
+			}
+		}
+		return
+	}
+	for _, c := range n.Content {
+		resolveTags(c)
+	}
+}
+
+// Validate implements plugin.Validator. It checks the syntax of every
+// document in r without building the flattened map.
+func (p *Plugin) Validate(r io.Reader) error {
+	decoder := yaml.NewDecoder(utils.StripBOM(r))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Warnings implements plugin.Warner
+func (p *Plugin) Warnings() []string {
+	return p.warnings
+}
+
+// Paths implements plugin.PathReporter
+func (p *Plugin) Paths() map[string]string {
+	return p.paths
+}
+
+// Collisions implements plugin.CollisionReporter
+func (p *Plugin) Collisions() map[string][]plugin.KV {
+	return p.collisions
+}
+
+// recordPath notes the source path of a flattened key for Paths
+func (p *Plugin) recordPath(key, path string) {
+	if p.paths == nil {
+		p.paths = make(map[string]string)
+	}
+	p.paths[key] = path
+}
+
+// Capabilities implements plugin.Capable
+func (p *Plugin) Capabilities() plugin.Capabilities {
+	return plugin.Capabilities{Ordered: true, Comments: true, Paths: true, Arrays: true}
+}
+
+// Reset implements plugin.Resetter
+func (p *Plugin) Reset() {
+	p.warnings = nil
+	p.paths = nil
+	p.collisions = nil
+}
+
+// ParseOrdered implements plugin.OrderedPlugin
+func (p *Plugin) ParseOrdered(r io.Reader) ([]plugin.KV, error) {
+	// Handle empty input
+	if r == nil {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(utils.StripBOM(r))
+	if err != nil {
+		return nil, err
+	}
+
+	env, sel, err := p.parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the node tree to recover the source order of keys
+	var node yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&node); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// Preserved literals decode nulls as strings, which are never dropped
+	w := &walker{
+		comments: make(map[string]string),
+		keyField: p.flatten.KeyField,
+		compact:  p.flatten.CompactArrays && !p.literals,
+	}
+	w.walk("", "", &node)
+
+	// Match the keys of the whole document to those of the selection
+	order := make([]string, len(w.order))
+	comments := make(map[string]string, len(w.comments))
+	for i, k := range w.order {
+		order[i] = sel.Rekey(k)
+	}
+	for k, c := range w.comments {
+		comments[sel.Rekey(k)] = c
+	}
+
+	pairs := plugin.OrderPairs(env, order)
+	for i := range pairs {
+		pairs[i].Comment = comments[pairs[i].Key]
+	}
+	return pairs, nil
+}
+
+// walker collects flattened keys in document order along with the comments
+// attached to their leaf values
+type walker struct {
+	order    []string
+	comments map[string]string
+	keyField string
+	compact  bool
+}
+
+// walk visits n, carrying any comments collected from enclosing keys
+func (w *walker) walk(prefix, comment string, n *yaml.Node) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			w.walk(prefix, "", c)
+		}
+	case yaml.AliasNode:
+		w.walk(prefix, comment, n.Alias)
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			w.add(prefix, comment)
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			// Merge keys flatten into the enclosing mapping
+			if key.Tag == "!!merge" {
+				if val.Kind == yaml.SequenceNode {
+					for _, c := range val.Content {
+						w.walk(prefix, "", c)
+					}
+				} else {
+					w.walk(prefix, "", val)
+				}
+				continue
+			}
+			w.walk(utils.JoinKey(prefix, key.Value), joinComments(key.HeadComment, key.LineComment), val)
+		}
+	case yaml.SequenceNode:
+		// Joined arrays are emitted under the array's own key
+		w.add(prefix, comment)
+		i := 0
+		for _, c := range n.Content {
+			if w.compact && isNull(c) {
+				continue
+			}
+			key, rest := w.elementKey(i, c)
+			w.walk(utils.JoinKey(prefix, key), "", rest)
+			i++
+		}
+	case yaml.ScalarNode:
+		w.add(prefix, joinComments(comment, n.HeadComment, n.LineComment))
+	}
+}
+
+// isNull reports whether n, or the node it aliases, is a null scalar
+func isNull(n *yaml.Node) bool {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null"
+}
+
+// elementKey mirrors utils.FlattenOptions.ElementKey for the node of element
+// i of a sequence
+func (w *walker) elementKey(i int, n *yaml.Node) (string, *yaml.Node) {
+	index := fmt.Sprintf("%d", i)
+	if w.keyField == "" {
+		return index, n
+	}
+	m := n
+	if m.Kind == yaml.AliasNode {
+		m = m.Alias
+	}
+	if m.Kind != yaml.MappingNode {
+		return index, n
+	}
+
+	for j := 0; j+1 < len(m.Content); j += 2 {
+		key, val := m.Content[j], m.Content[j+1]
+		if key.Value != w.keyField {
+			continue
+		}
+		if val.Kind != yaml.ScalarNode || val.ShortTag() == "!!null" || val.Value == "" {
+			return index, n
+		}
+		rest := *m
+		rest.Content = append(append([]*yaml.Node(nil), m.Content[:j]...), m.Content[j+2:]...)
+		return val.Value, &rest
+	}
+	return index, n
+}
+
+// add records a flattened key and its comment
+func (w *walker) add(prefix, comment string) {
+	key := strings.ToUpper(prefix)
+	w.order = append(w.order, key)
+	if comment != "" {
+		w.comments[key] = comment
+	}
+}
+
+// joinComments strips comment markers and joins the non-empty lines with newlines
+func joinComments(comments ...string) string {
+	var lines []string
+	for _, c := range comments {
+		for _, line := range strings.Split(c, "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
 	}
-	return env, nil
+	return strings.Join(lines, "\n")
 }
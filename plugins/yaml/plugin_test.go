@@ -1,11 +1,17 @@
 package yaml
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/handaber/cfg2env/lib/converter"
+	"github.com/handaber/cfg2env/lib/utils"
+	"github.com/handaber/cfg2env/plugin"
 )
 
 func getTestDataPath(file string) string {
@@ -108,6 +114,11 @@ func TestPlugin_Parse_EdgeCases(t *testing.T) {
 				"FLOAT":     "3.14",
 			},
 		},
+		{
+			name:  "int and bool keys",
+			input: "1: a\ntrue: b\nport:\n  8080: http\n",
+			want:  map[string]string{"1": "a", "TRUE": "b", "PORT_8080": "http"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -129,3 +140,653 @@ func TestPlugin_Parse_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestPlugin_ParseOrdered(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []plugin.KV
+		wantErr bool
+	}{
+		{
+			name: "source order",
+			input: `zebra: 1
+apple:
+  second: b
+  first: a
+list:
+  - x
+  - y
+empty: {}
+`,
+			want: []plugin.KV{
+				{Key: "ZEBRA", Value: "1"},
+				{Key: "APPLE_SECOND", Value: "b"},
+				{Key: "APPLE_FIRST", Value: "a"},
+				{Key: "LIST_0", Value: "x"},
+				{Key: "LIST_1", Value: "y"},
+				{Key: "EMPTY", Value: ""},
+			},
+		},
+		{
+			name: "merge keys",
+			input: `base: &base
+  b: 1
+  a: 2
+child:
+  <<: *base
+  c: 3
+`,
+			want: []plugin.KV{
+				{Key: "BASE_B", Value: "1"},
+				{Key: "BASE_A", Value: "2"},
+				{Key: "CHILD_B", Value: "1"},
+				{Key: "CHILD_A", Value: "2"},
+				{Key: "CHILD_C", Value: "3"},
+			},
+		},
+		{
+			name:  "empty document",
+			input: "",
+			want:  []plugin.KV{},
+		},
+		{
+			name:    "invalid yaml",
+			input:   "key: [unclosed",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			got, err := p.ParseOrdered(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOrdered() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrdered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_KeepComments(t *testing.T) {
+	input := `# Top-level comment is not attached to a leaf
+database:
+  # Primary database host
+  host: localhost
+  port: 5432 # default postgres port
+features:
+  # first feature
+  - logging
+  - metrics
+`
+
+	c := converter.New(New())
+	c.SetKeepComments(true)
+
+	var out bytes.Buffer
+	if err := c.Convert(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := "# This file was auto-generated by cfg2env\n# Version: dev\n# Plugin: yaml\n#\n\n" +
+		"# Primary database host\nDATABASE_HOST=localhost\n" +
+		"# default postgres port\nDATABASE_PORT=5432\n" +
+		"# first feature\nFEATURES_0=logging\n" +
+		"FEATURES_1=metrics\n"
+	if got := out.String(); got != want {
+		t.Errorf("Convert() = %q, want %q", got, want)
+	}
+}
+
+func TestPlugin_Parse_CRLF(t *testing.T) {
+	input := "database:\r\n  host: localhost\r\n  port: 5432\r\nnote: |\r\n  line1\r\n  line2\r\n"
+	want := map[string]string{
+		"DATABASE_HOST": "localhost",
+		"DATABASE_PORT": "5432",
+		"NOTE":          "line1\nline2\n",
+	}
+
+	got, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+}
+
+func TestPlugin_ArrayJoin(t *testing.T) {
+	input := `features: # enabled features
+  - logging
+  - metrics
+servers:
+  - host: a
+  - b
+`
+
+	p := New()
+	p.SetArrayMode(utils.ArrayJoin, ",")
+
+	got, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	want := []plugin.KV{
+		{Key: "FEATURES", Value: "logging,metrics", Comment: "enabled features"},
+		{Key: "SERVERS_0_HOST", Value: "a"},
+		{Key: "SERVERS_1", Value: "b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"mapping", "database:\n  host: localhost\n  ports: [1, 2]\n", false},
+		{"empty input", "", false},
+		{"multiple documents", "a: 1\n---\nb: 2\n", false},
+		{"bad indentation", "database:\n  host: localhost\n port: 5432\n", true},
+		{"unclosed flow sequence", "ports: [1, 2\n", true},
+		{"error in later document", "a: 1\n---\nb: [\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New().Validate(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlugin_SkipPaths(t *testing.T) {
+	input := `app:
+  name: svc
+  metadata:
+    owner: team
+    labels: [a, b]
+logging:
+  level: debug
+`
+	p := New()
+	p.SetSkipPaths([]string{"logging", "app.metadata"})
+
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{"APP_NAME": "svc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+
+	pairs, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	if len(pairs) != 1 || pairs[0].Key != "APP_NAME" {
+		t.Errorf("ParseOrdered() = %v, want only APP_NAME", pairs)
+	}
+}
+
+func TestPlugin_OnlyPath(t *testing.T) {
+	input := `app:
+  name: svc
+database:
+  # Primary host
+  host: db
+  port: 5432
+`
+
+	tests := []struct {
+		name  string
+		path  string
+		strip bool
+		want  []plugin.KV
+	}{
+		{
+			name: "nested path",
+			path: "database",
+			want: []plugin.KV{{Key: "DATABASE_HOST", Value: "db", Comment: "Primary host"}, {Key: "DATABASE_PORT", Value: "5432"}},
+		},
+		{
+			name:  "stripped prefix",
+			path:  "database",
+			strip: true,
+			want:  []plugin.KV{{Key: "HOST", Value: "db", Comment: "Primary host"}, {Key: "PORT", Value: "5432"}},
+		},
+		{
+			name: "missing path",
+			path: "cache",
+			want: []plugin.KV{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetOnlyPath(tt.path, tt.strip)
+
+			got, err := p.ParseOrdered(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ParseOrdered() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrdered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_Timestamps(t *testing.T) {
+	input := `released: 2024-01-02
+deployed: 2024-01-02T03:04:05+02:00
+quoted: "2024-01-02"
+`
+
+	tests := []struct {
+		name   string
+		layout string
+		want   map[string]string
+	}{
+		{
+			name: "default RFC3339",
+			want: map[string]string{
+				"RELEASED": "2024-01-02T00:00:00Z",
+				"DEPLOYED": "2024-01-02T03:04:05+02:00",
+				"QUOTED":   "2024-01-02",
+			},
+		},
+		{
+			name:   "custom layout",
+			layout: "2006-01-02",
+			want: map[string]string{
+				"RELEASED": "2024-01-02",
+				"DEPLOYED": "2024-01-02",
+				"QUOTED":   "2024-01-02",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetArrayMode(utils.ArrayJoin, ",")
+			p.SetTimeFormat(tt.layout)
+
+			got, err := p.Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_OmitEmptyContainers(t *testing.T) {
+	input := `metadata: {}
+tags: []
+description: ""
+name: app
+`
+	p := New()
+	p.SetArrayMode(utils.ArrayJoin, ",")
+	p.SetOmitEmptyContainers(true)
+
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{"DESCRIPTION": "", "NAME": "app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_MaxDepth(t *testing.T) {
+	// deep nests block mappings depth levels deep, as in a: {a: 1} for 2
+	deep := func(depth int) string {
+		var b strings.Builder
+		for i := 0; i < depth-1; i++ {
+			b.WriteString(strings.Repeat("  ", i) + "a:\n")
+		}
+		b.WriteString(strings.Repeat("  ", depth-1) + "a: 1\n")
+		return b.String()
+	}
+
+	// The default limit is generous enough for real configs
+	if _, err := New().Parse(strings.NewReader(deep(utils.DefaultMaxDepth))); err != nil {
+		t.Errorf("Parse() at the default limit error = %v", err)
+	}
+
+	_, err := New().Parse(strings.NewReader(deep(utils.DefaultMaxDepth + 1)))
+	if !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("Parse() past the default limit error = %v, want ErrMaxDepth", err)
+	}
+
+	p := New()
+	p.SetMaxDepth(3)
+	if _, err := p.Parse(strings.NewReader(deep(4))); !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("Parse() past a limit of 3 error = %v, want ErrMaxDepth", err)
+	}
+	if _, err := p.ParseOrdered(strings.NewReader(deep(4))); !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("ParseOrdered() past a limit of 3 error = %v, want ErrMaxDepth", err)
+	}
+
+	p.SetMaxDepth(0)
+	got, err := p.Parse(strings.NewReader(deep(500)))
+	if err != nil {
+		t.Fatalf("Parse() without a limit error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("Parse() without a limit = %d keys, want 1", len(got))
+	}
+}
+
+func TestPlugin_MaxDepthAnchors(t *testing.T) {
+	// Each alias doubles the tree, so a few anchors expand into a document
+	// six levels deep, counting the top-level map
+	input := `l0: &l0 {v: 1}
+l1: &l1 {x: *l0, y: *l0}
+l2: &l2 {x: *l1, y: *l1}
+l3: &l3 {x: *l2, y: *l2}
+l4: {x: *l3, y: *l3}
+`
+
+	p := New()
+	p.SetMaxDepth(5)
+	if _, err := p.Parse(strings.NewReader(input)); !errors.Is(err, utils.ErrMaxDepth) {
+		t.Errorf("Parse() error = %v, want ErrMaxDepth", err)
+	}
+
+	p.SetMaxDepth(6)
+	got, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got["L4_X_Y_X_Y_V"] != "1" {
+		t.Errorf("L4_X_Y_X_Y_V = %q, want 1", got["L4_X_Y_X_Y_V"])
+	}
+}
+
+func TestPlugin_RootArray(t *testing.T) {
+	input := `- a
+- x: 1
+  y: [true]
+- [2, 3]
+- null
+`
+	want := []plugin.KV{
+		{Key: "0", Value: "a"},
+		{Key: "1_X", Value: "1"},
+		{Key: "1_Y_0", Value: "true"},
+		{Key: "2_0", Value: "2"},
+		{Key: "2_1", Value: "3"},
+		{Key: "3", Value: ""},
+	}
+
+	got, err := New().ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+
+	env, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for _, kv := range want {
+		if v, ok := env[kv.Key]; !ok || v != kv.Value {
+			t.Errorf("Parse()[%q] = %q, want %q", kv.Key, v, kv.Value)
+		}
+	}
+	if len(env) != len(want) {
+		t.Errorf("Parse() = %v, want %d keys", env, len(want))
+	}
+}
+
+func TestPlugin_PreserveLiterals(t *testing.T) {
+	input := `yes_token: yes
+no_token: no
+on_token: on
+off_token: off
+title: True
+upper: FALSE
+lower: true
+tilde: ~
+null_word: Null
+empty:
+quoted: "TRUE"
+list: [On, True, ~]
+anchor: &flag Yes
+alias: *flag
+`
+	tests := []struct {
+		name     string
+		preserve bool
+		want     map[string]string
+	}{
+		{
+			name: "default",
+			want: map[string]string{
+				"YES_TOKEN": "yes", "NO_TOKEN": "no", "ON_TOKEN": "on", "OFF_TOKEN": "off",
+				"TITLE": "true", "UPPER": "false", "LOWER": "true",
+				"TILDE": "", "NULL_WORD": "", "EMPTY": "", "QUOTED": "TRUE",
+				"LIST_0": "On", "LIST_1": "true", "LIST_2": "",
+				"ANCHOR": "Yes", "ALIAS": "Yes",
+			},
+		},
+		{
+			name:     "preserve",
+			preserve: true,
+			want: map[string]string{
+				"YES_TOKEN": "yes", "NO_TOKEN": "no", "ON_TOKEN": "on", "OFF_TOKEN": "off",
+				"TITLE": "True", "UPPER": "FALSE", "LOWER": "true",
+				"TILDE": "~", "NULL_WORD": "Null", "EMPTY": "", "QUOTED": "TRUE",
+				"LIST_0": "On", "LIST_1": "True", "LIST_2": "~",
+				"ANCHOR": "Yes", "ALIAS": "Yes",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetPreserveLiterals(tt.preserve)
+
+			got, err := p.Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+
+			ordered, err := p.ParseOrdered(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ParseOrdered() error = %v", err)
+			}
+			for _, kv := range ordered {
+				if kv.Value != tt.want[kv.Key] {
+					t.Errorf("ParseOrdered() %s = %q, want %q", kv.Key, kv.Value, tt.want[kv.Key])
+				}
+			}
+		})
+	}
+}
+
+func TestPlugin_Tags(t *testing.T) {
+	input := `str_zero: !!str 007
+str_hex: !!str 0x1F
+str_bool: !!str true
+int_zero: !!int 007
+int_hex: !!int 0x1F
+quoted_zero: "007"
+plain_zero: 007
+bool_yes: !!bool yes
+bool_off: !!bool OFF
+plain_yes: yes
+custom: !secret 0x1F
+anchor: &code !!str 042
+alias: *code
+`
+	tests := []struct {
+		name     string
+		preserve bool
+		want     map[string]string
+	}{
+		{
+			name: "default",
+			want: map[string]string{
+				"STR_ZERO": "007", "STR_HEX": "0x1F", "STR_BOOL": "true",
+				"INT_ZERO": "7", "INT_HEX": "31",
+				"QUOTED_ZERO": "007", "PLAIN_ZERO": "7",
+				"BOOL_YES": "true", "BOOL_OFF": "false", "PLAIN_YES": "yes",
+				"CUSTOM": "0x1F", "ANCHOR": "042", "ALIAS": "042",
+			},
+		},
+		{
+			name:     "preserve",
+			preserve: true,
+			want: map[string]string{
+				"STR_ZERO": "007", "STR_HEX": "0x1F", "STR_BOOL": "true",
+				"INT_ZERO": "7", "INT_HEX": "31",
+				"QUOTED_ZERO": "007", "PLAIN_ZERO": "7",
+				"BOOL_YES": "yes", "BOOL_OFF": "OFF", "PLAIN_YES": "yes",
+				"CUSTOM": "0x1F", "ANCHOR": "042", "ALIAS": "042",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetPreserveLiterals(tt.preserve)
+
+			got, err := p.Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlugin_ArrayKeyField(t *testing.T) {
+	input := `servers:
+  - name: db
+    host: db.internal
+    port: 5432
+  - host: cache.internal
+  - host: numeric
+    name: 42
+  - name: ""
+    host: empty
+tags: [a]
+`
+	want := []plugin.KV{
+		{Key: "SERVERS_DB_HOST", Value: "db.internal"},
+		{Key: "SERVERS_DB_PORT", Value: "5432"},
+		{Key: "SERVERS_1_HOST", Value: "cache.internal"},
+		{Key: "SERVERS_42_HOST", Value: "numeric"},
+		{Key: "SERVERS_3_NAME", Value: ""},
+		{Key: "SERVERS_3_HOST", Value: "empty"},
+		{Key: "TAGS_0", Value: "a"},
+	}
+
+	p := New()
+	p.SetArrayKeyField("name")
+	got, err := p.ParseOrdered(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOrdered() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrdered() = %v, want %v", got, want)
+	}
+}
+
+func TestPlugin_CompactArrays(t *testing.T) {
+	input := `hosts: [a, null, c]
+ports:
+  - 80
+  - ~
+  - 443
+  -
+servers:
+  - null
+  - name: db
+    host: db.internal
+tags: [x, null]
+`
+
+	tests := []struct {
+		name    string
+		compact bool
+		want    []plugin.KV
+	}{
+		{
+			name: "positions kept by default",
+			want: []plugin.KV{
+				{Key: "HOSTS_0", Value: "a"},
+				{Key: "HOSTS_1", Value: ""},
+				{Key: "HOSTS_2", Value: "c"},
+				{Key: "PORTS_0", Value: "80"},
+				{Key: "PORTS_1", Value: ""},
+				{Key: "PORTS_2", Value: "443"},
+				{Key: "PORTS_3", Value: ""},
+				{Key: "SERVERS_0", Value: ""},
+				{Key: "SERVERS_1_NAME", Value: "db"},
+				{Key: "SERVERS_1_HOST", Value: "db.internal"},
+				{Key: "TAGS_0", Value: "x"},
+				{Key: "TAGS_1", Value: ""},
+			},
+		},
+		{
+			name:    "compacted",
+			compact: true,
+			want: []plugin.KV{
+				{Key: "HOSTS_0", Value: "a"},
+				{Key: "HOSTS_1", Value: "c"},
+				{Key: "PORTS_0", Value: "80"},
+				{Key: "PORTS_1", Value: "443"},
+				{Key: "SERVERS_0_NAME", Value: "db"},
+				{Key: "SERVERS_0_HOST", Value: "db.internal"},
+				{Key: "TAGS_0", Value: "x"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			p.SetCompactArrays(tt.compact)
+			got, err := p.ParseOrdered(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ParseOrdered() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOrdered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}